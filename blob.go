@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/handler"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+func NewBlobStore() (storage.BlobStore, error) {
+	switch driver := viper.GetString(config.ConfigKeyBlobDriver); driver {
+	case "local":
+		return storage.NewLocalBlobStore(viper.GetString(config.ConfigKeyBlobLocalDir))
+	case "s3":
+		var cfg storage.S3Config
+		if err := viper.UnmarshalKey(config.ConfigKeyBlobS3, &cfg); err != nil {
+			return nil, err
+		}
+		return storage.NewS3BlobStore(cfg)
+	default:
+		return nil, fmt.Errorf("unknown blob driver %q", driver)
+	}
+}
+
+func NewAttachmentConfig() handler.AttachmentConfig {
+	return handler.AttachmentConfig{
+		MaxSize:      viper.GetInt64(config.ConfigKeyAttachmentMaxSize),
+		AllowedTypes: viper.GetStringSlice(config.ConfigKeyAttachmentAllowedTypes),
+	}
+}
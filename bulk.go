@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/handler"
+)
+
+func NewBulkConfig() (handler.BulkConfig, error) {
+	cfg := handler.BulkConfig{
+		MaxBatchSize: viper.GetInt(config.ConfigKeyBulkMaxBatchSize),
+	}
+	if cfg.MaxBatchSize <= 0 {
+		return cfg, fmt.Errorf("invalid bulk max batch size %d", cfg.MaxBatchSize)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/spf13/viper"
+
+	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/handler"
+)
+
+// NewCalDAVConfig reads the CalDAV accounts, as user names mapped to the
+// bcrypt hashes of their passwords. Viper lowercases map keys, so user names
+// are lowercase.
+func NewCalDAVConfig() (handler.CalDAVConfig, error) {
+	cfg := handler.CalDAVConfig{
+		Realm: viper.GetString(config.ConfigKeyCalDAVRealm),
+		Users: viper.GetStringMapString(config.ConfigKeyCalDAVUsers),
+	}
+	for user, hash := range cfg.Users {
+		if user == "" || hash == "" {
+			return cfg, errors.New("caldav users need a name and a password hash")
+		}
+	}
+	return cfg, nil
+}
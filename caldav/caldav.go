@@ -0,0 +1,84 @@
+// Package caldav implements the parts of WebDAV (RFC 4918), CalDAV (RFC 4791)
+// and WebDAV collection synchronization (RFC 6578) that task apps rely on to
+// sync: discovering calendars with PROPFIND, listing and fetching their
+// objects with REPORT, and tracking changes with sync tokens and ETags.
+package caldav
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wei840222/go-restful-sample/config"
+)
+
+// Methods of WebDAV requests, besides those of HTTP.
+const (
+	MethodPropfind = "PROPFIND"
+	MethodReport   = "REPORT"
+)
+
+// Headers of WebDAV requests and responses.
+const (
+	HeaderDAV   = "DAV"
+	HeaderDepth = "Depth"
+)
+
+// Compliance lists the capabilities announced in the DAV header.
+const Compliance = "1, 3, calendar-access"
+
+// Values of the Depth header. Infinite depth is served as depth 1, which is
+// as deep as calendars go.
+const (
+	DepthZero     = "0"
+	DepthOne      = "1"
+	DepthInfinity = "infinity"
+)
+
+var (
+	ErrInvalidSyncToken  = errors.New("invalid sync token")
+	ErrUnsupportedReport = errors.New("unsupported report")
+)
+
+// syncTokenPrefix makes sync tokens URIs as RFC 6578 requires.
+var syncTokenPrefix = fmt.Sprintf("http://%s/ns/sync/", config.AppName)
+
+// SyncToken returns the sync token of a version of the audit trail.
+func SyncToken(version uint) string {
+	return syncTokenPrefix + strconv.FormatUint(uint64(version), 10)
+}
+
+// ParseSyncToken returns the version of the audit trail of a sync token.
+func ParseSyncToken(token string) (uint, error) {
+	s, ok := strings.CutPrefix(token, syncTokenPrefix)
+	if !ok {
+		return 0, ErrInvalidSyncToken
+	}
+	version, err := strconv.ParseUint(s, 10, 0)
+	if err != nil {
+		return 0, ErrInvalidSyncToken
+	}
+	return uint(version), nil
+}
+
+// ETag returns the strong entity tag of the data of a calendar object. It
+// changes exactly when the data does.
+func ETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ParseDepth returns the depth of a request, which defaults to infinity.
+func ParseDepth(depth string) (string, error) {
+	switch depth {
+	case "":
+		return DepthInfinity, nil
+	case DepthZero, DepthOne, DepthInfinity:
+		return depth, nil
+	default:
+		return "", fmt.Errorf("invalid depth %q", depth)
+	}
+}
@@ -0,0 +1,125 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSyncToken(t *testing.T) {
+	Convey("Given a sync token", t, func() {
+		token := SyncToken(42)
+
+		Convey("Then it should parse back to its version", func() {
+			version, err := ParseSyncToken(token)
+			So(err, ShouldBeNil)
+			So(version, ShouldEqual, 42)
+		})
+
+		Convey("Then tokens of other servers should be invalid", func() {
+			_, err := ParseSyncToken("http://example.com/ns/sync/42")
+			So(err, ShouldEqual, ErrInvalidSyncToken)
+			_, err = ParseSyncToken(token + "x")
+			So(err, ShouldEqual, ErrInvalidSyncToken)
+		})
+	})
+}
+
+func TestParsePropfind(t *testing.T) {
+	Convey("Given PROPFIND bodies", t, func() {
+		Convey("When the body is empty", func() {
+			req, err := ParsePropfind(nil)
+
+			Convey("Then it should ask for all properties", func() {
+				So(err, ShouldBeNil)
+				So(req.All, ShouldBeTrue)
+			})
+		})
+
+		Convey("When the body lists properties", func() {
+			req, err := ParsePropfind([]byte(`<propfind xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+				<prop><getetag/><C:calendar-data><C:comp name="VCALENDAR"/></C:calendar-data></prop>
+			</propfind>`))
+
+			Convey("Then it should ask for them", func() {
+				So(err, ShouldBeNil)
+				So(req.All, ShouldBeFalse)
+				So(req.Names, ShouldResemble, []xml.Name{PropGetETag, PropCalendarData})
+			})
+		})
+
+		Convey("When the body is not a propfind", func() {
+			_, err := ParsePropfind([]byte(`<propertyupdate xmlns="DAV:"/>`))
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestParseReport(t *testing.T) {
+	Convey("Given REPORT bodies", t, func() {
+		Convey("When it is a calendar-query on todos", func() {
+			report, err := ParseReport([]byte(`<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+				<D:prop><D:getetag/></D:prop>
+				<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VTODO"/></C:comp-filter></C:filter>
+			</C:calendar-query>`))
+
+			Convey("Then it should tell the components", func() {
+				So(err, ShouldBeNil)
+				So(report.Name, ShouldEqual, ReportCalendarQuery)
+				So(report.Components, ShouldResemble, []string{"VTODO"})
+			})
+		})
+
+		Convey("When it is a sync-collection", func() {
+			report, err := ParseReport([]byte(`<sync-collection xmlns="DAV:"><sync-token> ` + SyncToken(3) + ` </sync-token><prop><getetag/></prop></sync-collection>`))
+
+			Convey("Then it should tell the sync token", func() {
+				So(err, ShouldBeNil)
+				So(report.SyncToken, ShouldEqual, SyncToken(3))
+				So(report.Props.Names, ShouldResemble, []xml.Name{PropGetETag})
+			})
+		})
+
+		Convey("When it is not a report of calendars", func() {
+			_, err := ParseReport([]byte(`<expand-property xmlns="DAV:"/>`))
+
+			Convey("Then it should be unsupported", func() {
+				So(err, ShouldWrap, ErrUnsupportedReport)
+			})
+		})
+	})
+}
+
+func TestNewResponse(t *testing.T) {
+	Convey("Given the properties of a resource", t, func() {
+		props := []Property{
+			NewTextProperty(PropDisplayName, "Work"),
+			NewTextProperty(PropCalendarData, "BEGIN:VCALENDAR"),
+		}
+
+		Convey("When some of those asked for are missing", func() {
+			res := NewResponse("/a", props, PropRequest{Names: []xml.Name{PropDisplayName, PropGetCTag}})
+
+			Convey("Then they should be reported as not found", func() {
+				So(res.Propstats, ShouldHaveLength, 2)
+				So(res.Propstats[0].Prop.Properties, ShouldResemble, props[:1])
+				So(res.Propstats[1].Status, ShouldEqual, Status(http.StatusNotFound))
+				So(res.Propstats[1].Prop.Properties[0].Name, ShouldEqual, PropGetCTag)
+			})
+		})
+
+		Convey("When all of them are asked for", func() {
+			res := NewResponse("/a", props, PropRequest{All: true})
+
+			Convey("Then the calendar data should be left out", func() {
+				So(res.Propstats, ShouldHaveLength, 1)
+				So(res.Propstats[0].Prop.Properties, ShouldResemble, props[:1])
+			})
+		})
+	})
+}
@@ -0,0 +1,385 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	NamespaceDAV    = "DAV:"
+	NamespaceCalDAV = "urn:ietf:params:xml:ns:caldav"
+	// NamespaceCalendarServer holds getctag, which clients predating sync
+	// tokens poll to tell whether a calendar changed.
+	NamespaceCalendarServer = "http://calendarserver.org/ns/"
+	NamespaceAppleICal      = "http://apple.com/ns/ical/"
+)
+
+// prefixes are declared on the root of the documents written, so that the
+// elements of these namespaces do not each carry an xmlns attribute.
+var prefixes = map[string]string{
+	NamespaceDAV:            "d",
+	NamespaceCalDAV:         "c",
+	NamespaceCalendarServer: "cs",
+	NamespaceAppleICal:      "ical",
+}
+
+// Properties of the resources.
+var (
+	PropResourceType                  = xml.Name{Space: NamespaceDAV, Local: "resourcetype"}
+	PropDisplayName                   = xml.Name{Space: NamespaceDAV, Local: "displayname"}
+	PropGetETag                       = xml.Name{Space: NamespaceDAV, Local: "getetag"}
+	PropGetContentType                = xml.Name{Space: NamespaceDAV, Local: "getcontenttype"}
+	PropCurrentUserPrincipal          = xml.Name{Space: NamespaceDAV, Local: "current-user-principal"}
+	PropCurrentUserPrivilegeSet       = xml.Name{Space: NamespaceDAV, Local: "current-user-privilege-set"}
+	PropPrincipalURL                  = xml.Name{Space: NamespaceDAV, Local: "principal-URL"}
+	PropSupportedReportSet            = xml.Name{Space: NamespaceDAV, Local: "supported-report-set"}
+	PropSyncToken                     = xml.Name{Space: NamespaceDAV, Local: "sync-token"}
+	PropCalendarHomeSet               = xml.Name{Space: NamespaceCalDAV, Local: "calendar-home-set"}
+	PropCalendarData                  = xml.Name{Space: NamespaceCalDAV, Local: "calendar-data"}
+	PropSupportedCalendarComponentSet = xml.Name{Space: NamespaceCalDAV, Local: "supported-calendar-component-set"}
+	PropGetCTag                       = xml.Name{Space: NamespaceCalendarServer, Local: "getctag"}
+	PropCalendarColor                 = xml.Name{Space: NamespaceAppleICal, Local: "calendar-color"}
+)
+
+// Resource types, privileges and other values of properties.
+var (
+	Collection = xml.Name{Space: NamespaceDAV, Local: "collection"}
+	Principal  = xml.Name{Space: NamespaceDAV, Local: "principal"}
+	Calendar   = xml.Name{Space: NamespaceCalDAV, Local: "calendar"}
+
+	PrivilegeRead         = xml.Name{Space: NamespaceDAV, Local: "read"}
+	PrivilegeWrite        = xml.Name{Space: NamespaceDAV, Local: "write"}
+	PrivilegeWriteContent = xml.Name{Space: NamespaceDAV, Local: "write-content"}
+	PrivilegeBind         = xml.Name{Space: NamespaceDAV, Local: "bind"}
+	PrivilegeUnbind       = xml.Name{Space: NamespaceDAV, Local: "unbind"}
+)
+
+// Reports.
+var (
+	ReportCalendarMultiget = xml.Name{Space: NamespaceCalDAV, Local: "calendar-multiget"}
+	ReportCalendarQuery    = xml.Name{Space: NamespaceCalDAV, Local: "calendar-query"}
+	ReportSyncCollection   = xml.Name{Space: NamespaceDAV, Local: "sync-collection"}
+)
+
+// Preconditions reported in the error bodies of failed requests.
+var (
+	ConditionValidSyncToken             = xml.Name{Space: NamespaceDAV, Local: "valid-sync-token"}
+	ConditionSupportedReport            = xml.Name{Space: NamespaceDAV, Local: "supported-report"}
+	ConditionSupportedCalendarData      = xml.Name{Space: NamespaceCalDAV, Local: "supported-calendar-data"}
+	ConditionSupportedCalendarComponent = xml.Name{Space: NamespaceCalDAV, Local: "supported-calendar-component"}
+	ConditionValidCalendarData          = xml.Name{Space: NamespaceCalDAV, Local: "valid-calendar-data"}
+	ConditionNoUIDConflict              = xml.Name{Space: NamespaceCalDAV, Local: "no-uid-conflict"}
+)
+
+// notAllProps are left out of allprop responses, since they are expensive or
+// only make sense when asked for.
+var notAllProps = map[xml.Name]bool{
+	PropCalendarData:            true,
+	PropCurrentUserPrivilegeSet: true,
+	PropSupportedReportSet:      true,
+}
+
+func prefixed(name xml.Name) xml.Name {
+	if prefix, ok := prefixes[name.Space]; ok {
+		return xml.Name{Local: prefix + ":" + name.Local}
+	}
+	return name
+}
+
+// Element returns an empty element, as found in resource types and
+// privileges.
+func Element(name xml.Name) string {
+	var buf bytes.Buffer
+	xml.NewEncoder(&buf).Encode(struct {
+		XMLName xml.Name
+	}{prefixed(name)})
+	return buf.String()
+}
+
+// Property is a property of a resource. Inner is the XML content of its
+// element.
+type Property struct {
+	Name  xml.Name
+	Inner string
+}
+
+// NewTextProperty returns a property holding text.
+func NewTextProperty(name xml.Name, text string) Property {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(text))
+	return Property{Name: name, Inner: buf.String()}
+}
+
+// NewHrefProperty returns a property holding the URL of another resource.
+func NewHrefProperty(name xml.Name, href string) Property {
+	var buf strings.Builder
+	buf.WriteString("<d:href>")
+	xml.EscapeText(&buf, []byte(href))
+	buf.WriteString("</d:href>")
+	return Property{Name: name, Inner: buf.String()}
+}
+
+// NewElementsProperty returns a property holding empty elements, such as a
+// resource type.
+func NewElementsProperty(name xml.Name, elements ...xml.Name) Property {
+	var buf strings.Builder
+	for _, element := range elements {
+		buf.WriteString(Element(element))
+	}
+	return Property{Name: name, Inner: buf.String()}
+}
+
+// NewPrivilegeSetProperty returns the privileges the current user has on a
+// resource.
+func NewPrivilegeSetProperty(privileges ...xml.Name) Property {
+	var buf strings.Builder
+	for _, privilege := range privileges {
+		buf.WriteString("<d:privilege>" + Element(privilege) + "</d:privilege>")
+	}
+	return Property{Name: PropCurrentUserPrivilegeSet, Inner: buf.String()}
+}
+
+// NewReportSetProperty returns the reports supported by a resource.
+func NewReportSetProperty(reports ...xml.Name) Property {
+	var buf strings.Builder
+	for _, report := range reports {
+		buf.WriteString("<d:supported-report><d:report>" + Element(report) + "</d:report></d:supported-report>")
+	}
+	return Property{Name: PropSupportedReportSet, Inner: buf.String()}
+}
+
+// NewComponentSetProperty returns the components a calendar accepts.
+func NewComponentSetProperty(components ...string) Property {
+	var buf strings.Builder
+	for _, component := range components {
+		buf.WriteString(`<c:comp name="` + component + `"/>`)
+	}
+	return Property{Name: PropSupportedCalendarComponentSet, Inner: buf.String()}
+}
+
+func (p Property) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Inner string `xml:",innerxml"`
+	}{p.Inner}, xml.StartElement{Name: prefixed(p.Name)})
+}
+
+// Status returns the status line of code, as found in multistatus responses.
+func Status(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+type prop struct {
+	Properties []Property
+}
+
+type Propstat struct {
+	Prop   prop   `xml:"d:prop"`
+	Status string `xml:"d:status"`
+}
+
+// Response is the status of a resource in a multistatus response. Status is
+// set instead of Propstats for resources that cannot be read, such as the
+// removed members of a collection.
+type Response struct {
+	Href      string     `xml:"d:href"`
+	Status    string     `xml:"d:status,omitempty"`
+	Propstats []Propstat `xml:"d:propstat"`
+}
+
+// NewResponse returns the response of the resource at href with the
+// properties req asks for. Those the resource does not have are reported as
+// not found.
+func NewResponse(href string, props []Property, req PropRequest) Response {
+	var found, missing []Property
+	if req.All {
+		for _, p := range props {
+			if !notAllProps[p.Name] {
+				found = append(found, p)
+			}
+		}
+	} else {
+		for _, name := range req.Names {
+			i := 0
+			for i < len(props) && props[i].Name != name {
+				i++
+			}
+			if i < len(props) {
+				found = append(found, props[i])
+			} else {
+				missing = append(missing, Property{Name: name})
+			}
+		}
+	}
+
+	res := Response{Href: href}
+	if len(found) > 0 || len(missing) == 0 {
+		res.Propstats = append(res.Propstats, Propstat{Prop: prop{found}, Status: Status(http.StatusOK)})
+	}
+	if len(missing) > 0 {
+		res.Propstats = append(res.Propstats, Propstat{Prop: prop{missing}, Status: Status(http.StatusNotFound)})
+	}
+	return res
+}
+
+// NewStatusResponse returns the response of a resource that cannot be read.
+func NewStatusResponse(href string, code int) Response {
+	return Response{Href: href, Status: Status(code)}
+}
+
+type namespaces struct {
+	DAV            string `xml:"xmlns:d,attr"`
+	CalDAV         string `xml:"xmlns:c,attr"`
+	CalendarServer string `xml:"xmlns:cs,attr"`
+	AppleICal      string `xml:"xmlns:ical,attr"`
+}
+
+var rootNamespaces = namespaces{
+	DAV:            NamespaceDAV,
+	CalDAV:         NamespaceCalDAV,
+	CalendarServer: NamespaceCalendarServer,
+	AppleICal:      NamespaceAppleICal,
+}
+
+// EncodeMultistatus writes a multistatus document. syncToken is only set in
+// responses to sync-collection reports.
+func EncodeMultistatus(w io.Writer, responses []Response, syncToken string) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"d:multistatus"`
+		namespaces
+		Responses []Response `xml:"d:response"`
+		SyncToken string     `xml:"d:sync-token,omitempty"`
+	}{namespaces: rootNamespaces, Responses: responses, SyncToken: syncToken})
+}
+
+// EncodeError writes the error document telling which precondition of a
+// request failed.
+func EncodeError(w io.Writer, condition xml.Name) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"d:error"`
+		namespaces
+		Condition Property
+	}{namespaces: rootNamespaces, Condition: Property{Name: condition}})
+}
+
+// PropRequest is the set of properties a request asks for. All stands for
+// allprop, and propname requests are answered as such too.
+type PropRequest struct {
+	All   bool
+	Names []xml.Name
+}
+
+// propNames reads the names of the children of a prop element, skipping
+// their content such as the components calendar-data asks for.
+type propNames []xml.Name
+
+func (p *propNames) UnmarshalXML(d *xml.Decoder, _ xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			*p = append(*p, tok.Name)
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func newPropRequest(allProp, propName *struct{}, names propNames) PropRequest {
+	if allProp != nil || propName != nil || len(names) == 0 {
+		return PropRequest{All: true}
+	}
+	return PropRequest{Names: names}
+}
+
+// ParsePropfind returns the properties a PROPFIND request asks for. An empty
+// body asks for all of them.
+func ParsePropfind(body []byte) (PropRequest, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return PropRequest{All: true}, nil
+	}
+
+	var req struct {
+		XMLName  xml.Name  `xml:"DAV: propfind"`
+		AllProp  *struct{} `xml:"DAV: allprop"`
+		PropName *struct{} `xml:"DAV: propname"`
+		Prop     propNames `xml:"DAV: prop"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		return PropRequest{}, err
+	}
+	return newPropRequest(req.AllProp, req.PropName, req.Prop), nil
+}
+
+type compFilter struct {
+	Name        string       `xml:"name,attr"`
+	CompFilters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// Report is a REPORT request. Name tells which report it is, and so which of
+// the other fields are set:
+//   - Hrefs lists the objects a calendar-multiget asks for.
+//   - Components lists the components a calendar-query filters on, where no
+//     component stands for any. Other filters are not supported and the
+//     objects they would exclude are returned as well.
+//   - SyncToken is the token of the previous sync-collection, or empty for
+//     the initial one.
+type Report struct {
+	Name       xml.Name
+	Props      PropRequest
+	Hrefs      []string
+	Components []string
+	SyncToken  string
+}
+
+// ParseReport reads a REPORT request, which must be one of the reports of
+// calendars.
+func ParseReport(body []byte) (Report, error) {
+	var req struct {
+		XMLName  xml.Name
+		AllProp  *struct{} `xml:"DAV: allprop"`
+		PropName *struct{} `xml:"DAV: propname"`
+		Prop     propNames `xml:"DAV: prop"`
+		Hrefs    []string  `xml:"DAV: href"`
+		Filter   struct {
+			CompFilter compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+		} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+		SyncToken string `xml:"DAV: sync-token"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		return Report{}, err
+	}
+
+	report := Report{
+		Name:  req.XMLName,
+		Props: newPropRequest(req.AllProp, req.PropName, req.Prop),
+	}
+	switch report.Name {
+	case ReportCalendarMultiget:
+		report.Hrefs = req.Hrefs
+	case ReportCalendarQuery:
+		for _, filter := range req.Filter.CompFilter.CompFilters {
+			report.Components = append(report.Components, filter.Name)
+		}
+	case ReportSyncCollection:
+		report.SyncToken = strings.TrimSpace(req.SyncToken)
+	default:
+		return Report{}, fmt.Errorf("%w: %s %s", ErrUnsupportedReport, report.Name.Space, report.Name.Local)
+	}
+	return report, nil
+}
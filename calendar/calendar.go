@@ -0,0 +1,172 @@
+package calendar
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-ical"
+
+	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// MIMEType is the media type of iCalendar documents.
+const MIMEType = "text/calendar"
+
+// ProductID identifies the service as the producer of its calendars.
+var ProductID = fmt.Sprintf("-//%s//todos//EN", config.AppName)
+
+// ExternalSource is the external source of the todos imported from iCalendar.
+// Their external ID is the UID of their VTODO, which they keep when exported.
+const ExternalSource = "ical"
+
+// Statuses of a VTODO.
+const (
+	StatusNeedsAction = "NEEDS-ACTION"
+	StatusInProcess   = "IN-PROCESS"
+	StatusCompleted   = "COMPLETED"
+	StatusCancelled   = "CANCELLED"
+)
+
+// UID returns the UID of the VTODO of todo.
+func UID(todo storage.Todo) string {
+	if todo.ExternalSource != nil && *todo.ExternalSource == ExternalSource && todo.ExternalID != nil {
+		return *todo.ExternalID
+	}
+	return fmt.Sprintf("todo-%d@%s", todo.ID, config.AppName)
+}
+
+// TodoID returns the ID of the todo of a UID that UID generated, or false for
+// other UIDs.
+func TodoID(uid string) (uint, bool) {
+	s, ok := strings.CutPrefix(uid, "todo-")
+	if !ok {
+		return 0, false
+	}
+	if s, ok = strings.CutSuffix(s, "@"+config.AppName); !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(s, 10, 0)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// NewCalendar returns an empty calendar named name.
+func NewCalendar(name string) *ical.Calendar {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropProductID, ProductID)
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	if name != "" {
+		cal.Props.SetText(ical.PropName, name)
+		// X-WR-CALNAME is what most clients still read the name from, and
+		// some of them do not expect a VALUE parameter on it.
+		prop := ical.NewProp("X-WR-CALNAME")
+		prop.SetText(name)
+		prop.Params.Del(ical.ParamValue)
+		cal.Props.Set(prop)
+	}
+	return cal
+}
+
+// Encode writes cal. Unlike ical.Encoder, it accepts a calendar without
+// components, which is what a feed without todos is.
+func Encode(w io.Writer, cal *ical.Calendar) error {
+	if len(cal.Children) > 0 {
+		return ical.NewEncoder(w).Encode(cal)
+	}
+
+	// The calendar is encoded with a placeholder component removed afterwards.
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(&ical.Calendar{Component: &ical.Component{
+		Name:     ical.CompCalendar,
+		Props:    cal.Props,
+		Children: []*ical.Component{ical.NewComponent("X-PLACEHOLDER")},
+	}}); err != nil {
+		return err
+	}
+	_, err := w.Write(bytes.Replace(buf.Bytes(), []byte("BEGIN:X-PLACEHOLDER\r\nEND:X-PLACEHOLDER\r\n"), nil, 1))
+	return err
+}
+
+// NewToDo returns the VTODO of todo. Its STATUS follows the workflow state of
+// the todo.
+func NewToDo(todo storage.Todo, wf *storage.Workflow) *ical.Component {
+	comp := ical.NewComponent(ical.CompToDo)
+	setCommonProps(comp, UID(todo), todo)
+
+	var status string
+	switch {
+	case todo.Status == wf.Completed:
+		status = StatusCompleted
+	case wf.IsTerminal(todo.Status):
+		status = StatusCancelled
+	case todo.Status == wf.Initial:
+		status = StatusNeedsAction
+	default:
+		status = StatusInProcess
+	}
+	comp.Props.SetText(ical.PropStatus, status)
+	if status == StatusCompleted {
+		// Without a completion time, the last change is the closest.
+		completedAt := todo.UpdatedAt
+		if todo.CompletedAt != nil {
+			completedAt = *todo.CompletedAt
+		}
+		comp.Props.SetDateTime(ical.PropCompleted, completedAt.UTC())
+	}
+
+	if todo.DueAt != nil {
+		comp.Props.SetDateTime(ical.PropDue, todo.DueAt.UTC())
+		if todo.RRule != "" {
+			// Recurrences start from DTSTART, which is required along RRULE.
+			comp.Props.SetDateTime(ical.PropDateTimeStart, todo.DueAt.UTC())
+			setRRule(comp, todo.RRule)
+		}
+	}
+	return comp
+}
+
+// NewEvent returns a VEVENT taking place at the due date of todo, or nil when
+// it has none.
+func NewEvent(todo storage.Todo, wf *storage.Workflow) *ical.Component {
+	if todo.DueAt == nil {
+		return nil
+	}
+
+	comp := ical.NewComponent(ical.CompEvent)
+	setCommonProps(comp, "due-"+UID(todo), todo)
+	comp.Props.SetDateTime(ical.PropDateTimeStart, todo.DueAt.UTC())
+	if wf.IsTerminal(todo.Status) && todo.Status != wf.Completed {
+		comp.Props.SetText(ical.PropStatus, string(ical.EventCancelled))
+	} else {
+		comp.Props.SetText(ical.PropStatus, string(ical.EventConfirmed))
+	}
+	if todo.RRule != "" {
+		setRRule(comp, todo.RRule)
+	}
+	return comp
+}
+
+func setCommonProps(comp *ical.Component, uid string, todo storage.Todo) {
+	comp.Props.SetText(ical.PropUID, uid)
+	comp.Props.SetDateTime(ical.PropDateTimeStamp, todo.UpdatedAt.UTC())
+	comp.Props.SetDateTime(ical.PropCreated, todo.CreatedAt.UTC())
+	comp.Props.SetDateTime(ical.PropLastModified, todo.UpdatedAt.UTC())
+	comp.Props.SetText(ical.PropSummary, todo.Title)
+	if todo.Description != "" {
+		comp.Props.SetText(ical.PropDescription, todo.Description)
+	}
+}
+
+// setRRule sets the recurrence rule of todos, which is stored as the value of
+// an RRULE property.
+func setRRule(comp *ical.Component, rule string) {
+	prop := ical.NewProp(ical.PropRecurrenceRule)
+	prop.Value = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	comp.Props.Set(prop)
+}
@@ -0,0 +1,177 @@
+package calendar
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	. "github.com/smartystreets/goconvey/convey"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/transfer"
+)
+
+func TestCalendar(t *testing.T) {
+	Convey("Given a workflow and a todo", t, func() {
+		wf := &storage.Workflow{
+			Initial:   "todo",
+			Completed: "done",
+			States:    []string{"todo", "in_progress", "done", "wont_do"},
+			Terminal:  []string{"done", "wont_do"},
+		}
+		created := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+		due := time.Date(2025, 1, 6, 9, 0, 0, 0, time.FixedZone("UTC+8", 8*60*60))
+		todo := storage.Todo{
+			Model:       gorm.Model{ID: 7, CreatedAt: created, UpdatedAt: created.Add(time.Hour)},
+			Title:       "Stand-up",
+			Description: "Daily, with the team",
+			Status:      "todo",
+			DueAt:       &due,
+			RRule:       "FREQ=DAILY;COUNT=5",
+		}
+
+		Convey("When it is rendered as a VTODO", func() {
+			comp := NewToDo(todo, wf)
+
+			Convey("Then it should carry its fields", func() {
+				So(comp.Props.Get(ical.PropUID).Value, ShouldEqual, "todo-7@go-restful-sample")
+				So(comp.Props.Get(ical.PropDateTimeStamp).Value, ShouldEqual, "20250101T090000Z")
+				So(comp.Props.Get(ical.PropSummary).Value, ShouldEqual, "Stand-up")
+				So(comp.Props.Get(ical.PropStatus).Value, ShouldEqual, StatusNeedsAction)
+				So(comp.Props.Get(ical.PropDue).Value, ShouldEqual, "20250106T010000Z")
+				So(comp.Props.Get(ical.PropDateTimeStart).Value, ShouldEqual, "20250106T010000Z")
+				So(comp.Props.Get(ical.PropRecurrenceRule).Value, ShouldEqual, "FREQ=DAILY;COUNT=5")
+				So(comp.Props.Get(ical.PropCompleted), ShouldBeNil)
+			})
+		})
+
+		Convey("When it is in each workflow state", func() {
+			status := func(state string) string {
+				todo.Status = state
+				return NewToDo(todo, wf).Props.Get(ical.PropStatus).Value
+			}
+
+			Convey("Then its STATUS should follow", func() {
+				So(status("in_progress"), ShouldEqual, StatusInProcess)
+				So(status("done"), ShouldEqual, StatusCompleted)
+				So(status("wont_do"), ShouldEqual, StatusCancelled)
+			})
+		})
+
+		Convey("When it is completed", func() {
+			todo.Status = "done"
+			completed := time.Date(2025, 1, 3, 18, 0, 0, 0, time.UTC)
+
+			Convey("Then its COMPLETED date should be its completion time", func() {
+				todo.CompletedAt = &completed
+				So(NewToDo(todo, wf).Props.Get(ical.PropCompleted).Value, ShouldEqual, "20250103T180000Z")
+			})
+
+			Convey("Then its COMPLETED date should fall back to its last change", func() {
+				So(NewToDo(todo, wf).Props.Get(ical.PropCompleted).Value, ShouldEqual, "20250101T090000Z")
+			})
+		})
+
+		Convey("When it is rendered as a VEVENT", func() {
+			todo.Status = "wont_do"
+			comp := NewEvent(todo, wf)
+
+			Convey("Then it should take place at the due date", func() {
+				So(comp.Name, ShouldEqual, ical.CompEvent)
+				So(comp.Props.Get(ical.PropUID).Value, ShouldEqual, "due-todo-7@go-restful-sample")
+				So(comp.Props.Get(ical.PropDateTimeStart).Value, ShouldEqual, "20250106T010000Z")
+				So(comp.Props.Get(ical.PropStatus).Value, ShouldEqual, string(ical.EventCancelled))
+			})
+		})
+
+		Convey("When it has no due date", func() {
+			todo.DueAt = nil
+
+			Convey("Then it should have no VEVENT", func() {
+				So(NewEvent(todo, wf), ShouldBeNil)
+			})
+		})
+
+		Convey("When a calendar with it is encoded and decoded", func() {
+			todo.Status = "done"
+			completed := time.Date(2025, 1, 3, 18, 0, 0, 0, time.UTC)
+			todo.CompletedAt = &completed
+			cal := NewCalendar("Work")
+			cal.Children = append(cal.Children, NewToDo(todo, wf), NewEvent(todo, wf))
+
+			var buf bytes.Buffer
+			So(Encode(&buf, cal), ShouldBeNil)
+			dec := NewDecoder(&buf)
+			rec, err := dec.Decode()
+			So(err, ShouldBeNil)
+			_, err = dec.Decode()
+
+			Convey("Then the VTODO should read back as its record", func() {
+				So(rec.ExternalSource, ShouldEqual, ExternalSource)
+				So(rec.ExternalID, ShouldEqual, "todo-7@go-restful-sample")
+				So(rec.Title, ShouldEqual, "Stand-up")
+				So(rec.Description, ShouldEqual, "Daily, with the team")
+				So(rec.Completed, ShouldBeTrue)
+				So(rec.CompletedAt.Equal(completed), ShouldBeTrue)
+				So(rec.DueAt.Equal(due), ShouldBeTrue)
+				So(rec.RRule, ShouldEqual, "FREQ=DAILY;COUNT=5")
+				So(err, ShouldEqual, io.EOF)
+			})
+		})
+
+		Convey("When an imported todo is rendered", func() {
+			source, id := ExternalSource, "abc@example.com"
+			todo.ExternalSource, todo.ExternalID = &source, &id
+
+			Convey("Then it should keep the UID it was imported with", func() {
+				So(UID(todo), ShouldEqual, id)
+			})
+		})
+	})
+
+	Convey("Given an empty calendar", t, func() {
+		var buf bytes.Buffer
+		err := Encode(&buf, NewCalendar(""))
+
+		Convey("Then it should still be encoded", func() {
+			So(err, ShouldBeNil)
+			So(buf.String(), ShouldStartWith, "BEGIN:VCALENDAR\r\n")
+			So(buf.String(), ShouldEndWith, "END:VCALENDAR\r\n")
+			So(buf.String(), ShouldNotContainSubstring, "PLACEHOLDER")
+		})
+	})
+
+	Convey("Given iCalendar documents", t, func() {
+		Convey("When a VTODO has no summary", func() {
+			dec := NewDecoder(strings.NewReader("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:test\r\n" +
+				"BEGIN:VTODO\r\nUID:1\r\nDTSTAMP:20250101T000000Z\r\nDUE:tomorrow\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"))
+			_, err := dec.Decode()
+
+			Convey("Then it should be an invalid row", func() {
+				So(err, ShouldWrap, transfer.ErrInvalidRow)
+			})
+		})
+
+		Convey("When a VTODO has an invalid COMPLETED date", func() {
+			dec := NewDecoder(strings.NewReader("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:test\r\n" +
+				"BEGIN:VTODO\r\nUID:1\r\nDTSTAMP:20250101T000000Z\r\nSUMMARY:Done\r\nCOMPLETED:yesterday\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"))
+			_, err := dec.Decode()
+
+			Convey("Then it should be an invalid row", func() {
+				So(err, ShouldWrap, transfer.ErrInvalidRow)
+			})
+		})
+
+		Convey("When the document is not iCalendar", func() {
+			_, err := NewDecoder(strings.NewReader("hello")).Decode()
+
+			Convey("Then it should be malformed", func() {
+				So(err, ShouldWrap, transfer.ErrMalformed)
+			})
+		})
+	})
+}
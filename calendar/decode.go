@@ -0,0 +1,96 @@
+package calendar
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/emersion/go-ical"
+
+	"github.com/wei840222/go-restful-sample/transfer"
+)
+
+// ParseToDo returns the record of a todo for a VTODO. A todo is completed when
+// its STATUS is COMPLETED or it has a COMPLETED date, kept as its completion
+// time.
+func ParseToDo(comp *ical.Component) (transfer.Record, error) {
+	var rec transfer.Record
+	if comp.Name != ical.CompToDo {
+		return rec, fmt.Errorf("%s is not a %s", comp.Name, ical.CompToDo)
+	}
+
+	var err error
+	if rec.ExternalID, err = comp.Props.Text(ical.PropUID); err != nil {
+		return rec, err
+	}
+	if rec.ExternalID != "" {
+		rec.ExternalSource = ExternalSource
+	}
+	if rec.Title, err = comp.Props.Text(ical.PropSummary); err != nil {
+		return rec, err
+	}
+	if rec.Description, err = comp.Props.Text(ical.PropDescription); err != nil {
+		return rec, err
+	}
+	if prop := comp.Props.Get(ical.PropDue); prop != nil {
+		due, err := prop.DateTime(nil)
+		if err != nil {
+			return rec, fmt.Errorf("%s: %w", ical.PropDue, err)
+		}
+		due = due.UTC()
+		rec.DueAt = &due
+	}
+	if prop := comp.Props.Get(ical.PropRecurrenceRule); prop != nil {
+		rec.RRule = prop.Value
+	}
+
+	status, err := comp.Props.Text(ical.PropStatus)
+	if err != nil {
+		return rec, err
+	}
+	rec.Completed = status == StatusCompleted
+	if prop := comp.Props.Get(ical.PropCompleted); prop != nil {
+		completed, err := prop.DateTime(nil)
+		if err != nil {
+			return rec, fmt.Errorf("%s: %w", ical.PropCompleted, err)
+		}
+		completed = completed.UTC()
+		rec.Completed = true
+		rec.CompletedAt = &completed
+	}
+	return rec, nil
+}
+
+// Decoder reads the VTODOs of an iCalendar document as the rows of an import.
+// Other components are skipped.
+type Decoder struct {
+	dec   *ical.Decoder
+	todos []*ical.Component
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: ical.NewDecoder(r)}
+}
+
+func (d *Decoder) Decode() (transfer.Record, error) {
+	for len(d.todos) == 0 {
+		cal, err := d.dec.Decode()
+		if err == io.EOF {
+			return transfer.Record{}, err
+		} else if err != nil {
+			return transfer.Record{}, fmt.Errorf("%w: %v", transfer.ErrMalformed, err)
+		}
+		for _, child := range cal.Children {
+			if child.Name == ical.CompToDo {
+				d.todos = append(d.todos, child)
+			}
+		}
+	}
+
+	comp := d.todos[0]
+	d.todos = d.todos[1:]
+	rec, err := ParseToDo(comp)
+	if err != nil {
+		return rec, fmt.Errorf("%w: %v", transfer.ErrInvalidRow, err)
+	}
+	return rec, nil
+}
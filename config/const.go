@@ -11,4 +11,46 @@ const (
 	ConfigKeyGinMode = "gin.mode"
 	ConfigKeyGinPort = "gin.port"
 	ConfigKeyGinHost = "gin.host"
+
+	ConfigKeyGRPCHost        = "grpc.host"
+	ConfigKeyGRPCPort        = "grpc.port"
+	ConfigKeyGRPCRequireUser = "grpc.require_user"
+
+	ConfigKeyDatabaseDSN = "database.dsn"
+
+	ConfigKeyWorkflow = "workflow"
+
+	ConfigKeyTodoStorage = "todo.storage"
+
+	ConfigKeyBlobDriver   = "blob.driver"
+	ConfigKeyBlobLocalDir = "blob.local.dir"
+	ConfigKeyBlobS3       = "blob.s3"
+
+	ConfigKeyAttachmentMaxSize      = "attachment.max_size"
+	ConfigKeyAttachmentAllowedTypes = "attachment.allowed_types"
+
+	ConfigKeyOutboxRelay             = "outbox.relay"
+	ConfigKeyOutboxSink              = "outbox.sink"
+	ConfigKeyOutboxWebhookURL        = "outbox.webhook.url"
+	ConfigKeyOutboxWebhookTimeout    = "outbox.webhook.timeout"
+	ConfigKeyOutboxNATSURL           = "outbox.nats.url"
+	ConfigKeyOutboxNATSSubjectPrefix = "outbox.nats.subject_prefix"
+	ConfigKeyOutboxNATSTimeout       = "outbox.nats.timeout"
+
+	ConfigKeyWebhook = "webhook"
+
+	ConfigKeyStream = "stream"
+
+	ConfigKeyWebSocket = "websocket"
+
+	ConfigKeySyncConflictPolicy = "sync.conflict_policy"
+	ConfigKeySyncMaxBatchSize   = "sync.max_batch_size"
+
+	ConfigKeyBulkMaxBatchSize = "bulk.max_batch_size"
+
+	ConfigKeyGraphQLMaxDepth      = "graphql.max_depth"
+	ConfigKeyGraphQLMaxComplexity = "graphql.max_complexity"
+
+	ConfigKeyCalDAVRealm = "caldav.realm"
+	ConfigKeyCalDAVUsers = "caldav.users"
 )
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"io"
 	"os"
 	"time"
 
@@ -17,6 +18,18 @@ func InitZerolog() {
 		zerolog.SetGlobalLevel(l)
 	}
 	if viper.GetString(ConfigKeyLogFormat) != "json" {
-		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339, NoColor: !viper.GetBool(ConfigKeyLogColor)})
+		log.Logger = log.Output(newConsoleWriter(os.Stdout))
 	}
 }
+
+// LogToStderr moves the console logs to the standard error, for commands
+// writing their output to the standard output. JSON logs already go there.
+func LogToStderr() {
+	if viper.GetString(ConfigKeyLogFormat) != "json" {
+		log.Logger = log.Output(newConsoleWriter(os.Stderr))
+	}
+}
+
+func newConsoleWriter(out io.Writer) zerolog.ConsoleWriter {
+	return zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339, NoColor: !viper.GetBool(ConfigKeyLogColor)}
+}
@@ -13,6 +13,7 @@ import (
 	"go.uber.org/fx"
 
 	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/handler"
 )
 
 func NewGinLogger(notLogged ...string) gin.HandlerFunc {
@@ -90,7 +91,7 @@ func NewGinEngine(lc fx.Lifecycle) *gin.Engine {
 	e := gin.New()
 	e.ContextWithFallback = true
 
-	e.Use(NewGinLogger(), gin.Recovery())
+	e.Use(NewGinLogger(), gin.Recovery(), handler.AuditMiddleware())
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", viper.GetString(config.ConfigKeyGinHost), viper.GetInt(config.ConfigKeyGinPort)),
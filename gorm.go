@@ -1,14 +1,43 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
 	gorm_zerolog "github.com/wei840222/gorm-zerolog"
+
+	"github.com/wei840222/go-restful-sample/config"
 )
 
+// memoryDSN is the database used when none is configured, which is lost when
+// the process exits.
+const memoryDSN = "file::memory:?cache=shared"
+
 func NewGorm() (*gorm.DB, error) {
-	return gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{
-		Logger: gorm_zerolog.New(),
+	dsn := viper.GetString(config.ConfigKeyDatabaseDSN)
+	if dsn == "" {
+		dsn = memoryDSN
+	}
+	if err := createDatabaseDir(dsn); err != nil {
+		return nil, err
+	}
+	return gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger:         gorm_zerolog.New(),
+		TranslateError: true,
 	})
 }
+
+// createDatabaseDir creates the directory of the file of a SQLite DSN, which
+// SQLite does not do on its own.
+func createDatabaseDir(dsn string) error {
+	name, _, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	if name == "" || name == ":memory:" || strings.Contains(dsn, "mode=memory") {
+		return nil
+	}
+	return os.MkdirAll(filepath.Dir(name), 0o750)
+}
@@ -0,0 +1,144 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+const (
+	// loaderWait is how long a loader collects keys before fetching them, long
+	// enough for the resolvers of a list to request theirs.
+	loaderWait = 2 * time.Millisecond
+	// loaderMaxBatch bounds the number of keys fetched at once.
+	loaderMaxBatch = 100
+)
+
+// loader batches the keys requested by concurrent resolvers into a single
+// fetch, so that resolving a relation for every todo of a page costs one query
+// instead of one per todo. Results are cached for the rest of the operation.
+type loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending *batch[K, V]
+	batches map[K]*batch[K, V]
+}
+
+type batch[K comparable, V any] struct {
+	keys   []K
+	done   chan struct{}
+	values map[K]V
+	err    error
+}
+
+func newLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, batches: make(map[K]*batch[K, V])}
+}
+
+// Load returns the value of key, or the zero value if the fetch did not return
+// one.
+func (l *loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	b, ok := l.batches[key]
+	if !ok {
+		if l.pending == nil {
+			b := &batch[K, V]{done: make(chan struct{})}
+			l.pending = b
+			time.AfterFunc(loaderWait, func() { l.dispatch(ctx, b) })
+		}
+		b = l.pending
+		b.keys = append(b.keys, key)
+		l.batches[key] = b
+		if len(b.keys) >= loaderMaxBatch {
+			go l.dispatch(ctx, b)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-b.done:
+		return b.values[key], b.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// dispatch fetches b unless it was already fetched for reaching the maximum
+// batch size.
+func (l *loader[K, V]) dispatch(ctx context.Context, b *batch[K, V]) {
+	l.mu.Lock()
+	if l.pending != b {
+		l.mu.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mu.Unlock()
+
+	b.values, b.err = l.fetch(ctx, b.keys)
+	close(b.done)
+}
+
+// loaders holds the loaders of the relations of todos for one operation.
+type loaders struct {
+	projects    *loader[uint, *storage.Project]
+	owners      *loader[uint, string]
+	comments    *loader[uint, []*storage.Comment]
+	attachments *loader[uint, []*storage.Attachment]
+}
+
+type loadersKey struct{}
+
+func (r *Resolver) newLoaders() *loaders {
+	return &loaders{
+		projects: newLoader(func(ctx context.Context, ids []uint) (map[uint]*storage.Project, error) {
+			projects, err := r.projects.ListByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[uint]*storage.Project, len(projects))
+			for i := range projects {
+				byID[projects[i].ID] = &projects[i]
+			}
+			return byID, nil
+		}),
+		owners: newLoader(func(ctx context.Context, todoIDs []uint) (map[uint]string, error) {
+			return r.audit.ListOwners(ctx, todoIDs)
+		}),
+		comments: newLoader(func(ctx context.Context, todoIDs []uint) (map[uint][]*storage.Comment, error) {
+			comments, err := r.comments.ListByTodoIDs(ctx, todoIDs)
+			if err != nil {
+				return nil, err
+			}
+			byTodo := make(map[uint][]*storage.Comment, len(todoIDs))
+			for i := range comments {
+				byTodo[comments[i].TodoID] = append(byTodo[comments[i].TodoID], &comments[i])
+			}
+			return byTodo, nil
+		}),
+		attachments: newLoader(func(ctx context.Context, todoIDs []uint) (map[uint][]*storage.Attachment, error) {
+			attachments, err := r.attachments.ListByTodoIDs(ctx, todoIDs)
+			if err != nil {
+				return nil, err
+			}
+			byTodo := make(map[uint][]*storage.Attachment, len(todoIDs))
+			for i := range attachments {
+				byTodo[attachments[i].TodoID] = append(byTodo[attachments[i].TodoID], &attachments[i])
+			}
+			return byTodo, nil
+		}),
+	}
+}
+
+// withLoaders returns a context with fresh loaders so that nothing is cached
+// across operations.
+func (r *Resolver) withLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loadersKey{}, r.newLoaders())
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
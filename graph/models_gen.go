@@ -0,0 +1,95 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+type CreateTodoInput struct {
+	Title       string     `json:"title"`
+	Description *string    `json:"description,omitempty"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	Rrule       *string    `json:"rrule,omitempty"`
+	ProjectID   *uint      `json:"projectId,omitempty"`
+}
+
+type Mutation struct {
+}
+
+type Query struct {
+}
+
+type TodoPage struct {
+	TotalCount int             `json:"totalCount"`
+	Nodes      []*storage.Todo `json:"nodes"`
+}
+
+type UpdateTodoInput struct {
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Completed   *bool      `json:"completed,omitempty"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	Rrule       *string    `json:"rrule,omitempty"`
+}
+
+type UpdateScope string
+
+const (
+	UpdateScopeOccurrence UpdateScope = "OCCURRENCE"
+	UpdateScopeSeries     UpdateScope = "SERIES"
+)
+
+var AllUpdateScope = []UpdateScope{
+	UpdateScopeOccurrence,
+	UpdateScopeSeries,
+}
+
+func (e UpdateScope) IsValid() bool {
+	switch e {
+	case UpdateScopeOccurrence, UpdateScopeSeries:
+		return true
+	}
+	return false
+}
+
+func (e UpdateScope) String() string {
+	return string(e)
+}
+
+func (e *UpdateScope) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UpdateScope(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UpdateScope", str)
+	}
+	return nil
+}
+
+func (e UpdateScope) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *UpdateScope) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e UpdateScope) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
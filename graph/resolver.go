@@ -0,0 +1,53 @@
+package graph
+
+//go:generate go run github.com/99designs/gqlgen generate
+
+import (
+	"context"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Resolver resolves the GraphQL schema on top of the storages used by the REST
+// API, so both share the same validation, workflow and audit trail.
+type Resolver struct {
+	todos       storage.TodoStorage
+	projects    storage.ProjectStorage
+	comments    storage.CommentStorage
+	attachments storage.AttachmentStorage
+	audit       storage.AuditStorage
+}
+
+func NewResolver(todos storage.TodoStorage, projects storage.ProjectStorage, comments storage.CommentStorage, attachments storage.AttachmentStorage, audit storage.AuditStorage) *Resolver {
+	return &Resolver{
+		todos:       todos,
+		projects:    projects,
+		comments:    comments,
+		attachments: attachments,
+		audit:       audit,
+	}
+}
+
+func (r *Resolver) getTodo(ctx context.Context, id uint) (*storage.Todo, error) {
+	todo, err := r.todos.Get(ctx, int(id))
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func deref[T any](v *T) T {
+	var zero T
+	if v == nil {
+		return zero
+	}
+	return *v
+}
+
+func toInt(id *uint) *int {
+	if id == nil {
+		return nil
+	}
+	i := int(*id)
+	return &i
+}
@@ -0,0 +1,181 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+func (r *commentResolver) Mentions(ctx context.Context, obj *storage.Comment) ([]string, error) {
+	mentions := make([]string, 0, len(obj.Mentions))
+	for _, mention := range obj.Mentions {
+		mentions = append(mentions, mention.Username)
+	}
+	return mentions, nil
+}
+
+func (r *mutationResolver) CreateTodo(ctx context.Context, input CreateTodoInput) (*storage.Todo, error) {
+	var todo storage.Todo
+	todo.Title = input.Title
+	todo.Description = deref(input.Description)
+	todo.DueAt = input.DueAt
+	todo.RRule = deref(input.Rrule)
+	todo.ProjectID = input.ProjectID
+
+	if err := r.todos.Create(ctx, &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (r *mutationResolver) UpdateTodo(ctx context.Context, id uint, input UpdateTodoInput, scope UpdateScope) (*storage.Todo, error) {
+	var todo storage.Todo
+	todo.Title = deref(input.Title)
+	todo.Description = deref(input.Description)
+	todo.Completed = input.Completed
+	todo.DueAt = input.DueAt
+	todo.RRule = deref(input.Rrule)
+
+	var err error
+	if scope == UpdateScopeSeries {
+		if input.Completed != nil || input.DueAt != nil {
+			return nil, errors.New("completed and dueAt can only be updated on a single occurrence")
+		}
+		err = r.todos.UpdateSeries(ctx, int(id), todo)
+	} else {
+		err = r.todos.Update(ctx, int(id), todo)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.getTodo(ctx, id)
+}
+
+func (r *mutationResolver) DeleteTodo(ctx context.Context, id uint, purge bool) (*uint, error) {
+	var err error
+	if purge {
+		err = r.todos.Purge(ctx, int(id))
+	} else {
+		err = r.todos.Delete(ctx, int(id))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func (r *mutationResolver) TransitionTodo(ctx context.Context, id uint, status string) (*storage.Todo, error) {
+	if _, err := r.todos.Transition(ctx, int(id), status); err != nil {
+		return nil, err
+	}
+	return r.getTodo(ctx, id)
+}
+
+func (r *mutationResolver) MoveTodo(ctx context.Context, id uint, after *uint, before *uint) (*storage.Todo, error) {
+	if err := r.todos.Move(ctx, int(id), toInt(after), toInt(before)); err != nil {
+		return nil, err
+	}
+	return r.getTodo(ctx, id)
+}
+
+func (r *mutationResolver) MoveTodoToProject(ctx context.Context, id uint, projectID *uint) (*storage.Todo, error) {
+	if err := r.todos.MoveToProject(ctx, int(id), projectID); err != nil {
+		return nil, err
+	}
+	return r.getTodo(ctx, id)
+}
+
+func (r *queryResolver) Todo(ctx context.Context, id uint) (*storage.Todo, error) {
+	todo, err := r.getTodo(ctx, id)
+	if storage.IsNotFound(err) {
+		return nil, nil
+	}
+	return todo, err
+}
+
+func (r *queryResolver) Todos(ctx context.Context, filter *storage.TodoFilter, offset int, limit int) (*TodoPage, error) {
+	if offset < 0 || limit < 1 || limit > MaxPageSize {
+		return nil, errInvalidPage
+	}
+	if filter == nil {
+		filter = &storage.TodoFilter{}
+	}
+
+	todos, total, err := r.todos.Search(ctx, *filter, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TodoPage{TotalCount: int(total), Nodes: make([]*storage.Todo, 0, len(todos))}
+	for i := range todos {
+		page.Nodes = append(page.Nodes, &todos[i])
+	}
+	return page, nil
+}
+
+func (r *queryResolver) Project(ctx context.Context, id uint) (*storage.Project, error) {
+	project, err := r.projects.Get(ctx, int(id))
+	if storage.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (r *queryResolver) Projects(ctx context.Context) ([]*storage.Project, error) {
+	projects, err := r.projects.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*storage.Project, 0, len(projects))
+	for i := range projects {
+		res = append(res, &projects[i])
+	}
+	return res, nil
+}
+
+func (r *todoResolver) Project(ctx context.Context, obj *storage.Todo) (*storage.Project, error) {
+	if obj.ProjectID == nil {
+		return nil, nil
+	}
+	return loadersFrom(ctx).projects.Load(ctx, *obj.ProjectID)
+}
+
+func (r *todoResolver) Priority(ctx context.Context, obj *storage.Todo) (*string, error) {
+	if obj.Priority == "" {
+		return nil, nil
+	}
+	return &obj.Priority, nil
+}
+
+func (r *todoResolver) Owner(ctx context.Context, obj *storage.Todo) (*string, error) {
+	owner, err := loadersFrom(ctx).owners.Load(ctx, obj.ID)
+	if err != nil || owner == "" {
+		return nil, err
+	}
+	return &owner, nil
+}
+
+func (r *todoResolver) Comments(ctx context.Context, obj *storage.Todo) ([]*storage.Comment, error) {
+	return loadersFrom(ctx).comments.Load(ctx, obj.ID)
+}
+
+func (r *todoResolver) Attachments(ctx context.Context, obj *storage.Todo) ([]*storage.Attachment, error) {
+	return loadersFrom(ctx).attachments.Load(ctx, obj.ID)
+}
+
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+func (r *Resolver) Todo() TodoResolver { return &todoResolver{r} }
+
+type commentResolver struct{ *Resolver }
+type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type todoResolver struct{ *Resolver }
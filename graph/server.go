@@ -0,0 +1,135 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Codes set in the extensions of errors, mirroring the status codes of the
+// REST API.
+const (
+	ErrorCodeNotFound           = "NOT_FOUND"
+	ErrorCodeBadUserInput       = "BAD_USER_INPUT"
+	ErrorCodeConflict           = "CONFLICT"
+	ErrorCodeDepthLimitExceeded = "DEPTH_LIMIT_EXCEEDED"
+)
+
+// MaxPageSize is the largest limit accepted when paginating todos.
+const MaxPageSize = 100
+
+var errInvalidPage = fmt.Errorf("offset must not be negative and limit must be between 1 and %d", MaxPageSize)
+
+// Limits bound the cost of the operations a client can run. The complexity
+// of a field is 1 plus the complexity of its selection, multiplied by the
+// limit for paginated todos.
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// NewServer returns the GraphQL HTTP handler serving queries over GET and
+// POST.
+func NewServer(r *Resolver, limits Limits) *handler.Server {
+	c := Config{Resolvers: r}
+	c.Complexity.Query.Todos = func(childComplexity int, _ *storage.TodoFilter, _ int, limit int) int {
+		return 1 + limit*childComplexity
+	}
+
+	srv := handler.New(NewExecutableSchema(c))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(depthLimit(limits.MaxDepth))
+	srv.Use(extension.FixedComplexityLimit(limits.MaxComplexity))
+
+	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		return next(r.withLoaders(ctx))
+	})
+	srv.SetErrorPresenter(presentError)
+
+	return srv
+}
+
+// depthLimit rejects operations selecting fields nested deeper than itself.
+// Introspection fields are not counted so that tools can load the schema.
+type depthLimit int
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = depthLimit(0)
+
+func (depthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (depthLimit) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (l depthLimit) MutateOperationContext(_ context.Context, oc *graphql.OperationContext) *gqlerror.Error {
+	if depth := selectionDepth(oc.Operation.SelectionSet); depth > int(l) {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, l)
+		err.Extensions = map[string]any{"code": ErrorCodeDepthLimitExceeded}
+		return err
+	}
+	return nil
+}
+
+// selectionDepth returns the depth of the deepest field of set. Fragment cycles
+// are rejected by validation before.
+func selectionDepth(set ast.SelectionSet) int {
+	var depth int
+	for _, selection := range set {
+		var d int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name, "__") {
+				continue
+			}
+			d = 1 + selectionDepth(selection.SelectionSet)
+		case *ast.InlineFragment:
+			d = selectionDepth(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			if selection.Definition != nil {
+				d = selectionDepth(selection.Definition.SelectionSet)
+			}
+		}
+		depth = max(depth, d)
+	}
+	return depth
+}
+
+// presentError sets the code of the errors returned by resolvers.
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	var code string
+	switch {
+	case storage.IsNotFound(err):
+		code = ErrorCodeNotFound
+	case storage.IsInvalidRRule(err), storage.IsUnknownStatus(err), storage.IsInvalidMove(err), errors.Is(err, errInvalidPage):
+		code = ErrorCodeBadUserInput
+	case storage.IsIllegalTransition(err):
+		code = ErrorCodeConflict
+	default:
+		return gqlErr
+	}
+
+	if gqlErr.Extensions == nil {
+		gqlErr.Extensions = make(map[string]any)
+	}
+	gqlErr.Extensions["code"] = code
+	return gqlErr
+}
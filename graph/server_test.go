@@ -0,0 +1,176 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+type gqlRes struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestServer(t *testing.T) {
+	Convey("Given a GraphQL server with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		todos := mock.NewMockTodoStorage(ctrl)
+		projects := mock.NewMockProjectStorage(ctrl)
+		comments := mock.NewMockCommentStorage(ctrl)
+		attachments := mock.NewMockAttachmentStorage(ctrl)
+		audit := mock.NewMockAuditStorage(ctrl)
+		resolver := NewResolver(todos, projects, comments, attachments, audit)
+		srv := NewServer(resolver, Limits{MaxDepth: 4, MaxComplexity: 200})
+
+		do := func(query string) (int, gqlRes) {
+			body, _ := json.Marshal(map[string]string{"query": query})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			srv.ServeHTTP(w, req)
+
+			var res gqlRes
+			So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+			return w.Code, res
+		}
+
+		Convey("When querying todos with their relations", func() {
+			projectID := uint(1)
+			todos.EXPECT().
+				Search(gomock.Any(), gomock.Eq(storage.TodoFilter{Status: "todo"}), gomock.Eq(0), gomock.Eq(2)).
+				Return([]storage.Todo{
+					{Model: gorm.Model{ID: 1}, Title: "first", ProjectID: &projectID},
+					{Model: gorm.Model{ID: 2}, Title: "second", ProjectID: &projectID},
+				}, int64(3), nil).
+				Times(1)
+			projects.EXPECT().
+				ListByIDs(gomock.Any(), gomock.Eq([]uint{1})).
+				Return([]storage.Project{{Model: gorm.Model{ID: 1}, Name: "home"}}, nil).
+				Times(1)
+			audit.EXPECT().
+				ListOwners(gomock.Any(), gomock.InAnyOrder([]uint{1, 2})).
+				Return(map[uint]string{1: "alice"}, nil).
+				Times(1)
+			comments.EXPECT().
+				ListByTodoIDs(gomock.Any(), gomock.InAnyOrder([]uint{1, 2})).
+				Return([]storage.Comment{{Model: gorm.Model{ID: 5}, TodoID: 2, Body: "hi @bob", Mentions: []storage.CommentMention{{Username: "bob"}}}}, nil).
+				Times(1)
+
+			code, res := do(`{ todos(filter: {status: "todo"}, limit: 2) { totalCount nodes { id title owner project { name } comments { body mentions } } } }`)
+
+			Convey("Then each relation should be loaded with a single batch", func() {
+				So(code, ShouldEqual, http.StatusOK)
+				So(res.Errors, ShouldBeEmpty)
+
+				page := res.Data["todos"].(map[string]any)
+				So(page["totalCount"], ShouldEqual, 3)
+				nodes := page["nodes"].([]any)
+				So(nodes, ShouldHaveLength, 2)
+
+				first, second := nodes[0].(map[string]any), nodes[1].(map[string]any)
+				So(first["owner"], ShouldEqual, "alice")
+				So(second["owner"], ShouldBeNil)
+				So(first["project"].(map[string]any)["name"], ShouldEqual, "home")
+				So(first["comments"], ShouldBeEmpty)
+				So(second["comments"], ShouldResemble, []any{map[string]any{"body": "hi @bob", "mentions": []any{"bob"}}})
+			})
+		})
+
+		Convey("When querying todos by tag", func() {
+			todos.EXPECT().
+				Search(gomock.Any(), gomock.Eq(storage.TodoFilter{Tag: "@phone"}), gomock.Eq(0), gomock.Eq(20)).
+				Return([]storage.Todo{
+					{Model: gorm.Model{ID: 1}, Title: "Call mom", Priority: "A", Tags: []string{"@phone"}},
+					{Model: gorm.Model{ID: 2}, Title: "Call bank"},
+				}, int64(2), nil).
+				Times(1)
+
+			_, res := do(`{ todos(filter: {tag: "@phone"}) { nodes { priority tags } } }`)
+
+			Convey("Then their priority and tags should be returned", func() {
+				So(res.Errors, ShouldBeEmpty)
+				nodes := res.Data["todos"].(map[string]any)["nodes"].([]any)
+				So(nodes, ShouldResemble, []any{
+					map[string]any{"priority": "A", "tags": []any{"@phone"}},
+					map[string]any{"priority": nil, "tags": []any{}},
+				})
+			})
+		})
+
+		Convey("When querying a todo that does not exist", func() {
+			todos.EXPECT().Get(gomock.Any(), gomock.Eq(9)).Return(storage.Todo{}, gorm.ErrRecordNotFound).Times(1)
+
+			_, res := do(`{ todo(id: 9) { id } }`)
+
+			Convey("Then it should be null", func() {
+				So(res.Errors, ShouldBeEmpty)
+				So(res.Data["todo"], ShouldBeNil)
+			})
+		})
+
+		Convey("When updating a todo that does not exist", func() {
+			todos.EXPECT().Update(gomock.Any(), gomock.Eq(9), gomock.Eq(storage.Todo{Title: "renamed"})).Return(gorm.ErrRecordNotFound).Times(1)
+
+			_, res := do(`mutation { updateTodo(id: 9, input: {title: "renamed"}) { id } }`)
+
+			Convey("Then it should fail with a not found code", func() {
+				So(res.Errors, ShouldHaveLength, 1)
+				So(res.Errors[0].Extensions["code"], ShouldEqual, ErrorCodeNotFound)
+				So(res.Data["updateTodo"], ShouldBeNil)
+			})
+		})
+
+		Convey("When paginating from a negative offset", func() {
+			_, res := do(`{ todos(offset: -1) { totalCount } }`)
+
+			Convey("Then it should fail with a bad user input code", func() {
+				So(res.Errors, ShouldHaveLength, 1)
+				So(res.Errors[0].Extensions["code"], ShouldEqual, ErrorCodeBadUserInput)
+			})
+		})
+
+		Convey("When a query is nested too deeply", func() {
+			srv = NewServer(resolver, Limits{MaxDepth: 3, MaxComplexity: 200})
+			_, res := do(`{ todos { nodes { project { id } } } ...F } fragment F on Query { todos { nodes { comments { id } } } }`)
+
+			Convey("Then it should be rejected before resolving anything", func() {
+				So(res.Data, ShouldBeNil)
+				So(res.Errors, ShouldHaveLength, 1)
+				So(res.Errors[0].Extensions["code"], ShouldEqual, ErrorCodeDepthLimitExceeded)
+			})
+		})
+
+		Convey("When a query is too complex", func() {
+			_, res := do(`{ todos(limit: 100) { nodes { id title } } }`)
+
+			Convey("Then it should be rejected before resolving anything", func() {
+				So(res.Data, ShouldBeNil)
+				So(res.Errors, ShouldHaveLength, 1)
+				So(res.Errors[0].Extensions["code"], ShouldEqual, "COMPLEXITY_LIMIT_EXCEEDED")
+			})
+		})
+
+		Convey("When introspecting the schema", func() {
+			code, res := do(`{ __schema { types { name fields { name type { name ofType { name ofType { name } } } } } } }`)
+
+			Convey("Then it should not count against the depth limit", func() {
+				So(code, ShouldEqual, http.StatusOK)
+				So(res.Errors, ShouldBeEmpty)
+			})
+		})
+	})
+}
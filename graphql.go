@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/graph"
+	"github.com/wei840222/go-restful-sample/handler"
+)
+
+func NewGraphQLConfig() (handler.GraphQLConfig, error) {
+	cfg := handler.GraphQLConfig{
+		Limits: graph.Limits{
+			MaxDepth:      viper.GetInt(config.ConfigKeyGraphQLMaxDepth),
+			MaxComplexity: viper.GetInt(config.ConfigKeyGraphQLMaxComplexity),
+		},
+		GraphiQL: viper.GetString(config.ConfigKeyGinMode) == gin.DebugMode,
+	}
+	if cfg.MaxDepth <= 0 || cfg.MaxComplexity <= 0 {
+		return cfg, fmt.Errorf("invalid graphql limits %+v", cfg.Limits)
+	}
+	return cfg, nil
+}
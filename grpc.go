@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/grpcserver"
+)
+
+// NewGRPCServer serves the gRPC services on their own port, with reflection
+// and the standard health service reporting every registered service.
+func NewGRPCServer(lc fx.Lifecycle) *grpc.Server {
+	requireUser := viper.GetBool(config.ConfigKeyGRPCRequireUser)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			grpcserver.LoggingInterceptor(),
+			grpcserver.AuthInterceptor(requireUser),
+		),
+		grpc.ChainStreamInterceptor(
+			grpcserver.LoggingStreamInterceptor(),
+			grpcserver.AuthStreamInterceptor(requireUser),
+		),
+	)
+
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	reflection.Register(srv)
+
+	addr := fmt.Sprintf("%s:%d", viper.GetString(config.ConfigKeyGRPCHost), viper.GetInt(config.ConfigKeyGRPCPort))
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			lis, err := net.Listen("tcp", addr)
+			if err != nil {
+				return err
+			}
+			for name := range srv.GetServiceInfo() {
+				hs.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+			}
+			go func() {
+				if err := srv.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+					panic(err)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			hs.Shutdown()
+
+			stopped := make(chan struct{})
+			go func() {
+				srv.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				srv.Stop()
+			}
+			return nil
+		},
+	})
+
+	return srv
+}
@@ -0,0 +1,161 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Metadata keys matching the headers of the REST API.
+const (
+	MetadataUser      = "x-user"
+	MetadataRequestID = "x-request-id"
+)
+
+const anonymousUser = "anonymous"
+
+// infrastructureServices are called by health probes and tooling rather than
+// by users, so they are never required to carry one.
+var infrastructureServices = []string{
+	healthpb.Health_ServiceDesc.ServiceName,
+	reflectionv1.ServerReflection_ServiceDesc.ServiceName,
+	reflectionv1alpha.ServerReflection_ServiceDesc.ServiceName,
+}
+
+// LoggingInterceptor logs every call like the gin logger logs requests.
+func LoggingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		now := time.Now()
+		res, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, time.Since(now), err)
+		return res, err
+	}
+}
+
+// LoggingStreamInterceptor logs every stream once it ends, like
+// LoggingInterceptor logs unary calls.
+func LoggingStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		now := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), info.FullMethod, time.Since(now), err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, method string, latency time.Duration, err error) {
+	code := status.Code(err)
+	entry := map[string]any{
+		"method":  method,
+		"code":    code.String(),
+		"latency": latency.String(),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		entry["peer"] = p.Addr.String()
+	}
+
+	var event *zerolog.Event
+	switch code {
+	case codes.OK:
+		event = log.Info()
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss:
+		event = log.Error().Err(err)
+	default:
+		event = log.Warn().Err(err)
+	}
+	event.Fields(entry).Msgf("[GRPC] %s | %s | %13v", method, code, latency)
+}
+
+// AuthInterceptor attaches the user set by the authenticating proxy and the
+// request ID to the context so that mutations are attributed in the audit
+// trail, like AuditMiddleware does for HTTP. With requireUser, calls without a
+// user are rejected instead of being made as anonymous, except for the health
+// and reflection services.
+func AuthInterceptor(requireUser bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, id, err := authenticate(ctx, info.FullMethod, requireUser)
+		if err != nil {
+			return nil, err
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(MetadataRequestID, id))
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamInterceptor is the AuthInterceptor of streams.
+func AuthStreamInterceptor(requireUser bool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, id, err := authenticate(ss.Context(), info.FullMethod, requireUser)
+		if err != nil {
+			return err
+		}
+		ss.SetHeader(metadata.Pairs(MetadataRequestID, id))
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate returns ctx with the user and request ID of the call to
+// method, and the request ID to send back.
+func authenticate(ctx context.Context, method string, requireUser bool) (context.Context, string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	user := first(md.Get(MetadataUser))
+	if user == "" {
+		if requireUser && !isInfrastructure(method) {
+			return nil, "", status.Error(codes.Unauthenticated, "missing user")
+		}
+		user = anonymousUser
+	}
+
+	id := first(md.Get(MetadataRequestID))
+	if id == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+
+	ctx = storage.WithActor(ctx, user)
+	ctx = storage.WithRequestID(ctx, id)
+	return ctx, id, nil
+}
+
+// contextStream is a stream whose handler sees ctx as its context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func isInfrastructure(fullMethod string) bool {
+	for _, service := range infrastructureServices {
+		if strings.HasPrefix(fullMethod, "/"+service+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
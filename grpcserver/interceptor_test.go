@@ -0,0 +1,63 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestAuthStreamInterceptor(t *testing.T) {
+	Convey("Given an AuthStreamInterceptor requiring a user", t, func() {
+		interceptor := AuthStreamInterceptor(true)
+		info := &grpc.StreamServerInfo{FullMethod: "/todo.v1.TodoService/WatchTodos"}
+
+		var handled grpc.ServerStream
+		handler := func(_ any, ss grpc.ServerStream) error {
+			handled = ss
+			return nil
+		}
+
+		Convey("When a stream is opened without a user", func() {
+			ss := &fakeServerStream{ctx: context.Background()}
+			err := interceptor(nil, ss, info, handler)
+
+			Convey("Then it should fail with Unauthenticated", func() {
+				So(status.Code(err), ShouldEqual, codes.Unauthenticated)
+				So(handled, ShouldBeNil)
+			})
+		})
+
+		Convey("When a stream is opened with a user and a request ID", func() {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataUser, "alice", MetadataRequestID, "req-1"))
+			ss := &fakeServerStream{ctx: ctx}
+			err := interceptor(nil, ss, info, handler)
+
+			Convey("Then the handler should see the authenticated context", func() {
+				So(err, ShouldBeNil)
+				So(handled, ShouldNotBeNil)
+				So(handled.Context(), ShouldNotEqual, ctx)
+				So(ss.header.Get(MetadataRequestID), ShouldResemble, []string{"req-1"})
+			})
+		})
+	})
+}
@@ -0,0 +1,175 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	todov1 "github.com/wei840222/go-restful-sample/proto/todo/v1"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+type TodoServer struct {
+	todov1.UnimplementedTodoServiceServer
+	storage storage.TodoStorage
+}
+
+func NewTodoServer(s storage.TodoStorage) *TodoServer {
+	return &TodoServer{storage: s}
+}
+
+func newTodo(todo storage.Todo) *todov1.Todo {
+	res := &todov1.Todo{
+		Id:          uint32(todo.ID),
+		Title:       todo.Title,
+		Description: todo.Description,
+		Status:      todo.Status,
+		Completed:   proto.Bool(todo.Completed != nil && *todo.Completed),
+		Rrule:       todo.RRule,
+		SeriesId:    toUint32(todo.SeriesID),
+		ProjectId:   toUint32(todo.ProjectID),
+		Priority:    todo.Priority,
+		Tags:        todo.Tags,
+		CreatedAt:   timestamppb.New(todo.CreatedAt),
+		UpdatedAt:   timestamppb.New(todo.UpdatedAt),
+	}
+	if todo.DueAt != nil {
+		res.DueAt = timestamppb.New(*todo.DueAt)
+	}
+	if todo.ExternalSource != nil && todo.ExternalID != nil {
+		res.ExternalSource = *todo.ExternalSource
+		res.ExternalId = *todo.ExternalID
+	}
+	return res
+}
+
+func (s *TodoServer) GetTodo(ctx context.Context, req *todov1.GetTodoRequest) (*todov1.GetTodoResponse, error) {
+	todo, err := s.storage.Get(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, todoError(err)
+	}
+	return &todov1.GetTodoResponse{Todo: newTodo(todo)}, nil
+}
+
+func (s *TodoServer) ListTodos(ctx context.Context, _ *todov1.ListTodosRequest) (*todov1.ListTodosResponse, error) {
+	todos, err := s.storage.List(ctx)
+	if err != nil {
+		return nil, todoError(err)
+	}
+
+	res := &todov1.ListTodosResponse{Todos: make([]*todov1.Todo, 0, len(todos))}
+	for _, todo := range todos {
+		res.Todos = append(res.Todos, newTodo(todo))
+	}
+	return res, nil
+}
+
+func (s *TodoServer) CreateTodo(ctx context.Context, req *todov1.CreateTodoRequest) (*todov1.CreateTodoResponse, error) {
+	if req.GetTitle() == "" {
+		return nil, status.Error(codes.InvalidArgument, "title is required")
+	}
+	if p := req.GetPriority(); p != "" && (len(p) != 1 || p[0] < 'A' || p[0] > 'Z') {
+		return nil, status.Errorf(codes.InvalidArgument, "priority %q is not a letter from A to Z", p)
+	}
+
+	var todo storage.Todo
+	todo.Title = req.GetTitle()
+	todo.Description = req.GetDescription()
+	todo.DueAt = toTime(req.GetDueAt())
+	todo.RRule = req.GetRrule()
+	todo.Priority = req.GetPriority()
+	if len(req.GetTags()) > 0 {
+		todo.Tags = req.GetTags()
+	}
+	if req.ProjectId != nil {
+		projectID := uint(req.GetProjectId())
+		todo.ProjectID = &projectID
+	}
+
+	if err := s.storage.Create(ctx, &todo); err != nil {
+		return nil, todoError(err)
+	}
+	return &todov1.CreateTodoResponse{Todo: newTodo(todo)}, nil
+}
+
+func (s *TodoServer) UpdateTodo(ctx context.Context, req *todov1.UpdateTodoRequest) (*todov1.UpdateTodoResponse, error) {
+	var todo storage.Todo
+	todo.Title = req.GetTitle()
+	todo.Description = req.GetDescription()
+	todo.Completed = req.Completed
+	todo.DueAt = toTime(req.GetDueAt())
+	todo.RRule = req.GetRrule()
+
+	var err error
+	if req.GetScope() == todov1.UpdateScope_UPDATE_SCOPE_SERIES {
+		if req.Completed != nil || req.DueAt != nil {
+			return nil, status.Error(codes.InvalidArgument, "completed and due_at can only be updated on a single occurrence")
+		}
+		err = s.storage.UpdateSeries(ctx, int(req.GetId()), todo)
+	} else {
+		err = s.storage.Update(ctx, int(req.GetId()), todo)
+	}
+	if err != nil {
+		return nil, todoError(err)
+	}
+	return &todov1.UpdateTodoResponse{}, nil
+}
+
+func (s *TodoServer) DeleteTodo(ctx context.Context, req *todov1.DeleteTodoRequest) (*todov1.DeleteTodoResponse, error) {
+	var err error
+	if req.GetPurge() {
+		err = s.storage.Purge(ctx, int(req.GetId()))
+	} else {
+		err = s.storage.Delete(ctx, int(req.GetId()))
+	}
+	if err != nil {
+		return nil, todoError(err)
+	}
+	return &todov1.DeleteTodoResponse{}, nil
+}
+
+// todoError maps storage errors to the status codes matching those of the
+// REST API.
+func todoError(err error) error {
+	switch {
+	case storage.IsNotFound(err), storage.IsTodoDeleted(err):
+		return status.Error(codes.NotFound, err.Error())
+	case storage.IsInvalidRRule(err), storage.IsUnknownStatus(err), storage.IsInvalidMove(err):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case storage.IsIllegalTransition(err):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case storage.IsDuplicate(err):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func toUint32(id *uint) *uint32 {
+	if id == nil {
+		return nil
+	}
+	v := uint32(*id)
+	return &v
+}
+
+func toTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func RegisterTodoService(s *grpc.Server, todos storage.TodoStorage) error {
+	todov1.RegisterTodoServiceServer(s, NewTodoServer(todos))
+	return nil
+}
@@ -0,0 +1,193 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/gorm"
+
+	todov1 "github.com/wei840222/go-restful-sample/proto/todo/v1"
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestTodoServer(t *testing.T) {
+	Convey("Given a TodoService with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+
+		lis := bufconn.Listen(1 << 20)
+		srv := grpc.NewServer(
+			grpc.ChainUnaryInterceptor(LoggingInterceptor(), AuthInterceptor(true)),
+			grpc.ChainStreamInterceptor(LoggingStreamInterceptor(), AuthStreamInterceptor(true)),
+		)
+		RegisterTodoService(srv, mockStorage)
+		healthpb.RegisterHealthServer(srv, health.NewServer())
+		go srv.Serve(lis)
+		defer srv.Stop()
+
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		client := todov1.NewTodoServiceClient(conn)
+		ctx := metadata.AppendToOutgoingContext(context.Background(), MetadataUser, "alice")
+
+		Convey("When getting a todo", func() {
+			dueAt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+			projectID := uint(3)
+			mockStorage.EXPECT().
+				Get(gomock.Any(), gomock.Eq(1)).
+				Return(storage.Todo{Model: gorm.Model{ID: 1}, Title: "test", Status: "todo", Completed: proto.Bool(false), DueAt: &dueAt, ProjectID: &projectID}, nil).
+				Times(1)
+
+			var header metadata.MD
+			res, err := client.GetTodo(ctx, &todov1.GetTodoRequest{Id: 1}, grpc.Header(&header))
+
+			Convey("Then it should return the todo", func() {
+				So(err, ShouldBeNil)
+				So(res.GetTodo().GetTitle(), ShouldEqual, "test")
+				So(res.GetTodo().Completed, ShouldNotBeNil)
+				So(res.GetTodo().GetDueAt().AsTime(), ShouldEqual, dueAt)
+				So(res.GetTodo().GetProjectId(), ShouldEqual, 3)
+				So(res.GetTodo().SeriesId, ShouldBeNil)
+				So(header.Get(MetadataRequestID), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When getting a todo that does not exist", func() {
+			mockStorage.EXPECT().Get(gomock.Any(), gomock.Eq(9)).Return(storage.Todo{}, gorm.ErrRecordNotFound).Times(1)
+
+			_, err := client.GetTodo(ctx, &todov1.GetTodoRequest{Id: 9})
+
+			Convey("Then it should fail with NotFound", func() {
+				So(status.Code(err), ShouldEqual, codes.NotFound)
+			})
+		})
+
+		Convey("When creating a todo", func() {
+			projectID := uint(2)
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Eq(&storage.Todo{Title: "new", ProjectID: &projectID})).
+				DoAndReturn(func(_ context.Context, todo *storage.Todo) error {
+					todo.ID = 5
+					todo.Status = "todo"
+					return nil
+				}).
+				Times(1)
+
+			res, err := client.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: "new", ProjectId: proto.Uint32(2)})
+
+			Convey("Then it should return the created todo", func() {
+				So(err, ShouldBeNil)
+				So(res.GetTodo().GetId(), ShouldEqual, 5)
+				So(res.GetTodo().GetStatus(), ShouldEqual, "todo")
+			})
+		})
+
+		Convey("When creating a todo without a title", func() {
+			_, err := client.CreateTodo(ctx, &todov1.CreateTodoRequest{})
+
+			Convey("Then it should fail with InvalidArgument", func() {
+				So(status.Code(err), ShouldEqual, codes.InvalidArgument)
+			})
+		})
+
+		Convey("When completing a whole series", func() {
+			_, err := client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: 1, Completed: proto.Bool(true), Scope: todov1.UpdateScope_UPDATE_SCOPE_SERIES})
+
+			Convey("Then it should fail with InvalidArgument", func() {
+				So(status.Code(err), ShouldEqual, codes.InvalidArgument)
+			})
+		})
+
+		Convey("When updating a todo with an illegal transition", func() {
+			mockStorage.EXPECT().
+				Update(gomock.Any(), gomock.Eq(1), gomock.Eq(storage.Todo{Completed: proto.Bool(true)})).
+				Return(storage.ErrIllegalTransition).
+				Times(1)
+
+			_, err := client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: 1, Completed: proto.Bool(true)})
+
+			Convey("Then it should fail with FailedPrecondition", func() {
+				So(status.Code(err), ShouldEqual, codes.FailedPrecondition)
+			})
+		})
+
+		Convey("When updating a todo that was deleted", func() {
+			mockStorage.EXPECT().Update(gomock.Any(), gomock.Eq(1), gomock.Any()).Return(storage.ErrTodoDeleted).Times(1)
+
+			_, err := client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: 1, Title: proto.String("First")})
+
+			Convey("Then it should fail with NotFound", func() {
+				So(status.Code(err), ShouldEqual, codes.NotFound)
+			})
+		})
+
+		Convey("When creating a todo that already exists", func() {
+			mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).Return(gorm.ErrDuplicatedKey).Times(1)
+
+			_, err := client.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: "First"})
+
+			Convey("Then it should fail with AlreadyExists", func() {
+				So(status.Code(err), ShouldEqual, codes.AlreadyExists)
+			})
+		})
+
+		Convey("When purging a todo", func() {
+			mockStorage.EXPECT().Purge(gomock.Any(), gomock.Eq(1)).Return(nil).Times(1)
+
+			_, err := client.DeleteTodo(ctx, &todov1.DeleteTodoRequest{Id: 1, Purge: true})
+
+			Convey("Then it should purge instead of soft deleting", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When calling without a user", func() {
+			_, err := client.DeleteTodo(context.Background(), &todov1.DeleteTodoRequest{Id: 1})
+
+			Convey("Then it should fail with Unauthenticated", func() {
+				So(status.Code(err), ShouldEqual, codes.Unauthenticated)
+			})
+		})
+
+		Convey("When a health probe calls without a user", func() {
+			res, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+
+			Convey("Then it should be answered", func() {
+				So(err, ShouldBeNil)
+				So(res.GetStatus(), ShouldEqual, healthpb.HealthCheckResponse_SERVING)
+			})
+		})
+
+		Convey("When a health probe watches without a user", func() {
+			stream, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+			So(err, ShouldBeNil)
+			res, err := stream.Recv()
+
+			Convey("Then it should be streamed the status", func() {
+				So(err, ShouldBeNil)
+				So(res.GetStatus(), ShouldEqual, healthpb.HealthCheckResponse_SERVING)
+			})
+		})
+	})
+}
@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// AttachmentConfig limits what can be uploaded as an attachment.
+type AttachmentConfig struct {
+	// MaxSize is the maximum size of an attachment in bytes.
+	MaxSize int64
+	// AllowedTypes lists the accepted MIME types. An entry ending with a slash
+	// such as "image/" accepts the whole top-level type.
+	AllowedTypes []string
+}
+
+func (cfg AttachmentConfig) allows(contentType string) bool {
+	for _, allowed := range cfg.AllowedTypes {
+		if contentType == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(contentType, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+var errAttachmentTooLarge = errors.New("attachment too large")
+
+type AttachmentHandler struct {
+	storage storage.AttachmentStorage
+	config  AttachmentConfig
+}
+
+type GetAttachmentRes struct {
+	ID          uint      `json:"id"`
+	TodoID      uint      `json:"todoId"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func newGetAttachmentRes(attachment storage.Attachment) GetAttachmentRes {
+	return GetAttachmentRes{
+		ID:          attachment.ID,
+		TodoID:      attachment.TodoID,
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		SHA256:      attachment.SHA256,
+		CreatedAt:   attachment.CreatedAt,
+	}
+}
+
+func parseAttachmentParams(c *gin.Context) (int, int, error) {
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, 0, err
+	}
+	id, err := strconv.Atoi(c.Param("attachmentId"))
+	if err != nil {
+		return 0, 0, err
+	}
+	return todoID, id, nil
+}
+
+func (h *AttachmentHandler) Get(c *gin.Context) {
+	todoID, id, err := parseAttachmentParams(c)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	attachment, err := h.storage.Get(c, todoID, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newGetAttachmentRes(attachment))
+}
+
+type ListAttachmentRes []GetAttachmentRes
+
+func (h *AttachmentHandler) List(c *gin.Context) {
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	attachments, err := h.storage.List(c, todoID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	res := make(ListAttachmentRes, 0, len(attachments))
+	for _, attachment := range attachments {
+		res = append(res, newGetAttachmentRes(attachment))
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// Create streams the "file" part of a multipart upload to the storage. The
+// content type is sniffed from the content rather than trusted from the
+// client.
+func (h *AttachmentHandler) Create(c *gin.Context) {
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			err = errors.New("missing file part")
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		content := bufio.NewReaderSize(&maxSizeReader{r: part, n: h.config.MaxSize}, 3072)
+		head, err := content.Peek(3072)
+		if err != nil && err != io.EOF {
+			if errors.Is(err, errAttachmentTooLarge) {
+				c.Error(err)
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, ErrorRes{Error: err.Error()})
+			} else {
+				c.Error(err)
+				c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+			}
+			return
+		}
+
+		contentType, _, _ := mime.ParseMediaType(mimetype.Detect(head).String())
+		if !h.config.allows(contentType) {
+			err := fmt.Errorf("content type %q is not allowed", contentType)
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, ErrorRes{Error: err.Error()})
+			return
+		}
+
+		var attachment storage.Attachment
+		attachment.TodoID = uint(todoID)
+		attachment.Filename = part.FileName()
+		attachment.ContentType = contentType
+
+		if err := h.storage.Create(c, &attachment, content); err != nil {
+			if storage.IsNotFound(err) {
+				c.Error(err)
+				c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+			} else if errors.Is(err, errAttachmentTooLarge) {
+				c.Error(err)
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, ErrorRes{Error: err.Error()})
+			} else {
+				c.Error(err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusCreated, newGetAttachmentRes(attachment))
+		return
+	}
+}
+
+// Download serves the attachment content with support for range and
+// conditional requests.
+func (h *AttachmentHandler) Download(c *gin.Context) {
+	todoID, id, err := parseAttachmentParams(c)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	attachment, err := h.storage.Get(c, todoID, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	content, err := h.storage.Open(c, attachment)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Type", attachment.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	c.Header("ETag", strconv.Quote(attachment.SHA256))
+	http.ServeContent(c.Writer, c.Request, attachment.Filename, attachment.CreatedAt, content)
+}
+
+func (h *AttachmentHandler) Delete(c *gin.Context) {
+	todoID, id, err := parseAttachmentParams(c)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	if err := h.storage.Delete(c, todoID, id); err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func RegisterAttachmentHandler(e *gin.Engine, s storage.AttachmentStorage, cfg AttachmentConfig) error {
+	h := &AttachmentHandler{
+		storage: s,
+		config:  cfg,
+	}
+
+	attachment := e.Group("/todos/:id/attachments")
+	{
+		attachment.GET("", h.List)
+		attachment.POST("", h.Create)
+		attachment.GET("/:attachmentId", h.Get)
+		attachment.GET("/:attachmentId/content", h.Download)
+		attachment.DELETE("/:attachmentId", h.Delete)
+	}
+
+	return nil
+}
+
+// maxSizeReader fails with errAttachmentTooLarge once more than n bytes have
+// been read.
+type maxSizeReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n -= int64(n)
+	if r.n < 0 {
+		return n, errAttachmentTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func newMultipartBody(filename string, content []byte) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	w.WriteField("description", "ignored")
+	part, _ := w.CreateFormFile("file", filename)
+	part.Write(content)
+	w.Close()
+	return body, w.FormDataContentType()
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func TestAttachmentHandler_Create(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given an AttachmentHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockAttachmentStorage(ctrl)
+		e := gin.Default()
+		RegisterAttachmentHandler(e, mockStorage, AttachmentConfig{
+			MaxSize:      1024,
+			AllowedTypes: []string{"image/", "application/pdf"},
+		})
+
+		png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)
+
+		Convey("When uploading an allowed file", func() {
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, attachment *storage.Attachment, content io.Reader) error {
+					b, err := io.ReadAll(content)
+					So(err, ShouldBeNil)
+					So(b, ShouldResemble, png)
+					So(attachment.TodoID, ShouldEqual, 1)
+					attachment.ID = 1
+					attachment.Size = int64(len(b))
+					return nil
+				}).
+				Times(1)
+
+			body, contentType := newMultipartBody("screenshot.bin", png)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/attachments", body)
+			req.Header.Set("Content-Type", contentType)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 201 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusCreated)
+			})
+
+			Convey("And use the sniffed content type", func() {
+				var res GetAttachmentRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(res.Filename, ShouldEqual, "screenshot.bin")
+				So(res.ContentType, ShouldEqual, "image/png")
+				So(res.Size, ShouldEqual, len(png))
+			})
+		})
+
+		Convey("When uploading a file larger than the limit", func() {
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, _ *storage.Attachment, content io.Reader) error {
+					_, err := io.ReadAll(content)
+					return err
+				}).
+				Times(1)
+
+			body, contentType := newMultipartBody("big.png", append(png, make([]byte, 4096)...))
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/attachments", body)
+			req.Header.Set("Content-Type", contentType)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 413 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			})
+		})
+
+		Convey("When uploading a file type that is not allowed", func() {
+			body, contentType := newMultipartBody("notes.png", []byte("just some text"))
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/attachments", body)
+			req.Header.Set("Content-Type", contentType)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 415 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusUnsupportedMediaType)
+			})
+		})
+
+		Convey("When the request is not multipart", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/attachments", bytes.NewBufferString(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When todo is not found", func() {
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(gorm.ErrRecordNotFound).
+				Times(1)
+
+			body, contentType := newMultipartBody("screenshot.png", png)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/999/attachments", body)
+			req.Header.Set("Content-Type", contentType)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestAttachmentHandler_Download(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given an AttachmentHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockAttachmentStorage(ctrl)
+		e := gin.Default()
+		RegisterAttachmentHandler(e, mockStorage, AttachmentConfig{})
+
+		attachment := storage.Attachment{
+			Model:       gorm.Model{ID: 2, CreatedAt: time.Now()},
+			TodoID:      1,
+			Filename:    "notes.txt",
+			ContentType: "text/plain",
+			Size:        12,
+			SHA256:      "09ca7e4eaa6e8ae9c7d261167129184883644d07dfba7cbfbc4c8a2e08360d5b",
+		}
+
+		Convey("When downloading a range of an attachment", func() {
+			mockStorage.EXPECT().
+				Get(gomock.Any(), gomock.Eq(1), gomock.Eq(2)).
+				Return(attachment, nil).
+				Times(1)
+			mockStorage.EXPECT().
+				Open(gomock.Any(), gomock.Eq(attachment)).
+				Return(nopSeekCloser{bytes.NewReader([]byte("hello, world"))}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/1/attachments/2/content", nil)
+			req.Header.Set("Range", "bytes=7-")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 206 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusPartialContent)
+			})
+
+			Convey("And return the requested bytes with metadata headers", func() {
+				So(w.Body.String(), ShouldEqual, "world")
+				So(w.Header().Get("Content-Type"), ShouldEqual, "text/plain")
+				So(w.Header().Get("Content-Range"), ShouldEqual, "bytes 7-11/12")
+				So(w.Header().Get("Content-Disposition"), ShouldEqual, `attachment; filename=notes.txt`)
+			})
+		})
+
+		Convey("When attachment is not found", func() {
+			mockStorage.EXPECT().
+				Get(gomock.Any(), gomock.Eq(1), gomock.Eq(999)).
+				Return(storage.Attachment{}, gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/1/attachments/999/content", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestAttachmentHandler_Delete(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given an AttachmentHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockAttachmentStorage(ctrl)
+		e := gin.Default()
+		RegisterAttachmentHandler(e, mockStorage, AttachmentConfig{})
+
+		Convey("When deleting an attachment", func() {
+			mockStorage.EXPECT().
+				Delete(gomock.Any(), gomock.Eq(1), gomock.Eq(2)).
+				Return(nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/todos/1/attachments/2", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+	})
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// HeaderRequestID carries the ID of a request. A new one is generated when the
+// client does not send it, and it is always echoed in the response.
+const HeaderRequestID = "X-Request-ID"
+
+// AuditMiddleware attaches the current user and the request ID to the request
+// context so that storage mutations can be attributed in the audit trail.
+func AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if id == "" {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Header(HeaderRequestID, id)
+
+		ctx := storage.WithActor(c.Request.Context(), currentUser(c))
+		ctx = storage.WithRequestID(ctx, id)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+type AuditHandler struct {
+	storage storage.AuditStorage
+}
+
+type FieldChangeRes struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+type GetTodoEventRes struct {
+	ID        uint             `json:"id"`
+	TodoID    uint             `json:"todoId"`
+	Action    string           `json:"action"`
+	Actor     string           `json:"actor"`
+	RequestID string           `json:"requestId"`
+	Changes   []FieldChangeRes `json:"changes"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+func newGetTodoEventRes(event storage.TodoEvent) GetTodoEventRes {
+	res := GetTodoEventRes{
+		ID:        event.ID,
+		TodoID:    event.TodoID,
+		Action:    event.Action,
+		Actor:     event.Actor,
+		RequestID: event.RequestID,
+		Changes:   make([]FieldChangeRes, 0, len(event.Changes)),
+		CreatedAt: event.CreatedAt,
+	}
+	for _, change := range event.Changes {
+		res.Changes = append(res.Changes, FieldChangeRes{
+			Field:  change.Field,
+			Before: change.Before,
+			After:  change.After,
+		})
+	}
+	return res
+}
+
+type ListTodoEventRes []GetTodoEventRes
+
+func newListTodoEventRes(events []storage.TodoEvent) ListTodoEventRes {
+	res := make(ListTodoEventRes, 0, len(events))
+	for _, event := range events {
+		res = append(res, newGetTodoEventRes(event))
+	}
+	return res
+}
+
+type ListAuditQuery struct {
+	TodoID    uint      `form:"todoId"`
+	Action    string    `form:"action" binding:"omitempty,oneof=created updated deleted purged"`
+	Actor     string    `form:"actor"`
+	RequestID string    `form:"requestId"`
+	Since     time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until     time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Offset    int       `form:"offset" binding:"min=0"`
+	Limit     int       `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+// List returns the audit trail of all todos, newest first.
+func (h *AuditHandler) List(c *gin.Context) {
+	var query ListAuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	filter := storage.TodoEventFilter{
+		TodoID:    query.TodoID,
+		Action:    query.Action,
+		Actor:     query.Actor,
+		RequestID: query.RequestID,
+	}
+	if !query.Since.IsZero() {
+		filter.Since = &query.Since
+	}
+	if !query.Until.IsZero() {
+		filter.Until = &query.Until
+	}
+
+	events, total, err := h.storage.List(c, filter, query.Offset, query.Limit)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	c.Header(HeaderTotalCount, strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, newListTodoEventRes(events))
+}
+
+func RegisterAuditHandler(e *gin.Engine, s storage.AuditStorage) error {
+	h := &AuditHandler{
+		storage: s,
+	}
+
+	audit := e.Group("/audit")
+	{
+		audit.GET("", h.List)
+	}
+
+	return nil
+}
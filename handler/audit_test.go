@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestAuditHandler_List(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given an AuditHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockAuditStorage(ctrl)
+		e := gin.Default()
+		RegisterAuditHandler(e, mockStorage)
+
+		Convey("When listing the audit trail with filters", func() {
+			since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			mockStorage.EXPECT().
+				List(gomock.Any(), gomock.Eq(storage.TodoEventFilter{
+					TodoID: 1,
+					Action: storage.TodoEventDeleted,
+					Actor:  "alice",
+					Since:  &since,
+				}), gomock.Eq(0), gomock.Eq(20)).
+				Return([]storage.TodoEvent{
+					{ID: 3, TodoID: 1, Action: storage.TodoEventDeleted, Actor: "alice"},
+				}, int64(1), nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/audit?todoId=1&action=deleted&actor=alice&since=2024-01-01T00:00:00Z", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 200 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And return the matching events with the total count", func() {
+				var res ListTodoEventRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(len(res), ShouldEqual, 1)
+				So(res[0].Action, ShouldEqual, storage.TodoEventDeleted)
+				So(w.Header().Get(HeaderTotalCount), ShouldEqual, "1")
+			})
+		})
+
+		Convey("When filtering by an unknown action", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/audit?action=renamed", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given an engine using the audit middleware", t, func() {
+		e := gin.New()
+		e.Use(AuditMiddleware())
+		e.GET("/", func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+
+		Convey("When the client sends a request ID", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(HeaderRequestID, "req-1")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should echo the request ID", func() {
+				So(w.Header().Get(HeaderRequestID), ShouldEqual, "req-1")
+			})
+		})
+
+		Convey("When the client sends no request ID", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should generate one", func() {
+				So(w.Header().Get(HeaderRequestID), ShouldHaveLength, 32)
+			})
+		})
+	})
+}
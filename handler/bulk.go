@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Types of the operations of a bulk request.
+const (
+	BulkOpCreate = "create"
+	BulkOpUpdate = "update"
+	BulkOpDelete = "delete"
+)
+
+// Modes of a bulk request.
+const (
+	BulkModeAtomic     = "atomic"
+	BulkModeBestEffort = "best_effort"
+)
+
+// BulkConfig limits the size of bulk requests.
+type BulkConfig struct {
+	MaxBatchSize int
+}
+
+type BulkTodoQuery struct {
+	Mode string `form:"mode,default=atomic" binding:"oneof=atomic best_effort"`
+}
+
+// BulkTodoOpReq is an operation of a bulk request. Data holds a CreateTodoReq
+// or an UpdateTodoReq, and ID the todo to update or delete.
+type BulkTodoOpReq struct {
+	Op   string          `json:"op" binding:"required,oneof=create update delete"`
+	ID   int             `json:"id" binding:"required_unless=Op create"`
+	Data json.RawMessage `json:"data"`
+}
+
+// BulkTodoResultRes is the result of an operation. Status is the status code
+// the equivalent REST request would have returned, or 424 for the operations
+// of a failed atomic request.
+type BulkTodoResultRes struct {
+	Index  int         `json:"index"`
+	Status int         `json:"status"`
+	Todo   *GetTodoRes `json:"todo,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type BulkTodoRes struct {
+	Results []BulkTodoResultRes `json:"results"`
+}
+
+// bulkTodoOp is a validated operation. Todo holds the fields to create or
+// update.
+type bulkTodoOp struct {
+	Type string
+	ID   int
+	Todo storage.Todo
+}
+
+// errBulkAborted is reported for the operations of an atomic request that
+// were rolled back or not run because another operation failed.
+var errBulkAborted = errors.New("aborted by another failed operation")
+
+type BulkHandler struct {
+	storage storage.TodoStorage
+	tx      storage.TxManager
+	config  BulkConfig
+}
+
+// Todos runs a batch of todo operations in order. In atomic mode nothing is
+// applied unless every operation succeeds, and the response has the status of
+// the failed operation. In best-effort mode each operation is applied on its
+// own and the response is 200 whatever the results.
+func (h *BulkHandler) Todos(c *gin.Context) {
+	var query BulkTodoQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var req []BulkTodoOpReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+	if len(req) == 0 {
+		err := errors.New("no operations")
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+	if len(req) > h.config.MaxBatchSize {
+		err := fmt.Errorf("at most %d operations can be run at once", h.config.MaxBatchSize)
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, ErrorRes{Error: err.Error()})
+		return
+	}
+	atomic := query.Mode == BulkModeAtomic
+
+	// Invalid operations are reported without being sent to storage, which
+	// only gets the valid ones. indexes maps them back to the request.
+	res := BulkTodoRes{Results: make([]BulkTodoResultRes, len(req))}
+	ops := make([]bulkTodoOp, 0, len(req))
+	indexes := make([]int, 0, len(req))
+	for i, item := range req {
+		op, err := newBulkTodoOp(item)
+		if err != nil {
+			res.Results[i] = newBulkErrorRes(i, http.StatusBadRequest, err)
+			continue
+		}
+		ops = append(ops, op)
+		indexes = append(indexes, i)
+	}
+	if atomic && len(ops) < len(req) {
+		for i := range res.Results {
+			if res.Results[i].Status == 0 {
+				res.Results[i] = newBulkErrorRes(i, http.StatusFailedDependency, errBulkAborted)
+			}
+		}
+		c.JSON(http.StatusBadRequest, res)
+		return
+	}
+
+	errs, err := h.run(c, ops, atomic)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	for j, err := range errs {
+		i := indexes[j]
+		if err != nil {
+			code := http.StatusFailedDependency
+			if !errors.Is(err, errBulkAborted) {
+				code = todoErrorStatus(err)
+				if atomic {
+					status = code
+				}
+			}
+			if code == http.StatusInternalServerError {
+				c.Error(err)
+			}
+			res.Results[i] = newBulkErrorRes(i, code, err)
+			continue
+		}
+		res.Results[i] = newBulkResultRes(i, ops[j])
+	}
+
+	c.JSON(status, res)
+}
+
+// run applies ops in order and returns the error of each one. In atomic mode
+// they share a transaction rolled back on the first failure, the other
+// operations reporting errBulkAborted. The returned error is set when the
+// transaction itself failed.
+func (h *BulkHandler) run(ctx context.Context, ops []bulkTodoOp, atomic bool) ([]error, error) {
+	errs := make([]error, len(ops))
+	if !atomic {
+		for i := range ops {
+			errs[i] = h.apply(ctx, &ops[i])
+		}
+		return errs, nil
+	}
+
+	failed := -1
+	err := h.tx.WithinTx(ctx, func(ctx context.Context) error {
+		for i := range ops {
+			if err := h.apply(ctx, &ops[i]); err != nil {
+				failed = i
+				return err
+			}
+		}
+		return nil
+	})
+	if failed < 0 {
+		return errs, err
+	}
+	for i := range errs {
+		errs[i] = errBulkAborted
+	}
+	errs[failed] = err
+	return errs, nil
+}
+
+// apply runs op and replaces its todo by the resulting one.
+func (h *BulkHandler) apply(ctx context.Context, op *bulkTodoOp) error {
+	switch op.Type {
+	case BulkOpCreate:
+		return h.storage.Create(ctx, &op.Todo)
+	case BulkOpUpdate:
+		if err := h.storage.Update(ctx, op.ID, op.Todo); err != nil {
+			return err
+		}
+		todo, err := h.storage.Get(ctx, op.ID)
+		op.Todo = todo
+		return err
+	default:
+		return h.storage.Delete(ctx, op.ID)
+	}
+}
+
+func newBulkTodoOp(item BulkTodoOpReq) (bulkTodoOp, error) {
+	op := bulkTodoOp{Type: item.Op, ID: item.ID}
+
+	switch item.Op {
+	case BulkOpCreate:
+		var data CreateTodoReq
+		if err := bindData(item.Data, &data); err != nil {
+			return op, err
+		}
+		op.Todo.Title = data.Title
+		op.Todo.Description = data.Description
+		op.Todo.DueAt = data.DueAt
+		op.Todo.RRule = data.RRule
+		op.Todo.ProjectID = data.ProjectID
+	case BulkOpUpdate:
+		var data UpdateTodoReq
+		if err := bindData(item.Data, &data); err != nil {
+			return op, err
+		}
+		op.Todo.Title = data.Title
+		op.Todo.Description = data.Description
+		op.Todo.Completed = data.Completed
+		op.Todo.DueAt = data.DueAt
+		op.Todo.RRule = data.RRule
+	}
+	return op, nil
+}
+
+func newBulkResultRes(index int, op bulkTodoOp) BulkTodoResultRes {
+	switch op.Type {
+	case BulkOpCreate:
+		return BulkTodoResultRes{Index: index, Status: http.StatusCreated, Todo: ptr(newGetTodoRes(op.Todo))}
+	case BulkOpUpdate:
+		return BulkTodoResultRes{Index: index, Status: http.StatusOK, Todo: ptr(newGetTodoRes(op.Todo))}
+	default:
+		return BulkTodoResultRes{Index: index, Status: http.StatusNoContent}
+	}
+}
+
+func newBulkErrorRes(index, status int, err error) BulkTodoResultRes {
+	return BulkTodoResultRes{Index: index, Status: status, Error: err.Error()}
+}
+
+func RegisterBulkHandler(e *gin.Engine, s storage.TodoStorage, tx storage.TxManager, cfg BulkConfig) error {
+	h := &BulkHandler{
+		storage: s,
+		tx:      tx,
+		config:  cfg,
+	}
+
+	e.POST("/todos/bulk", h.Todos)
+
+	return nil
+}
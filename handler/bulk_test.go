@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestBulkHandler_Todos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a BulkHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		mockTx := mock.NewMockTxManager(ctrl)
+		e := gin.Default()
+		RegisterBulkHandler(e, mockStorage, mockTx, BulkConfig{MaxBatchSize: 3})
+
+		type txKey struct{}
+		withinTx := func(ctx context.Context, fn func(context.Context) error) error {
+			return fn(context.WithValue(ctx, txKey{}, true))
+		}
+		inTx := gomock.Cond(func(ctx context.Context) bool { return ctx.Value(txKey{}) != nil })
+
+		do := func(url, body string) (*httptest.ResponseRecorder, BulkTodoRes) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			var res BulkTodoRes
+			json.Unmarshal(w.Body.Bytes(), &res)
+			return w, res
+		}
+
+		body := `[
+			{"op": "create", "data": {"title": "new"}},
+			{"op": "update", "id": 1, "data": {"title": "renamed"}},
+			{"op": "delete", "id": 2}
+		]`
+
+		Convey("When running operations atomically", func() {
+			mockTx.EXPECT().WithinTx(gomock.Any(), gomock.Any()).DoAndReturn(withinTx).Times(1)
+			gomock.InOrder(
+				mockStorage.EXPECT().
+					Create(inTx, gomock.Eq(&storage.Todo{Title: "new"})).
+					DoAndReturn(func(_ context.Context, todo *storage.Todo) error {
+						todo.ID = 3
+						return nil
+					}),
+				mockStorage.EXPECT().Update(inTx, gomock.Eq(1), gomock.Eq(storage.Todo{Title: "renamed"})).Return(nil),
+				mockStorage.EXPECT().Get(inTx, gomock.Eq(1)).Return(storage.Todo{Model: gorm.Model{ID: 1}, Title: "renamed"}, nil),
+				mockStorage.EXPECT().Delete(inTx, gomock.Eq(2)).Return(nil),
+			)
+
+			w, res := do("/todos/bulk", body)
+
+			Convey("Then it should run them in a single transaction and return the result of each", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(res.Results, ShouldHaveLength, 3)
+				So(res.Results[0].Status, ShouldEqual, http.StatusCreated)
+				So(res.Results[0].Todo.ID, ShouldEqual, 3)
+				So(res.Results[1].Status, ShouldEqual, http.StatusOK)
+				So(res.Results[1].Todo.Title, ShouldEqual, "renamed")
+				So(res.Results[2].Status, ShouldEqual, http.StatusNoContent)
+				So(res.Results[2].Index, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When an atomic operation fails", func() {
+			mockTx.EXPECT().WithinTx(gomock.Any(), gomock.Any()).DoAndReturn(withinTx).Times(1)
+			mockStorage.EXPECT().Create(inTx, gomock.Any()).Return(nil).Times(1)
+			mockStorage.EXPECT().Update(inTx, gomock.Eq(1), gomock.Any()).Return(gorm.ErrRecordNotFound).Times(1)
+
+			w, res := do("/todos/bulk", body)
+
+			Convey("Then it should stop and return the status of the failed operation", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+				So(res.Results[0].Status, ShouldEqual, http.StatusFailedDependency)
+				So(res.Results[1].Status, ShouldEqual, http.StatusNotFound)
+				So(res.Results[2].Status, ShouldEqual, http.StatusFailedDependency)
+			})
+		})
+
+		Convey("When an operation is invalid in atomic mode", func() {
+			w, res := do("/todos/bulk", `[{"op": "create", "data": {}}, {"op": "delete", "id": 2}]`)
+
+			Convey("Then nothing should be run", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(res.Results[0].Status, ShouldEqual, http.StatusBadRequest)
+				So(res.Results[1].Status, ShouldEqual, http.StatusFailedDependency)
+			})
+		})
+
+		Convey("When an operation is invalid in best-effort mode", func() {
+			mockStorage.EXPECT().
+				Delete(gomock.Not(inTx), gomock.Eq(2)).
+				Return(nil).
+				Times(1)
+
+			w, res := do("/todos/bulk?mode=best_effort", `[{"op": "create", "data": {}}, {"op": "delete", "id": 2}]`)
+
+			Convey("Then the valid operations should still be run", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(res.Results[0].Status, ShouldEqual, http.StatusBadRequest)
+				So(res.Results[1].Status, ShouldEqual, http.StatusNoContent)
+				So(res.Results[1].Index, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When an update does not name its todo", func() {
+			w, _ := do("/todos/bulk", `[{"op": "update", "data": {"title": "renamed"}}]`)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When sending more operations than allowed", func() {
+			w, _ := do("/todos/bulk", `[{"op": "delete", "id": 1}, {"op": "delete", "id": 2}, {"op": "delete", "id": 3}, {"op": "delete", "id": 4}]`)
+
+			Convey("Then it should return 413 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			})
+		})
+
+		Convey("When using an unknown mode", func() {
+			w, _ := do("/todos/bulk?mode=eventually", body)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
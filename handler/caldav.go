@@ -0,0 +1,754 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-ical"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/wei840222/go-restful-sample/caldav"
+	"github.com/wei840222/go-restful-sample/calendar"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Paths of the CalDAV resources. The root is the principal of every user and
+// the home holds a calendar per project, whose objects are named after the
+// UID of their todo.
+const (
+	caldavRoot = "/caldav/"
+	caldavHome = caldavRoot + "projects/"
+)
+
+const caldavAllow = "OPTIONS, GET, PUT, DELETE, PROPFIND, REPORT"
+
+const caldavSyncPageSize = 500
+
+// CalDAVConfig holds the accounts allowed to sync, as user names mapped to
+// the bcrypt hashes of their passwords.
+type CalDAVConfig struct {
+	Realm string
+	Users map[string]string
+}
+
+type CalDAVHandler struct {
+	todos    storage.TodoStorage
+	projects storage.ProjectStorage
+	sync     storage.SyncStorage
+	tx       storage.TxManager
+	workflow *storage.Workflow
+}
+
+func calendarHref(projectID uint) string {
+	return fmt.Sprintf("%s%d/", caldavHome, projectID)
+}
+
+func objectHref(projectID uint, todo storage.Todo) string {
+	return calendarHref(projectID) + url.PathEscape(calendar.UID(todo)) + ".ics"
+}
+
+// abortWithCondition fails a request on a WebDAV precondition, which clients
+// read from an XML error body.
+func abortWithCondition(c *gin.Context, status int, condition xml.Name, err error) {
+	c.Error(err)
+	c.Header("Content-Type", "application/xml; charset=utf-8")
+	c.Status(status)
+	caldav.EncodeError(c.Writer, condition)
+	c.Abort()
+}
+
+func writeMultistatus(c *gin.Context, responses []caldav.Response, syncToken string) {
+	c.Header("Content-Type", "application/xml; charset=utf-8")
+	c.Status(http.StatusMultiStatus)
+	if err := caldav.EncodeMultistatus(c.Writer, responses, syncToken); err != nil {
+		c.Error(err)
+	}
+}
+
+func (h *CalDAVHandler) Options(c *gin.Context) {
+	c.Header(caldav.HeaderDAV, caldav.Compliance)
+	c.Header("Allow", caldavAllow)
+	c.Status(http.StatusOK)
+}
+
+// render returns the iCalendar document of todo, as served and hashed into
+// its ETag.
+func (h *CalDAVHandler) render(todo storage.Todo) ([]byte, error) {
+	cal := calendar.NewCalendar("")
+	cal.Children = append(cal.Children, calendar.NewToDo(todo, h.workflow))
+	var buf bytes.Buffer
+	if err := calendar.Encode(&buf, cal); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (h *CalDAVHandler) principalProps(c *gin.Context) []caldav.Property {
+	return []caldav.Property{
+		caldav.NewElementsProperty(caldav.PropResourceType, caldav.Collection, caldav.Principal),
+		caldav.NewTextProperty(caldav.PropDisplayName, c.GetString(gin.AuthUserKey)),
+		caldav.NewHrefProperty(caldav.PropCurrentUserPrincipal, caldavRoot),
+		caldav.NewHrefProperty(caldav.PropPrincipalURL, caldavRoot),
+		caldav.NewHrefProperty(caldav.PropCalendarHomeSet, caldavHome),
+	}
+}
+
+func (h *CalDAVHandler) homeProps() []caldav.Property {
+	return []caldav.Property{
+		caldav.NewElementsProperty(caldav.PropResourceType, caldav.Collection),
+		caldav.NewTextProperty(caldav.PropDisplayName, "Projects"),
+		caldav.NewHrefProperty(caldav.PropCurrentUserPrincipal, caldavRoot),
+	}
+}
+
+// calendarProps returns the properties of the calendar of a project. Its sync
+// token is the current version of the audit trail, which any change to any
+// todo moves on.
+func (h *CalDAVHandler) calendarProps(project storage.Project, version uint) []caldav.Property {
+	props := []caldav.Property{
+		caldav.NewElementsProperty(caldav.PropResourceType, caldav.Collection, caldav.Calendar),
+		caldav.NewTextProperty(caldav.PropDisplayName, project.Name),
+		caldav.NewHrefProperty(caldav.PropCurrentUserPrincipal, caldavRoot),
+		caldav.NewComponentSetProperty(ical.CompToDo),
+		caldav.NewReportSetProperty(caldav.ReportCalendarMultiget, caldav.ReportCalendarQuery, caldav.ReportSyncCollection),
+		caldav.NewPrivilegeSetProperty(caldav.PrivilegeRead, caldav.PrivilegeWrite, caldav.PrivilegeWriteContent, caldav.PrivilegeBind, caldav.PrivilegeUnbind),
+		caldav.NewTextProperty(caldav.PropSyncToken, caldav.SyncToken(version)),
+		caldav.NewTextProperty(caldav.PropGetCTag, caldav.SyncToken(version)),
+	}
+	if project.Color != "" {
+		props = append(props, caldav.NewTextProperty(caldav.PropCalendarColor, project.Color))
+	}
+	return props
+}
+
+func (h *CalDAVHandler) objectProps(data []byte) []caldav.Property {
+	return []caldav.Property{
+		caldav.NewElementsProperty(caldav.PropResourceType),
+		caldav.NewTextProperty(caldav.PropGetETag, caldav.ETag(data)),
+		caldav.NewTextProperty(caldav.PropGetContentType, calendar.MIMEType+"; charset=utf-8; component="+ical.CompToDo),
+		caldav.NewTextProperty(caldav.PropCalendarData, string(data)),
+	}
+}
+
+func (h *CalDAVHandler) objectResponse(projectID uint, todo storage.Todo, req caldav.PropRequest) (caldav.Response, error) {
+	data, err := h.render(todo)
+	if err != nil {
+		return caldav.Response{}, err
+	}
+	return caldav.NewResponse(objectHref(projectID, todo), h.objectProps(data), req), nil
+}
+
+// project returns the project of the calendar the request is about.
+func (h *CalDAVHandler) project(c *gin.Context) (storage.Project, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		return storage.Project{}, false
+	}
+
+	project, err := h.projects.Get(c, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return project, false
+	}
+	return project, true
+}
+
+// find returns the todo of the project named uid, and whether there is one.
+func (h *CalDAVHandler) find(ctx context.Context, projectID uint, uid string) (storage.Todo, bool, error) {
+	if id, ok := calendar.TodoID(uid); ok {
+		todo, err := h.todos.Get(ctx, int(id))
+		if storage.IsNotFound(err) {
+			return todo, false, nil
+		} else if err != nil {
+			return todo, false, err
+		}
+		return todo, todo.ProjectID != nil && *todo.ProjectID == projectID, nil
+	}
+
+	var todo storage.Todo
+	found := false
+	filter := storage.TodoFilter{ProjectID: &projectID, ExternalSource: calendar.ExternalSource, ExternalID: uid}
+	err := h.todos.Each(ctx, filter, func(t storage.Todo) error {
+		todo, found = t, true
+		return nil
+	})
+	return todo, found, err
+}
+
+// object returns the todo the request is about along with its document.
+func (h *CalDAVHandler) object(c *gin.Context, projectID uint) (storage.Todo, []byte, bool) {
+	todo, found, err := h.find(c, projectID, strings.TrimSuffix(c.Param("name"), ".ics"))
+	if err == nil && !found {
+		err = errObjectNotFound
+	}
+	var data []byte
+	if err == nil {
+		data, err = h.render(todo)
+	}
+	if err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return todo, nil, false
+	}
+	return todo, data, true
+}
+
+func readPropfind(c *gin.Context) (caldav.PropRequest, string, bool) {
+	depth, err := caldav.ParseDepth(c.GetHeader(caldav.HeaderDepth))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return caldav.PropRequest{}, "", false
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return caldav.PropRequest{}, "", false
+	}
+	req, err := caldav.ParsePropfind(body)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return req, "", false
+	}
+	return req, depth, true
+}
+
+// PropfindPrincipal describes the principal of the user, which points clients
+// to the calendar home.
+func (h *CalDAVHandler) PropfindPrincipal(c *gin.Context) {
+	req, _, ok := readPropfind(c)
+	if !ok {
+		return
+	}
+
+	writeMultistatus(c, []caldav.Response{caldav.NewResponse(caldavRoot, h.principalProps(c), req)}, "")
+}
+
+// PropfindHome describes the calendar home and, at depth 1, the calendars of
+// the projects that are not archived.
+func (h *CalDAVHandler) PropfindHome(c *gin.Context) {
+	req, depth, ok := readPropfind(c)
+	if !ok {
+		return
+	}
+
+	responses := []caldav.Response{caldav.NewResponse(caldavHome, h.homeProps(), req)}
+	if depth != caldav.DepthZero {
+		projects, err := h.projects.List(c)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			return
+		}
+		version, err := h.sync.CurrentVersion(c)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			return
+		}
+		for _, project := range projects {
+			if project.Archived != nil && *project.Archived {
+				continue
+			}
+			responses = append(responses, caldav.NewResponse(calendarHref(project.ID), h.calendarProps(project, version), req))
+		}
+	}
+
+	writeMultistatus(c, responses, "")
+}
+
+// PropfindCalendar describes the calendar of a project and, at depth 1, its
+// objects.
+func (h *CalDAVHandler) PropfindCalendar(c *gin.Context) {
+	project, ok := h.project(c)
+	if !ok {
+		return
+	}
+	req, depth, ok := readPropfind(c)
+	if !ok {
+		return
+	}
+
+	version, err := h.sync.CurrentVersion(c)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	responses := []caldav.Response{caldav.NewResponse(calendarHref(project.ID), h.calendarProps(project, version), req)}
+	if depth != caldav.DepthZero {
+		err := h.todos.Each(c, storage.TodoFilter{ProjectID: &project.ID}, func(todo storage.Todo) error {
+			res, err := h.objectResponse(project.ID, todo, req)
+			responses = append(responses, res)
+			return err
+		})
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			return
+		}
+	}
+
+	writeMultistatus(c, responses, "")
+}
+
+func (h *CalDAVHandler) PropfindObject(c *gin.Context) {
+	project, ok := h.project(c)
+	if !ok {
+		return
+	}
+	req, _, ok := readPropfind(c)
+	if !ok {
+		return
+	}
+
+	todo, data, ok := h.object(c, project.ID)
+	if !ok {
+		return
+	}
+
+	writeMultistatus(c, []caldav.Response{caldav.NewResponse(objectHref(project.ID, todo), h.objectProps(data), req)}, "")
+}
+
+// Report answers the calendar-multiget, calendar-query and sync-collection
+// reports on the calendar of a project.
+func (h *CalDAVHandler) Report(c *gin.Context) {
+	project, ok := h.project(c)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+	report, err := caldav.ParseReport(body)
+	if err != nil {
+		if errors.Is(err, caldav.ErrUnsupportedReport) {
+			abortWithCondition(c, http.StatusForbidden, caldav.ConditionSupportedReport, err)
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	switch report.Name {
+	case caldav.ReportCalendarMultiget:
+		h.multiget(c, project, report)
+	case caldav.ReportCalendarQuery:
+		h.query(c, project, report)
+	case caldav.ReportSyncCollection:
+		h.syncCollection(c, project, report)
+	}
+}
+
+// multiget returns the objects at the hrefs of the report, and reports those
+// that are not found.
+func (h *CalDAVHandler) multiget(c *gin.Context, project storage.Project, report caldav.Report) {
+	responses := make([]caldav.Response, 0, len(report.Hrefs))
+	for _, href := range report.Hrefs {
+		u, err := url.Parse(strings.TrimSpace(href))
+		if err != nil {
+			responses = append(responses, caldav.NewStatusResponse(href, http.StatusBadRequest))
+			continue
+		}
+		name, ok := strings.CutPrefix(u.Path, calendarHref(project.ID))
+		if !ok || !strings.HasSuffix(name, ".ics") {
+			responses = append(responses, caldav.NewStatusResponse(href, http.StatusNotFound))
+			continue
+		}
+
+		todo, found, err := h.find(c, project.ID, strings.TrimSuffix(name, ".ics"))
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			return
+		}
+		if !found {
+			responses = append(responses, caldav.NewStatusResponse(href, http.StatusNotFound))
+			continue
+		}
+		res, err := h.objectResponse(project.ID, todo, report.Props)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			return
+		}
+		responses = append(responses, res)
+	}
+
+	writeMultistatus(c, responses, "")
+}
+
+// query returns every object of the calendar unless the report only asks for
+// components other than VTODOs, which calendars do not hold.
+func (h *CalDAVHandler) query(c *gin.Context, project storage.Project, report caldav.Report) {
+	responses := []caldav.Response{}
+	todos := len(report.Components) == 0
+	for _, component := range report.Components {
+		todos = todos || component == ical.CompToDo
+	}
+
+	if todos {
+		err := h.todos.Each(c, storage.TodoFilter{ProjectID: &project.ID}, func(todo storage.Todo) error {
+			res, err := h.objectResponse(project.ID, todo, report.Props)
+			responses = append(responses, res)
+			return err
+		})
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			return
+		}
+	}
+
+	writeMultistatus(c, responses, "")
+}
+
+// syncCollection returns the objects changed since the sync token of the
+// report, or all of them without one. The todos deleted or moved out of the
+// project are reported as not found, and so may be todos that were never part
+// of it since the audit trail is not kept per project.
+func (h *CalDAVHandler) syncCollection(c *gin.Context, project storage.Project, report caldav.Report) {
+	responses := []caldav.Response{}
+
+	if report.SyncToken == "" {
+		// The version is read first so that changes made meanwhile are
+		// synced again next time rather than missed.
+		version, err := h.sync.CurrentVersion(c)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			return
+		}
+		err = h.todos.Each(c, storage.TodoFilter{ProjectID: &project.ID}, func(todo storage.Todo) error {
+			res, err := h.objectResponse(project.ID, todo, report.Props)
+			responses = append(responses, res)
+			return err
+		})
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			return
+		}
+
+		writeMultistatus(c, responses, caldav.SyncToken(version))
+		return
+	}
+
+	version, err := caldav.ParseSyncToken(report.SyncToken)
+	if err != nil {
+		abortWithCondition(c, http.StatusForbidden, caldav.ConditionValidSyncToken, err)
+		return
+	}
+	for {
+		changes, err := h.sync.ListChanges(c, version, version, caldavSyncPageSize)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			return
+		}
+
+		for _, change := range changes {
+			version = change.Version
+			todo := change.Todo
+			todo.ID = change.TodoID
+			if change.Deleted || todo.ProjectID == nil || *todo.ProjectID != project.ID {
+				responses = append(responses, caldav.NewStatusResponse(objectHref(project.ID, todo), http.StatusNotFound))
+				continue
+			}
+			res, err := h.objectResponse(project.ID, todo, report.Props)
+			if err != nil {
+				c.Error(err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+				return
+			}
+			responses = append(responses, res)
+		}
+		if len(changes) < caldavSyncPageSize {
+			break
+		}
+	}
+
+	writeMultistatus(c, responses, caldav.SyncToken(version))
+}
+
+func (h *CalDAVHandler) GetObject(c *gin.Context) {
+	project, ok := h.project(c)
+	if !ok {
+		return
+	}
+
+	_, data, ok := h.object(c, project.ID)
+	if !ok {
+		return
+	}
+
+	c.Header("ETag", caldav.ETag(data))
+	c.Data(http.StatusOK, calendar.MIMEType+"; charset=utf-8", data)
+}
+
+// preconditionFailed tells whether the If-Match and If-None-Match headers of
+// a request rule out changing an object. etag is empty for an object that does
+// not exist.
+func preconditionFailed(c *gin.Context, etag string) bool {
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		if etag == "" || (ifMatch != "*" && ifMatch != etag) {
+			return true
+		}
+	}
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		if etag != "" && (ifNoneMatch == "*" || ifNoneMatch == etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// PutObject creates or replaces the todo of a VTODO. The name of the object
+// must be the UID of the VTODO, as clients pick it. The response carries no
+// ETag since the stored todo is not rendered back as it was sent, which tells
+// clients to fetch it again.
+func (h *CalDAVHandler) PutObject(c *gin.Context) {
+	project, ok := h.project(c)
+	if !ok {
+		return
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(c.ContentType()); err != nil || mediaType != calendar.MIMEType {
+		err := fmt.Errorf("unsupported content type %q", c.ContentType())
+		abortWithCondition(c, http.StatusUnsupportedMediaType, caldav.ConditionSupportedCalendarData, err)
+		return
+	}
+	cal, err := ical.NewDecoder(c.Request.Body).Decode()
+	if err != nil {
+		abortWithCondition(c, http.StatusBadRequest, caldav.ConditionValidCalendarData, err)
+		return
+	}
+	var todos []*ical.Component
+	for _, child := range cal.Children {
+		if child.Name == ical.CompToDo {
+			todos = append(todos, child)
+		}
+	}
+	if len(todos) != 1 {
+		err := fmt.Errorf("calendar objects hold a single %s", ical.CompToDo)
+		abortWithCondition(c, http.StatusForbidden, caldav.ConditionSupportedCalendarComponent, err)
+		return
+	}
+	rec, err := calendar.ParseToDo(todos[0])
+	if err != nil {
+		abortWithCondition(c, http.StatusBadRequest, caldav.ConditionValidCalendarData, err)
+		return
+	}
+	uid := strings.TrimSuffix(c.Param("name"), ".ics")
+	if rec.ExternalID != uid {
+		abortWithCondition(c, http.StatusBadRequest, caldav.ConditionValidCalendarData, errUIDMismatch)
+		return
+	}
+
+	status := http.StatusNoContent
+	err = h.tx.WithinTx(c, func(ctx context.Context) error {
+		current, found, err := h.find(ctx, project.ID, uid)
+		if err != nil {
+			return err
+		}
+		etag := ""
+		if found {
+			data, err := h.render(current)
+			if err != nil {
+				return err
+			}
+			etag = caldav.ETag(data)
+		}
+		if preconditionFailed(c, etag) {
+			return errPreconditionFailed
+		}
+
+		todo := rec.Todo()
+		todo.ProjectID = &project.ID
+		if etag != "" {
+			// VTODOs do not carry the priority and tags, so they are kept.
+			todo.Priority = current.Priority
+			todo.Tags = current.Tags
+			return h.todos.Replace(ctx, int(current.ID), todo)
+		}
+		if _, ok := calendar.TodoID(uid); ok {
+			return fmt.Errorf("%w: %s", errUIDConflict, uid)
+		}
+		// A todo of another project with the UID is replaced and moved here.
+		if _, err := h.todos.Upsert(ctx, &todo); err != nil {
+			return err
+		}
+		status = http.StatusCreated
+		if todo.ProjectID == nil || *todo.ProjectID != project.ID {
+			return h.todos.MoveToProject(ctx, int(todo.ID), &project.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errPreconditionFailed):
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusPreconditionFailed, ErrorRes{Error: err.Error()})
+		case errors.Is(err, errUIDConflict):
+			abortWithCondition(c, http.StatusConflict, caldav.ConditionNoUIDConflict, err)
+		default:
+			c.Error(err)
+			c.AbortWithStatusJSON(todoErrorStatus(err), ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.Status(status)
+}
+
+func (h *CalDAVHandler) DeleteObject(c *gin.Context) {
+	project, ok := h.project(c)
+	if !ok {
+		return
+	}
+
+	todo, data, ok := h.object(c, project.ID)
+	if !ok {
+		return
+	}
+	if preconditionFailed(c, caldav.ETag(data)) {
+		c.Error(errPreconditionFailed)
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, ErrorRes{Error: errPreconditionFailed.Error()})
+		return
+	}
+
+	if err := h.todos.Delete(c, int(todo.ID)); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(todoErrorStatus(err), ErrorRes{Error: err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+var (
+	errObjectNotFound     = errors.New("calendar object not found")
+	errPreconditionFailed = errors.New("the object does not match the precondition")
+	errUIDConflict        = errors.New("todos with a generated UID cannot be created")
+	errUIDMismatch        = errors.New("the UID of the todo must be the name of its resource")
+)
+
+// caldavAuth authenticates the users of cfg with basic auth. Passwords are
+// checked against their bcrypt hash, and against dummy for unknown users so
+// that the response time does not tell which users exist.
+func caldavAuth(cfg CalDAVConfig, dummy []byte) gin.HandlerFunc {
+	challenge := "Basic realm=" + strconv.Quote(cfg.Realm)
+	return func(c *gin.Context) {
+		user, password, ok := c.Request.BasicAuth()
+		hash, known := cfg.Users[user]
+		if !known {
+			hash = string(dummy)
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil || !ok || !known {
+			c.Header("WWW-Authenticate", challenge)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set(gin.AuthUserKey, user)
+		c.Next()
+	}
+}
+
+// caldavActor records the authenticated user as the author of the changes.
+func caldavActor(c *gin.Context) {
+	ctx := storage.WithActor(c.Request.Context(), c.GetString(gin.AuthUserKey))
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+// RegisterCalDAVHandler serves each project as a task calendar under
+// /caldav/, behind basic auth. Without any account CalDAV is disabled.
+func RegisterCalDAVHandler(e *gin.Engine, todos storage.TodoStorage, projects storage.ProjectStorage, s storage.SyncStorage, tx storage.TxManager, wf *storage.Workflow, cfg CalDAVConfig) error {
+	if len(cfg.Users) == 0 {
+		log.Info().Msg("caldav is disabled as no user is configured")
+		return nil
+	}
+
+	// The dummy hash costs as much to check as the most expensive one.
+	cost := bcrypt.MinCost
+	for user, hash := range cfg.Users {
+		c, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return fmt.Errorf("caldav user %q: password is not a bcrypt hash: %w", user, err)
+		}
+		cost = max(cost, c)
+	}
+	dummy, err := bcrypt.GenerateFromPassword(nil, cost)
+	if err != nil {
+		return err
+	}
+
+	h := &CalDAVHandler{
+		todos:    todos,
+		projects: projects,
+		sync:     s,
+		tx:       tx,
+		workflow: wf,
+	}
+
+	// Clients discover the principal from the well-known URL (RFC 6764).
+	wellKnown := func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, caldavRoot)
+	}
+	e.GET("/.well-known/caldav", wellKnown)
+	e.Handle(caldav.MethodPropfind, "/.well-known/caldav", wellKnown)
+
+	dav := e.Group(caldavRoot, caldavAuth(cfg, dummy), caldavActor)
+	{
+		dav.OPTIONS("", h.Options)
+		dav.Handle(caldav.MethodPropfind, "", h.PropfindPrincipal)
+
+		dav.OPTIONS("/projects/", h.Options)
+		dav.Handle(caldav.MethodPropfind, "/projects/", h.PropfindHome)
+
+		dav.OPTIONS("/projects/:id/", h.Options)
+		dav.Handle(caldav.MethodPropfind, "/projects/:id/", h.PropfindCalendar)
+		dav.Handle(caldav.MethodReport, "/projects/:id/", h.Report)
+
+		dav.OPTIONS("/projects/:id/:name", h.Options)
+		dav.Handle(caldav.MethodPropfind, "/projects/:id/:name", h.PropfindObject)
+		dav.GET("/projects/:id/:name", h.GetObject)
+		dav.PUT("/projects/:id/:name", h.PutObject)
+		dav.DELETE("/projects/:id/:name", h.DeleteObject)
+	}
+
+	return nil
+}
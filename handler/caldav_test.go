@@ -0,0 +1,355 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/caldav"
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+// replayCalDAV serves a request recorded from a CalDAV client, after edit
+// adapted it to the mocked todos.
+func replayCalDAV(t *testing.T, e *gin.Engine, name string, edit ...func(*http.Request)) *httptest.ResponseRecorder {
+	f, err := os.Open(filepath.Join("testdata", "caldav", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	req, err := http.ReadRequest(bufio.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range edit {
+		fn(req)
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestCalDAVHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a CalDAVHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockTodos := mock.NewMockTodoStorage(ctrl)
+		mockProjects := mock.NewMockProjectStorage(ctrl)
+		mockSync := mock.NewMockSyncStorage(ctrl)
+		mockTx := mock.NewMockTxManager(ctrl)
+		wf := &storage.Workflow{
+			Initial:   "todo",
+			Completed: "done",
+			States:    []string{"todo", "done"},
+			Terminal:  []string{"done"},
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+		So(err, ShouldBeNil)
+		e := gin.Default()
+		So(RegisterCalDAVHandler(e, mockTodos, mockProjects, mockSync, mockTx, wf, CalDAVConfig{
+			Realm: "test",
+			Users: map[string]string{"alice": string(hash)},
+		}), ShouldBeNil)
+
+		work := storage.Project{Model: gorm.Model{ID: 1}, Name: "Work", Color: "#ff8800"}
+		mockProjects.EXPECT().Get(gomock.Any(), 1).Return(work, nil).AnyTimes()
+
+		projectID := uint(1)
+		at := time.Date(2025, 1, 2, 17, 42, 10, 0, time.UTC)
+		source, uid := "ical", "8F1C4A52-5E67-4E4B-9E0C-0B1D0C4B9A11"
+		generated := storage.Todo{Model: gorm.Model{ID: 7, CreatedAt: at, UpdatedAt: at}, Title: "Pay rent", Status: "todo", ProjectID: &projectID}
+		imported := storage.Todo{Model: gorm.Model{ID: 9, CreatedAt: at, UpdatedAt: at}, Title: "Buy milk", Status: "todo", ProjectID: &projectID, Priority: "A", Tags: []string{"@store"}, ExternalSource: &source, ExternalID: &uid}
+		etag := func(todo storage.Todo) string {
+			data, err := (&CalDAVHandler{workflow: wf}).render(todo)
+			So(err, ShouldBeNil)
+			return caldav.ETag(data)
+		}
+		each := func(todos ...storage.Todo) func(context.Context, storage.TodoFilter, func(storage.Todo) error) error {
+			return func(_ context.Context, _ storage.TodoFilter, fn func(storage.Todo) error) error {
+				for _, todo := range todos {
+					if err := fn(todo); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		}
+		withinTx := func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) }
+
+		Convey("When Thunderbird discovers the principal", func() {
+			w := replayCalDAV(t, e, "thunderbird-propfind-principal.http")
+
+			Convey("Then it should point to the calendar home", func() {
+				So(w.Code, ShouldEqual, http.StatusMultiStatus)
+				So(w.Body.String(), ShouldContainSubstring, "<d:current-user-principal><d:href>/caldav/</d:href></d:current-user-principal>")
+				So(w.Body.String(), ShouldContainSubstring, "<c:calendar-home-set><d:href>/caldav/projects/</d:href></c:calendar-home-set>")
+				So(w.Body.String(), ShouldContainSubstring, "<d:propstat><d:prop><d:owner></d:owner>")
+				So(w.Body.String(), ShouldEndWith, "</d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat></d:response></d:multistatus>")
+			})
+		})
+
+		Convey("When a request is not authenticated", func() {
+			w := replayCalDAV(t, e, "thunderbird-propfind-principal.http", func(req *http.Request) {
+				req.Header.Del("Authorization")
+			})
+
+			Convey("Then it should return 401 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusUnauthorized)
+				So(w.Header().Get("WWW-Authenticate"), ShouldEqual, `Basic realm="test"`)
+			})
+		})
+
+		Convey("When a request has a wrong password", func() {
+			w := replayCalDAV(t, e, "thunderbird-propfind-principal.http", func(req *http.Request) {
+				req.SetBasicAuth("alice", "alice")
+			})
+
+			Convey("Then it should return 401 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusUnauthorized)
+			})
+		})
+
+		Convey("When a request is from an unknown user", func() {
+			w := replayCalDAV(t, e, "thunderbird-propfind-principal.http", func(req *http.Request) {
+				req.SetBasicAuth("bob", "secret")
+			})
+
+			Convey("Then it should return 401 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusUnauthorized)
+			})
+		})
+
+		Convey("When Apple Reminders lists the calendars", func() {
+			archived := true
+			mockProjects.EXPECT().List(gomock.Any()).
+				Return([]storage.Project{work, {Model: gorm.Model{ID: 2}, Name: "Old", Archived: &archived}}, nil).
+				Times(1)
+			mockSync.EXPECT().CurrentVersion(gomock.Any()).Return(uint(42), nil).Times(1)
+
+			w := replayCalDAV(t, e, "apple-propfind-home.http")
+
+			Convey("Then it should return a task calendar per project that is not archived", func() {
+				So(w.Code, ShouldEqual, http.StatusMultiStatus)
+				So(w.Body.String(), ShouldContainSubstring, "<d:href>/caldav/projects/1/</d:href>")
+				So(w.Body.String(), ShouldNotContainSubstring, "<d:href>/caldav/projects/2/</d:href>")
+				So(w.Body.String(), ShouldContainSubstring, "<d:resourcetype><d:collection></d:collection><c:calendar></c:calendar></d:resourcetype>")
+				So(w.Body.String(), ShouldContainSubstring, `<c:supported-calendar-component-set><c:comp name="VTODO"/></c:supported-calendar-component-set>`)
+				So(w.Body.String(), ShouldContainSubstring, "<cs:getctag>http://go-restful-sample/ns/sync/42</cs:getctag>")
+				So(w.Body.String(), ShouldContainSubstring, "<d:sync-token>http://go-restful-sample/ns/sync/42</d:sync-token>")
+				So(w.Body.String(), ShouldContainSubstring, "<ical:calendar-color>#ff8800</ical:calendar-color>")
+				So(w.Body.String(), ShouldContainSubstring, "<ical:calendar-order></ical:calendar-order></d:prop><d:status>HTTP/1.1 404 Not Found</d:status>")
+			})
+		})
+
+		Convey("When DAVx5 syncs a calendar for the first time", func() {
+			mockSync.EXPECT().CurrentVersion(gomock.Any()).Return(uint(42), nil).Times(1)
+			mockTodos.EXPECT().
+				Each(gomock.Any(), gomock.Eq(storage.TodoFilter{ProjectID: &projectID}), gomock.Any()).
+				DoAndReturn(each(generated, imported)).
+				Times(1)
+
+			w := replayCalDAV(t, e, "davx5-sync-collection-initial.http")
+
+			Convey("Then it should return every object and the current sync token", func() {
+				So(w.Code, ShouldEqual, http.StatusMultiStatus)
+				So(w.Body.String(), ShouldContainSubstring, "<d:href>/caldav/projects/1/todo-7@go-restful-sample.ics</d:href>")
+				So(w.Body.String(), ShouldContainSubstring, "<d:href>/caldav/projects/1/"+uid+".ics</d:href>")
+				So(w.Body.String(), ShouldContainSubstring, "<d:getetag>&#34;"+etag(generated)[1:33]+"&#34;</d:getetag>")
+				So(w.Body.String(), ShouldEndWith, "<d:sync-token>http://go-restful-sample/ns/sync/42</d:sync-token></d:multistatus>")
+			})
+		})
+
+		Convey("When DAVx5 syncs a calendar again", func() {
+			other := uint(2)
+			moved := generated
+			moved.ID, moved.ProjectID = 8, &other
+			mockSync.EXPECT().ListChanges(gomock.Any(), uint(41), uint(41), caldavSyncPageSize).Return([]storage.TodoChange{
+				{TodoVersion: storage.TodoVersion{TodoID: 7, Version: 42}, Todo: generated},
+				{TodoVersion: storage.TodoVersion{TodoID: 9, Version: 43}, Todo: imported, Deleted: true},
+				{TodoVersion: storage.TodoVersion{TodoID: 8, Version: 44}, Todo: moved},
+				{TodoVersion: storage.TodoVersion{TodoID: 10, Version: 45}, Deleted: true},
+			}, nil).Times(1)
+
+			w := replayCalDAV(t, e, "davx5-sync-collection.http")
+
+			Convey("Then it should return the changed objects and report the removed ones", func() {
+				So(w.Code, ShouldEqual, http.StatusMultiStatus)
+				So(w.Body.String(), ShouldContainSubstring, "<d:href>/caldav/projects/1/todo-7@go-restful-sample.ics</d:href><d:propstat>")
+				So(w.Body.String(), ShouldContainSubstring, "<d:href>/caldav/projects/1/"+uid+".ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status>")
+				So(w.Body.String(), ShouldContainSubstring, "<d:href>/caldav/projects/1/todo-8@go-restful-sample.ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status>")
+				So(w.Body.String(), ShouldContainSubstring, "<d:href>/caldav/projects/1/todo-10@go-restful-sample.ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status>")
+				So(w.Body.String(), ShouldEndWith, "<d:sync-token>http://go-restful-sample/ns/sync/45</d:sync-token></d:multistatus>")
+			})
+		})
+
+		Convey("When a sync token is not one of the server", func() {
+			w := replayCalDAV(t, e, "davx5-sync-collection.http", func(req *http.Request) {
+				body, _ := io.ReadAll(req.Body)
+				body = bytes.Replace(body, []byte("http://go-restful-sample/ns/sync/41"), []byte("http://example.com/sync/41"), 1)
+				req.Body, req.ContentLength = io.NopCloser(bytes.NewReader(body)), int64(len(body))
+			})
+
+			Convey("Then it should return 403 status code with the failed precondition", func() {
+				So(w.Code, ShouldEqual, http.StatusForbidden)
+				So(w.Body.String(), ShouldContainSubstring, "<d:valid-sync-token>")
+			})
+		})
+
+		Convey("When Thunderbird fetches objects", func() {
+			mockTodos.EXPECT().Get(gomock.Any(), 7).Return(generated, nil).Times(1)
+			mockTodos.EXPECT().
+				Each(gomock.Any(), gomock.Eq(storage.TodoFilter{ProjectID: &projectID, ExternalSource: "ical", ExternalID: uid}), gomock.Any()).
+				DoAndReturn(each()).
+				Times(1)
+
+			w := replayCalDAV(t, e, "thunderbird-calendar-multiget.http")
+
+			Convey("Then it should return those found with their data", func() {
+				So(w.Code, ShouldEqual, http.StatusMultiStatus)
+				So(w.Body.String(), ShouldContainSubstring, "<c:calendar-data>BEGIN:VCALENDAR")
+				So(w.Body.String(), ShouldContainSubstring, "SUMMARY:Pay rent")
+				So(w.Body.String(), ShouldContainSubstring, "<d:href>/caldav/projects/1/"+uid+".ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status>")
+				So(w.Body.String(), ShouldContainSubstring, "<d:href>/caldav/projects/2/todo-8@go-restful-sample.ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status>")
+			})
+		})
+
+		Convey("When Thunderbird queries events", func() {
+			w := replayCalDAV(t, e, "thunderbird-calendar-query-events.http")
+
+			Convey("Then it should return no object", func() {
+				So(w.Code, ShouldEqual, http.StatusMultiStatus)
+				So(w.Body.String(), ShouldNotContainSubstring, "<d:response>")
+			})
+		})
+
+		Convey("When Apple Reminders creates a todo", func() {
+			mockTx.EXPECT().WithinTx(gomock.Any(), gomock.Any()).DoAndReturn(withinTx).Times(1)
+			mockTodos.EXPECT().Each(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(each()).Times(1)
+			mockTodos.EXPECT().
+				Upsert(gomock.Any(), gomock.Cond(func(todo *storage.Todo) bool {
+					return todo.Title == "Buy milk" && *todo.Completed && *todo.ProjectID == 1 &&
+						*todo.ExternalSource == "ical" && *todo.ExternalID == uid &&
+						todo.DueAt.Equal(time.Date(2025, 1, 5, 10, 0, 0, 0, time.UTC))
+				})).
+				DoAndReturn(func(_ context.Context, todo *storage.Todo) (bool, error) {
+					todo.ID = 9
+					return true, nil
+				}).
+				Times(1)
+
+			w := replayCalDAV(t, e, "apple-put-create.http")
+
+			Convey("Then it should return 201 status code without an ETag", func() {
+				So(w.Code, ShouldEqual, http.StatusCreated)
+				So(w.Header().Get("ETag"), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When Apple Reminders creates a todo that exists", func() {
+			mockTx.EXPECT().WithinTx(gomock.Any(), gomock.Any()).DoAndReturn(withinTx).Times(1)
+			mockTodos.EXPECT().Each(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(each(imported)).Times(1)
+
+			w := replayCalDAV(t, e, "apple-put-create.http")
+
+			Convey("Then it should return 412 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusPreconditionFailed)
+			})
+		})
+
+		Convey("When Apple Reminders updates the todo it last fetched", func() {
+			mockTx.EXPECT().WithinTx(gomock.Any(), gomock.Any()).DoAndReturn(withinTx).Times(1)
+			mockTodos.EXPECT().Each(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(each(imported)).Times(1)
+			mockTodos.EXPECT().
+				Replace(gomock.Any(), 9, gomock.Cond(func(todo storage.Todo) bool {
+					return todo.Title == "Buy milk" && *todo.Completed && todo.Priority == "A" && slices.Equal(todo.Tags, []string{"@store"})
+				})).
+				Return(nil).
+				Times(1)
+
+			w := replayCalDAV(t, e, "apple-put-update.http", func(req *http.Request) {
+				req.Header.Set("If-Match", etag(imported))
+			})
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When Apple Reminders updates a todo changed meanwhile", func() {
+			mockTx.EXPECT().WithinTx(gomock.Any(), gomock.Any()).DoAndReturn(withinTx).Times(1)
+			mockTodos.EXPECT().Each(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(each(imported)).Times(1)
+
+			w := replayCalDAV(t, e, "apple-put-update.http")
+
+			Convey("Then it should return 412 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusPreconditionFailed)
+			})
+		})
+
+		Convey("When a todo is put under another name than its UID", func() {
+			w := replayCalDAV(t, e, "apple-put-create.http", func(req *http.Request) {
+				req.URL.Path = "/caldav/projects/1/other.ics"
+			})
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, "<c:valid-calendar-data>")
+			})
+		})
+
+		Convey("When DAVx5 deletes the todo it last fetched", func() {
+			mockTodos.EXPECT().Get(gomock.Any(), 7).Return(generated, nil).Times(1)
+			mockTodos.EXPECT().Delete(gomock.Any(), 7).Return(nil).Times(1)
+
+			w := replayCalDAV(t, e, "davx5-delete.http", func(req *http.Request) {
+				req.Header.Set("If-Match", etag(generated))
+			})
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When DAVx5 deletes a todo changed meanwhile", func() {
+			mockTodos.EXPECT().Get(gomock.Any(), 7).Return(generated, nil).Times(1)
+
+			w := replayCalDAV(t, e, "davx5-delete.http")
+
+			Convey("Then it should return 412 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusPreconditionFailed)
+			})
+		})
+
+		Convey("When a todo of another project is deleted", func() {
+			other := uint(2)
+			moved := generated
+			moved.ProjectID = &other
+			mockTodos.EXPECT().Get(gomock.Any(), 7).Return(moved, nil).Times(1)
+
+			w := replayCalDAV(t, e, "davx5-delete.http")
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/calendar"
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/transfer"
+)
+
+type CreateCalendarFeedReq struct {
+	ProjectID *uint `json:"projectId"`
+	Events    bool  `json:"events"`
+}
+
+// GetCalendarFeedRes describes a feed. URL is the path to subscribe to.
+type GetCalendarFeedRes struct {
+	ID        uint      `json:"id"`
+	URL       string    `json:"url"`
+	ProjectID *uint     `json:"projectId,omitempty"`
+	Events    bool      `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newGetCalendarFeedRes(feed storage.CalendarFeed) GetCalendarFeedRes {
+	return GetCalendarFeedRes{
+		ID:        feed.ID,
+		URL:       "/calendar/" + feed.Token + ".ics",
+		ProjectID: feed.ProjectID,
+		Events:    feed.Events,
+		CreatedAt: feed.CreatedAt,
+	}
+}
+
+type ListCalendarFeedRes []GetCalendarFeedRes
+
+func newListCalendarFeedRes(feeds []storage.CalendarFeed) ListCalendarFeedRes {
+	res := make(ListCalendarFeedRes, 0, len(feeds))
+	for _, feed := range feeds {
+		res = append(res, newGetCalendarFeedRes(feed))
+	}
+	return res
+}
+
+type ImportCalendarQuery struct {
+	DryRun bool `form:"dryRun"`
+}
+
+type CalendarHandler struct {
+	feeds    storage.CalendarFeedStorage
+	todos    storage.TodoStorage
+	projects storage.ProjectStorage
+	workflow *storage.Workflow
+	importer *transfer.Importer
+}
+
+// CreateFeed creates a feed owned by the current user.
+func (h *CalendarHandler) CreateFeed(c *gin.Context) {
+	var req CreateCalendarFeedReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	if req.ProjectID != nil {
+		if _, err := h.projects.Get(c, int(*req.ProjectID)); err != nil {
+			if storage.IsNotFound(err) {
+				c.Error(err)
+				c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+			} else {
+				c.Error(err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			}
+			return
+		}
+	}
+
+	feed := storage.CalendarFeed{
+		Owner:     currentUser(c),
+		ProjectID: req.ProjectID,
+		Events:    req.Events,
+	}
+	if err := h.feeds.Create(c, &feed); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newGetCalendarFeedRes(feed))
+}
+
+func (h *CalendarHandler) ListFeeds(c *gin.Context) {
+	feeds, err := h.feeds.List(c, currentUser(c))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newListCalendarFeedRes(feeds))
+}
+
+func (h *CalendarHandler) DeleteFeed(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	if err := h.feeds.Delete(c, currentUser(c), id); err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Feed renders the todos of the feed whose token names the requested file as
+// VTODOs, plus VEVENTs at their due dates when the feed asks for them.
+func (h *CalendarHandler) Feed(c *gin.Context) {
+	token, ok := strings.CutSuffix(c.Param("file"), ".ics")
+	if !ok {
+		err := errors.New("calendar feeds end with .ics")
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	feed, err := h.feeds.GetByToken(c, token)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	name := "Todos"
+	if feed.ProjectID != nil {
+		project, err := h.projects.Get(c, int(*feed.ProjectID))
+		if err != nil && !storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+			return
+		}
+		if err == nil {
+			name = project.Name
+		}
+	}
+
+	cal := calendar.NewCalendar(name)
+	err = h.todos.Each(c, storage.TodoFilter{ProjectID: feed.ProjectID}, func(todo storage.Todo) error {
+		cal.Children = append(cal.Children, calendar.NewToDo(todo, h.workflow))
+		if feed.Events {
+			if event := calendar.NewEvent(todo, h.workflow); event != nil {
+				cal.Children = append(cal.Children, event)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", calendar.MIMEType+"; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := calendar.Encode(c.Writer, cal); err != nil {
+		c.Error(err)
+	}
+}
+
+// Import creates a todo for every VTODO of an iCalendar document, reporting
+// the skipped ones as POST /todos/import does. The UID of a VTODO becomes the
+// external ID of its todo, so importing the same document again only reports
+// duplicates.
+func (h *CalendarHandler) Import(c *gin.Context) {
+	var query ImportCalendarQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	res, err := h.importer.Import(c, calendar.NewDecoder(c.Request.Body), query.DryRun)
+	if err != nil {
+		if errors.Is(err, transfer.ErrMalformed) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newImportTodoRes(res))
+}
+
+func RegisterCalendarHandler(e *gin.Engine, feeds storage.CalendarFeedStorage, todos storage.TodoStorage, projects storage.ProjectStorage, wf *storage.Workflow, tx storage.TxManager) error {
+	h := &CalendarHandler{
+		feeds:    feeds,
+		todos:    todos,
+		projects: projects,
+		workflow: wf,
+		importer: transfer.NewImporter(todos, tx),
+	}
+
+	cal := e.Group("/calendar")
+	{
+		cal.GET("/feeds", h.ListFeeds)
+		cal.POST("/feeds", h.CreateFeed)
+		cal.DELETE("/feeds/:id", h.DeleteFeed)
+		cal.POST("/import", h.Import)
+		cal.GET("/:file", h.Feed)
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestCalendarHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a CalendarHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockFeeds := mock.NewMockCalendarFeedStorage(ctrl)
+		mockTodos := mock.NewMockTodoStorage(ctrl)
+		mockProjects := mock.NewMockProjectStorage(ctrl)
+		mockTx := mock.NewMockTxManager(ctrl)
+		wf := &storage.Workflow{
+			Initial:   "todo",
+			Completed: "done",
+			States:    []string{"todo", "done"},
+			Terminal:  []string{"done"},
+		}
+		e := gin.Default()
+		RegisterCalendarHandler(e, mockFeeds, mockTodos, mockProjects, wf, mockTx)
+
+		Convey("When reading a feed of a project with events", func() {
+			projectID := uint(3)
+			due := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+			mockFeeds.EXPECT().GetByToken(gomock.Any(), "secret").
+				Return(storage.CalendarFeed{ID: 1, Token: "secret", ProjectID: &projectID, Events: true}, nil).
+				Times(1)
+			mockProjects.EXPECT().Get(gomock.Any(), 3).Return(storage.Project{Name: "Work"}, nil).Times(1)
+			mockTodos.EXPECT().
+				Each(gomock.Any(), gomock.Eq(storage.TodoFilter{ProjectID: &projectID}), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ storage.TodoFilter, fn func(storage.Todo) error) error {
+					if err := fn(storage.Todo{Model: gorm.Model{ID: 1}, Title: "First", Status: "todo", DueAt: &due}); err != nil {
+						return err
+					}
+					return fn(storage.Todo{Model: gorm.Model{ID: 2}, Title: "Second", Status: "done"})
+				}).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/calendar/secret.ics", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return the todos as an iCalendar document", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "text/calendar; charset=utf-8")
+				So(w.Body.String(), ShouldContainSubstring, "X-WR-CALNAME:Work")
+				So(bytes.Count(w.Body.Bytes(), []byte("BEGIN:VTODO")), ShouldEqual, 2)
+				So(bytes.Count(w.Body.Bytes(), []byte("BEGIN:VEVENT")), ShouldEqual, 1)
+				So(w.Body.String(), ShouldContainSubstring, "UID:todo-2@go-restful-sample")
+				So(w.Body.String(), ShouldContainSubstring, "STATUS:COMPLETED")
+			})
+		})
+
+		Convey("When reading a feed with an unknown token", func() {
+			mockFeeds.EXPECT().GetByToken(gomock.Any(), "unknown").Return(storage.CalendarFeed{}, gorm.ErrRecordNotFound).Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/calendar/unknown.ics", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When reading a feed without the .ics extension", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/calendar/secret", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When creating a feed", func() {
+			mockFeeds.EXPECT().
+				Create(gomock.Any(), gomock.Cond(func(feed *storage.CalendarFeed) bool { return feed.Owner == "alice" && feed.Events })).
+				DoAndReturn(func(_ context.Context, feed *storage.CalendarFeed) error {
+					feed.ID, feed.Token = 1, "secret"
+					return nil
+				}).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/calendar/feeds", bytes.NewBufferString(`{"events": true}`))
+			req.Header.Set("X-User", "alice")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return the URL of the feed", func() {
+				So(w.Code, ShouldEqual, http.StatusCreated)
+				var res GetCalendarFeedRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.URL, ShouldEqual, "/calendar/secret.ics")
+				So(res.Events, ShouldBeTrue)
+			})
+		})
+
+		Convey("When creating a feed of an unknown project", func() {
+			mockProjects.EXPECT().Get(gomock.Any(), 9).Return(storage.Project{}, gorm.ErrRecordNotFound).Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/calendar/feeds", bytes.NewBufferString(`{"projectId": 9}`))
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When deleting a feed of another user", func() {
+			mockFeeds.EXPECT().Delete(gomock.Any(), "alice", 1).Return(gorm.ErrRecordNotFound).Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/calendar/feeds/1", nil)
+			req.Header.Set("X-User", "alice")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When importing an iCalendar document", func() {
+			mockTodos.EXPECT().Each(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+			mockTx.EXPECT().
+				WithinTx(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) }).
+				Times(2)
+			mockTodos.EXPECT().
+				Create(gomock.Any(), gomock.Cond(func(todo *storage.Todo) bool {
+					return todo.Title == "Buy milk" && *todo.ExternalSource == "ical" && *todo.ExternalID == "milk@example.com"
+				})).
+				Return(nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/calendar/import", bytes.NewBufferString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:test\r\n"+
+				"BEGIN:VTODO\r\nUID:milk@example.com\r\nDTSTAMP:20250101T000000Z\r\nSUMMARY:Buy milk\r\nEND:VTODO\r\n"+
+				"BEGIN:VTODO\r\nUID:bad@example.com\r\nDTSTAMP:20250101T000000Z\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"))
+			req.Header.Set("Content-Type", "text/calendar")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should report what was imported", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var res ImportTodoRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Total, ShouldEqual, 2)
+				So(res.Created, ShouldEqual, 1)
+				So(res.Failed, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When importing a document that is not iCalendar", func() {
+			mockTodos.EXPECT().Each(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+			mockTx.EXPECT().
+				WithinTx(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) }).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/calendar/import", bytes.NewBufferString("hello"))
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
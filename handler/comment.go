@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// HeaderTotalCount carries the total number of items of a paginated list.
+const HeaderTotalCount = "X-Total-Count"
+
+type CommentHandler struct {
+	storage storage.CommentStorage
+}
+
+type GetCommentRes struct {
+	ID        uint       `json:"id"`
+	TodoID    uint       `json:"todoId"`
+	Author    string     `json:"author"`
+	Body      string     `json:"body"`
+	Mentions  []string   `json:"mentions"`
+	EditedAt  *time.Time `json:"editedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+func newGetCommentRes(comment storage.Comment) GetCommentRes {
+	res := GetCommentRes{
+		ID:        comment.ID,
+		TodoID:    comment.TodoID,
+		Author:    comment.Author,
+		Body:      comment.Body,
+		Mentions:  make([]string, 0, len(comment.Mentions)),
+		EditedAt:  comment.EditedAt,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+	}
+	for _, mention := range comment.Mentions {
+		res.Mentions = append(res.Mentions, mention.Username)
+	}
+	return res
+}
+
+func parseCommentParams(c *gin.Context) (int, int, error) {
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, 0, err
+	}
+	id, err := strconv.Atoi(c.Param("commentId"))
+	if err != nil {
+		return 0, 0, err
+	}
+	return todoID, id, nil
+}
+
+func (h *CommentHandler) Get(c *gin.Context) {
+	todoID, id, err := parseCommentParams(c)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	comment, err := h.storage.Get(c, todoID, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newGetCommentRes(comment))
+}
+
+type ListCommentQuery struct {
+	Offset int `form:"offset" binding:"min=0"`
+	Limit  int `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+type ListCommentRes []GetCommentRes
+
+func (h *CommentHandler) List(c *gin.Context) {
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var query ListCommentQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	comments, total, err := h.storage.List(c, todoID, query.Offset, query.Limit)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	res := make(ListCommentRes, 0, len(comments))
+	for _, comment := range comments {
+		res = append(res, newGetCommentRes(comment))
+	}
+
+	c.Header(HeaderTotalCount, strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, res)
+}
+
+type CreateCommentReq struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+func (h *CommentHandler) Create(c *gin.Context) {
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var req CreateCommentReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var comment storage.Comment
+	comment.TodoID = uint(todoID)
+	comment.Author = currentUser(c)
+	comment.Body = req.Body
+
+	if err := h.storage.Create(c, &comment); err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, newGetCommentRes(comment))
+}
+
+type UpdateCommentReq struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+func (h *CommentHandler) Update(c *gin.Context) {
+	todoID, id, err := parseCommentParams(c)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var req UpdateCommentReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	if err := h.storage.Update(c, todoID, id, req.Body); err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *CommentHandler) Delete(c *gin.Context) {
+	todoID, id, err := parseCommentParams(c)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	if err := h.storage.Delete(c, todoID, id); err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type GetCommentRevisionRes struct {
+	ID        uint      `json:"id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ListCommentRevisionRes []GetCommentRevisionRes
+
+func (h *CommentHandler) ListRevisions(c *gin.Context) {
+	todoID, id, err := parseCommentParams(c)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	revisions, err := h.storage.ListRevisions(c, todoID, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	res := make(ListCommentRevisionRes, 0, len(revisions))
+	for _, revision := range revisions {
+		res = append(res, GetCommentRevisionRes{
+			ID:        revision.ID,
+			Body:      revision.Body,
+			CreatedAt: revision.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func RegisterCommentHandler(e *gin.Engine, s storage.CommentStorage) error {
+	h := &CommentHandler{
+		storage: s,
+	}
+
+	comment := e.Group("/todos/:id/comments")
+	{
+		comment.GET("", h.List)
+		comment.POST("", h.Create)
+		comment.GET("/:commentId", h.Get)
+		comment.PATCH("/:commentId", h.Update)
+		comment.DELETE("/:commentId", h.Delete)
+		comment.GET("/:commentId/revisions", h.ListRevisions)
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestCommentHandler_List(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a CommentHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockCommentStorage(ctrl)
+		e := gin.Default()
+		RegisterCommentHandler(e, mockStorage)
+
+		Convey("When listing a page of comments", func() {
+			mockStorage.EXPECT().
+				List(gomock.Any(), gomock.Eq(1), gomock.Eq(20), gomock.Eq(10)).
+				Return([]storage.Comment{
+					{
+						Model:    gorm.Model{ID: 21},
+						TodoID:   1,
+						Author:   "alice",
+						Body:     "ping @bob",
+						Mentions: []storage.CommentMention{{Username: "bob"}},
+					},
+				}, int64(21), nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/1/comments?offset=20&limit=10", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 200 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And return the page with the total count", func() {
+				So(w.Header().Get(HeaderTotalCount), ShouldEqual, "21")
+
+				var res ListCommentRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(len(res), ShouldEqual, 1)
+				So(res[0].Author, ShouldEqual, "alice")
+				So(res[0].Mentions, ShouldResemble, []string{"bob"})
+			})
+		})
+
+		Convey("When the page size is too large", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/1/comments?limit=1000", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When todo is not found", func() {
+			mockStorage.EXPECT().
+				List(gomock.Any(), gomock.Eq(999), gomock.Any(), gomock.Any()).
+				Return(nil, int64(0), gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/999/comments", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestCommentHandler_Create(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a CommentHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockCommentStorage(ctrl)
+		e := gin.Default()
+		RegisterCommentHandler(e, mockStorage)
+
+		Convey("When posting a comment", func() {
+			now := time.Now()
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, comment *storage.Comment) error {
+					So(comment.TodoID, ShouldEqual, 1)
+					So(comment.Author, ShouldEqual, "alice")
+					comment.ID = 1
+					comment.CreatedAt = now
+					comment.UpdatedAt = now
+					comment.Mentions = []storage.CommentMention{{Username: "bob"}}
+					return nil
+				}).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/comments", bytes.NewBufferString(`{"body": "**done**, @bob please check"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(HeaderUser, "alice")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 201 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusCreated)
+			})
+
+			Convey("And return the created comment", func() {
+				var res GetCommentRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(res.ID, ShouldEqual, 1)
+				So(res.Body, ShouldEqual, "**done**, @bob please check")
+				So(res.Mentions, ShouldResemble, []string{"bob"})
+				So(res.EditedAt, ShouldBeNil)
+			})
+		})
+
+		Convey("When posting a comment without a body", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/comments", bytes.NewBufferString(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When todo is not found", func() {
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Any()).
+				Return(gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/999/comments", bytes.NewBufferString(`{"body": "hello"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestCommentHandler_Update(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a CommentHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockCommentStorage(ctrl)
+		e := gin.Default()
+		RegisterCommentHandler(e, mockStorage)
+
+		Convey("When editing a comment", func() {
+			mockStorage.EXPECT().
+				Update(gomock.Any(), gomock.Eq(1), gomock.Eq(2), gomock.Eq("edited")).
+				Return(nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/todos/1/comments/2", bytes.NewBufferString(`{"body": "edited"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When editing a comment with invalid ID format", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/todos/1/comments/invalid", bytes.NewBufferString(`{"body": "edited"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestCommentHandler_Delete(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a CommentHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockCommentStorage(ctrl)
+		e := gin.Default()
+		RegisterCommentHandler(e, mockStorage)
+
+		Convey("When deleting a comment", func() {
+			mockStorage.EXPECT().
+				Delete(gomock.Any(), gomock.Eq(1), gomock.Eq(2)).
+				Return(nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/todos/1/comments/2", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When comment is not found", func() {
+			mockStorage.EXPECT().
+				Delete(gomock.Any(), gomock.Eq(1), gomock.Eq(999)).
+				Return(gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/todos/1/comments/999", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestCommentHandler_ListRevisions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a CommentHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockCommentStorage(ctrl)
+		e := gin.Default()
+		RegisterCommentHandler(e, mockStorage)
+
+		Convey("When listing the edit history of a comment", func() {
+			mockStorage.EXPECT().
+				ListRevisions(gomock.Any(), gomock.Eq(1), gomock.Eq(2)).
+				Return([]storage.CommentRevision{
+					{ID: 1, CommentID: 2, Body: "first"},
+					{ID: 2, CommentID: 2, Body: "second"},
+				}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/1/comments/2/revisions", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 200 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And return the previous bodies in order", func() {
+				var res ListCommentRevisionRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(len(res), ShouldEqual, 2)
+				So(res[0].Body, ShouldEqual, "first")
+				So(res[1].Body, ShouldEqual, "second")
+			})
+		})
+	})
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/stream"
+)
+
+// HeaderLastEventID is sent by EventSource clients when reconnecting.
+const HeaderLastEventID = "Last-Event-ID"
+
+// EventReset is streamed to a resuming client that missed events no longer
+// buffered. It should refetch the todos it shows.
+const EventReset = "reset"
+
+type EventHandler struct {
+	broker *stream.Broker
+}
+
+type StreamTodoEventQuery struct {
+	ProjectID   uint   `form:"projectId"`
+	Tag         string `form:"tag"`
+	LastEventID string `form:"lastEventId"`
+}
+
+// Stream sends the todo changes as server-sent events until the client goes
+// away, falls behind or the server stops. Every event carries the TodoChanged
+// payload of the outbox. Clients resume with the Last-Event-ID header, or the
+// lastEventId query parameter where they cannot set headers.
+func (h *EventHandler) Stream(c *gin.Context) {
+	var query StreamTodoEventQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+	lastEventID := c.GetHeader(HeaderLastEventID)
+	if lastEventID == "" {
+		lastEventID = query.LastEventID
+	}
+
+	sub := h.broker.Subscribe(lastEventID, stream.Filter{ProjectID: query.ProjectID, Tag: query.Tag})
+	defer h.broker.Unsubscribe(sub)
+
+	cfg := h.broker.Config()
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	c.Writer.WriteString("retry: " + strconv.FormatInt(cfg.Retry.Milliseconds(), 10) + "\n\n")
+	if sub.Reset {
+		c.Render(-1, sse.Event{Event: EventReset, Data: gin.H{}})
+	}
+	for _, event := range sub.Replay {
+		c.Render(-1, newSSEvent(event))
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(cfg.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			c.Render(-1, newSSEvent(event))
+		case <-heartbeat.C:
+			// A comment line keeps proxies from closing an idle connection
+			// without waking up the client.
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func newSSEvent(event stream.Event) sse.Event {
+	return sse.Event{
+		Id:    event.ID,
+		Event: event.Type,
+		Data:  event.Data,
+	}
+}
+
+func RegisterEventHandler(e *gin.Engine, b *stream.Broker) error {
+	h := &EventHandler{
+		broker: b,
+	}
+
+	e.GET("/todos/events", h.Stream)
+
+	return nil
+}
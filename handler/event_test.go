@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx/fxtest"
+
+	"github.com/wei840222/go-restful-sample/outbox"
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/stream"
+)
+
+func TestEventHandler_Stream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given an EventHandler with a broker holding two events", t, func() {
+		lc := fxtest.NewLifecycle(t)
+		b := stream.NewBroker(lc, stream.Config{BufferSize: 10, SubscriberBuffer: 10, Heartbeat: time.Minute, Retry: time.Second})
+		lc.RequireStart()
+
+		for _, changed := range []storage.TodoChanged{
+			{Type: "todo.created", TodoID: 1, Todo: map[string]any{"projectId": 1}},
+			{Type: "todo.created", TodoID: 2, Todo: map[string]any{"projectId": 2}},
+		} {
+			payload, _ := json.Marshal(changed)
+			So(b.Publish(context.Background(), outbox.Message{ID: changed.TodoID, Topic: changed.Type, Payload: payload}), ShouldBeNil)
+		}
+
+		e := gin.Default()
+		RegisterEventHandler(e, b)
+
+		Convey("When a client resumes the stream of a project", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/events?projectId=2", nil)
+			req.Header.Set(HeaderLastEventID, "0")
+
+			// The stream ends once the broker closes.
+			time.AfterFunc(100*time.Millisecond, lc.RequireStop)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should be told to reset as the event is unknown", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "text/event-stream")
+				So(w.Body.String(), ShouldStartWith, "retry: 1000\n\nevent:reset\n")
+			})
+		})
+
+		Convey("When a client resumes after a buffered event", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/events?lastEventId=1", nil)
+
+			time.AfterFunc(100*time.Millisecond, lc.RequireStop)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should replay the later events", func() {
+				So(w.Body.String(), ShouldContainSubstring, "id:2\nevent:todo.created\n")
+				So(w.Body.String(), ShouldNotContainSubstring, "id:1\n")
+			})
+		})
+	})
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/wei840222/go-restful-sample/grpcserver"
+	todov1 "github.com/wei840222/go-restful-sample/proto/todo/v1"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+type ginContextKey struct{}
+
+// newTodoGateway transcodes the routes annotated with google.api.http in
+// todo.proto to an in-process grpcserver.TodoServer, so the REST and gRPC
+// APIs share the same implementation. The request context is passed through
+// untouched, which keeps the actor and request ID set by AuditMiddleware.
+func newTodoGateway(s storage.TodoStorage) (gin.HandlerFunc, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithErrorHandler(gatewayErrorHandler),
+		runtime.WithForwardResponseOption(gatewayResponseStatus),
+	)
+	if err := todov1.RegisterTodoServiceHandlerServer(context.Background(), mux, grpcserver.NewTodoServer(s)); err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), ginContextKey{}, c)
+		mux.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+	}, nil
+}
+
+// gatewayErrorHandler writes errors in the ErrorRes format of the rest of the
+// REST API, mapping status codes the way the hand-written handlers do.
+func gatewayErrorHandler(ctx context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, _ *http.Request, err error) {
+	s := status.Convert(err)
+	code := runtime.HTTPStatusFromCode(s.Code())
+	if s.Code() == codes.FailedPrecondition {
+		code = http.StatusConflict
+	}
+
+	if c, ok := ctx.Value(ginContextKey{}).(*gin.Context); ok {
+		c.Error(err)
+		c.AbortWithStatusJSON(code, ErrorRes{Error: s.Message()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(ErrorRes{Error: s.Message()})
+}
+
+// gatewayResponseStatus answers 201 instead of 200 for the methods creating a
+// resource.
+func gatewayResponseStatus(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+	if method, ok := runtime.RPCMethod(ctx); ok && method == todov1.TodoService_CreateTodo_FullMethodName {
+		w.WriteHeader(http.StatusCreated)
+	}
+	return nil
+}
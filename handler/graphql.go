@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/graph"
+)
+
+// GraphQLConfig controls the GraphQL endpoint. GraphiQL serves an in-browser
+// IDE on /graphiql and is meant for debug mode only.
+type GraphQLConfig struct {
+	graph.Limits
+	GraphiQL bool
+}
+
+func RegisterGraphQLHandler(e *gin.Engine, r *graph.Resolver, cfg GraphQLConfig) error {
+	srv := gin.WrapH(graph.NewServer(r, cfg.Limits))
+
+	e.GET("/graphql", srv)
+	e.POST("/graphql", srv)
+	if cfg.GraphiQL {
+		e.GET("/graphiql", gin.WrapH(playground.Handler("GraphiQL", "/graphql")))
+	}
+
+	return nil
+}
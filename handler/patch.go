@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Media types of the patch documents accepted by PATCH /todos/:id besides
+// application/json, which binds to UpdateTodoReq.
+const (
+	MIMEMergePatch = "application/merge-patch+json"
+	MIMEJSONPatch  = "application/json-patch+json"
+)
+
+// PatchTodoReq holds the fields of a patched todo document that are saved.
+// Clearing the title or removing completed is rejected.
+type PatchTodoReq struct {
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	Completed   *bool      `json:"completed" binding:"required"`
+	DueAt       *time.Time `json:"dueAt"`
+	RRule       string     `json:"rrule"`
+}
+
+// patchError is an error of the patch itself rather than of the todo, along
+// with the status code to answer.
+type patchError struct {
+	status int
+	err    error
+}
+
+func (e *patchError) Error() string {
+	return e.err.Error()
+}
+
+func (e *patchError) Unwrap() error {
+	return e.err
+}
+
+// patchTodo applies a patch document of the given media type to the todo as
+// returned by GET /todos/:id and returns the fields to save. Fields omitted
+// from that representation when empty are included so that patches can test
+// and replace them.
+func patchTodo(mediaType string, todo storage.Todo, patch []byte) (storage.Todo, error) {
+	original := newGetTodoRes(todo)
+	doc, err := newPatchTodoDoc(original)
+	if err != nil {
+		return todo, err
+	}
+
+	switch mediaType {
+	case MIMEMergePatch:
+		doc, err = jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return todo, &patchError{status: http.StatusBadRequest, err: err}
+		}
+	case MIMEJSONPatch:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return todo, &patchError{status: http.StatusBadRequest, err: err}
+		}
+		if doc, err = ops.Apply(doc); errors.Is(err, jsonpatch.ErrTestFailed) {
+			return todo, &patchError{status: http.StatusConflict, err: err}
+		} else if err != nil {
+			return todo, &patchError{status: http.StatusUnprocessableEntity, err: err}
+		}
+	}
+
+	// Every field of the document must still be known, and the read-only ones
+	// unchanged.
+	var patched GetTodoRes
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patched); err != nil {
+		return todo, &patchError{status: http.StatusBadRequest, err: err}
+	}
+	if !sameReadOnlyFields(original, patched) {
+		return todo, &patchError{status: http.StatusBadRequest, err: errors.New("only title, description, completed, dueAt and rrule can be patched")}
+	}
+
+	var req PatchTodoReq
+	if err := json.Unmarshal(doc, &req); err != nil {
+		return todo, &patchError{status: http.StatusBadRequest, err: err}
+	}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return todo, &patchError{status: http.StatusBadRequest, err: err}
+	}
+
+	todo.Title = req.Title
+	todo.Description = req.Description
+	todo.Completed = req.Completed
+	todo.DueAt = req.DueAt
+	todo.RRule = req.RRule
+	return todo, nil
+}
+
+func newPatchTodoDoc(res GetTodoRes) ([]byte, error) {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for key, empty := range map[string]any{"description": "", "dueAt": nil, "rrule": ""} {
+		if _, ok := doc[key]; !ok {
+			doc[key] = empty
+		}
+	}
+	return json.Marshal(doc)
+}
+
+func sameReadOnlyFields(a, b GetTodoRes) bool {
+	readOnly := func(res GetTodoRes) []byte {
+		data, _ := json.Marshal(GetTodoRes{
+			ID:        res.ID,
+			Status:    res.Status,
+			SeriesID:  res.SeriesID,
+			ProjectID: res.ProjectID,
+			Priority:  res.Priority,
+			Tags:      res.Tags,
+			CreatedAt: res.CreatedAt,
+			UpdatedAt: res.UpdatedAt,
+
+			ExternalSource: res.ExternalSource,
+			ExternalID:     res.ExternalID,
+		})
+		return data
+	}
+	return bytes.Equal(readOnly(a), readOnly(b))
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestTodoHandler_Patch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a TodoHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		mockTx := mock.NewMockTxManager(ctrl)
+		e := gin.Default()
+		RegisterTodoHandler(e, mockStorage, mockTx)
+
+		type txKey struct{}
+		inTx := gomock.Cond(func(ctx context.Context) bool { return ctx.Value(txKey{}) != nil })
+		mockTx.EXPECT().
+			WithinTx(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error {
+				return fn(context.WithValue(ctx, txKey{}, true))
+			}).
+			AnyTimes()
+
+		now := time.Now()
+		dueAt := now.Add(24 * time.Hour)
+		completed := false
+		todo := storage.Todo{
+			Model:       gorm.Model{ID: 1, CreatedAt: now, UpdatedAt: now},
+			Title:       "Test Todo",
+			Description: "Test Description",
+			Completed:   &completed,
+			DueAt:       &dueAt,
+		}
+
+		do := func(contentType, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/todos/1", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", contentType)
+			e.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When sending a merge patch", func() {
+			mockStorage.EXPECT().Get(inTx, gomock.Eq(1)).Return(todo, nil).Times(1)
+			mockStorage.EXPECT().
+				Replace(inTx, gomock.Eq(1), gomock.Cond(func(patched storage.Todo) bool {
+					return patched.Title == "Renamed" && patched.Description == "" && patched.DueAt == nil &&
+						patched.Completed != nil && !*patched.Completed
+				})).
+				Return(nil).
+				Times(1)
+
+			w := do(MIMEMergePatch, `{"title": "Renamed", "description": null, "dueAt": null}`)
+
+			Convey("Then it should clear the fields set to null and keep the others", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When a merge patch removes the title", func() {
+			mockStorage.EXPECT().Get(inTx, gomock.Eq(1)).Return(todo, nil).Times(1)
+
+			w := do(MIMEMergePatch, `{"title": null}`)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When sending a JSON patch whose tests pass", func() {
+			mockStorage.EXPECT().Get(inTx, gomock.Eq(1)).Return(todo, nil).Times(1)
+			mockStorage.EXPECT().
+				Replace(inTx, gomock.Eq(1), gomock.Cond(func(patched storage.Todo) bool {
+					return patched.Completed != nil && *patched.Completed && patched.RRule == "FREQ=DAILY"
+				})).
+				Return(nil).
+				Times(1)
+
+			w := do(MIMEJSONPatch, `[
+				{"op": "test", "path": "/completed", "value": false},
+				{"op": "replace", "path": "/completed", "value": true},
+				{"op": "replace", "path": "/rrule", "value": "FREQ=DAILY"}
+			]`)
+
+			Convey("Then it should save the patched todo", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When a JSON patch test fails", func() {
+			mockStorage.EXPECT().Get(inTx, gomock.Eq(1)).Return(todo, nil).Times(1)
+
+			w := do(MIMEJSONPatch, `[
+				{"op": "test", "path": "/title", "value": "Other Todo"},
+				{"op": "replace", "path": "/title", "value": "Renamed"}
+			]`)
+
+			Convey("Then it should return 409 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusConflict)
+			})
+		})
+
+		Convey("When a JSON patch cannot be applied", func() {
+			mockStorage.EXPECT().Get(inTx, gomock.Eq(1)).Return(todo, nil).Times(1)
+
+			w := do(MIMEJSONPatch, `[{"op": "remove", "path": "/missing"}]`)
+
+			Convey("Then it should return 422 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusUnprocessableEntity)
+			})
+		})
+
+		Convey("When a JSON patch changes a read-only field", func() {
+			mockStorage.EXPECT().Get(inTx, gomock.Eq(1)).Return(todo, nil).Times(1)
+
+			w := do(MIMEJSONPatch, `[{"op": "replace", "path": "/status", "value": "done"}]`)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When a JSON patch adds an unknown field", func() {
+			mockStorage.EXPECT().Get(inTx, gomock.Eq(1)).Return(todo, nil).Times(1)
+
+			w := do(MIMEJSONPatch, `[{"op": "add", "path": "/color", "value": "red"}]`)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When todo is not found", func() {
+			mockStorage.EXPECT().Get(inTx, gomock.Eq(1)).Return(storage.Todo{}, gorm.ErrRecordNotFound).Times(1)
+
+			w := do(MIMEMergePatch, `{"title": "Renamed"}`)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When sending an unsupported patch type", func() {
+			w := do("text/plain", `title=Renamed`)
+
+			Convey("Then it should return 415 status code with the accepted types", func() {
+				So(w.Code, ShouldEqual, http.StatusUnsupportedMediaType)
+				So(w.Header().Get("Accept-Patch"), ShouldContainSubstring, MIMEMergePatch)
+				So(w.Header().Get("Accept-Patch"), ShouldContainSubstring, MIMEJSONPatch)
+			})
+		})
+	})
+}
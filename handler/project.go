@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+type ProjectHandler struct {
+	storage storage.ProjectStorage
+}
+
+type GetProjectRes struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"`
+	Archived  bool      `json:"archived"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func newGetProjectRes(project storage.Project) GetProjectRes {
+	res := GetProjectRes{
+		ID:        project.ID,
+		Name:      project.Name,
+		Color:     project.Color,
+		Position:  project.Position,
+		CreatedAt: project.CreatedAt,
+		UpdatedAt: project.UpdatedAt,
+	}
+	if project.Archived != nil {
+		res.Archived = *project.Archived
+	}
+	return res
+}
+
+func (h *ProjectHandler) Get(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	project, err := h.storage.Get(c, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newGetProjectRes(project))
+}
+
+type ListProjectRes []GetProjectRes
+
+func (h *ProjectHandler) List(c *gin.Context) {
+	projects, err := h.storage.List(c)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	res := make(ListProjectRes, 0, len(projects))
+	for _, project := range projects {
+		res = append(res, newGetProjectRes(project))
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *ProjectHandler) ListTodos(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	todos, err := h.storage.ListTodos(c, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newListTodoRes(todos))
+}
+
+type CreateProjectReq struct {
+	Name     string `json:"name" binding:"required"`
+	Color    string `json:"color" binding:"omitempty,hexcolor"`
+	Position int    `json:"position"`
+}
+
+func (h *ProjectHandler) Create(c *gin.Context) {
+	var req CreateProjectReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var project storage.Project
+	project.Name = req.Name
+	project.Color = req.Color
+	project.Position = req.Position
+
+	if err := h.storage.Create(c, &project); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newGetProjectRes(project))
+}
+
+type UpdateProjectReq struct {
+	Name     string `json:"name"`
+	Color    string `json:"color" binding:"omitempty,hexcolor"`
+	Archived *bool  `json:"archived"`
+	Position int    `json:"position"`
+}
+
+func (h *ProjectHandler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var req UpdateProjectReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var project storage.Project
+	project.Name = req.Name
+	project.Color = req.Color
+	project.Archived = req.Archived
+	project.Position = req.Position
+
+	if err := h.storage.Update(c, id, project); err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+const (
+	DeleteProjectTodosInbox   = "inbox"
+	DeleteProjectTodosCascade = "cascade"
+)
+
+type DeleteProjectQuery struct {
+	Todos string `form:"todos,default=inbox" binding:"oneof=inbox cascade"`
+}
+
+func (h *ProjectHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var query DeleteProjectQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	if err := h.storage.Delete(c, id, query.Todos == DeleteProjectTodosCascade); err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func RegisterProjectHandler(e *gin.Engine, s storage.ProjectStorage) error {
+	h := &ProjectHandler{
+		storage: s,
+	}
+
+	project := e.Group("/projects")
+	{
+		project.GET("", h.List)
+		project.POST("", h.Create)
+		project.GET("/:id", h.Get)
+		project.PATCH("/:id", h.Update)
+		project.DELETE("/:id", h.Delete)
+		project.GET("/:id/todos", h.ListTodos)
+	}
+
+	return nil
+}
@@ -0,0 +1,377 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestProjectHandler_Get(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a ProjectHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockProjectStorage(ctrl)
+		e := gin.Default()
+		RegisterProjectHandler(e, mockStorage)
+
+		Convey("When getting a project with valid ID", func() {
+			now := time.Now()
+			archived := true
+			mockStorage.EXPECT().
+				Get(gomock.Any(), gomock.Eq(1)).
+				Return(storage.Project{
+					Model: gorm.Model{
+						ID:        1,
+						CreatedAt: now,
+						UpdatedAt: now,
+					},
+					Name:     "Home",
+					Color:    "#ff8800",
+					Archived: &archived,
+					Position: 2,
+				}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/projects/1", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 200 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And return the project", func() {
+				var res GetProjectRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(res.ID, ShouldEqual, 1)
+				So(res.Name, ShouldEqual, "Home")
+				So(res.Color, ShouldEqual, "#ff8800")
+				So(res.Archived, ShouldBeTrue)
+				So(res.Position, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When project is not found", func() {
+			mockStorage.EXPECT().
+				Get(gomock.Any(), gomock.Eq(999)).
+				Return(storage.Project{}, gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/projects/999", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestProjectHandler_List(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a ProjectHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockProjectStorage(ctrl)
+		e := gin.Default()
+		RegisterProjectHandler(e, mockStorage)
+
+		Convey("When listing projects successfully", func() {
+			mockStorage.EXPECT().
+				List(gomock.Any()).
+				Return([]storage.Project{
+					{Model: gorm.Model{ID: 1}, Name: "Home"},
+					{Model: gorm.Model{ID: 2}, Name: "Work"},
+				}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/projects", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 200 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And return the list of projects", func() {
+				var res ListProjectRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(len(res), ShouldEqual, 2)
+				So(res[0].Name, ShouldEqual, "Home")
+				So(res[1].Name, ShouldEqual, "Work")
+			})
+		})
+
+		Convey("When storage returns an error", func() {
+			mockStorage.EXPECT().
+				List(gomock.Any()).
+				Return(nil, errors.New("internal server error")).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/projects", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 500 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
+
+func TestProjectHandler_ListTodos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a ProjectHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockProjectStorage(ctrl)
+		e := gin.Default()
+		RegisterProjectHandler(e, mockStorage)
+
+		Convey("When listing the todos of a project", func() {
+			projectID := uint(1)
+			mockStorage.EXPECT().
+				ListTodos(gomock.Any(), gomock.Eq(1)).
+				Return([]storage.Todo{
+					{Model: gorm.Model{ID: 3}, Title: "Buy milk", ProjectID: &projectID},
+				}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/projects/1/todos", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 200 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And return the todos of the project", func() {
+				var res ListTodoRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(len(res), ShouldEqual, 1)
+				So(res[0].Title, ShouldEqual, "Buy milk")
+				So(*res[0].ProjectID, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When project is not found", func() {
+			mockStorage.EXPECT().
+				ListTodos(gomock.Any(), gomock.Eq(999)).
+				Return(nil, gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/projects/999/todos", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestProjectHandler_Create(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a ProjectHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockProjectStorage(ctrl)
+		e := gin.Default()
+		RegisterProjectHandler(e, mockStorage)
+
+		Convey("When creating a new project with valid input", func() {
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, project *storage.Project) error {
+					archived := false
+					project.ID = 1
+					project.Archived = &archived
+					return nil
+				}).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/projects", bytes.NewBufferString(`{"name": "Home", "color": "#00ff00"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 201 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusCreated)
+			})
+
+			Convey("And return the created project", func() {
+				var res GetProjectRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(res.ID, ShouldEqual, 1)
+				So(res.Name, ShouldEqual, "Home")
+				So(res.Color, ShouldEqual, "#00ff00")
+				So(res.Archived, ShouldBeFalse)
+			})
+		})
+
+		Convey("When creating a project with an invalid colour", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/projects", bytes.NewBufferString(`{"name": "Home", "color": "green"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When creating a project without a name", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/projects", bytes.NewBufferString(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestProjectHandler_Update(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a ProjectHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockProjectStorage(ctrl)
+		e := gin.Default()
+		RegisterProjectHandler(e, mockStorage)
+
+		Convey("When archiving a project", func() {
+			mockStorage.EXPECT().
+				Update(gomock.Any(), gomock.Eq(1), gomock.Any()).
+				DoAndReturn(func(_ any, _ int, project storage.Project) error {
+					So(*project.Archived, ShouldBeTrue)
+					return nil
+				}).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/projects/1", bytes.NewBufferString(`{"archived": true}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When project is not found", func() {
+			mockStorage.EXPECT().
+				Update(gomock.Any(), gomock.Eq(999), gomock.Any()).
+				Return(gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/projects/999", bytes.NewBufferString(`{"name": "Work"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestProjectHandler_Delete(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a ProjectHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockProjectStorage(ctrl)
+		e := gin.Default()
+		RegisterProjectHandler(e, mockStorage)
+
+		Convey("When deleting a project and moving its todos to the inbox", func() {
+			mockStorage.EXPECT().
+				Delete(gomock.Any(), gomock.Eq(1), gomock.Eq(false)).
+				Return(nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/projects/1", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When deleting a project together with its todos", func() {
+			mockStorage.EXPECT().
+				Delete(gomock.Any(), gomock.Eq(1), gomock.Eq(true)).
+				Return(nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/projects/1?todos=cascade", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When deleting with an unknown todos option", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/projects/1?todos=keep", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When project is not found", func() {
+			mockStorage.EXPECT().
+				Delete(gomock.Any(), gomock.Eq(999), gomock.Any()).
+				Return(gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/projects/999", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// JSONRPCVersion is the only protocol version accepted on /rpc.
+const JSONRPCVersion = "2.0"
+
+// MaxRPCBatchSize is the maximum number of calls of a batch request.
+const MaxRPCBatchSize = 100
+
+// Error codes of the JSON-RPC 2.0 specification, followed by the server
+// defined ones mirroring the REST status codes.
+const (
+	RPCErrorParse          = -32700
+	RPCErrorInvalidRequest = -32600
+	RPCErrorMethodNotFound = -32601
+	RPCErrorInvalidParams  = -32602
+	RPCErrorInternal       = -32603
+	RPCErrorNotFound       = -32004
+	RPCErrorConflict       = -32009
+)
+
+// RPCReq is a JSON-RPC call. A call without ID is a notification and gets no
+// response.
+type RPCReq struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type RPCRes struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+type RPCTodoIDParams struct {
+	ID int `json:"id" binding:"required"`
+}
+
+type RPCUpdateTodoParams struct {
+	ID    int    `json:"id" binding:"required"`
+	Scope string `json:"scope" binding:"omitempty,oneof=occurrence series"`
+	UpdateTodoReq
+}
+
+type RPCDeleteTodoParams struct {
+	ID    int  `json:"id" binding:"required"`
+	Purge bool `json:"purge"`
+}
+
+type RPCHandler struct {
+	storage storage.TodoStorage
+}
+
+// Serve handles a single call or a batch of calls, which are run in order.
+// Requests made only of notifications are answered with 204.
+func (h *RPCHandler) Serve(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, newRPCErrorRes(nil, RPCErrorParse, err))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if !json.Valid(body) {
+		c.JSON(http.StatusOK, newRPCErrorRes(nil, RPCErrorParse, errors.New("parse error")))
+		return
+	}
+
+	if body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, newRPCErrorRes(nil, RPCErrorInternal, err))
+			return
+		}
+		if len(batch) == 0 {
+			c.JSON(http.StatusOK, newRPCErrorRes(nil, RPCErrorInvalidRequest, errors.New("empty batch")))
+			return
+		}
+		if len(batch) > MaxRPCBatchSize {
+			c.JSON(http.StatusOK, newRPCErrorRes(nil, RPCErrorInvalidRequest, fmt.Errorf("at most %d calls can be batched", MaxRPCBatchSize)))
+			return
+		}
+
+		res := make([]RPCRes, 0, len(batch))
+		for _, raw := range batch {
+			if r, ok := h.call(c, raw); ok {
+				res = append(res, r)
+			}
+		}
+		if len(res) == 0 {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if res, ok := h.call(c, body); ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// call runs a single call and reports whether it expects a response.
+func (h *RPCHandler) call(c *gin.Context, raw json.RawMessage) (RPCRes, bool) {
+	var req RPCReq
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return newRPCErrorRes(nil, RPCErrorInvalidRequest, err), true
+	}
+	if req.JSONRPC != JSONRPCVersion || req.Method == "" {
+		return newRPCErrorRes(req.ID, RPCErrorInvalidRequest, errors.New("invalid request")), true
+	}
+
+	result, err := h.invoke(c, req.Method, req.Params)
+	if err != nil {
+		c.Error(err)
+	}
+	if req.ID == nil {
+		return RPCRes{}, false
+	}
+	if err != nil {
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &RPCError{Code: rpcErrorCode(err), Message: err.Error()}
+		}
+		return RPCRes{JSONRPC: JSONRPCVersion, Error: rpcErr, ID: req.ID}, true
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return newRPCErrorRes(req.ID, RPCErrorInternal, err), true
+	}
+	return RPCRes{JSONRPC: JSONRPCVersion, Result: data, ID: req.ID}, true
+}
+
+func (h *RPCHandler) invoke(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "todo.get":
+		var p RPCTodoIDParams
+		if err := bindParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		todo, err := h.storage.Get(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		return newGetTodoRes(todo), nil
+	case "todo.list":
+		todos, err := h.storage.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newListTodoRes(todos), nil
+	case "todo.create":
+		var p CreateTodoReq
+		if err := bindParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		var todo storage.Todo
+		todo.Title = p.Title
+		todo.Description = p.Description
+		todo.DueAt = p.DueAt
+		todo.RRule = p.RRule
+		todo.ProjectID = p.ProjectID
+
+		if err := h.storage.Create(ctx, &todo); err != nil {
+			return nil, err
+		}
+		return newGetTodoRes(todo), nil
+	case "todo.update":
+		var p RPCUpdateTodoParams
+		if err := bindParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		var todo storage.Todo
+		todo.Title = p.Title
+		todo.Description = p.Description
+		todo.Completed = p.Completed
+		todo.DueAt = p.DueAt
+		todo.RRule = p.RRule
+
+		var err error
+		if p.Scope == UpdateScopeSeries {
+			if p.Completed != nil || p.DueAt != nil {
+				return nil, &RPCError{Code: RPCErrorInvalidParams, Message: "completed and dueAt can only be updated on a single occurrence"}
+			}
+			err = h.storage.UpdateSeries(ctx, p.ID, todo)
+		} else {
+			err = h.storage.Update(ctx, p.ID, todo)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		todo, err = h.storage.Get(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		return newGetTodoRes(todo), nil
+	case "todo.delete":
+		var p RPCDeleteTodoParams
+		if err := bindParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if p.Purge {
+			err = h.storage.Purge(ctx, p.ID)
+		} else {
+			err = h.storage.Delete(ctx, p.ID)
+		}
+		return nil, err
+	default:
+		return nil, &RPCError{Code: RPCErrorMethodNotFound, Message: fmt.Sprintf("method %q not found", method)}
+	}
+}
+
+// bindParams binds by-name params like bindData, reporting failures as
+// invalid params.
+func bindParams(params json.RawMessage, v any) error {
+	if err := bindData(params, v); err != nil {
+		return &RPCError{Code: RPCErrorInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// rpcErrorCode maps the errors of todo methods to error codes like
+// todoErrorStatus maps them to status codes.
+func rpcErrorCode(err error) int {
+	switch {
+	case storage.IsNotFound(err):
+		return RPCErrorNotFound
+	case storage.IsInvalidRRule(err), storage.IsUnknownStatus(err):
+		return RPCErrorInvalidParams
+	case storage.IsIllegalTransition(err):
+		return RPCErrorConflict
+	default:
+		return RPCErrorInternal
+	}
+}
+
+func newRPCErrorRes(id json.RawMessage, code int, err error) RPCRes {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return RPCRes{JSONRPC: JSONRPCVersion, Error: &RPCError{Code: code, Message: err.Error()}, ID: id}
+}
+
+func RegisterRPCHandler(e *gin.Engine, s storage.TodoStorage) error {
+	h := &RPCHandler{
+		storage: s,
+	}
+
+	e.POST("/rpc", h.Serve)
+
+	return nil
+}
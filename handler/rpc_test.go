@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestRPCHandler_Serve(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given an RPCHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		e := gin.Default()
+		RegisterRPCHandler(e, mockStorage)
+
+		do := func(body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/rpc", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When calling todo.get", func() {
+			mockStorage.EXPECT().
+				Get(gomock.Any(), gomock.Eq(1)).
+				Return(storage.Todo{Model: gorm.Model{ID: 1}, Title: "Test Todo"}, nil).
+				Times(1)
+
+			w := do(`{"jsonrpc": "2.0", "method": "todo.get", "params": {"id": 1}, "id": "a"}`)
+
+			Convey("Then it should return the todo with the request ID", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var res RPCRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Error, ShouldBeNil)
+				So(string(res.ID), ShouldEqual, `"a"`)
+
+				var todo GetTodoRes
+				So(json.Unmarshal(res.Result, &todo), ShouldBeNil)
+				So(todo.Title, ShouldEqual, "Test Todo")
+			})
+		})
+
+		Convey("When calling todo.get on a todo that does not exist", func() {
+			mockStorage.EXPECT().Get(gomock.Any(), gomock.Eq(9)).Return(storage.Todo{}, gorm.ErrRecordNotFound).Times(1)
+
+			w := do(`{"jsonrpc": "2.0", "method": "todo.get", "params": {"id": 9}, "id": 1}`)
+
+			Convey("Then it should fail with the not found code", func() {
+				var res RPCRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Result, ShouldBeNil)
+				So(res.Error.Code, ShouldEqual, RPCErrorNotFound)
+				So(string(res.ID), ShouldEqual, "1")
+			})
+		})
+
+		Convey("When calling todo.create without a title", func() {
+			w := do(`{"jsonrpc": "2.0", "method": "todo.create", "params": {"description": "no title"}, "id": 1}`)
+
+			Convey("Then it should fail with the invalid params code", func() {
+				var res RPCRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Error.Code, ShouldEqual, RPCErrorInvalidParams)
+			})
+		})
+
+		Convey("When calling an unknown method", func() {
+			w := do(`{"jsonrpc": "2.0", "method": "todo.archive", "id": 1}`)
+
+			Convey("Then it should fail with the method not found code", func() {
+				var res RPCRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Error.Code, ShouldEqual, RPCErrorMethodNotFound)
+			})
+		})
+
+		Convey("When sending a batch mixing calls, notifications and invalid requests", func() {
+			mockStorage.EXPECT().
+				List(gomock.Any()).
+				Return([]storage.Todo{{Model: gorm.Model{ID: 1}, Title: "Test Todo"}}, nil).
+				Times(1)
+			mockStorage.EXPECT().Purge(gomock.Any(), gomock.Eq(2)).Return(nil).Times(1)
+
+			w := do(`[
+				{"jsonrpc": "2.0", "method": "todo.list", "id": 1},
+				{"jsonrpc": "2.0", "method": "todo.delete", "params": {"id": 2, "purge": true}},
+				{"jsonrpc": "1.0", "method": "todo.list", "id": 3},
+				42
+			]`)
+
+			Convey("Then it should answer every call but the notification in order", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var res []RPCRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res, ShouldHaveLength, 3)
+
+				var todos ListTodoRes
+				So(json.Unmarshal(res[0].Result, &todos), ShouldBeNil)
+				So(todos, ShouldHaveLength, 1)
+				So(res[1].Error.Code, ShouldEqual, RPCErrorInvalidRequest)
+				So(string(res[1].ID), ShouldEqual, "3")
+				So(res[2].Error.Code, ShouldEqual, RPCErrorInvalidRequest)
+				So(string(res[2].ID), ShouldEqual, "null")
+			})
+		})
+
+		Convey("When sending only notifications", func() {
+			mockStorage.EXPECT().Delete(gomock.Any(), gomock.Eq(1)).Return(gorm.ErrRecordNotFound).Times(1)
+
+			w := do(`[{"jsonrpc": "2.0", "method": "todo.delete", "params": {"id": 1}}]`)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(w.Body.Len(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When sending an empty batch", func() {
+			w := do(`[]`)
+
+			Convey("Then it should fail with the invalid request code", func() {
+				var res RPCRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Error.Code, ShouldEqual, RPCErrorInvalidRequest)
+			})
+		})
+
+		Convey("When sending invalid JSON", func() {
+			w := do(`{"jsonrpc": "2.0", "method"`)
+
+			Convey("Then it should fail with the parse error code", func() {
+				var res RPCRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Error.Code, ShouldEqual, RPCErrorParse)
+				So(string(res.ID), ShouldEqual, "null")
+			})
+		})
+	})
+}
@@ -0,0 +1,350 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Conflict policies of POST /sync, applied when a mutation was made on an
+// older version of a todo than the current one.
+const (
+	// SyncConflictLastWriteWins applies the mutation if it was made after the
+	// current version, by the modifiedAt the client reports.
+	SyncConflictLastWriteWins = "lww"
+	// SyncConflictReject never applies such mutations.
+	SyncConflictReject = "reject"
+)
+
+// Statuses of the mutations of POST /sync.
+const (
+	SyncStatusApplied  = "applied"
+	SyncStatusConflict = "conflict"
+	SyncStatusError    = "error"
+)
+
+const syncTokenPrefix = "v1."
+
+var errInvalidSyncToken = errors.New("invalid sync token")
+
+// SyncConfig controls how client mutations are applied.
+type SyncConfig struct {
+	ConflictPolicy string
+	MaxBatchSize   int
+}
+
+type SyncHandler struct {
+	todos   storage.TodoStorage
+	storage storage.SyncStorage
+	config  SyncConfig
+}
+
+// newSyncToken encodes a position in the audit trail, along with the position
+// the sync started from while it has more pages to list. Clients must treat it
+// as opaque.
+func newSyncToken(version, since uint) string {
+	token := syncTokenPrefix + strconv.FormatUint(uint64(version), 10)
+	if since != version {
+		token += "." + strconv.FormatUint(uint64(since), 10)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(token))
+}
+
+// parseSyncToken returns the position a sync token resumes from and the
+// position its sync started from.
+func parseSyncToken(token string) (version, since uint, err error) {
+	if token == "" {
+		return 0, 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(b), syncTokenPrefix) {
+		return 0, 0, errInvalidSyncToken
+	}
+	v, s, paged := strings.Cut(strings.TrimPrefix(string(b), syncTokenPrefix), ".")
+	if !paged {
+		s = v
+	}
+	parsedVersion, err := strconv.ParseUint(v, 10, 0)
+	if err != nil {
+		return 0, 0, errInvalidSyncToken
+	}
+	parsedSince, err := strconv.ParseUint(s, 10, 0)
+	if err != nil || parsedSince > parsedVersion {
+		return 0, 0, errInvalidSyncToken
+	}
+	return uint(parsedVersion), uint(parsedSince), nil
+}
+
+type SyncTodoRes struct {
+	GetTodoRes
+	Version uint `json:"version"`
+}
+
+func newSyncTodoRes(todo storage.Todo, version uint) SyncTodoRes {
+	return SyncTodoRes{
+		GetTodoRes: newGetTodoRes(todo),
+		Version:    version,
+	}
+}
+
+// SyncTombstoneRes marks a deleted todo. DeletedAt is omitted for purged todos.
+type SyncTombstoneRes struct {
+	ID        uint       `json:"id"`
+	Version   uint       `json:"version"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+type GetSyncRes struct {
+	Token   string             `json:"token"`
+	HasMore bool               `json:"hasMore"`
+	Created []SyncTodoRes      `json:"created"`
+	Updated []SyncTodoRes      `json:"updated"`
+	Deleted []SyncTombstoneRes `json:"deleted"`
+}
+
+type GetSyncQuery struct {
+	Since string `form:"since"`
+	Limit int    `form:"limit,default=100" binding:"min=1,max=1000"`
+}
+
+// Get returns the todos changed since the token of the previous sync, or all
+// of them without one, and the token to pass next time. Clients keep syncing
+// while hasMore is set.
+func (h *SyncHandler) Get(c *gin.Context) {
+	var query GetSyncQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	version, since, err := parseSyncToken(query.Since)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	changes, err := h.storage.ListChanges(c, since, version, query.Limit+1)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	res := GetSyncRes{
+		Created: []SyncTodoRes{},
+		Updated: []SyncTodoRes{},
+		Deleted: []SyncTombstoneRes{},
+	}
+	if len(changes) > query.Limit {
+		changes = changes[:query.Limit]
+		res.HasMore = true
+	}
+	for _, change := range changes {
+		switch {
+		case change.Deleted:
+			tombstone := SyncTombstoneRes{ID: change.TodoID, Version: change.Version}
+			if change.Todo.DeletedAt.Valid {
+				tombstone.DeletedAt = &change.Todo.DeletedAt.Time
+			}
+			res.Deleted = append(res.Deleted, tombstone)
+		case change.Created:
+			res.Created = append(res.Created, newSyncTodoRes(change.Todo, change.Version))
+		default:
+			res.Updated = append(res.Updated, newSyncTodoRes(change.Todo, change.Version))
+		}
+		version = change.Version
+	}
+	// The todos created since the sync started are still reported as created
+	// on the next pages.
+	if res.HasMore {
+		res.Token = newSyncToken(version, since)
+	} else {
+		res.Token = newSyncToken(version, version)
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+const (
+	SyncOpCreate = "create"
+	SyncOpUpdate = "update"
+	SyncOpDelete = "delete"
+)
+
+// SyncMutationReq is a mutation made by a client while offline. BaseVersion is
+// the version of the todo it was made on; without one the mutation is applied
+// regardless of concurrent changes.
+type SyncMutationReq struct {
+	ClientID    string          `json:"clientId" binding:"required"`
+	Op          string          `json:"op" binding:"required,oneof=create update delete"`
+	ID          int             `json:"id" binding:"required_unless=Op create"`
+	BaseVersion *uint           `json:"baseVersion"`
+	ModifiedAt  *time.Time      `json:"modifiedAt"`
+	Data        json.RawMessage `json:"data"`
+}
+
+type PushSyncReq struct {
+	Mutations []SyncMutationReq `json:"mutations" binding:"required,dive"`
+}
+
+// SyncResultRes is the outcome of a mutation. Todo is the state of the todo on
+// the server after the mutation, or the conflicting one, and is omitted once
+// the todo is deleted.
+type SyncResultRes struct {
+	ClientID string       `json:"clientId"`
+	Status   string       `json:"status"`
+	Todo     *SyncTodoRes `json:"todo,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+type PushSyncRes struct {
+	Results []SyncResultRes `json:"results"`
+}
+
+// Push applies the mutations of a client in order. Each one succeeds or fails
+// on its own, and the results tell which ones the client must reconcile.
+func (h *SyncHandler) Push(c *gin.Context) {
+	var req PushSyncReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+	if len(req.Mutations) > h.config.MaxBatchSize {
+		err := fmt.Errorf("at most %d mutations can be synced at once", h.config.MaxBatchSize)
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	res := PushSyncRes{Results: make([]SyncResultRes, 0, len(req.Mutations))}
+	for _, mutation := range req.Mutations {
+		result, err := h.apply(c, mutation)
+		if err != nil {
+			if status := todoErrorStatus(err); status == http.StatusInternalServerError {
+				c.Error(err)
+				c.AbortWithStatusJSON(status, ErrorRes{Error: err.Error()})
+				return
+			}
+			result = newSyncErrorRes(mutation, err)
+		}
+		res.Results = append(res.Results, result)
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *SyncHandler) apply(c *gin.Context, mutation SyncMutationReq) (SyncResultRes, error) {
+	result := SyncResultRes{ClientID: mutation.ClientID, Status: SyncStatusApplied}
+
+	if mutation.Op == SyncOpCreate {
+		var data CreateTodoReq
+		if err := bindData(mutation.Data, &data); err != nil {
+			return newSyncErrorRes(mutation, err), nil
+		}
+
+		var todo storage.Todo
+		todo.Title = data.Title
+		todo.Description = data.Description
+		todo.DueAt = data.DueAt
+		todo.RRule = data.RRule
+		todo.ProjectID = data.ProjectID
+
+		if err := h.todos.Create(c, &todo); err != nil {
+			return result, err
+		}
+		return h.withTodo(c, result, int(todo.ID))
+	}
+
+	current, err := h.storage.GetVersion(c, mutation.ID)
+	if storage.IsNotFound(err) {
+		// Deleted meanwhile: deleting again is a no-op, while changes are lost.
+		if mutation.Op != SyncOpDelete {
+			result.Status = SyncStatusConflict
+		}
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+	if h.conflicts(mutation, current) {
+		result.Status = SyncStatusConflict
+		return h.withTodo(c, result, mutation.ID)
+	}
+
+	if mutation.Op == SyncOpDelete {
+		return result, h.todos.Delete(c, mutation.ID)
+	}
+
+	var data UpdateTodoReq
+	if err := bindData(mutation.Data, &data); err != nil {
+		return newSyncErrorRes(mutation, err), nil
+	}
+
+	var todo storage.Todo
+	todo.Title = data.Title
+	todo.Description = data.Description
+	todo.Completed = data.Completed
+	todo.DueAt = data.DueAt
+	todo.RRule = data.RRule
+
+	if err := h.todos.Update(c, mutation.ID, todo); err != nil {
+		return result, err
+	}
+	return h.withTodo(c, result, mutation.ID)
+}
+
+func newSyncErrorRes(mutation SyncMutationReq, err error) SyncResultRes {
+	return SyncResultRes{ClientID: mutation.ClientID, Status: SyncStatusError, Error: err.Error()}
+}
+
+// conflicts reports whether a mutation must not be applied on the current
+// version of its todo under the configured policy.
+func (h *SyncHandler) conflicts(mutation SyncMutationReq, current storage.TodoVersion) bool {
+	if mutation.BaseVersion == nil || *mutation.BaseVersion == current.Version {
+		return false
+	}
+	if h.config.ConflictPolicy == SyncConflictLastWriteWins {
+		return mutation.ModifiedAt != nil && !mutation.ModifiedAt.After(current.ModifiedAt)
+	}
+	return true
+}
+
+func (h *SyncHandler) withTodo(c *gin.Context, result SyncResultRes, id int) (SyncResultRes, error) {
+	todo, err := h.todos.Get(c, id)
+	if err != nil {
+		return result, err
+	}
+	version, err := h.storage.GetVersion(c, id)
+	if err != nil {
+		return result, err
+	}
+	result.Todo = ptr(newSyncTodoRes(todo, version.Version))
+	return result, nil
+}
+
+func RegisterSyncHandler(e *gin.Engine, todos storage.TodoStorage, s storage.SyncStorage, cfg SyncConfig) error {
+	h := &SyncHandler{
+		todos:   todos,
+		storage: s,
+		config:  cfg,
+	}
+
+	sync := e.Group("/sync")
+	{
+		sync.GET("", h.Get)
+		sync.POST("", h.Push)
+	}
+
+	return nil
+}
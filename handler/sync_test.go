@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestSyncHandler_Get(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a SyncHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockTodoStorage := mock.NewMockTodoStorage(ctrl)
+		mockStorage := mock.NewMockSyncStorage(ctrl)
+		e := gin.Default()
+		RegisterSyncHandler(e, mockTodoStorage, mockStorage, SyncConfig{ConflictPolicy: SyncConflictReject, MaxBatchSize: 10})
+
+		Convey("When syncing since a token with more changes than the limit", func() {
+			deletedAt := time.Now()
+			mockStorage.EXPECT().
+				ListChanges(gomock.Any(), gomock.Eq(uint(5)), gomock.Eq(uint(5)), gomock.Eq(3)).
+				Return([]storage.TodoChange{
+					{TodoVersion: storage.TodoVersion{TodoID: 1, Version: 6}, Todo: storage.Todo{Model: gorm.Model{ID: 1}, Title: "new"}, Created: true},
+					{TodoVersion: storage.TodoVersion{TodoID: 2, Version: 8}, Todo: storage.Todo{Model: gorm.Model{ID: 2, DeletedAt: gorm.DeletedAt{Time: deletedAt, Valid: true}}}, Deleted: true},
+					{TodoVersion: storage.TodoVersion{TodoID: 3, Version: 9}, Todo: storage.Todo{Model: gorm.Model{ID: 3}, Title: "changed"}},
+				}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/sync?limit=2&since="+newSyncToken(5, 5), nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return the first page and the token to resume from", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var res GetSyncRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.HasMore, ShouldBeTrue)
+				So(res.Token, ShouldEqual, newSyncToken(8, 5))
+				So(res.Created, ShouldHaveLength, 1)
+				So(res.Created[0].Version, ShouldEqual, 6)
+				So(res.Updated, ShouldBeEmpty)
+				So(res.Deleted, ShouldHaveLength, 1)
+				So(res.Deleted[0].ID, ShouldEqual, 2)
+				So(res.Deleted[0].DeletedAt, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When syncing without changes", func() {
+			mockStorage.EXPECT().ListChanges(gomock.Any(), gomock.Eq(uint(7)), gomock.Eq(uint(7)), gomock.Any()).Return(nil, nil).Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/sync?since="+newSyncToken(7, 7), nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return the same token", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var res GetSyncRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Token, ShouldEqual, newSyncToken(7, 7))
+				So(res.HasMore, ShouldBeFalse)
+			})
+		})
+
+		Convey("When resuming a sync with the token of a page", func() {
+			mockStorage.EXPECT().
+				ListChanges(gomock.Any(), gomock.Eq(uint(5)), gomock.Eq(uint(8)), gomock.Eq(3)).
+				Return([]storage.TodoChange{
+					{TodoVersion: storage.TodoVersion{TodoID: 3, Version: 9}, Todo: storage.Todo{Model: gorm.Model{ID: 3}, Title: "new"}, Created: true},
+				}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/sync?limit=2&since="+newSyncToken(8, 5), nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should list the changes since the start of the sync from the page on", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var res GetSyncRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.HasMore, ShouldBeFalse)
+				So(res.Token, ShouldEqual, newSyncToken(9, 9))
+				So(res.Created, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When syncing with a token starting after its position", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/sync?since="+newSyncToken(5, 8), nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When syncing with an invalid token", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/sync?since=garbage", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestSyncHandler_Push(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a SyncHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockTodoStorage := mock.NewMockTodoStorage(ctrl)
+		mockStorage := mock.NewMockSyncStorage(ctrl)
+		cfg := SyncConfig{ConflictPolicy: SyncConflictReject, MaxBatchSize: 2}
+
+		push := func(body string) (*httptest.ResponseRecorder, PushSyncRes) {
+			e := gin.Default()
+			RegisterSyncHandler(e, mockTodoStorage, mockStorage, cfg)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/sync", bytes.NewBufferString(body))
+			e.ServeHTTP(w, req)
+
+			var res PushSyncRes
+			json.Unmarshal(w.Body.Bytes(), &res)
+			return w, res
+		}
+
+		modifiedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		current := storage.TodoVersion{TodoID: 1, Version: 4, ModifiedAt: modifiedAt}
+
+		Convey("When updating a todo changed since its base version", func() {
+			mockStorage.EXPECT().GetVersion(gomock.Any(), gomock.Eq(1)).Return(current, nil).Times(2)
+			mockTodoStorage.EXPECT().Get(gomock.Any(), gomock.Eq(1)).Return(storage.Todo{Model: gorm.Model{ID: 1}, Title: "theirs"}, nil).Times(1)
+
+			w, res := push(`{"mutations":[{"clientId":"a","op":"update","id":1,"baseVersion":3,"modifiedAt":"2024-01-02T00:00:00Z","data":{"title":"mine"}}]}`)
+
+			Convey("Then it should be rejected with the current todo", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(res.Results, ShouldHaveLength, 1)
+				So(res.Results[0].Status, ShouldEqual, SyncStatusConflict)
+				So(res.Results[0].Todo.Title, ShouldEqual, "theirs")
+				So(res.Results[0].Todo.Version, ShouldEqual, 4)
+			})
+		})
+
+		Convey("When the last write wins", func() {
+			cfg.ConflictPolicy = SyncConflictLastWriteWins
+			mockStorage.EXPECT().GetVersion(gomock.Any(), gomock.Eq(1)).Return(current, nil).AnyTimes()
+			mockTodoStorage.EXPECT().Get(gomock.Any(), gomock.Eq(1)).Return(storage.Todo{Model: gorm.Model{ID: 1}, Title: "mine"}, nil).AnyTimes()
+			mockTodoStorage.EXPECT().Update(gomock.Any(), gomock.Eq(1), gomock.Eq(storage.Todo{Title: "mine"})).Return(nil).Times(1)
+
+			w, res := push(`{"mutations":[` +
+				`{"clientId":"a","op":"update","id":1,"baseVersion":3,"modifiedAt":"2024-01-02T00:00:00Z","data":{"title":"mine"}},` +
+				`{"clientId":"b","op":"update","id":1,"baseVersion":3,"modifiedAt":"2023-12-31T00:00:00Z","data":{"title":"older"}}]}`)
+
+			Convey("Then only the later write should be applied", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(res.Results[0].Status, ShouldEqual, SyncStatusApplied)
+				So(res.Results[1].Status, ShouldEqual, SyncStatusConflict)
+			})
+		})
+
+		Convey("When creating and deleting todos", func() {
+			mockTodoStorage.EXPECT().
+				Create(gomock.Any(), gomock.Eq(&storage.Todo{Title: "offline"})).
+				DoAndReturn(func(_ any, todo *storage.Todo) error {
+					todo.ID = 2
+					return nil
+				}).
+				Times(1)
+			mockTodoStorage.EXPECT().Get(gomock.Any(), gomock.Eq(2)).Return(storage.Todo{Model: gorm.Model{ID: 2}, Title: "offline"}, nil).Times(1)
+			mockStorage.EXPECT().GetVersion(gomock.Any(), gomock.Eq(2)).Return(storage.TodoVersion{TodoID: 2, Version: 10}, nil).Times(1)
+			mockStorage.EXPECT().GetVersion(gomock.Any(), gomock.Eq(9)).Return(storage.TodoVersion{}, gorm.ErrRecordNotFound).Times(1)
+
+			w, res := push(`{"mutations":[{"clientId":"a","op":"create","data":{"title":"offline"}},{"clientId":"b","op":"delete","id":9}]}`)
+
+			Convey("Then both should be applied", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(res.Results[0].Status, ShouldEqual, SyncStatusApplied)
+				So(res.Results[0].Todo.ID, ShouldEqual, 2)
+				So(res.Results[0].Todo.Version, ShouldEqual, 10)
+				So(res.Results[1].Status, ShouldEqual, SyncStatusApplied)
+				So(res.Results[1].Todo, ShouldBeNil)
+			})
+		})
+
+		Convey("When a mutation is invalid", func() {
+			w, res := push(`{"mutations":[{"clientId":"a","op":"create","data":{"description":"no title"}}]}`)
+
+			Convey("Then only its result should be an error", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(res.Results[0].Status, ShouldEqual, SyncStatusError)
+				So(res.Results[0].Error, ShouldNotBeEmpty)
+			})
+		})
+
+		Convey("When pushing more mutations than allowed", func() {
+			w, _ := push(`{"mutations":[{"clientId":"a","op":"delete","id":1},{"clientId":"b","op":"delete","id":2},{"clientId":"c","op":"delete","id":3}]}`)
+
+			Convey("Then it should return 413 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			})
+		})
+	})
+}
@@ -1,29 +1,194 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 
 	"github.com/wei840222/go-restful-sample/storage"
 )
 
 type TodoHandler struct {
 	storage storage.TodoStorage
+	tx      storage.TxManager
 }
 
 type GetTodoRes struct {
-	ID          uint      `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description,omitempty"`
-	Completed   bool      `json:"completed"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status"`
+	Completed   bool       `json:"completed"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	RRule       string     `json:"rrule,omitempty"`
+	SeriesID    *uint      `json:"seriesId,omitempty"`
+	ProjectID   *uint      `json:"projectId,omitempty"`
+	Priority    string     `json:"priority,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+
+	ExternalSource string `json:"externalSource,omitempty"`
+	ExternalID     string `json:"externalId,omitempty"`
 }
 
-func (h *TodoHandler) Get(c *gin.Context) {
+func newGetTodoRes(todo storage.Todo) GetTodoRes {
+	res := GetTodoRes{
+		ID:          todo.ID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Status:      todo.Status,
+		DueAt:       todo.DueAt,
+		RRule:       todo.RRule,
+		SeriesID:    todo.SeriesID,
+		ProjectID:   todo.ProjectID,
+		Priority:    todo.Priority,
+		Tags:        todo.Tags,
+		CreatedAt:   todo.CreatedAt,
+		UpdatedAt:   todo.UpdatedAt,
+	}
+	if todo.Completed != nil {
+		res.Completed = *todo.Completed
+	}
+	if todo.ExternalSource != nil && todo.ExternalID != nil {
+		res.ExternalSource = *todo.ExternalSource
+		res.ExternalID = *todo.ExternalID
+	}
+	return res
+}
+
+type ListTodoRes []GetTodoRes
+
+func newListTodoRes(todos []storage.Todo) ListTodoRes {
+	res := make(ListTodoRes, 0, len(todos))
+	for _, todo := range todos {
+		res = append(res, newGetTodoRes(todo))
+	}
+	return res
+}
+
+type CreateTodoReq struct {
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	DueAt       *time.Time `json:"dueAt"`
+	RRule       string     `json:"rrule"`
+	ProjectID   *uint      `json:"projectId"`
+}
+
+type UpdateTodoReq struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   *bool      `json:"completed"`
+	DueAt       *time.Time `json:"dueAt"`
+	RRule       string     `json:"rrule"`
+}
+
+// ReplaceTodoReq is the whole of a todo as sent to PUT /todos/:id. Omitted
+// fields are reset.
+type ReplaceTodoReq struct {
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueAt       *time.Time `json:"dueAt"`
+	RRule       string     `json:"rrule"`
+	Priority    string     `json:"priority" binding:"omitempty,len=1,alpha,uppercase"`
+	Tags        []string   `json:"tags"`
+}
+
+// ReplaceTodoQuery names the system a todo is synced from when replacing it
+// by its ID there.
+type ReplaceTodoQuery struct {
+	Source string `form:"source" binding:"max=64"`
+}
+
+const (
+	UpdateScopeOccurrence = "occurrence"
+	UpdateScopeSeries     = "series"
+)
+
+type UpdateTodoQuery struct {
+	Scope string `form:"scope,default=occurrence" binding:"oneof=occurrence series"`
+}
+
+// Replace sets every field of a todo that can be edited from a
+// ReplaceTodoReq and returns the result. With a source, the id is the ID of
+// the todo in that source rather than ours, and the todo is created with 201
+// when it does not exist yet, which makes syncing from it idempotent.
+func (h *TodoHandler) Replace(c *gin.Context) {
+	var query ReplaceTodoQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var req ReplaceTodoReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	todo := storage.Todo{
+		Title:       req.Title,
+		Description: req.Description,
+		Completed:   &req.Completed,
+		DueAt:       req.DueAt,
+		RRule:       req.RRule,
+		Priority:    req.Priority,
+		Tags:        req.Tags,
+	}
+
+	status := http.StatusOK
+	if query.Source != "" {
+		todo.ExternalSource = &query.Source
+		todo.ExternalID = ptr(c.Param("id"))
+
+		created, err := h.storage.Upsert(c, &todo)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(todoErrorStatus(err), ErrorRes{Error: err.Error()})
+			return
+		}
+		if created {
+			status = http.StatusCreated
+		}
+	} else {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+			return
+		}
+
+		err = h.tx.WithinTx(c, func(ctx context.Context) error {
+			if err := h.storage.Replace(ctx, id, todo); err != nil {
+				return err
+			}
+			todo, err = h.storage.Get(ctx, id)
+			return err
+		})
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(todoErrorStatus(err), ErrorRes{Error: err.Error()})
+			return
+		}
+	}
+
+	c.JSON(status, newGetTodoRes(todo))
+}
+
+// Update applies the set fields of an UpdateTodoReq sent as application/json,
+// or a patch document, which can also clear fields, in one of the patch media
+// types.
+func (h *TodoHandler) Update(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.Error(err)
@@ -31,11 +196,61 @@ func (h *TodoHandler) Get(c *gin.Context) {
 		return
 	}
 
-	todo, err := h.storage.Get(c, id)
+	switch c.ContentType() {
+	case "", binding.MIMEJSON:
+	case MIMEMergePatch, MIMEJSONPatch:
+		h.patch(c, id)
+		return
+	default:
+		err := fmt.Errorf("unsupported content type %q", c.ContentType())
+		c.Header("Accept-Patch", strings.Join([]string{binding.MIMEJSON, MIMEMergePatch, MIMEJSONPatch}, ", "))
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var query UpdateTodoQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var req UpdateTodoReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var todo storage.Todo
+	todo.Title = req.Title
+	todo.Description = req.Description
+	todo.Completed = req.Completed
+	todo.DueAt = req.DueAt
+	todo.RRule = req.RRule
+
+	if query.Scope == UpdateScopeSeries {
+		if req.Completed != nil || req.DueAt != nil {
+			err := errors.New("completed and dueAt can only be updated on a single occurrence")
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+			return
+		}
+		err = h.storage.UpdateSeries(c, id, todo)
+	} else {
+		err = h.storage.Update(c, id, todo)
+	}
 	if err != nil {
 		if storage.IsNotFound(err) {
 			c.Error(err)
 			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else if storage.IsInvalidRRule(err) || storage.IsUnknownStatus(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		} else if storage.IsIllegalTransition(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusConflict, ErrorRes{Error: err.Error()})
 		} else {
 			c.Error(err)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
@@ -43,88 +258,203 @@ func (h *TodoHandler) Get(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, &GetTodoRes{
-		ID:          todo.ID,
-		Title:       todo.Title,
-		Description: todo.Description,
-		Completed:   *todo.Completed,
-		CreatedAt:   todo.CreatedAt,
-		UpdatedAt:   todo.UpdatedAt,
+	c.Status(http.StatusNoContent)
+}
+
+// patch applies the patch document in the body to the stored todo and saves it
+// once validated, in a transaction so that the todo cannot change meanwhile.
+func (h *TodoHandler) patch(c *gin.Context, id int) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	err = h.tx.WithinTx(c, func(ctx context.Context) error {
+		todo, err := h.storage.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if todo, err = patchTodo(c.ContentType(), todo, body); err != nil {
+			return err
+		}
+		return h.storage.Replace(ctx, id, todo)
 	})
+	if err != nil {
+		var patchErr *patchError
+		if errors.As(err, &patchErr) {
+			c.Error(err)
+			c.AbortWithStatusJSON(patchErr.status, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(todoErrorStatus(err), ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
 }
 
-type ListTodoRes []GetTodoRes
+type DeleteTodoQuery struct {
+	// Purge removes the todo permanently together with its comments and
+	// attachments instead of soft deleting it.
+	Purge bool `form:"purge"`
+}
 
-func (h *TodoHandler) List(c *gin.Context) {
-	todos, err := h.storage.List(c)
+func (h *TodoHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.Error(err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
 		return
 	}
 
-	var res ListTodoRes
-	res = make(ListTodoRes, 0, len(todos))
-	for _, todo := range todos {
-		getTodoRes := GetTodoRes{
-			ID:          todo.ID,
-			Title:       todo.Title,
-			Description: todo.Description,
-			CreatedAt:   todo.CreatedAt,
-			UpdatedAt:   todo.UpdatedAt,
-		}
-		if todo.Completed != nil {
-			getTodoRes.Completed = *todo.Completed
+	var query DeleteTodoQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	if query.Purge {
+		err = h.storage.Purge(c, id)
+	} else {
+		err = h.storage.Delete(c, id)
+	}
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
 		}
-		res = append(res, getTodoRes)
+		return
 	}
 
-	c.JSON(http.StatusOK, res)
+	c.Status(http.StatusNoContent)
 }
 
-type CreateTodoReq struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description"`
+type CreateTransitionReq struct {
+	Status string `json:"status" binding:"required"`
 }
 
-func (h *TodoHandler) Create(c *gin.Context) {
-	var req CreateTodoReq
+type GetTransitionRes struct {
+	ID        uint      `json:"id"`
+	TodoID    uint      `json:"todoId"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (h *TodoHandler) CreateTransition(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var req CreateTransitionReq
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.Error(err)
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
 		return
 	}
 
-	var todo storage.Todo
-	todo.Title = req.Title
-	todo.Description = req.Description
+	transition, err := h.storage.Transition(c, id, req.Status)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else if storage.IsUnknownStatus(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		} else if storage.IsIllegalTransition(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusConflict, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
 
-	if err := h.storage.Create(c, &todo); err != nil {
+	c.JSON(http.StatusCreated, GetTransitionRes{
+		ID:        transition.ID,
+		TodoID:    transition.TodoID,
+		From:      transition.From,
+		To:        transition.To,
+		CreatedAt: transition.CreatedAt,
+	})
+}
+
+type ListTransitionRes []GetTransitionRes
+
+func (h *TodoHandler) ListTransitions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
 		c.Error(err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
 		return
 	}
 
-	res := GetTodoRes{
-		ID:          todo.ID,
-		Title:       todo.Title,
-		Description: todo.Description,
-		CreatedAt:   todo.CreatedAt,
-		UpdatedAt:   todo.UpdatedAt,
+	transitions, err := h.storage.ListTransitions(c, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
 	}
-	if todo.Completed != nil {
-		res.Completed = *todo.Completed
+
+	res := make(ListTransitionRes, 0, len(transitions))
+	for _, transition := range transitions {
+		res = append(res, GetTransitionRes{
+			ID:        transition.ID,
+			TodoID:    transition.TodoID,
+			From:      transition.From,
+			To:        transition.To,
+			CreatedAt: transition.CreatedAt,
+		})
 	}
-	c.JSON(http.StatusCreated, res)
+
+	c.JSON(http.StatusOK, res)
 }
 
-type UpdateTodoReq struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   *bool  `json:"completed"`
+// ListHistory returns the audit trail of a todo, oldest first.
+func (h *TodoHandler) ListHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	events, err := h.storage.ListHistory(c, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newListTodoEventRes(events))
 }
 
-func (h *TodoHandler) Update(c *gin.Context) {
+type MoveTodoToProjectReq struct {
+	ProjectID *uint `json:"projectId"`
+}
+
+func (h *TodoHandler) MoveToProject(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.Error(err)
@@ -132,19 +462,14 @@ func (h *TodoHandler) Update(c *gin.Context) {
 		return
 	}
 
-	var req UpdateTodoReq
+	var req MoveTodoToProjectReq
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.Error(err)
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
 		return
 	}
 
-	var todo storage.Todo
-	todo.Title = req.Title
-	todo.Description = req.Description
-	todo.Completed = req.Completed
-
-	if err := h.storage.Update(c, id, todo); err != nil {
+	if err := h.storage.MoveToProject(c, id, req.ProjectID); err != nil {
 		if storage.IsNotFound(err) {
 			c.Error(err)
 			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
@@ -158,7 +483,12 @@ func (h *TodoHandler) Update(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-func (h *TodoHandler) Delete(c *gin.Context) {
+type MoveTodoReq struct {
+	After  *int `json:"after"`
+	Before *int `json:"before"`
+}
+
+func (h *TodoHandler) Move(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.Error(err)
@@ -166,10 +496,20 @@ func (h *TodoHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.storage.Delete(c, id); err != nil {
+	var req MoveTodoReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	if err := h.storage.Move(c, id, req.After, req.Before); err != nil {
 		if storage.IsNotFound(err) {
 			c.Error(err)
 			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else if storage.IsInvalidMove(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
 		} else {
 			c.Error(err)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
@@ -180,18 +520,70 @@ func (h *TodoHandler) Delete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-func RegisterTodoHandler(e *gin.Engine, s storage.TodoStorage) error {
+type ListOccurrencesQuery struct {
+	Count int `form:"count,default=10" binding:"min=1,max=100"`
+}
+
+type ListOccurrencesRes []time.Time
+
+func (h *TodoHandler) ListOccurrences(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var query ListOccurrencesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	occurrences, err := h.storage.ListOccurrences(c, id, query.Count)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else if storage.IsInvalidRRule(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ListOccurrencesRes(occurrences))
+}
+
+func RegisterTodoHandler(e *gin.Engine, s storage.TodoStorage, tx storage.TxManager) error {
 	h := &TodoHandler{
 		storage: s,
+		tx:      tx,
+	}
+
+	gateway, err := newTodoGateway(s)
+	if err != nil {
+		return err
 	}
 
 	todo := e.Group("/todos")
 	{
-		todo.GET("", h.List)
-		todo.POST("", h.Create)
-		todo.GET("/:id", h.Get)
+		todo.GET("", gateway)
+		todo.POST("", gateway)
+		todo.GET("/:id", gateway)
+		todo.PUT("/:id", h.Replace)
 		todo.PATCH("/:id", h.Update)
 		todo.DELETE("/:id", h.Delete)
+		todo.GET("/:id/occurrences", h.ListOccurrences)
+		todo.POST("/:id/move", h.Move)
+		todo.PUT("/:id/project", h.MoveToProject)
+		todo.GET("/:id/transitions", h.ListTransitions)
+		todo.POST("/:id/transitions", h.CreateTransition)
+		todo.GET("/:id/history", h.ListHistory)
 	}
 
 	return nil
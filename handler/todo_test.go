@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -27,7 +28,7 @@ func TestTodoHandler_Get(t *testing.T) {
 
 		mockStorage := mock.NewMockTodoStorage(ctrl)
 		e := gin.Default()
-		RegisterTodoHandler(e, mockStorage)
+		RegisterTodoHandler(e, mockStorage, mock.NewMockTxManager(ctrl))
 
 		Convey("When getting a todo with valid ID", func() {
 			now := time.Now()
@@ -120,7 +121,7 @@ func TestTodoHandler_List(t *testing.T) {
 
 		mockStorage := mock.NewMockTodoStorage(ctrl)
 		e := gin.Default()
-		RegisterTodoHandler(e, mockStorage)
+		RegisterTodoHandler(e, mockStorage, mock.NewMockTxManager(ctrl))
 
 		Convey("When listing todos successfully", func() {
 			now := time.Now()
@@ -233,7 +234,7 @@ func TestTodoHandler_Create(t *testing.T) {
 
 		mockStorage := mock.NewMockTodoStorage(ctrl)
 		e := gin.Default()
-		RegisterTodoHandler(e, mockStorage)
+		RegisterTodoHandler(e, mockStorage, mock.NewMockTxManager(ctrl))
 
 		Convey("When creating a new todo with valid input", func() {
 			now := time.Now()
@@ -284,6 +285,76 @@ func TestTodoHandler_Create(t *testing.T) {
 			})
 		})
 
+		Convey("When creating a todo without a title", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos", bytes.NewBufferString(`{"description": "Test Description"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code with the error", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+
+				var res ErrorRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Error, ShouldEqual, "title is required")
+			})
+		})
+
+		Convey("When creating a todo in a project with a due date", func() {
+			dueAt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+			projectID := uint(2)
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Eq(&storage.Todo{Title: "Test Todo", DueAt: &dueAt, ProjectID: &projectID})).
+				Return(nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos", bytes.NewBufferString(`{"title": "Test Todo", "dueAt": "2024-01-01T09:00:00Z", "projectId": 2, "unknown": true}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should transcode the request fields", func() {
+				So(w.Code, ShouldEqual, http.StatusCreated)
+
+				var res GetTodoRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(*res.DueAt, ShouldEqual, dueAt)
+				So(*res.ProjectID, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When creating a todo with a priority and tags", func() {
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Eq(&storage.Todo{Title: "Call mom", Priority: "A", Tags: []string{"@phone", "+family"}})).
+				Return(nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos", bytes.NewBufferString(`{"title": "Call mom", "priority": "A", "tags": ["@phone", "+family"]}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should transcode them both ways", func() {
+				So(w.Code, ShouldEqual, http.StatusCreated)
+
+				var res GetTodoRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Priority, ShouldEqual, "A")
+				So(res.Tags, ShouldResemble, []string{"@phone", "+family"})
+			})
+		})
+
+		Convey("When creating a todo with an invalid priority", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos", bytes.NewBufferString(`{"title": "Call mom", "priority": "high"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
 		Convey("When storage returns an error", func() {
 			mockStorage.EXPECT().
 				Create(gomock.Any(), gomock.Any()).
@@ -314,7 +385,7 @@ func TestTodoHandler_Update(t *testing.T) {
 
 		mockStorage := mock.NewMockTodoStorage(ctrl)
 		e := gin.Default()
-		RegisterTodoHandler(e, mockStorage)
+		RegisterTodoHandler(e, mockStorage, mock.NewMockTxManager(ctrl))
 
 		Convey("When updating a todo with valid input", func() {
 			mockStorage.EXPECT().
@@ -418,7 +489,7 @@ func TestTodoHandler_Delete(t *testing.T) {
 
 		mockStorage := mock.NewMockTodoStorage(ctrl)
 		e := gin.Default()
-		RegisterTodoHandler(e, mockStorage)
+		RegisterTodoHandler(e, mockStorage, mock.NewMockTxManager(ctrl))
 
 		Convey("When deleting a todo with valid ID", func() {
 			mockStorage.EXPECT().
@@ -435,6 +506,21 @@ func TestTodoHandler_Delete(t *testing.T) {
 			})
 		})
 
+		Convey("When purging a todo", func() {
+			mockStorage.EXPECT().
+				Purge(gomock.Any(), gomock.Eq(1)).
+				Return(nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/todos/1?purge=true", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
 		Convey("When deleting a todo with invalid ID format", func() {
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest(http.MethodDelete, "/todos/invalid", nil)
@@ -476,3 +562,626 @@ func TestTodoHandler_Delete(t *testing.T) {
 		})
 	})
 }
+
+func TestTodoHandler_CreateTransition(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a TodoHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		e := gin.Default()
+		RegisterTodoHandler(e, mockStorage, mock.NewMockTxManager(ctrl))
+
+		Convey("When transitioning a todo to an allowed status", func() {
+			now := time.Now()
+			mockStorage.EXPECT().
+				Transition(gomock.Any(), gomock.Eq(1), gomock.Eq("in_progress")).
+				Return(storage.TodoTransition{
+					ID:        1,
+					CreatedAt: now,
+					TodoID:    1,
+					From:      "todo",
+					To:        "in_progress",
+				}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/transitions", bytes.NewBufferString(`{"status": "in_progress"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 201 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusCreated)
+			})
+
+			Convey("And return the transition", func() {
+				var res GetTransitionRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(res.TodoID, ShouldEqual, 1)
+				So(res.From, ShouldEqual, "todo")
+				So(res.To, ShouldEqual, "in_progress")
+			})
+		})
+
+		Convey("When transitioning without a status", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/transitions", bytes.NewBufferString(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When transitioning to an unknown status", func() {
+			mockStorage.EXPECT().
+				Transition(gomock.Any(), gomock.Eq(1), gomock.Eq("archived")).
+				Return(storage.TodoTransition{}, storage.ErrUnknownStatus).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/transitions", bytes.NewBufferString(`{"status": "archived"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When the transition is not allowed", func() {
+			mockStorage.EXPECT().
+				Transition(gomock.Any(), gomock.Eq(1), gomock.Eq("in_review")).
+				Return(storage.TodoTransition{}, storage.ErrIllegalTransition).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/transitions", bytes.NewBufferString(`{"status": "in_review"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 409 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusConflict)
+			})
+		})
+
+		Convey("When todo is not found", func() {
+			mockStorage.EXPECT().
+				Transition(gomock.Any(), gomock.Eq(999), gomock.Any()).
+				Return(storage.TodoTransition{}, gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/999/transitions", bytes.NewBufferString(`{"status": "done"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestTodoHandler_ListTransitions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a TodoHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		e := gin.Default()
+		RegisterTodoHandler(e, mockStorage, mock.NewMockTxManager(ctrl))
+
+		Convey("When listing the transition history of a todo", func() {
+			mockStorage.EXPECT().
+				ListTransitions(gomock.Any(), gomock.Eq(1)).
+				Return([]storage.TodoTransition{
+					{ID: 1, TodoID: 1, From: "todo", To: "in_progress"},
+					{ID: 2, TodoID: 1, From: "in_progress", To: "done"},
+				}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/1/transitions", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 200 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And return the transitions in order", func() {
+				var res ListTransitionRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(len(res), ShouldEqual, 2)
+				So(res[0].To, ShouldEqual, "in_progress")
+				So(res[1].To, ShouldEqual, "done")
+			})
+		})
+
+		Convey("When todo is not found", func() {
+			mockStorage.EXPECT().
+				ListTransitions(gomock.Any(), gomock.Eq(999)).
+				Return(nil, gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/999/transitions", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestTodoHandler_ListHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a TodoHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		e := gin.Default()
+		RegisterTodoHandler(e, mockStorage, mock.NewMockTxManager(ctrl))
+
+		Convey("When listing the history of a todo", func() {
+			mockStorage.EXPECT().
+				ListHistory(gomock.Any(), gomock.Eq(1)).
+				Return([]storage.TodoEvent{
+					{ID: 1, TodoID: 1, Action: storage.TodoEventCreated, Actor: "alice", Changes: []storage.FieldChange{
+						{Field: "title", After: "Test Todo"},
+					}},
+					{ID: 2, TodoID: 1, Action: storage.TodoEventUpdated, Actor: "bob", RequestID: "req-1", Changes: []storage.FieldChange{
+						{Field: "title", Before: "Test Todo", After: "Renamed Todo"},
+					}},
+				}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/1/history", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 200 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And return the field changes in order", func() {
+				var res ListTodoEventRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(len(res), ShouldEqual, 2)
+				So(res[1].Actor, ShouldEqual, "bob")
+				So(res[1].RequestID, ShouldEqual, "req-1")
+				So(res[1].Changes, ShouldResemble, []FieldChangeRes{
+					{Field: "title", Before: "Test Todo", After: "Renamed Todo"},
+				})
+			})
+		})
+
+		Convey("When todo is not found", func() {
+			mockStorage.EXPECT().
+				ListHistory(gomock.Any(), gomock.Eq(999)).
+				Return(nil, gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/999/history", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestTodoHandler_Recurrence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a TodoHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		e := gin.Default()
+		RegisterTodoHandler(e, mockStorage, mock.NewMockTxManager(ctrl))
+
+		Convey("When creating a todo with an invalid recurrence rule", func() {
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, todo *storage.Todo) error {
+					So(todo.RRule, ShouldEqual, "FREQ=SOMETIMES")
+					return storage.ErrInvalidRRule
+				}).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos", bytes.NewBufferString(`{
+																							"title": "Water plants",
+																							"dueAt": "2026-01-05T09:00:00Z",
+																							"rrule": "FREQ=SOMETIMES"
+																						}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When updating the entire series", func() {
+			mockStorage.EXPECT().
+				UpdateSeries(gomock.Any(), gomock.Eq(1), gomock.Any()).
+				DoAndReturn(func(_ any, _ int, todo storage.Todo) error {
+					So(todo.Title, ShouldEqual, "Water all plants")
+					return nil
+				}).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/todos/1?scope=series", bytes.NewBufferString(`{"title": "Water all plants"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When completing the entire series", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/todos/1?scope=series", bytes.NewBufferString(`{"completed": true}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When updating with an unknown scope", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/todos/1?scope=everything", bytes.NewBufferString(`{"title": "Water all plants"}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When previewing the next occurrences", func() {
+			monday := time.Date(2026, 1, 12, 9, 0, 0, 0, time.UTC)
+			mockStorage.EXPECT().
+				ListOccurrences(gomock.Any(), gomock.Eq(1), gomock.Eq(2)).
+				Return([]time.Time{monday, monday.AddDate(0, 0, 7)}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/1/occurrences?count=2", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 200 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And return the due dates", func() {
+				var res ListOccurrencesRes
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				So(err, ShouldBeNil)
+				So(len(res), ShouldEqual, 2)
+				So(res[0].Equal(monday), ShouldBeTrue)
+			})
+		})
+
+		Convey("When previewing too many occurrences", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/1/occurrences?count=1000", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When previewing occurrences of a todo that is not recurring", func() {
+			mockStorage.EXPECT().
+				ListOccurrences(gomock.Any(), gomock.Eq(2), gomock.Eq(10)).
+				Return(nil, storage.ErrInvalidRRule).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/2/occurrences", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestTodoHandler_MoveToProject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a TodoHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		e := gin.Default()
+		RegisterTodoHandler(e, mockStorage, mock.NewMockTxManager(ctrl))
+
+		Convey("When moving a todo into a project", func() {
+			mockStorage.EXPECT().
+				MoveToProject(gomock.Any(), gomock.Eq(1), gomock.Any()).
+				DoAndReturn(func(_ any, _ int, projectID *uint) error {
+					So(*projectID, ShouldEqual, 2)
+					return nil
+				}).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/todos/1/project", bytes.NewBufferString(`{"projectId": 2}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When moving a todo back to the inbox", func() {
+			mockStorage.EXPECT().
+				MoveToProject(gomock.Any(), gomock.Eq(1), gomock.Nil()).
+				Return(nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/todos/1/project", bytes.NewBufferString(`{"projectId": null}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When the project is not found", func() {
+			mockStorage.EXPECT().
+				MoveToProject(gomock.Any(), gomock.Eq(1), gomock.Any()).
+				Return(gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/todos/1/project", bytes.NewBufferString(`{"projectId": 999}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestTodoHandler_Move(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a TodoHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		e := gin.Default()
+		RegisterTodoHandler(e, mockStorage, mock.NewMockTxManager(ctrl))
+
+		Convey("When moving a todo between two neighbours", func() {
+			mockStorage.EXPECT().
+				Move(gomock.Any(), gomock.Eq(1), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, _ int, after, before *int) error {
+					So(*after, ShouldEqual, 2)
+					So(*before, ShouldEqual, 3)
+					return nil
+				}).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/move", bytes.NewBufferString(`{"after": 2, "before": 3}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 204 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When the neighbours are not adjacent in the right order", func() {
+			mockStorage.EXPECT().
+				Move(gomock.Any(), gomock.Eq(1), gomock.Any(), gomock.Any()).
+				Return(storage.ErrInvalidMove).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/move", bytes.NewBufferString(`{"after": 3, "before": 2}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When a neighbour is not found", func() {
+			mockStorage.EXPECT().
+				Move(gomock.Any(), gomock.Eq(1), gomock.Any(), gomock.Nil()).
+				Return(gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/1/move", bytes.NewBufferString(`{"after": 999}`))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestTodoHandler_Replace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a TodoHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		mockTx := mock.NewMockTxManager(ctrl)
+		e := gin.Default()
+		RegisterTodoHandler(e, mockStorage, mockTx)
+
+		do := func(url, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, url, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When replacing a todo", func() {
+			mockTx.EXPECT().
+				WithinTx(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) }).
+				Times(1)
+			mockStorage.EXPECT().
+				Replace(gomock.Any(), gomock.Eq(1), gomock.Eq(storage.Todo{Title: "Replaced", Completed: ptr(false)})).
+				Return(nil).
+				Times(1)
+			mockStorage.EXPECT().
+				Get(gomock.Any(), gomock.Eq(1)).
+				Return(storage.Todo{Model: gorm.Model{ID: 1}, Title: "Replaced"}, nil).
+				Times(1)
+
+			w := do("/todos/1", `{"title": "Replaced"}`)
+
+			Convey("Then it should reset the omitted fields and return the todo", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var res GetTodoRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Title, ShouldEqual, "Replaced")
+			})
+		})
+
+		Convey("When replacing a todo with a priority and tags", func() {
+			mockTx.EXPECT().
+				WithinTx(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) }).
+				Times(1)
+			mockStorage.EXPECT().
+				Replace(gomock.Any(), gomock.Eq(1), gomock.Eq(storage.Todo{Title: "Replaced", Completed: ptr(false), Priority: "A", Tags: []string{"@work"}})).
+				Return(nil).
+				Times(1)
+			mockStorage.EXPECT().
+				Get(gomock.Any(), gomock.Eq(1)).
+				Return(storage.Todo{Model: gorm.Model{ID: 1}, Title: "Replaced", Priority: "A", Tags: []string{"@work"}}, nil).
+				Times(1)
+
+			w := do("/todos/1", `{"title": "Replaced", "priority": "A", "tags": ["@work"]}`)
+
+			Convey("Then it should replace them too", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var res GetTodoRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Priority, ShouldEqual, "A")
+				So(res.Tags, ShouldResemble, []string{"@work"})
+			})
+		})
+
+		Convey("When replacing a todo with an invalid priority", func() {
+			w := do("/todos/1", `{"title": "Replaced", "priority": "high"}`)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When replacing a todo that does not exist", func() {
+			mockTx.EXPECT().
+				WithinTx(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) }).
+				Times(1)
+			mockStorage.EXPECT().
+				Replace(gomock.Any(), gomock.Eq(999), gomock.Any()).
+				Return(gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := do("/todos/999", `{"title": "Replaced"}`)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When replacing a todo without a title", func() {
+			w := do("/todos/1", `{"description": "No title"}`)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When upserting a todo that is not synced yet", func() {
+			mockStorage.EXPECT().
+				Upsert(gomock.Any(), gomock.Cond(func(todo *storage.Todo) bool {
+					return *todo.ExternalSource == "jira" && *todo.ExternalID == "PROJ-1" && *todo.Completed
+				})).
+				DoAndReturn(func(_ context.Context, todo *storage.Todo) (bool, error) {
+					todo.ID = 3
+					return true, nil
+				}).
+				Times(1)
+
+			w := do("/todos/PROJ-1?source=jira", `{"title": "Synced", "completed": true}`)
+
+			Convey("Then it should return 201 status code with the todo", func() {
+				So(w.Code, ShouldEqual, http.StatusCreated)
+				var res GetTodoRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.ID, ShouldEqual, 3)
+				So(res.ExternalSource, ShouldEqual, "jira")
+				So(res.ExternalID, ShouldEqual, "PROJ-1")
+			})
+		})
+
+		Convey("When upserting a todo that is already synced", func() {
+			mockStorage.EXPECT().Upsert(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+
+			w := do("/todos/PROJ-1?source=jira", `{"title": "Synced"}`)
+
+			Convey("Then it should return 200 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When upserting a todo that was deleted", func() {
+			mockStorage.EXPECT().Upsert(gomock.Any(), gomock.Any()).Return(false, storage.ErrTodoDeleted).Times(1)
+
+			w := do("/todos/PROJ-1?source=jira", `{"title": "Synced"}`)
+
+			Convey("Then it should return 410 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusGone)
+			})
+		})
+	})
+}
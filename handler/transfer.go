@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/transfer"
+)
+
+// ExportTodoQuery selects the format of an export and the todos to include.
+type ExportTodoQuery struct {
+	Format    string     `form:"format,default=json" binding:"oneof=csv json ndjson todotxt markdown"`
+	Status    string     `form:"status"`
+	Completed *bool      `form:"completed"`
+	ProjectID *uint      `form:"projectId"`
+	Query     string     `form:"q"`
+	DueAfter  *time.Time `form:"dueAfter" time_format:"2006-01-02T15:04:05Z07:00"`
+	DueBefore *time.Time `form:"dueBefore" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// ImportTodoQuery selects the format of an import, which defaults to the one
+// of the content type.
+type ImportTodoQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=csv json ndjson todotxt markdown"`
+	DryRun bool   `form:"dryRun"`
+}
+
+type ImportTodoRowRes struct {
+	Row    int    `json:"row"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportTodoRes struct {
+	DryRun     bool               `json:"dryRun"`
+	Total      int                `json:"total"`
+	Created    int                `json:"created"`
+	Duplicates int                `json:"duplicates"`
+	Failed     int                `json:"failed"`
+	Rows       []ImportTodoRowRes `json:"rows"`
+}
+
+func newImportTodoRes(res transfer.ImportResult) ImportTodoRes {
+	rows := make([]ImportTodoRowRes, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		r := ImportTodoRowRes{Row: row.Row, Status: row.Status}
+		if row.Err != nil {
+			r.Error = row.Err.Error()
+		}
+		rows = append(rows, r)
+	}
+	return ImportTodoRes{
+		DryRun:     res.DryRun,
+		Total:      res.Total,
+		Created:    res.Created,
+		Duplicates: res.Duplicates,
+		Failed:     res.Failed,
+		Rows:       rows,
+	}
+}
+
+type TransferHandler struct {
+	storage  storage.TodoStorage
+	importer *transfer.Importer
+}
+
+// Export streams the todos matching the query as they are read from storage.
+// Once the first todo is written the status can no longer change, so a later
+// failure leaves the document truncated.
+func (h *TransferHandler) Export(c *gin.Context) {
+	var query ExportTodoQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	enc, err := transfer.NewEncoder(c.Writer, query.Format)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", transfer.ContentType(query.Format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, transfer.FileName(query.Format)))
+	c.Status(http.StatusOK)
+
+	filter := storage.TodoFilter{
+		Status:    query.Status,
+		Completed: query.Completed,
+		ProjectID: query.ProjectID,
+		Query:     query.Query,
+		DueAfter:  query.DueAfter,
+		DueBefore: query.DueBefore,
+	}
+	err = h.storage.Each(c, filter, func(todo storage.Todo) error {
+		return enc.Encode(transfer.NewRecord(todo))
+	})
+	if err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		c.Error(err)
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+	}
+}
+
+// Import creates todos from a document read as it is received. Rows that
+// cannot be created are reported rather than failing the import, which is
+// only rejected as a whole when the document is malformed.
+func (h *TransferHandler) Import(c *gin.Context) {
+	var query ImportTodoQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	format := query.Format
+	if format == "" {
+		format = transfer.FormatOf(c.ContentType())
+	}
+	dec, err := transfer.NewDecoder(c.Request.Body, format)
+	if err != nil {
+		err := fmt.Errorf("unsupported content type %q", c.ContentType())
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	res, err := h.importer.Import(c, dec, query.DryRun)
+	if err != nil {
+		if errors.Is(err, transfer.ErrMalformed) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newImportTodoRes(res))
+}
+
+func RegisterTransferHandler(e *gin.Engine, s storage.TodoStorage, tx storage.TxManager) error {
+	h := &TransferHandler{
+		storage:  s,
+		importer: transfer.NewImporter(s, tx),
+	}
+
+	e.GET("/todos/export", h.Export)
+	e.POST("/todos/import", h.Import)
+
+	return nil
+}
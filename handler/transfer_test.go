@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestTransferHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a TransferHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		mockTx := mock.NewMockTxManager(ctrl)
+		e := gin.Default()
+		RegisterTransferHandler(e, mockStorage, mockTx)
+
+		each := func(todos ...storage.Todo) func(context.Context, storage.TodoFilter, func(storage.Todo) error) error {
+			return func(_ context.Context, _ storage.TodoFilter, fn func(storage.Todo) error) error {
+				for _, todo := range todos {
+					if err := fn(todo); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		}
+
+		Convey("When exporting todos as CSV", func() {
+			completed := true
+			mockStorage.EXPECT().
+				Each(gomock.Any(), gomock.Eq(storage.TodoFilter{Completed: &completed}), gomock.Any()).
+				DoAndReturn(each(
+					storage.Todo{Model: gorm.Model{ID: 1}, Title: "First", Status: "done", Completed: &completed},
+					storage.Todo{Model: gorm.Model{ID: 2}, Title: "Second, with a comma", Status: "done", Completed: &completed},
+				)).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/export?format=csv&completed=true", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return a CSV document with a row per todo", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "text/csv")
+				So(w.Header().Get("Content-Disposition"), ShouldContainSubstring, "todos.csv")
+				lines := bytes.Split(bytes.TrimSpace(w.Body.Bytes()), []byte("\n"))
+				So(lines, ShouldHaveLength, 3)
+				So(string(lines[2]), ShouldStartWith, `2,"Second, with a comma",,done,true`)
+			})
+		})
+
+		Convey("When the export fails before any todo is written", func() {
+			mockStorage.EXPECT().Each(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("internal server error")).Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/export?format=ndjson", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 500 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(w.Header().Get("Content-Disposition"), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When exporting to an unknown format", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/export?format=xml", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When importing an NDJSON document", func() {
+			mockStorage.EXPECT().Each(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(each()).Times(1)
+			mockTx.EXPECT().
+				WithinTx(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) }).
+				Times(2)
+			mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/import", bytes.NewBufferString("{\"title\": \"First\"}\n{\"title\": \"First\"}\n"))
+			req.Header.Set("Content-Type", "application/x-ndjson")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should report what was imported", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var res ImportTodoRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Total, ShouldEqual, 2)
+				So(res.Created, ShouldEqual, 1)
+				So(res.Duplicates, ShouldEqual, 1)
+				So(res.Rows, ShouldHaveLength, 1)
+				So(res.Rows[0].Row, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When exporting todos as todo.txt", func() {
+			mockStorage.EXPECT().
+				Each(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(each(storage.Todo{Model: gorm.Model{ID: 1}, Title: "Call mom", Priority: "A", Tags: []string{"@phone"}})).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/todos/export?format=todotxt", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return a todo.txt document", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "text/x-todo-txt")
+				So(w.Header().Get("Content-Disposition"), ShouldContainSubstring, `filename="todo.txt"`)
+				So(w.Body.String(), ShouldEqual, "(A) Call mom @phone\n")
+			})
+		})
+
+		Convey("When importing a Markdown checklist", func() {
+			mockStorage.EXPECT().Each(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(each()).Times(1)
+			mockTx.EXPECT().
+				WithinTx(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) }).
+				Times(2)
+			var created storage.Todo
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, todo *storage.Todo) error {
+					created = *todo
+					return nil
+				}).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/import", bytes.NewBufferString("# Chores\n\n- [ ] (B) Water the plants +garden\n"))
+			req.Header.Set("Content-Type", "text/markdown; charset=utf-8")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should create a todo per item", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(created.Title, ShouldEqual, "Water the plants")
+				So(created.Priority, ShouldEqual, "B")
+				So(created.Tags, ShouldResemble, []string{"+garden"})
+			})
+		})
+
+		Convey("When importing a malformed document", func() {
+			mockStorage.EXPECT().Each(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(each()).Times(1)
+			mockTx.EXPECT().
+				WithinTx(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) }).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/import?format=json", bytes.NewBufferString(`{"title": "First"}`))
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When importing an unsupported content type", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/todos/import", bytes.NewBufferString("First"))
+			req.Header.Set("Content-Type", "text/plain")
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 415 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusUnsupportedMediaType)
+			})
+		})
+	})
+}
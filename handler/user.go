@@ -0,0 +1,16 @@
+package handler
+
+import "github.com/gin-gonic/gin"
+
+// HeaderUser carries the name of the user performing the request. It is
+// expected to be set by the authenticating proxy in front of the service.
+const HeaderUser = "X-User"
+
+const anonymousUser = "anonymous"
+
+func currentUser(c *gin.Context) string {
+	if user := c.GetHeader(HeaderUser); user != "" {
+		return user
+	}
+	return anonymousUser
+}
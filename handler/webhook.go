@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+type WebhookHandler struct {
+	storage storage.WebhookStorage
+}
+
+type GetWebhookRes struct {
+	ID           uint       `json:"id"`
+	URL          string     `json:"url"`
+	Events       []string   `json:"events"`
+	Active       bool       `json:"active"`
+	FailureCount int        `json:"failureCount"`
+	DisabledAt   *time.Time `json:"disabledAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+func newGetWebhookRes(webhook storage.Webhook) GetWebhookRes {
+	res := GetWebhookRes{
+		ID:           webhook.ID,
+		URL:          webhook.URL,
+		Events:       webhook.Events,
+		FailureCount: webhook.FailureCount,
+		DisabledAt:   webhook.DisabledAt,
+		CreatedAt:    webhook.CreatedAt,
+		UpdatedAt:    webhook.UpdatedAt,
+	}
+	if res.Events == nil {
+		res.Events = []string{}
+	}
+	if webhook.Active != nil {
+		res.Active = *webhook.Active
+	}
+	return res
+}
+
+// CreateWebhookRes is the only response carrying the secret of a webhook.
+type CreateWebhookRes struct {
+	GetWebhookRes
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryAttemptRes struct {
+	ID           uint      `json:"id"`
+	ResponseCode int       `json:"responseCode,omitempty"`
+	ResponseBody string    `json:"responseBody,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	DurationMs   int64     `json:"durationMs"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type GetWebhookDeliveryRes struct {
+	ID            uint                        `json:"id"`
+	WebhookID     uint                        `json:"webhookId"`
+	Event         string                      `json:"event"`
+	Status        string                      `json:"status"`
+	Attempts      int                         `json:"attempts"`
+	ResponseCode  int                         `json:"responseCode,omitempty"`
+	NextAttemptAt *time.Time                  `json:"nextAttemptAt,omitempty"`
+	DeliveredAt   *time.Time                  `json:"deliveredAt,omitempty"`
+	AttemptLog    []WebhookDeliveryAttemptRes `json:"attemptLog"`
+	CreatedAt     time.Time                   `json:"createdAt"`
+}
+
+func newGetWebhookDeliveryRes(delivery storage.WebhookDelivery) GetWebhookDeliveryRes {
+	res := GetWebhookDeliveryRes{
+		ID:           delivery.ID,
+		WebhookID:    delivery.WebhookID,
+		Event:        delivery.Event,
+		Status:       delivery.Status,
+		Attempts:     delivery.Attempts,
+		ResponseCode: delivery.ResponseCode,
+		DeliveredAt:  delivery.DeliveredAt,
+		AttemptLog:   make([]WebhookDeliveryAttemptRes, 0, len(delivery.AttemptLog)),
+		CreatedAt:    delivery.CreatedAt,
+	}
+	if delivery.Status == storage.WebhookDeliveryPending {
+		res.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	for _, attempt := range delivery.AttemptLog {
+		res.AttemptLog = append(res.AttemptLog, WebhookDeliveryAttemptRes{
+			ID:           attempt.ID,
+			ResponseCode: attempt.ResponseCode,
+			ResponseBody: attempt.ResponseBody,
+			Error:        attempt.Error,
+			DurationMs:   attempt.Duration.Milliseconds(),
+			CreatedAt:    attempt.CreatedAt,
+		})
+	}
+	return res
+}
+
+func (h *WebhookHandler) Get(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	webhook, err := h.storage.Get(c, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newGetWebhookRes(webhook))
+}
+
+type ListWebhookRes []GetWebhookRes
+
+func (h *WebhookHandler) List(c *gin.Context) {
+	webhooks, err := h.storage.List(c)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	res := make(ListWebhookRes, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		res = append(res, newGetWebhookRes(webhook))
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+type CreateWebhookReq struct {
+	URL    string   `json:"url" binding:"required,url"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=* todo.created todo.updated todo.completed todo.deleted todo.purged"`
+	Secret string   `json:"secret" binding:"omitempty,min=16"`
+}
+
+func (h *WebhookHandler) Create(c *gin.Context) {
+	var req CreateWebhookReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var webhook storage.Webhook
+	webhook.URL = req.URL
+	webhook.Events = req.Events
+	webhook.Secret = req.Secret
+
+	if err := h.storage.Create(c, &webhook); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateWebhookRes{
+		GetWebhookRes: newGetWebhookRes(webhook),
+		Secret:        webhook.Secret,
+	})
+}
+
+type UpdateWebhookReq struct {
+	URL    string   `json:"url" binding:"omitempty,url"`
+	Events []string `json:"events" binding:"omitempty,min=1,dive,oneof=* todo.created todo.updated todo.completed todo.deleted todo.purged"`
+	Secret string   `json:"secret" binding:"omitempty,min=16"`
+	Active *bool    `json:"active"`
+}
+
+func (h *WebhookHandler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var req UpdateWebhookReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var webhook storage.Webhook
+	webhook.URL = req.URL
+	webhook.Events = req.Events
+	webhook.Secret = req.Secret
+	webhook.Active = req.Active
+
+	if err := h.storage.Update(c, id, webhook); err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	if err := h.storage.Delete(c, id); err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type ListWebhookDeliveryQuery struct {
+	Offset int `form:"offset" binding:"min=0"`
+	Limit  int `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+type ListWebhookDeliveryRes []GetWebhookDeliveryRes
+
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	var query ListWebhookDeliveryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	deliveries, total, err := h.storage.ListDeliveries(c, id, query.Offset, query.Limit)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	res := make(ListWebhookDeliveryRes, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		res = append(res, newGetWebhookDeliveryRes(delivery))
+	}
+
+	c.Header(HeaderTotalCount, strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, res)
+}
+
+// Test queues a test event for the webhook. It is sent by the webhook worker,
+// so the outcome shows up in the delivery log.
+func (h *WebhookHandler) Test(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorRes{Error: err.Error()})
+		return
+	}
+
+	delivery, err := h.storage.CreateTestDelivery(c, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorRes{Error: err.Error()})
+		} else {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorRes{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, newGetWebhookDeliveryRes(delivery))
+}
+
+func RegisterWebhookHandler(e *gin.Engine, s storage.WebhookStorage) error {
+	h := &WebhookHandler{
+		storage: s,
+	}
+
+	webhook := e.Group("/webhooks")
+	{
+		webhook.GET("", h.List)
+		webhook.POST("", h.Create)
+		webhook.GET("/:id", h.Get)
+		webhook.PATCH("/:id", h.Update)
+		webhook.DELETE("/:id", h.Delete)
+		webhook.GET("/:id/deliveries", h.ListDeliveries)
+		webhook.POST("/:id/test", h.Test)
+	}
+
+	return nil
+}
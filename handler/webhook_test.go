@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestWebhookHandler_Create(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a WebhookHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockWebhookStorage(ctrl)
+		e := gin.Default()
+		RegisterWebhookHandler(e, mockStorage)
+
+		Convey("When creating a webhook", func() {
+			mockStorage.EXPECT().
+				Create(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ any, webhook *storage.Webhook) error {
+					So(webhook.URL, ShouldEqual, "https://example.com/hook")
+					So(webhook.Events, ShouldResemble, []string{"todo.completed"})
+					webhook.ID = 1
+					webhook.Secret = "whsec_generated"
+					active := true
+					webhook.Active = &active
+					return nil
+				}).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(`{"url":"https://example.com/hook","events":["todo.completed"]}`))
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 201 status code with the secret", func() {
+				So(w.Code, ShouldEqual, http.StatusCreated)
+
+				var res CreateWebhookRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.ID, ShouldEqual, 1)
+				So(res.Active, ShouldBeTrue)
+				So(res.Secret, ShouldEqual, "whsec_generated")
+			})
+		})
+
+		Convey("When subscribing to an unknown event", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(`{"url":"https://example.com/hook","events":["todo.renamed"]}`))
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestWebhookHandler_Get(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a WebhookHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockWebhookStorage(ctrl)
+		e := gin.Default()
+		RegisterWebhookHandler(e, mockStorage)
+
+		Convey("When getting a webhook", func() {
+			mockStorage.EXPECT().
+				Get(gomock.Any(), gomock.Eq(1)).
+				Return(storage.Webhook{Model: gorm.Model{ID: 1}, URL: "https://example.com/hook", Secret: "whsec_secret"}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/webhooks/1", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should not expose the secret", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldNotContainSubstring, "whsec_secret")
+			})
+		})
+
+		Convey("When getting a webhook that does not exist", func() {
+			mockStorage.EXPECT().
+				Get(gomock.Any(), gomock.Eq(2)).
+				Return(storage.Webhook{}, gorm.ErrRecordNotFound).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/webhooks/2", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 404 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestWebhookHandler_ListDeliveries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a WebhookHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockWebhookStorage(ctrl)
+		e := gin.Default()
+		RegisterWebhookHandler(e, mockStorage)
+
+		Convey("When listing the deliveries of a webhook", func() {
+			mockStorage.EXPECT().
+				ListDeliveries(gomock.Any(), gomock.Eq(1), gomock.Eq(0), gomock.Eq(20)).
+				Return([]storage.WebhookDelivery{{
+					ID:           3,
+					WebhookID:    1,
+					Event:        storage.WebhookEventTodoCreated,
+					Status:       storage.WebhookDeliveryFailed,
+					Attempts:     1,
+					ResponseCode: 500,
+					AttemptLog:   []storage.WebhookDeliveryAttempt{{ID: 1, ResponseCode: 500, Error: "webhook responded 500"}},
+				}}, int64(1), nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/webhooks/1/deliveries", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return the deliveries with their attempts", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get(HeaderTotalCount), ShouldEqual, "1")
+
+				var res ListWebhookDeliveryRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res, ShouldHaveLength, 1)
+				So(res[0].ResponseCode, ShouldEqual, 500)
+				So(res[0].AttemptLog, ShouldHaveLength, 1)
+			})
+		})
+	})
+}
+
+func TestWebhookHandler_Test(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a WebhookHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockWebhookStorage(ctrl)
+		e := gin.Default()
+		RegisterWebhookHandler(e, mockStorage)
+
+		Convey("When sending a test event", func() {
+			mockStorage.EXPECT().
+				CreateTestDelivery(gomock.Any(), gomock.Eq(1)).
+				Return(storage.WebhookDelivery{ID: 4, WebhookID: 1, Event: storage.WebhookEventTest, Status: storage.WebhookDeliveryPending}, nil).
+				Times(1)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/webhooks/1/test", nil)
+			e.ServeHTTP(w, req)
+
+			Convey("Then it should return 202 status code with the queued delivery", func() {
+				So(w.Code, ShouldEqual, http.StatusAccepted)
+
+				var res GetWebhookDeliveryRes
+				So(json.Unmarshal(w.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Event, ShouldEqual, storage.WebhookEventTest)
+			})
+		})
+	})
+}
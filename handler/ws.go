@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+
+	"github.com/wei840222/go-restful-sample/realtime"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Types of the messages clients send over the WebSocket.
+const (
+	WSMessageSubscribe   = "subscribe"
+	WSMessageUnsubscribe = "unsubscribe"
+	WSMessageCreate      = "create"
+	WSMessageUpdate      = "update"
+	WSMessageDelete      = "delete"
+)
+
+// Types of the replies to client messages.
+const (
+	WSMessageResult = "result"
+	WSMessageError  = "error"
+)
+
+// WSReq is a message sent by a client. ID is echoed in the reply so that
+// clients can match replies to requests.
+type WSReq struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Channel string          `json:"channel"`
+	TodoID  int             `json:"todoId"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// WSRes is the reply to a client message. Status mirrors the HTTP status code
+// the equivalent REST request would have returned.
+type WSRes struct {
+	ID     string      `json:"id,omitempty"`
+	Type   string      `json:"type"`
+	Status int         `json:"status"`
+	Todo   *GetTodoRes `json:"todo,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type WebSocketHandler struct {
+	storage  storage.TodoStorage
+	hub      *realtime.Hub
+	upgrader websocket.Upgrader
+}
+
+// Serve upgrades the request to a WebSocket on which the client subscribes to
+// todo channels and sends mutations. Replies and channel messages are written
+// by a separate goroutine that also pings the client, while this one reads.
+func (h *WebSocketHandler) Serve(c *gin.Context) {
+	client, ok := h.hub.Register(currentUser(c))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, ErrorRes{Error: "server is shutting down"})
+		return
+	}
+	defer h.hub.Unregister(client)
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already replied with an error.
+		c.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	cfg := h.hub.Config()
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		h.write(conn, client, cfg)
+	}()
+
+	// The request context is detached from the hijacked connection, so the
+	// mutations keep its actor and request ID but are bounded by the reader.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(c.Request.Context()))
+	defer cancel()
+	h.read(ctx, conn, client, cfg, c.Writer.Header().Get(HeaderRequestID))
+
+	h.hub.Unregister(client)
+	<-writerDone
+}
+
+func (h *WebSocketHandler) read(ctx context.Context, conn *websocket.Conn, client *realtime.Client, cfg realtime.Config, requestID string) {
+	conn.SetReadLimit(cfg.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(cfg.PongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(cfg.PongTimeout))
+	})
+
+	limiter := rate.NewLimiter(rate.Limit(cfg.RateLimit), cfg.RateBurst)
+	for {
+		var req WSReq
+		if err := conn.ReadJSON(&req); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+				client.Send(WSRes{Type: WSMessageError, Status: http.StatusBadRequest, Error: err.Error()})
+				continue
+			}
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Debug().Err(err).Str("user", client.User).Msg("websocket closed")
+			}
+			return
+		}
+		if !limiter.Allow() {
+			client.Send(WSRes{ID: req.ID, Type: WSMessageError, Status: http.StatusTooManyRequests, Error: "rate limit exceeded"})
+			continue
+		}
+
+		ctx := storage.WithRequestID(ctx, requestID+"/"+req.ID)
+		client.Send(h.handle(ctx, client, req))
+	}
+}
+
+func (h *WebSocketHandler) write(conn *websocket.Conn, client *realtime.Client, cfg realtime.Config) {
+	ping := time.NewTicker(cfg.PingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case b, ok := <-client.Outgoing():
+			conn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+			if !ok {
+				code, reason := websocket.ClosePolicyViolation, "too slow"
+				if h.hub.Closed() {
+					code, reason = websocket.CloseGoingAway, "server is shutting down"
+				}
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+				// Unblock the reader in case the client does not answer.
+				conn.SetReadDeadline(time.Now().Add(cfg.WriteTimeout))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, b); err != nil {
+				conn.Close()
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (h *WebSocketHandler) handle(ctx context.Context, client *realtime.Client, req WSReq) WSRes {
+	res := WSRes{ID: req.ID, Type: WSMessageResult, Status: http.StatusOK}
+
+	var err error
+	switch req.Type {
+	case WSMessageSubscribe:
+		err = h.hub.Subscribe(client, req.Channel)
+		if errors.Is(err, realtime.ErrUnknownChannel) {
+			return newWSErrorRes(req.ID, http.StatusBadRequest, err)
+		}
+	case WSMessageUnsubscribe:
+		h.hub.Unsubscribe(client, req.Channel)
+	case WSMessageCreate:
+		var data CreateTodoReq
+		if err := bindData(req.Data, &data); err != nil {
+			return newWSErrorRes(req.ID, http.StatusBadRequest, err)
+		}
+
+		var todo storage.Todo
+		todo.Title = data.Title
+		todo.Description = data.Description
+		todo.DueAt = data.DueAt
+		todo.RRule = data.RRule
+		todo.ProjectID = data.ProjectID
+
+		if err = h.storage.Create(ctx, &todo); err == nil {
+			res.Status = http.StatusCreated
+			res.Todo = ptr(newGetTodoRes(todo))
+		}
+	case WSMessageUpdate:
+		var data UpdateTodoReq
+		if err := bindData(req.Data, &data); err != nil {
+			return newWSErrorRes(req.ID, http.StatusBadRequest, err)
+		}
+
+		var todo storage.Todo
+		todo.Title = data.Title
+		todo.Description = data.Description
+		todo.Completed = data.Completed
+		todo.DueAt = data.DueAt
+		todo.RRule = data.RRule
+
+		if err = h.storage.Update(ctx, req.TodoID, todo); err == nil {
+			todo, err = h.storage.Get(ctx, req.TodoID)
+			res.Todo = ptr(newGetTodoRes(todo))
+		}
+	case WSMessageDelete:
+		if err = h.storage.Delete(ctx, req.TodoID); err == nil {
+			res.Status = http.StatusNoContent
+		}
+	default:
+		return newWSErrorRes(req.ID, http.StatusBadRequest, errors.New("unknown message type "+strconv.Quote(req.Type)))
+	}
+	if err != nil {
+		return newWSErrorRes(req.ID, todoErrorStatus(err), err)
+	}
+	return res
+}
+
+// bindData decodes and validates the data of a mutation embedded in another
+// message like the REST handlers bind request bodies.
+func bindData(data json.RawMessage, v any) error {
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(v)
+}
+
+func newWSErrorRes(id string, status int, err error) WSRes {
+	return WSRes{ID: id, Type: WSMessageError, Status: status, Error: err.Error()}
+}
+
+// todoErrorStatus maps the errors of todo mutations to the status codes of the
+// REST handlers.
+func todoErrorStatus(err error) int {
+	switch {
+	case storage.IsNotFound(err):
+		return http.StatusNotFound
+	case storage.IsInvalidRRule(err), storage.IsUnknownStatus(err):
+		return http.StatusBadRequest
+	case storage.IsIllegalTransition(err):
+		return http.StatusConflict
+	case storage.IsTodoDeleted(err):
+		return http.StatusGone
+	case storage.IsDuplicate(err):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func RegisterWebSocketHandler(e *gin.Engine, s storage.TodoStorage, hub *realtime.Hub) error {
+	h := &WebSocketHandler{
+		storage: s,
+		hub:     hub,
+	}
+
+	e.GET("/ws", h.Serve)
+
+	return nil
+}
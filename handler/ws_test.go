@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/outbox"
+	"github.com/wei840222/go-restful-sample/realtime"
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestWebSocketHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a WebSocketHandler with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		lc := fxtest.NewLifecycle(t)
+		hub := realtime.NewHub(lc, realtime.Config{
+			SendBuffer:     16,
+			PingInterval:   time.Minute,
+			PongTimeout:    2 * time.Minute,
+			WriteTimeout:   time.Second,
+			MaxMessageSize: 4096,
+			RateLimit:      1,
+			RateBurst:      3,
+		})
+		lc.RequireStart()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		e := gin.New()
+		e.Use(AuditMiddleware())
+		RegisterWebSocketHandler(e, mockStorage, hub)
+
+		server := httptest.NewServer(e)
+		defer server.Close()
+
+		dial := func(user string) *websocket.Conn {
+			header := http.Header{HeaderUser: []string{user}}
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", header)
+			So(err, ShouldBeNil)
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			return conn
+		}
+		read := func(conn *websocket.Conn) map[string]any {
+			var msg map[string]any
+			So(conn.ReadJSON(&msg), ShouldBeNil)
+			return msg
+		}
+
+		alice := dial("alice")
+		defer alice.Close()
+
+		Convey("When two users subscribe to a todo", func() {
+			So(alice.WriteJSON(WSReq{ID: "1", Type: WSMessageSubscribe, Channel: "todo:1"}), ShouldBeNil)
+			So(read(alice)["type"], ShouldEqual, realtime.MessagePresence)
+			So(read(alice)["type"], ShouldEqual, WSMessageResult)
+
+			bob := dial("bob")
+			defer bob.Close()
+			So(bob.WriteJSON(WSReq{ID: "1", Type: WSMessageSubscribe, Channel: "todo:1"}), ShouldBeNil)
+
+			Convey("Then both should see each other", func() {
+				presence := read(alice)
+				So(presence["users"], ShouldResemble, []any{"alice", "bob"})
+			})
+
+			Convey("Then both should receive the changes of the todo", func() {
+				read(alice)
+				payload, _ := json.Marshal(storage.TodoChanged{Type: "todo.updated", TodoID: 1})
+				So(hub.Publish(context.Background(), outbox.Message{ID: 1, Topic: "todo.updated", Key: "todo:1", Payload: payload}), ShouldBeNil)
+
+				event := read(alice)
+				So(event["type"], ShouldEqual, realtime.MessageEvent)
+				So(event["channel"], ShouldEqual, "todo:1")
+				So(event["event"].(map[string]any)["todoId"], ShouldEqual, 1)
+			})
+
+			Convey("Then the others should be told when one leaves", func() {
+				read(alice)
+				bob.Close()
+				So(read(alice)["users"], ShouldResemble, []any{"alice"})
+			})
+		})
+
+		Convey("When a client updates a todo", func() {
+			mockStorage.EXPECT().
+				Update(gomock.Any(), gomock.Eq(1), gomock.Eq(storage.Todo{Title: "renamed"})).
+				Return(nil)
+			mockStorage.EXPECT().
+				Get(gomock.Any(), gomock.Eq(1)).
+				Return(storage.Todo{Model: gorm.Model{ID: 1}, Title: "renamed"}, nil)
+
+			So(alice.WriteJSON(WSReq{ID: "2", Type: WSMessageUpdate, TodoID: 1, Data: json.RawMessage(`{"title":"renamed"}`)}), ShouldBeNil)
+
+			Convey("Then it should reply with the updated todo", func() {
+				var res WSRes
+				So(alice.ReadJSON(&res), ShouldBeNil)
+				So(res.ID, ShouldEqual, "2")
+				So(res.Status, ShouldEqual, http.StatusOK)
+				So(res.Todo.Title, ShouldEqual, "renamed")
+			})
+		})
+
+		Convey("When a client sends an invalid mutation", func() {
+			So(alice.WriteJSON(WSReq{ID: "3", Type: WSMessageCreate, Data: json.RawMessage(`{"description":"no title"}`)}), ShouldBeNil)
+
+			Convey("Then it should reply with a validation error", func() {
+				var res WSRes
+				So(alice.ReadJSON(&res), ShouldBeNil)
+				So(res.Type, ShouldEqual, WSMessageError)
+				So(res.Status, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When a client mutates a todo that does not exist", func() {
+			mockStorage.EXPECT().Delete(gomock.Any(), gomock.Eq(9)).Return(gorm.ErrRecordNotFound)
+			So(alice.WriteJSON(WSReq{ID: "4", Type: WSMessageDelete, TodoID: 9}), ShouldBeNil)
+
+			Convey("Then it should reply with not found", func() {
+				var res WSRes
+				So(alice.ReadJSON(&res), ShouldBeNil)
+				So(res.Status, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When a client sends messages too fast", func() {
+			for i := 0; i < 4; i++ {
+				So(alice.WriteJSON(WSReq{ID: "5", Type: WSMessageUnsubscribe, Channel: "todos"}), ShouldBeNil)
+			}
+
+			Convey("Then the excess messages should be rejected", func() {
+				var statuses []int
+				for i := 0; i < 4; i++ {
+					var res WSRes
+					So(alice.ReadJSON(&res), ShouldBeNil)
+					statuses = append(statuses, res.Status)
+				}
+				So(statuses, ShouldResemble, []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests})
+			})
+		})
+
+		Convey("When the app stops", func() {
+			lc.RequireStop()
+
+			Convey("Then the connection should be closed as going away", func() {
+				_, _, err := alice.ReadMessage()
+				So(websocket.IsCloseError(err, websocket.CloseGoingAway), ShouldBeTrue)
+			})
+		})
+	})
+}
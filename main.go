@@ -14,19 +14,30 @@ import (
 	"go.uber.org/fx"
 
 	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/graph"
+	"github.com/wei840222/go-restful-sample/grpcserver"
 	"github.com/wei840222/go-restful-sample/handler"
+	"github.com/wei840222/go-restful-sample/outbox"
+	"github.com/wei840222/go-restful-sample/realtime"
 	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/stream"
+	"github.com/wei840222/go-restful-sample/transfer"
+	"github.com/wei840222/go-restful-sample/webhook"
 )
 
 var (
 	flagReplacer = strings.NewReplacer(".", "-")
 )
 
+// annotationStdout marks the commands that may write their output to the
+// standard output, which then must not receive the logs.
+const annotationStdout = "stdout"
+
 var rootCmd = &cobra.Command{
 	Use:   "hello",
 	Short: "Hello is a hello world program",
 	Long:  `Hello is a hello world program, it will print hello world`,
-	PreRunE: func(cmd *cobra.Command, _ []string) error {
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
 		if err := config.InitViper(); err != nil {
 			return err
 		}
@@ -36,6 +47,9 @@ var rootCmd = &cobra.Command{
 		viper.BindPFlag(config.ConfigKeyLogColor, cmd.Flags().Lookup(flagReplacer.Replace(config.ConfigKeyLogColor)))
 
 		config.InitZerolog()
+		if cmd.Annotations[annotationStdout] != "" {
+			config.LogToStderr()
+		}
 
 		b, err := json.Marshal(viper.AllSettings())
 		if err != nil {
@@ -50,10 +64,55 @@ var rootCmd = &cobra.Command{
 			fx.Provide(
 				NewGorm,
 				NewGinEngine,
-				storage.NewTodoStorage,
+				NewGRPCServer,
+				NewWorkflow,
+				NewBlobStore,
+				NewAttachmentConfig,
+				NewTodoStorage,
+				storage.NewProjectStorage,
+				storage.NewCommentStorage,
+				storage.NewAttachmentStorage,
+				storage.NewAuditStorage,
+				storage.NewOutboxStorage,
+				NewOutboxSink,
+				NewOutboxConfig,
+				outbox.NewRelay,
+				storage.NewWebhookStorage,
+				webhook.NewDispatcher,
+				NewWebhookConfig,
+				webhook.NewWorker,
+				NewStreamConfig,
+				stream.NewBroker,
+				NewWebSocketConfig,
+				realtime.NewHub,
+				storage.NewSyncStorage,
+				storage.NewCalendarFeedStorage,
+				storage.NewTxManager,
+				NewSyncConfig,
+				NewBulkConfig,
+				graph.NewResolver,
+				NewGraphQLConfig,
+				NewCalDAVConfig,
 			),
 			fx.Invoke(
 				handler.RegisterTodoHandler,
+				handler.RegisterProjectHandler,
+				handler.RegisterCommentHandler,
+				handler.RegisterAttachmentHandler,
+				handler.RegisterAuditHandler,
+				handler.RegisterWebhookHandler,
+				handler.RegisterEventHandler,
+				handler.RegisterWebSocketHandler,
+				handler.RegisterSyncHandler,
+				handler.RegisterBulkHandler,
+				handler.RegisterTransferHandler,
+				handler.RegisterCalendarHandler,
+				handler.RegisterCalDAVHandler,
+				handler.RegisterGraphQLHandler,
+				handler.RegisterRPCHandler,
+				grpcserver.RegisterTodoService,
+				outbox.RunRelay,
+				webhook.RunWorker,
 			),
 			fx.WithLogger(fxlogger.WithZerolog(log.Logger)),
 		)
@@ -62,11 +121,20 @@ var rootCmd = &cobra.Command{
 	},
 }
 
-func main() {
+func init() {
 	rootCmd.PersistentFlags().String(flagReplacer.Replace(config.ConfigKeyLogLevel), "info", "Log level")
 	rootCmd.PersistentFlags().String(flagReplacer.Replace(config.ConfigKeyLogFormat), "console", "Log format")
 	rootCmd.PersistentFlags().Bool(flagReplacer.Replace(config.ConfigKeyLogColor), true, "Log color")
 
+	exportCmd.Flags().String("format", transfer.FormatJSON, "Format of the document: csv, json, ndjson, todotxt or markdown")
+	exportCmd.Flags().StringP("output", "o", "", "File to write instead of the standard output")
+	importCmd.Flags().String("format", transfer.FormatJSON, "Format of the document: csv, json, ndjson, todotxt or markdown")
+	importCmd.Flags().Bool("dry-run", false, "Report what would be imported without saving anything")
+
+	rootCmd.AddCommand(replayCmd, exportCmd, importCmd)
+}
+
+func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/outbox"
+	"github.com/wei840222/go-restful-sample/realtime"
+	"github.com/wei840222/go-restful-sample/stream"
+	"github.com/wei840222/go-restful-sample/webhook"
+)
+
+// NewOutboxSink returns the configured sink, fanned out with the dispatcher of
+// user-defined webhooks, the broker of the event stream and the WebSocket hub.
+// These go first as they cope with the duplicates caused by a failure of the
+// configured sink.
+func NewOutboxSink(dispatcher *webhook.Dispatcher, broker *stream.Broker, hub *realtime.Hub) (outbox.Sink, error) {
+	var sink outbox.Sink
+	switch name := viper.GetString(config.ConfigKeyOutboxSink); name {
+	case "log":
+		sink = outbox.NewLogSink()
+	case "webhook":
+		sink = outbox.NewWebhookSink(viper.GetString(config.ConfigKeyOutboxWebhookURL), viper.GetDuration(config.ConfigKeyOutboxWebhookTimeout))
+	case "nats":
+		var err error
+		sink, err = outbox.NewNATSSink(
+			viper.GetString(config.ConfigKeyOutboxNATSURL),
+			viper.GetString(config.ConfigKeyOutboxNATSSubjectPrefix),
+			viper.GetDuration(config.ConfigKeyOutboxNATSTimeout),
+		)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown outbox sink %q", name)
+	}
+	return outbox.NewFanoutSink(dispatcher, broker, hub, sink), nil
+}
+
+func NewOutboxConfig() (outbox.Config, error) {
+	var cfg outbox.Config
+	if err := viper.UnmarshalKey(config.ConfigKeyOutboxRelay, &cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.PollInterval <= 0 || cfg.BatchSize <= 0 || cfg.MaxAttempts <= 0 || cfg.InitialBackoff <= 0 || cfg.MaxBackoff < cfg.InitialBackoff {
+		return cfg, fmt.Errorf("invalid outbox relay config %+v", cfg)
+	}
+	return cfg, nil
+}
+
+func NewWebhookConfig() (webhook.Config, error) {
+	var cfg webhook.Config
+	if err := viper.UnmarshalKey(config.ConfigKeyWebhook, &cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.PollInterval <= 0 || cfg.BatchSize <= 0 || cfg.MaxAttempts <= 0 || cfg.InitialBackoff <= 0 || cfg.MaxBackoff < cfg.InitialBackoff || cfg.DisableAfter < 0 || cfg.Timeout <= 0 {
+		return cfg, fmt.Errorf("invalid webhook config %+v", cfg)
+	}
+	return cfg, nil
+}
+
+func NewStreamConfig() (stream.Config, error) {
+	var cfg stream.Config
+	if err := viper.UnmarshalKey(config.ConfigKeyStream, &cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.BufferSize < 0 || cfg.SubscriberBuffer <= 0 || cfg.Heartbeat <= 0 || cfg.Retry < 0 {
+		return cfg, fmt.Errorf("invalid stream config %+v", cfg)
+	}
+	return cfg, nil
+}
+
+func NewWebSocketConfig() (realtime.Config, error) {
+	var cfg realtime.Config
+	if err := viper.UnmarshalKey(config.ConfigKeyWebSocket, &cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.SendBuffer <= 0 || cfg.PingInterval <= 0 || cfg.PongTimeout <= cfg.PingInterval || cfg.WriteTimeout <= 0 || cfg.MaxMessageSize <= 0 || cfg.RateLimit <= 0 || cfg.RateBurst <= 0 {
+		return cfg, fmt.Errorf("invalid websocket config %+v", cfg)
+	}
+	return cfg, nil
+}
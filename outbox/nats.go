@@ -0,0 +1,188 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type natsSink struct {
+	url           *url.URL
+	subjectPrefix string
+	timeout       time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	r       *bufio.Reader
+	headers bool
+}
+
+// NewNATSSink returns a Sink publishing every message to a NATS compatible
+// broker on the subject made of subjectPrefix and the message topic. The
+// message ID is sent in the Nats-Msg-Id header when the server supports headers,
+// which lets JetStream drop duplicates. Only plain TCP connections with
+// optional user and password or token in the URL are supported.
+func NewNATSSink(rawURL, subjectPrefix string, timeout time.Duration) (Sink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nats" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid nats url %q", rawURL)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	return &natsSink{
+		url:           u,
+		subjectPrefix: subjectPrefix,
+		timeout:       timeout,
+	}, nil
+}
+
+// Publish sends the message and waits for the server to acknowledge it with a
+// PONG, reconnecting first if the previous connection failed.
+func (s *natsSink) Publish(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := s.publish(ctx, msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *natsSink) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.url.Host)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.r = bufio.NewReader(conn)
+	s.setDeadline(ctx)
+
+	line, err := s.readLine()
+	if err != nil {
+		return s.closeWith(err)
+	}
+	op, args, _ := strings.Cut(line, " ")
+	if op != "INFO" {
+		return s.closeWith(fmt.Errorf("nats: unexpected %q instead of INFO", line))
+	}
+	var info struct {
+		Headers bool `json:"headers"`
+	}
+	if err := json.Unmarshal([]byte(args), &info); err != nil {
+		return s.closeWith(err)
+	}
+	s.headers = info.Headers
+
+	options := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"lang":     "go",
+		"version":  "1.0.0",
+		"protocol": 1,
+		"headers":  true,
+	}
+	if user := s.url.User; user != nil {
+		if password, ok := user.Password(); ok {
+			options["user"] = user.Username()
+			options["pass"] = password
+		} else {
+			options["auth_token"] = user.Username()
+		}
+	}
+	b, err := json.Marshal(options)
+	if err != nil {
+		return s.closeWith(err)
+	}
+	if _, err := fmt.Fprintf(s.conn, "CONNECT %s\r\nPING\r\n", b); err != nil {
+		return s.closeWith(err)
+	}
+	if err := s.awaitPong(); err != nil {
+		return s.closeWith(err)
+	}
+	return nil
+}
+
+func (s *natsSink) publish(ctx context.Context, msg Message) error {
+	s.setDeadline(ctx)
+
+	subject := s.subjectPrefix + msg.Topic
+	var err error
+	if s.headers {
+		header := "NATS/1.0\r\nNats-Msg-Id: " + strconv.FormatUint(uint64(msg.ID), 10) + "\r\n\r\n"
+		_, err = fmt.Fprintf(s.conn, "HPUB %s %d %d\r\n%s%s\r\nPING\r\n", subject, len(header), len(header)+len(msg.Payload), header, msg.Payload)
+	} else {
+		_, err = fmt.Fprintf(s.conn, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(msg.Payload), msg.Payload)
+	}
+	if err != nil {
+		return err
+	}
+	return s.awaitPong()
+}
+
+// awaitPong reads until the server answers our PING, which guarantees that the
+// commands sent before it have been processed.
+func (s *natsSink) awaitPong() error {
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+		op, args, _ := strings.Cut(line, " ")
+		switch op {
+		case "PONG":
+			return nil
+		case "PING":
+			if _, err := s.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case "+OK", "INFO":
+		case "-ERR":
+			return errors.New("nats: " + strings.Trim(args, "'"))
+		default:
+			return fmt.Errorf("nats: unexpected %q", line)
+		}
+	}
+}
+
+func (s *natsSink) readLine() (string, error) {
+	line, err := s.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (s *natsSink) setDeadline(ctx context.Context) {
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	s.conn.SetDeadline(deadline)
+}
+
+func (s *natsSink) closeWith(err error) error {
+	s.conn.Close()
+	s.conn = nil
+	return err
+}
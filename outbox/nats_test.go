@@ -0,0 +1,158 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type natsPublication struct {
+	subject string
+	header  string
+	payload string
+}
+
+// fakeNATS is a local stand-in for a NATS server implementing the part of the
+// client protocol used by the sink.
+type fakeNATS struct {
+	listener net.Listener
+	headers  bool
+	reject   bool
+	received chan natsPublication
+	connect  chan string
+}
+
+func newFakeNATS(headers bool) *fakeNATS {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	So(err, ShouldBeNil)
+	s := &fakeNATS{
+		listener: l,
+		headers:  headers,
+		received: make(chan natsPublication, 10),
+		connect:  make(chan string, 10),
+	}
+	go s.serve()
+	return s
+}
+
+func (s *fakeNATS) URL() string {
+	return "nats://" + s.listener.Addr().String()
+}
+
+func (s *fakeNATS) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeNATS) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"headers\":%t}\r\n", s.headers)
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		switch args[0] {
+		case "CONNECT":
+			s.connect <- strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case "PUB", "HPUB":
+			var headerLen, totalLen int
+			if args[0] == "HPUB" {
+				headerLen, _ = strconv.Atoi(args[2])
+				totalLen, _ = strconv.Atoi(args[3])
+			} else {
+				totalLen, _ = strconv.Atoi(args[2])
+			}
+			b := make([]byte, totalLen+2)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return
+			}
+			if s.reject {
+				fmt.Fprint(conn, "-ERR 'Permissions Violation for Publish'\r\n")
+				return
+			}
+			s.received <- natsPublication{
+				subject: args[1],
+				header:  string(b[:headerLen]),
+				payload: string(b[headerLen:totalLen]),
+			}
+		}
+	}
+}
+
+func TestNATSSink_Publish(t *testing.T) {
+	Convey("Given a NATS sink connected to a server supporting headers", t, func() {
+		server := newFakeNATS(true)
+		defer server.listener.Close()
+
+		sink, err := NewNATSSink(server.URL(), "events.", time.Second)
+		So(err, ShouldBeNil)
+		msg := Message{ID: 7, Topic: "todo.updated", Key: "todo:1", Payload: []byte(`{"todoId":1}`)}
+
+		Convey("When publishing messages", func() {
+			So(sink.Publish(context.Background(), msg), ShouldBeNil)
+			So(sink.Publish(context.Background(), msg), ShouldBeNil)
+
+			Convey("Then they should be published on the prefixed subject with the message ID header", func() {
+				So(server.connect, ShouldHaveLength, 1)
+				publication := <-server.received
+				So(publication.subject, ShouldEqual, "events.todo.updated")
+				So(publication.header, ShouldEqual, "NATS/1.0\r\nNats-Msg-Id: 7\r\n\r\n")
+				So(publication.payload, ShouldEqual, `{"todoId":1}`)
+				So(server.received, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When the server rejects the message", func() {
+			server.reject = true
+			err := sink.Publish(context.Background(), msg)
+
+			Convey("Then it should fail and reconnect on the next attempt", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "Permissions Violation")
+
+				server.reject = false
+				So(sink.Publish(context.Background(), msg), ShouldBeNil)
+				So(server.connect, ShouldHaveLength, 2)
+			})
+		})
+	})
+
+	Convey("Given a NATS sink connected to a server without headers", t, func() {
+		server := newFakeNATS(false)
+		defer server.listener.Close()
+
+		sink, err := NewNATSSink("nats://s3cr3t@"+server.listener.Addr().String(), "", time.Second)
+		So(err, ShouldBeNil)
+
+		Convey("When publishing a message", func() {
+			So(sink.Publish(context.Background(), Message{ID: 1, Topic: "todo.created", Payload: []byte(`{}`)}), ShouldBeNil)
+
+			Convey("Then it should authenticate with the token and publish without headers", func() {
+				So(<-server.connect, ShouldContainSubstring, `"auth_token":"s3cr3t"`)
+				publication := <-server.received
+				So(publication.subject, ShouldEqual, "todo.created")
+				So(publication.header, ShouldBeEmpty)
+				So(publication.payload, ShouldEqual, `{}`)
+			})
+		})
+	})
+}
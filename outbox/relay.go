@@ -0,0 +1,150 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.uber.org/fx"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Config controls how the relay polls the outbox and retries failed deliveries.
+type Config struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	// MaxAttempts is the number of failed deliveries after which a message is
+	// dead-lettered.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// InitialBackoff is the delay before the first retry. It doubles with every
+	// further attempt up to MaxBackoff.
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+}
+
+// Backoff returns the delay before retrying a message that failed attempts
+// times.
+func (cfg Config) Backoff(attempts int) time.Duration {
+	backoff := cfg.InitialBackoff
+	for i := 1; i < attempts && backoff < cfg.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, cfg.MaxBackoff)
+}
+
+// Relay delivers the messages of the outbox to a Sink.
+type Relay struct {
+	storage storage.OutboxStorage
+	sink    Sink
+	config  Config
+	now     func() time.Time
+}
+
+func NewRelay(s storage.OutboxStorage, sink Sink, cfg Config) *Relay {
+	return &Relay{
+		storage: s,
+		sink:    sink,
+		config:  cfg,
+		now:     time.Now,
+	}
+}
+
+// RunRelay runs the relay in the background for the lifetime of the app. On
+// stop it finishes the delivery in progress and returns.
+func RunRelay(lc fx.Lifecycle, r *Relay) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				r.Run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+}
+
+// Run relays messages until ctx is cancelled. As delivering a message makes the
+// next message of its key due, the relay polls again right away after every
+// non-empty batch and only waits for the poll interval once nothing is due.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("failed to relay outbox messages")
+		}
+		if n > 0 && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce delivers the messages currently due and returns how many it
+// attempted.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	messages, err := r.storage.ListDue(ctx, r.now(), r.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, message := range messages {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if err := r.deliver(ctx, message); err != nil {
+			return i + 1, err
+		}
+	}
+	return len(messages), nil
+}
+
+func (r *Relay) deliver(ctx context.Context, message storage.OutboxMessage) error {
+	err := r.sink.Publish(ctx, Message{
+		ID:        message.ID,
+		Topic:     message.Topic,
+		Key:       message.Key,
+		Payload:   []byte(message.Payload),
+		CreatedAt: message.CreatedAt,
+	})
+	if err == nil {
+		return r.storage.MarkDelivered(ctx, message.ID)
+	}
+	if ctx.Err() != nil {
+		// Interrupted by shutdown rather than failed: try again on next start
+		// without counting the attempt.
+		return ctx.Err()
+	}
+
+	attempts := message.Attempts + 1
+	if attempts >= r.config.MaxAttempts {
+		log.Error().Err(err).Uint("id", message.ID).Str("topic", message.Topic).Str("key", message.Key).
+			Int("attempts", attempts).Msg("outbox message dead-lettered")
+		return r.storage.MarkFailed(ctx, message.ID, err, nil)
+	}
+
+	retryAt := r.now().Add(r.config.Backoff(attempts))
+	log.Warn().Err(err).Uint("id", message.ID).Str("topic", message.Topic).Str("key", message.Key).
+		Int("attempts", attempts).Time("retryAt", retryAt).Msg("failed to deliver outbox message")
+	return r.storage.MarkFailed(ctx, message.ID, err, &retryAt)
+}
@@ -0,0 +1,120 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+type fakeSink struct {
+	published []Message
+	err       error
+}
+
+func (s *fakeSink) Publish(_ context.Context, msg Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.published = append(s.published, msg)
+	return nil
+}
+
+func TestConfig_Backoff(t *testing.T) {
+	Convey("Given a relay config", t, func() {
+		cfg := Config{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+		Convey("Then the backoff should double with every attempt up to the maximum", func() {
+			So(cfg.Backoff(1), ShouldEqual, time.Second)
+			So(cfg.Backoff(2), ShouldEqual, 2*time.Second)
+			So(cfg.Backoff(4), ShouldEqual, 8*time.Second)
+			So(cfg.Backoff(5), ShouldEqual, 10*time.Second)
+			So(cfg.Backoff(100), ShouldEqual, 10*time.Second)
+		})
+	})
+}
+
+func TestRelay_RelayOnce(t *testing.T) {
+	Convey("Given a relay with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockOutboxStorage(ctrl)
+		sink := &fakeSink{}
+		r := NewRelay(mockStorage, sink, Config{
+			BatchSize:      10,
+			MaxAttempts:    3,
+			InitialBackoff: time.Second,
+			MaxBackoff:     time.Minute,
+		})
+		now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+		r.now = func() time.Time { return now }
+
+		messages := []storage.OutboxMessage{
+			{ID: 1, Topic: "todo.created", Key: "todo:1", Payload: `{"todoId":1}`},
+			{ID: 2, Topic: "todo.updated", Key: "todo:2", Payload: `{"todoId":2}`, Attempts: 1},
+		}
+
+		Convey("When the sink accepts the messages", func() {
+			mockStorage.EXPECT().ListDue(gomock.Any(), gomock.Eq(now), gomock.Eq(10)).Return(messages, nil).Times(1)
+			mockStorage.EXPECT().MarkDelivered(gomock.Any(), gomock.Eq(uint(1))).Return(nil).Times(1)
+			mockStorage.EXPECT().MarkDelivered(gomock.Any(), gomock.Eq(uint(2))).Return(nil).Times(1)
+
+			n, err := r.RelayOnce(context.Background())
+
+			Convey("Then they should be published in order and marked delivered", func() {
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 2)
+				So(sink.published, ShouldHaveLength, 2)
+				So(sink.published[0].Topic, ShouldEqual, "todo.created")
+				So(string(sink.published[1].Payload), ShouldEqual, `{"todoId":2}`)
+			})
+		})
+
+		Convey("When the sink fails", func() {
+			sink.err = errors.New("unavailable")
+			mockStorage.EXPECT().ListDue(gomock.Any(), gomock.Eq(now), gomock.Eq(10)).Return(messages, nil).Times(1)
+
+			Convey("Then a message with attempts left should be retried after a backoff", func() {
+				mockStorage.EXPECT().MarkFailed(gomock.Any(), gomock.Eq(uint(1)), gomock.Eq(sink.err), gomock.Eq(ptr(now.Add(time.Second)))).Return(nil).Times(1)
+				mockStorage.EXPECT().MarkFailed(gomock.Any(), gomock.Eq(uint(2)), gomock.Eq(sink.err), gomock.Eq(ptr(now.Add(2*time.Second)))).Return(nil).Times(1)
+
+				_, err := r.RelayOnce(context.Background())
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then a message out of attempts should be dead-lettered", func() {
+				messages[1].Attempts = 2
+				mockStorage.EXPECT().MarkFailed(gomock.Any(), gomock.Eq(uint(1)), gomock.Eq(sink.err), gomock.Any()).Return(nil).Times(1)
+				mockStorage.EXPECT().MarkFailed(gomock.Any(), gomock.Eq(uint(2)), gomock.Eq(sink.err), gomock.Nil()).Return(nil).Times(1)
+
+				_, err := r.RelayOnce(context.Background())
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When the relay is stopped", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			mockStorage.EXPECT().ListDue(gomock.Any(), gomock.Any(), gomock.Any()).Return(messages, nil).Times(1)
+
+			n, err := r.RelayOnce(ctx)
+
+			Convey("Then it should not attempt any delivery", func() {
+				So(err, ShouldEqual, context.Canceled)
+				So(n, ShouldEqual, 0)
+				So(sink.published, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
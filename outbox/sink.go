@@ -0,0 +1,60 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Message is a domain event handed to a Sink.
+type Message struct {
+	// ID identifies the message across delivery attempts so that consumers can
+	// drop duplicates.
+	ID        uint
+	Topic     string
+	Key       string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// Sink delivers messages to downstream services. The relay retries a message
+// for which Publish returns an error, so delivery is at least once.
+type Sink interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+type logSink struct{}
+
+// NewLogSink returns a Sink writing every message to the log.
+func NewLogSink() Sink {
+	return logSink{}
+}
+
+func (logSink) Publish(_ context.Context, msg Message) error {
+	log.Info().
+		Uint("id", msg.ID).
+		Str("topic", msg.Topic).
+		Str("key", msg.Key).
+		RawJSON("payload", msg.Payload).
+		Msg("domain event published")
+	return nil
+}
+
+type fanoutSink []Sink
+
+// NewFanoutSink returns a Sink publishing every message to all of sinks in turn.
+// It stops at the first failure, so the sinks before it see the message again
+// on retry and must tolerate duplicates.
+func NewFanoutSink(sinks ...Sink) Sink {
+	return fanoutSink(sinks)
+}
+
+func (s fanoutSink) Publish(ctx context.Context, msg Message) error {
+	for _, sink := range s {
+		if err := sink.Publish(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
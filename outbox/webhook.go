@@ -0,0 +1,56 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers set on the requests of the webhook sink.
+const (
+	HeaderMessageID = "X-Outbox-Message-ID"
+	HeaderTopic     = "X-Outbox-Topic"
+	HeaderKey       = "X-Outbox-Key"
+)
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink returns a Sink posting the payload of every message as JSON to
+// url. Any response other than 2xx fails the delivery.
+func NewWebhookSink(url string, timeout time.Duration) Sink {
+	return &webhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *webhookSink) Publish(ctx context.Context, msg Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(msg.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderMessageID, strconv.FormatUint(uint64(msg.ID), 10))
+	req.Header.Set(HeaderTopic, msg.Topic)
+	req.Header.Set(HeaderKey, msg.Key)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("webhook %s: %s: %s", s.url, res.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
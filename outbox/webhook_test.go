@@ -0,0 +1,52 @@
+package outbox
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWebhookSink_Publish(t *testing.T) {
+	Convey("Given a webhook sink", t, func() {
+		var received *http.Request
+		var body []byte
+		status := http.StatusNoContent
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(status)
+		}))
+		defer srv.Close()
+
+		sink := NewWebhookSink(srv.URL, time.Second)
+		msg := Message{ID: 7, Topic: "todo.updated", Key: "todo:1", Payload: []byte(`{"todoId":1}`)}
+
+		Convey("When the endpoint accepts the message", func() {
+			err := sink.Publish(context.Background(), msg)
+
+			Convey("Then it should post the payload with the message headers", func() {
+				So(err, ShouldBeNil)
+				So(string(body), ShouldEqual, `{"todoId":1}`)
+				So(received.Header.Get("Content-Type"), ShouldEqual, "application/json")
+				So(received.Header.Get(HeaderMessageID), ShouldEqual, "7")
+				So(received.Header.Get(HeaderTopic), ShouldEqual, "todo.updated")
+				So(received.Header.Get(HeaderKey), ShouldEqual, "todo:1")
+			})
+		})
+
+		Convey("When the endpoint rejects the message", func() {
+			status = http.StatusServiceUnavailable
+			err := sink.Publish(context.Background(), msg)
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "503")
+			})
+		})
+	})
+}
@@ -0,0 +1,5 @@
+// Package proto holds the protobuf definitions of the APIs. The Go code is
+// generated next to each definition with buf.
+package proto
+
+//go:generate buf generate
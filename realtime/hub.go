@@ -0,0 +1,285 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/fx"
+
+	"github.com/wei840222/go-restful-sample/outbox"
+)
+
+// ChannelTodos carries the changes of every todo. The changes of a single todo
+// are also published on its own channel, such as "todo:1", which is the key of
+// its outbox messages.
+const ChannelTodos = "todos"
+
+var channelPattern = regexp.MustCompile(`^(todos|todo:[1-9][0-9]*)$`)
+
+var ErrUnknownChannel = errors.New("unknown channel")
+
+// Types of the messages sent by the hub.
+const (
+	MessageEvent    = "event"
+	MessagePresence = "presence"
+)
+
+// Config controls the connections of the hub.
+type Config struct {
+	// SendBuffer is the number of messages queued for a client before it is
+	// considered too slow and disconnected.
+	SendBuffer     int           `mapstructure:"send_buffer"`
+	PingInterval   time.Duration `mapstructure:"ping_interval"`
+	PongTimeout    time.Duration `mapstructure:"pong_timeout"`
+	WriteTimeout   time.Duration `mapstructure:"write_timeout"`
+	MaxMessageSize int64         `mapstructure:"max_message_size"`
+	// RateLimit is the number of messages per second a client may send, with
+	// bursts of up to RateBurst messages.
+	RateLimit float64 `mapstructure:"rate_limit"`
+	RateBurst int     `mapstructure:"rate_burst"`
+}
+
+// EventMessage carries a todo change on a channel.
+type EventMessage struct {
+	Type    string          `json:"type"`
+	Channel string          `json:"channel"`
+	Event   json.RawMessage `json:"event"`
+}
+
+// PresenceMessage lists the users subscribed to a channel. It is sent to the
+// subscribers whenever somebody joins or leaves.
+type PresenceMessage struct {
+	Type    string   `json:"type"`
+	Channel string   `json:"channel"`
+	Users   []string `json:"users"`
+}
+
+// Client is a connection registered with the hub. Messages for it are queued
+// in Outgoing, which is closed when the client is dropped or the hub closes.
+type Client struct {
+	User string
+
+	hub      *Hub
+	send     chan []byte
+	channels map[string]bool
+}
+
+func (c *Client) Outgoing() <-chan []byte {
+	return c.send
+}
+
+// Send queues v for the client. A client whose queue is full is dropped.
+func (c *Client) Send(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	c.hub.sendLocked(c, b)
+	return nil
+}
+
+// Hub routes the todo changes relayed from the outbox to the clients
+// subscribed to their channels and tracks who is present on every channel.
+type Hub struct {
+	config Config
+
+	mu       sync.Mutex
+	clients  map[*Client]struct{}
+	channels map[string]map[*Client]struct{}
+	closed   bool
+	conns    sync.WaitGroup
+}
+
+func NewHub(lc fx.Lifecycle, cfg Config) *Hub {
+	h := &Hub{
+		config:   cfg,
+		clients:  make(map[*Client]struct{}),
+		channels: make(map[string]map[*Client]struct{}),
+	}
+	lc.Append(fx.Hook{
+		OnStop: h.Close,
+	})
+	return h
+}
+
+func (h *Hub) Config() Config {
+	return h.config
+}
+
+// Register adds a client for user. It returns false once the hub is closed.
+// Every registered client must be unregistered when its connection ends.
+func (h *Hub) Register(user string) (*Client, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, false
+	}
+	c := &Client{
+		User:     user,
+		hub:      h,
+		send:     make(chan []byte, h.config.SendBuffer),
+		channels: make(map[string]bool),
+	}
+	h.clients[c] = struct{}{}
+	h.conns.Add(1)
+	return c, true
+}
+
+// Unregister removes a client from its channels and from the hub.
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.drop(c)
+	for channel := range c.channels {
+		h.leave(c, channel)
+	}
+	if c.channels != nil {
+		c.channels = nil
+		h.conns.Done()
+	}
+}
+
+// Subscribe joins a client to a channel.
+func (h *Hub) Subscribe(c *Client, channel string) error {
+	if !channelPattern.MatchString(channel) {
+		return fmt.Errorf("%w %q", ErrUnknownChannel, channel)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[c]; !ok || c.channels[channel] {
+		return nil
+	}
+	if h.channels[channel] == nil {
+		h.channels[channel] = make(map[*Client]struct{})
+	}
+	h.channels[channel][c] = struct{}{}
+	c.channels[channel] = true
+	h.announce(channel)
+	return nil
+}
+
+// Unsubscribe removes a client from a channel.
+func (h *Hub) Unsubscribe(c *Client, channel string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if c.channels[channel] {
+		h.leave(c, channel)
+	}
+}
+
+func (h *Hub) leave(c *Client, channel string) {
+	delete(c.channels, channel)
+	delete(h.channels[channel], c)
+	if len(h.channels[channel]) == 0 {
+		delete(h.channels, channel)
+		return
+	}
+	h.announce(channel)
+}
+
+// announce sends the presence on channel to its subscribers.
+func (h *Hub) announce(channel string) {
+	users := make([]string, 0, len(h.channels[channel]))
+	for c := range h.channels[channel] {
+		users = append(users, c.User)
+	}
+	slices.Sort(users)
+
+	b, _ := json.Marshal(PresenceMessage{
+		Type:    MessagePresence,
+		Channel: channel,
+		Users:   slices.Compact(users),
+	})
+	for c := range h.channels[channel] {
+		h.sendLocked(c, b)
+	}
+}
+
+// Publish sends the todo change of msg to the subscribers of ChannelTodos and
+// of the channel of the todo.
+func (h *Hub) Publish(_ context.Context, msg outbox.Message) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, channel := range []string{ChannelTodos, msg.Key} {
+		if len(h.channels[channel]) == 0 {
+			continue
+		}
+		b, err := json.Marshal(EventMessage{
+			Type:    MessageEvent,
+			Channel: channel,
+			Event:   json.RawMessage(msg.Payload),
+		})
+		if err != nil {
+			return err
+		}
+		for c := range h.channels[channel] {
+			h.sendLocked(c, b)
+		}
+	}
+	return nil
+}
+
+func (h *Hub) sendLocked(c *Client, b []byte) {
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	select {
+	case c.send <- b:
+	default:
+		h.drop(c)
+	}
+}
+
+// drop closes the outgoing queue of a client so that its connection ends. It
+// stays on its channels until unregistered.
+func (h *Hub) drop(c *Client) {
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	delete(h.clients, c)
+	close(c.send)
+}
+
+// Close drops all clients and waits for their connections to end.
+func (h *Hub) Close(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	for c := range h.clients {
+		h.drop(c)
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.conns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Closed reports whether the hub is closed, telling clients dropped for
+// shutting down apart from slow ones.
+func (h *Hub) Closed() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closed
+}
@@ -0,0 +1,70 @@
+package realtime
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx/fxtest"
+
+	"github.com/wei840222/go-restful-sample/outbox"
+)
+
+func TestHub(t *testing.T) {
+	Convey("Given a hub with a client", t, func() {
+		lc := fxtest.NewLifecycle(t)
+		h := NewHub(lc, Config{SendBuffer: 2})
+		lc.RequireStart()
+
+		c, ok := h.Register("alice")
+		So(ok, ShouldBeTrue)
+
+		Convey("When subscribing to an unknown channel", func() {
+			err := h.Subscribe(c, "project:1")
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldWrap, ErrUnknownChannel)
+			})
+			h.Unregister(c)
+		})
+
+		Convey("When the client falls behind", func() {
+			So(h.Subscribe(c, ChannelTodos), ShouldBeNil)
+			for id := uint(1); id <= 2; id++ {
+				So(h.Publish(context.Background(), outbox.Message{ID: id, Key: "todo:1", Payload: []byte(`{}`)}), ShouldBeNil)
+			}
+
+			Convey("Then it should be dropped after its queued messages", func() {
+				var n int
+				for range c.Outgoing() {
+					n++
+				}
+				So(n, ShouldEqual, 2)
+			})
+			h.Unregister(c)
+		})
+
+		Convey("When the hub closes", func() {
+			stopped := make(chan struct{})
+			go func() {
+				defer close(stopped)
+				lc.RequireStop()
+			}()
+
+			Convey("Then it should wait for the client to unregister", func() {
+				_, ok := <-c.Outgoing()
+				So(ok, ShouldBeFalse)
+				select {
+				case <-stopped:
+					So("stopped before unregister", ShouldBeEmpty)
+				default:
+				}
+
+				h.Unregister(c)
+				<-stopped
+				_, ok = h.Register("bob")
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog/log"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+// Attachment is the metadata of a file attached to a todo. The content itself
+// lives in the BlobStore under Key.
+type Attachment struct {
+	gorm.Model
+	TodoID      uint `gorm:"index"`
+	Filename    string
+	ContentType string
+	Size        int64
+	SHA256      string
+	Key         string
+}
+
+//go:generate mockgen -destination=mock/attachment.go -package=mock . AttachmentStorage
+type AttachmentStorage interface {
+	Get(ctx context.Context, todoID, id int) (Attachment, error)
+	List(ctx context.Context, todoID int) ([]Attachment, error)
+	ListByTodoIDs(ctx context.Context, todoIDs []uint) ([]Attachment, error)
+	Create(ctx context.Context, attachment *Attachment, content io.Reader) error
+	Open(ctx context.Context, attachment Attachment) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, todoID, id int) error
+}
+
+type attachmentStorage struct {
+	db    *gorm.DB
+	blobs BlobStore
+}
+
+func NewAttachmentStorage(lc fx.Lifecycle, db *gorm.DB, blobs BlobStore) AttachmentStorage {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return db.AutoMigrate(&Attachment{})
+		},
+	})
+	return &attachmentStorage{db: db, blobs: blobs}
+}
+
+// Create streams content to the blob store, computing its size and checksum on
+// the way, then records the metadata. The blob is removed again if the
+// metadata cannot be saved.
+func (s *attachmentStorage) Create(ctx context.Context, attachment *Attachment, content io.Reader) error {
+	if err := s.db.WithContext(ctx).First(&Todo{}, attachment.TodoID).Error; err != nil {
+		return err
+	}
+
+	key, err := newBlobKey(fmt.Sprintf("todos/%d", attachment.TodoID))
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	counter := &countingReader{r: io.TeeReader(content, h)}
+	if err := s.blobs.Put(ctx, key, counter); err != nil {
+		return err
+	}
+
+	attachment.Key = key
+	attachment.Size = counter.n
+	attachment.SHA256 = hex.EncodeToString(h.Sum(nil))
+	if err := s.db.WithContext(ctx).Create(attachment).Error; err != nil {
+		deleteBlobs(ctx, s.blobs, key)
+		return err
+	}
+	return nil
+}
+
+func (s *attachmentStorage) List(ctx context.Context, todoID int) ([]Attachment, error) {
+	if err := s.db.WithContext(ctx).First(&Todo{}, todoID).Error; err != nil {
+		return nil, err
+	}
+
+	var attachments []Attachment
+	if err := s.db.WithContext(ctx).Where("todo_id = ?", todoID).Order("id").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// ListByTodoIDs returns the attachments of several todos at once.
+func (s *attachmentStorage) ListByTodoIDs(ctx context.Context, todoIDs []uint) ([]Attachment, error) {
+	var attachments []Attachment
+	if err := s.db.WithContext(ctx).Where("todo_id IN ?", todoIDs).Order("id").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (s *attachmentStorage) Get(ctx context.Context, todoID, id int) (Attachment, error) {
+	var attachment Attachment
+	if err := s.db.WithContext(ctx).Where("todo_id = ?", todoID).First(&attachment, id).Error; err != nil {
+		return attachment, err
+	}
+	return attachment, nil
+}
+
+func (s *attachmentStorage) Open(ctx context.Context, attachment Attachment) (io.ReadSeekCloser, error) {
+	return s.blobs.Open(ctx, attachment.Key)
+}
+
+// Delete removes the attachment and its blob for good.
+func (s *attachmentStorage) Delete(ctx context.Context, todoID, id int) error {
+	attachment, err := s.Get(ctx, todoID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Unscoped().Delete(&attachment).Error; err != nil {
+		return err
+	}
+	deleteBlobs(ctx, s.blobs, attachment.Key)
+	return nil
+}
+
+func newBlobKey(prefix string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + "/" + hex.EncodeToString(b), nil
+}
+
+// deleteBlobs removes blobs whose metadata is already gone. Failures only leave
+// orphaned blobs behind, so they are logged rather than returned.
+func deleteBlobs(ctx context.Context, blobs BlobStore, keys ...string) {
+	for _, key := range keys {
+		if err := blobs.Delete(ctx, key); err != nil {
+			log.Warn().Err(err).Str("key", key).Msg("failed to delete blob")
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
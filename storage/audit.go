@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TodoEventCreated = "created"
+	TodoEventUpdated = "updated"
+	TodoEventDeleted = "deleted"
+	TodoEventPurged  = "purged"
+)
+
+// TodoEvent is an entry of the append-only audit trail of todo mutations.
+type TodoEvent struct {
+	ID        uint          `gorm:"primarykey"`
+	CreatedAt time.Time     `gorm:"index"`
+	TodoID    uint          `gorm:"index"`
+	Action    string        `gorm:"index"`
+	Actor     string        `gorm:"index"`
+	RequestID string        `gorm:"index"`
+	Changes   []FieldChange `gorm:"serializer:json"`
+}
+
+// FieldChange is the value of a single todo field before and after a mutation.
+// Before is nil for created todos and After is nil for deleted ones.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// TodoEventFilter narrows down the audit trail. Zero fields match everything.
+type TodoEventFilter struct {
+	TodoID    uint
+	Action    string
+	Actor     string
+	RequestID string
+	Since     *time.Time
+	Until     *time.Time
+}
+
+//go:generate mockgen -destination=mock/audit.go -package=mock . AuditStorage
+type AuditStorage interface {
+	List(ctx context.Context, filter TodoEventFilter, offset, limit int) ([]TodoEvent, int64, error)
+	ListOwners(ctx context.Context, todoIDs []uint) (map[uint]string, error)
+}
+
+type auditStorage struct {
+	db *gorm.DB
+}
+
+func NewAuditStorage(db *gorm.DB) AuditStorage {
+	return &auditStorage{db: db}
+}
+
+// List returns the events matching filter, newest first.
+func (s *auditStorage) List(ctx context.Context, filter TodoEventFilter, offset, limit int) ([]TodoEvent, int64, error) {
+	query := s.db.WithContext(ctx).Model(&TodoEvent{})
+	if filter.TodoID != 0 {
+		query = query.Where("todo_id = ?", filter.TodoID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []TodoEvent
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+// ListOwners returns the users who created the given todos, by todo ID. Todos
+// created before the audit trail existed have no owner.
+func (s *auditStorage) ListOwners(ctx context.Context, todoIDs []uint) (map[uint]string, error) {
+	var events []TodoEvent
+	if err := s.db.WithContext(ctx).
+		Select("todo_id, actor").
+		Where("todo_id IN ? AND action = ?", todoIDs, TodoEventCreated).
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	owners := make(map[uint]string, len(events))
+	for _, event := range events {
+		owners[event.TodoID] = event.Actor
+	}
+	return owners, nil
+}
+
+type actorKey struct{}
+
+type requestIDKey struct{}
+
+// WithActor returns a context recording actor as the author of the mutations
+// made with it.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// WithRequestID returns a context tying the mutations made with it to the
+// request with the given ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// recordTodoEvent appends an event describing the difference between before
+// and after to the audit trail inside tx, attributed to the actor and request of
+// the transaction context, and queues it for publishing.
+func recordTodoEvent(tx *gorm.DB, action string, before, after *Todo) error {
+	event := TodoEvent{Action: action}
+
+	ctx := tx.Statement.Context
+	if actor, ok := ctx.Value(actorKey{}).(string); ok {
+		event.Actor = actor
+	}
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		event.RequestID = id
+	}
+
+	saved, err := appendTodoEvent(tx, event, before, after)
+	if err != nil || saved == nil {
+		return err
+	}
+	return enqueueTodoChanged(tx, *saved)
+}
+
+// appendTodoEvent completes event with the todo ID and the changes between
+// before and after and saves it. Either side may be nil for created and deleted
+// todos. Updates that change nothing are not recorded and return nil.
+func appendTodoEvent(tx *gorm.DB, event TodoEvent, before, after *Todo) (*TodoEvent, error) {
+	event.Changes = diffTodos(before, after)
+	if len(event.Changes) == 0 && event.Action == TodoEventUpdated {
+		return nil, nil
+	}
+
+	if after != nil {
+		event.TodoID = after.ID
+	} else {
+		event.TodoID = before.ID
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+type todoField struct {
+	name  string
+	value any
+}
+
+func auditedFields(todo *Todo) []todoField {
+	if todo == nil {
+		return nil
+	}
+	return []todoField{
+		{"title", todo.Title},
+		{"description", todo.Description},
+		{"status", todo.Status},
+		{"completed", valueOf(todo.Completed)},
+		{"dueAt", valueOf(utc(todo.DueAt))},
+		{"rrule", todo.RRule},
+		{"seriesId", valueOf(todo.SeriesID)},
+		{"projectId", valueOf(todo.ProjectID)},
+		{"rank", todo.Rank},
+		{"priority", todo.Priority},
+		{"tags", todo.Tags},
+		{"externalSource", valueOf(todo.ExternalSource)},
+		{"externalId", valueOf(todo.ExternalID)},
+	}
+}
+
+func diffTodos(before, after *Todo) []FieldChange {
+	beforeFields, afterFields := auditedFields(before), auditedFields(after)
+
+	var changes []FieldChange
+	for i := range max(len(beforeFields), len(afterFields)) {
+		var change FieldChange
+		if i < len(beforeFields) {
+			change.Field = beforeFields[i].name
+			change.Before = beforeFields[i].value
+		}
+		if i < len(afterFields) {
+			change.Field = afterFields[i].name
+			change.After = afterFields[i].value
+		}
+		if isZero(change.Before) && isZero(change.After) || reflect.DeepEqual(change.Before, change.After) {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func valueOf[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return ptr(t.UTC())
+}
+
+func isZero(v any) bool {
+	return v == nil || reflect.ValueOf(v).IsZero()
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiffTodos(t *testing.T) {
+	Convey("Given a todo", t, func() {
+		dueAt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+		todo := Todo{
+			Title:     "Write report",
+			Status:    "todo",
+			Completed: ptr(false),
+			DueAt:     &dueAt,
+			Rank:      "i",
+		}
+
+		Convey("When it is created", func() {
+			changes := diffTodos(nil, &todo)
+
+			Convey("Then every set field should be recorded without a before value", func() {
+				So(changes, ShouldResemble, []FieldChange{
+					{Field: "title", After: "Write report"},
+					{Field: "status", After: "todo"},
+					{Field: "dueAt", After: dueAt.UTC()},
+					{Field: "rank", After: "i"},
+				})
+			})
+		})
+
+		Convey("When it is updated", func() {
+			updated := todo
+			updated.Title = "Write annual report"
+			updated.Status = "done"
+			updated.Completed = ptr(true)
+			updated.DueAt = ptr(dueAt.UTC())
+			updated.ProjectID = ptr(uint(2))
+
+			changes := diffTodos(&todo, &updated)
+
+			Convey("Then only the changed fields should be recorded", func() {
+				So(changes, ShouldResemble, []FieldChange{
+					{Field: "title", Before: "Write report", After: "Write annual report"},
+					{Field: "status", Before: "todo", After: "done"},
+					{Field: "completed", Before: false, After: true},
+					{Field: "projectId", Before: nil, After: uint(2)},
+				})
+			})
+		})
+
+		Convey("When it is linked to an external record", func() {
+			linked := todo
+			linked.ExternalSource = ptr("github")
+			linked.ExternalID = ptr("42")
+
+			changes := diffTodos(&todo, &linked)
+
+			Convey("Then the external source and ID should be recorded", func() {
+				So(changes, ShouldResemble, []FieldChange{
+					{Field: "externalSource", Before: nil, After: "github"},
+					{Field: "externalId", Before: nil, After: "42"},
+				})
+			})
+		})
+
+		Convey("When it is deleted", func() {
+			changes := diffTodos(&todo, nil)
+
+			Convey("Then every set field should be recorded without an after value", func() {
+				So(changes, ShouldHaveLength, 4)
+				So(changes[0], ShouldResemble, FieldChange{Field: "title", Before: "Write report"})
+			})
+		})
+	})
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// BlobStore keeps the content of attachments outside of the database.
+type BlobStore interface {
+	// Put stores the content read from r under key, replacing any existing blob.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the content stored under key. The returned reader supports
+	// seeking so it can serve range requests.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+}
+
+func validateBlobKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+// CalendarFeed is a calendar subscription of a user. Knowing its Token is
+// enough to read the feed, so that calendar apps can subscribe to it without
+// credentials. ProjectID narrows the feed down to a project, and Events adds
+// an event at the due date of every todo.
+type CalendarFeed struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	Owner     string `gorm:"index"`
+	Token     string `gorm:"uniqueIndex"`
+	ProjectID *uint
+	Events    bool
+}
+
+//go:generate mockgen -destination=mock/calendar.go -package=mock . CalendarFeedStorage
+type CalendarFeedStorage interface {
+	Create(ctx context.Context, feed *CalendarFeed) error
+	GetByToken(ctx context.Context, token string) (CalendarFeed, error)
+	List(ctx context.Context, owner string) ([]CalendarFeed, error)
+	Delete(ctx context.Context, owner string, id int) error
+}
+
+type calendarFeedStorage struct {
+	db *gorm.DB
+}
+
+func NewCalendarFeedStorage(lc fx.Lifecycle, db *gorm.DB) CalendarFeedStorage {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return db.AutoMigrate(&CalendarFeed{})
+		},
+	})
+	return &calendarFeedStorage{db: db}
+}
+
+// Create saves a new feed with a freshly generated token.
+func (s *calendarFeedStorage) Create(ctx context.Context, feed *CalendarFeed) error {
+	token, err := newCalendarFeedToken()
+	if err != nil {
+		return err
+	}
+	feed.Token = token
+	return s.db.WithContext(ctx).Create(feed).Error
+}
+
+func (s *calendarFeedStorage) GetByToken(ctx context.Context, token string) (CalendarFeed, error) {
+	var feed CalendarFeed
+	if err := s.db.WithContext(ctx).Where("token = ?", token).First(&feed).Error; err != nil {
+		return feed, err
+	}
+	return feed, nil
+}
+
+func (s *calendarFeedStorage) List(ctx context.Context, owner string) ([]CalendarFeed, error) {
+	var feeds []CalendarFeed
+	if err := s.db.WithContext(ctx).Where("owner = ?", owner).Order("id").Find(&feeds).Error; err != nil {
+		return nil, err
+	}
+	return feeds, nil
+}
+
+// Delete revokes a feed of owner. The feeds of other users are not found.
+func (s *calendarFeedStorage) Delete(ctx context.Context, owner string, id int) error {
+	res := s.db.WithContext(ctx).Where("owner = ?", owner).Delete(&CalendarFeed{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func newCalendarFeedToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx/fxtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCalendarFeedStorage(t *testing.T) {
+	Convey("Given feeds of two users", t, func() {
+		db, err := gorm.Open(sqlite.Open("file:calendar?mode=memory&cache=shared"), &gorm.Config{})
+		So(err, ShouldBeNil)
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+
+		lc := fxtest.NewLifecycle(t)
+		s := NewCalendarFeedStorage(lc, db)
+		lc.RequireStart()
+		defer lc.RequireStop()
+
+		ctx := context.Background()
+		alice, bob := CalendarFeed{Owner: "alice"}, CalendarFeed{Owner: "bob", Events: true}
+		So(s.Create(ctx, &alice), ShouldBeNil)
+		So(s.Create(ctx, &bob), ShouldBeNil)
+
+		Convey("Then each should get its own token", func() {
+			So(alice.Token, ShouldHaveLength, 48)
+			So(bob.Token, ShouldNotEqual, alice.Token)
+
+			feed, err := s.GetByToken(ctx, bob.Token)
+			So(err, ShouldBeNil)
+			So(feed.ID, ShouldEqual, bob.ID)
+			So(feed.Events, ShouldBeTrue)
+		})
+
+		Convey("Then a user should only list their feeds", func() {
+			feeds, err := s.List(ctx, "alice")
+			So(err, ShouldBeNil)
+			So(feeds, ShouldHaveLength, 1)
+			So(feeds[0].ID, ShouldEqual, alice.ID)
+		})
+
+		Convey("When a user deletes the feed of another", func() {
+			err := s.Delete(ctx, "alice", int(bob.ID))
+
+			Convey("Then it should not be found", func() {
+				So(IsNotFound(err), ShouldBeTrue)
+				_, err := s.GetByToken(ctx, bob.Token)
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When a user deletes their feed", func() {
+			So(s.Delete(ctx, "alice", int(alice.ID)), ShouldBeNil)
+
+			Convey("Then its token should no longer be found", func() {
+				_, err := s.GetByToken(ctx, alice.Token)
+				So(IsNotFound(err), ShouldBeTrue)
+			})
+		})
+	})
+}
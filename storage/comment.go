@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+// Comment is a markdown comment on a todo. Deleting a comment only soft
+// deletes it so the thread keeps its history.
+type Comment struct {
+	gorm.Model
+	TodoID   uint `gorm:"index"`
+	Author   string
+	Body     string
+	EditedAt *time.Time
+	Mentions []CommentMention
+}
+
+// CommentRevision keeps the previous body of a comment each time it is edited.
+type CommentRevision struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	CommentID uint `gorm:"index"`
+	Body      string
+}
+
+// CommentMention is a user mentioned with @name in a comment, stored for
+// notifications.
+type CommentMention struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	CommentID uint   `gorm:"index"`
+	Username  string `gorm:"index"`
+}
+
+//go:generate mockgen -destination=mock/comment.go -package=mock . CommentStorage
+type CommentStorage interface {
+	Get(ctx context.Context, todoID, id int) (Comment, error)
+	List(ctx context.Context, todoID, offset, limit int) ([]Comment, int64, error)
+	ListByTodoIDs(ctx context.Context, todoIDs []uint) ([]Comment, error)
+	Create(ctx context.Context, comment *Comment) error
+	Update(ctx context.Context, todoID, id int, body string) error
+	Delete(ctx context.Context, todoID, id int) error
+	ListRevisions(ctx context.Context, todoID, id int) ([]CommentRevision, error)
+}
+
+type commentStorage struct {
+	db *gorm.DB
+}
+
+func NewCommentStorage(lc fx.Lifecycle, db *gorm.DB) CommentStorage {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return db.AutoMigrate(&Comment{}, &CommentRevision{}, &CommentMention{})
+		},
+	})
+	return &commentStorage{db: db}
+}
+
+func (s *commentStorage) Create(ctx context.Context, comment *Comment) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&Todo{}, comment.TodoID).Error; err != nil {
+			return err
+		}
+		comment.Mentions = newCommentMentions(comment.Body)
+		return tx.Create(comment).Error
+	})
+}
+
+// List returns a page of the comments of a todo in the order they were posted,
+// together with the total number of comments.
+func (s *commentStorage) List(ctx context.Context, todoID, offset, limit int) ([]Comment, int64, error) {
+	if err := s.db.WithContext(ctx).First(&Todo{}, todoID).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := s.db.WithContext(ctx).Model(&Comment{}).Where("todo_id = ?", todoID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var comments []Comment
+	if err := s.db.WithContext(ctx).
+		Preload("Mentions").
+		Where("todo_id = ?", todoID).
+		Order("id").
+		Offset(offset).Limit(limit).
+		Find(&comments).Error; err != nil {
+		return nil, 0, err
+	}
+	return comments, total, nil
+}
+
+// ListByTodoIDs returns the comments of several todos at once, in the order of
+// each thread.
+func (s *commentStorage) ListByTodoIDs(ctx context.Context, todoIDs []uint) ([]Comment, error) {
+	var comments []Comment
+	if err := s.db.WithContext(ctx).
+		Preload("Mentions").
+		Where("todo_id IN ?", todoIDs).
+		Order("id").
+		Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (s *commentStorage) Get(ctx context.Context, todoID, id int) (Comment, error) {
+	var comment Comment
+	if err := s.db.WithContext(ctx).Preload("Mentions").Where("todo_id = ?", todoID).First(&comment, id).Error; err != nil {
+		return comment, err
+	}
+	return comment, nil
+}
+
+// Update replaces the body of a comment, keeping the previous body as a
+// revision and extracting the mentions again.
+func (s *commentStorage) Update(ctx context.Context, todoID, id int, body string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var comment Comment
+		if err := tx.Where("todo_id = ?", todoID).First(&comment, id).Error; err != nil {
+			return err
+		}
+		if comment.Body == body {
+			return nil
+		}
+
+		if err := tx.Create(&CommentRevision{CommentID: comment.ID, Body: comment.Body}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(&CommentMention{}).Error; err != nil {
+			return err
+		}
+		if mentions := newCommentMentions(body); len(mentions) > 0 {
+			for i := range mentions {
+				mentions[i].CommentID = comment.ID
+			}
+			if err := tx.Create(&mentions).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&comment).Updates(map[string]any{
+			"body":      body,
+			"edited_at": time.Now(),
+		}).Error
+	})
+}
+
+func (s *commentStorage) Delete(ctx context.Context, todoID, id int) error {
+	if _, err := s.Get(ctx, todoID, id); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Delete(&Comment{}, id).Error
+}
+
+// ListRevisions returns the previous bodies of a comment, oldest first.
+func (s *commentStorage) ListRevisions(ctx context.Context, todoID, id int) ([]CommentRevision, error) {
+	if _, err := s.Get(ctx, todoID, id); err != nil {
+		return nil, err
+	}
+
+	var revisions []CommentRevision
+	if err := s.db.WithContext(ctx).Where("comment_id = ?", id).Order("id").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+var (
+	mentionPattern      = regexp.MustCompile(`(^|[^\w@])@(\w[\w.-]*\w|\w)`)
+	fencedCodePattern   = regexp.MustCompile("(?s)```.*?(```|$)")
+	inlineCodePattern   = regexp.MustCompile("`[^`\n]*`")
+	markdownLinkPattern = regexp.MustCompile(`\]\([^)]*\)`)
+)
+
+// ExtractMentions returns the distinct usernames mentioned with @name in a
+// markdown body, ignoring code and link targets.
+func ExtractMentions(body string) []string {
+	body = fencedCodePattern.ReplaceAllString(body, " ")
+	body = inlineCodePattern.ReplaceAllString(body, " ")
+	body = markdownLinkPattern.ReplaceAllString(body, "]")
+
+	var usernames []string
+	seen := make(map[string]struct{})
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		username := strings.ToLower(m[2])
+		if _, ok := seen[username]; ok {
+			continue
+		}
+		seen[username] = struct{}{}
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
+
+func newCommentMentions(body string) []CommentMention {
+	var mentions []CommentMention
+	for _, username := range ExtractMentions(body) {
+		mentions = append(mentions, CommentMention{Username: username})
+	}
+	return mentions
+}
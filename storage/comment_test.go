@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExtractMentions(t *testing.T) {
+	Convey("Given a markdown comment mentioning users", t, func() {
+		body := "@alice can you review this with @Bob.Smith? cc @alice\n\n" +
+			"Mail me at carol@example.com, see `@dave` and [@erin](https://example.com/@frank).\n" +
+			"```\n@grace\n```"
+
+		mentions := ExtractMentions(body)
+
+		Convey("Then it should return each mentioned user once", func() {
+			So(mentions, ShouldResemble, []string{"alice", "bob.smith", "erin"})
+		})
+	})
+
+	Convey("Given a comment without mentions", t, func() {
+		Convey("Then it should return no usernames", func() {
+			So(ExtractMentions("Looks good to me"), ShouldBeEmpty)
+		})
+	})
+}
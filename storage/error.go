@@ -6,6 +6,41 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	ErrUnknownStatus     = errors.New("unknown status")
+	ErrIllegalTransition = errors.New("illegal status transition")
+	ErrInvalidRRule      = errors.New("invalid recurrence rule")
+	ErrInvalidMove       = errors.New("invalid move")
+	ErrTodoDeleted       = errors.New("todo was deleted")
+	ErrNotEventSourced   = errors.New("todos were written outside of the event log")
+)
+
 func IsNotFound(err error) bool {
 	return errors.Is(err, gorm.ErrRecordNotFound)
 }
+
+func IsUnknownStatus(err error) bool {
+	return errors.Is(err, ErrUnknownStatus)
+}
+
+func IsIllegalTransition(err error) bool {
+	return errors.Is(err, ErrIllegalTransition)
+}
+
+func IsInvalidRRule(err error) bool {
+	return errors.Is(err, ErrInvalidRRule)
+}
+
+func IsInvalidMove(err error) bool {
+	return errors.Is(err, ErrInvalidMove)
+}
+
+func IsTodoDeleted(err error) bool {
+	return errors.Is(err, ErrTodoDeleted)
+}
+
+// IsDuplicate reports whether err is a violation of a unique index, such as
+// the one of the external source and ID of todos.
+func IsDuplicate(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
@@ -0,0 +1,783 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+// Types of the events in the todo event log.
+const (
+	EventTodoCreated            = "TodoCreated"
+	EventTodoRenamed            = "TodoRenamed"
+	EventTodoDescriptionChanged = "TodoDescriptionChanged"
+	EventTodoRescheduled        = "TodoRescheduled"
+	EventTodoRecurrenceChanged  = "TodoRecurrenceChanged"
+	EventTodoPriorityChanged    = "TodoPriorityChanged"
+	EventTodoTagsChanged        = "TodoTagsChanged"
+	EventTodoStatusChanged      = "TodoStatusChanged"
+	EventTodoCompleted          = "TodoCompleted"
+	EventTodoReopened           = "TodoReopened"
+	EventTodoMovedToProject     = "TodoMovedToProject"
+	EventTodoRanked             = "TodoRanked"
+	EventTodoDeleted            = "TodoDeleted"
+	EventTodoPurged             = "TodoPurged"
+)
+
+// TodoLogEntry is an event of the todo event log. Version numbers the events of
+// a single todo starting at 1, which also guards against concurrent writers.
+type TodoLogEntry struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	TodoID    uint `gorm:"uniqueIndex:idx_todo_log_entry_version"`
+	Version   int  `gorm:"uniqueIndex:idx_todo_log_entry_version"`
+	Type      string
+	Data      string
+	Actor     string
+	RequestID string
+}
+
+type todoCreated struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status"`
+	Completed   bool       `json:"completed"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	RRule       string     `json:"rrule,omitempty"`
+	SeriesID    *uint      `json:"seriesId,omitempty"`
+	ProjectID   *uint      `json:"projectId,omitempty"`
+	Rank        string     `json:"rank"`
+	Priority    string     `json:"priority,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+
+	ExternalSource *string `json:"externalSource,omitempty"`
+	ExternalID     *string `json:"externalId,omitempty"`
+}
+
+type todoRenamed struct {
+	Title string `json:"title"`
+}
+
+type todoDescriptionChanged struct {
+	Description string `json:"description"`
+}
+
+type todoRescheduled struct {
+	DueAt *time.Time `json:"dueAt"`
+}
+
+type todoRecurrenceChanged struct {
+	RRule    string `json:"rrule"`
+	SeriesID *uint  `json:"seriesId"`
+}
+
+type todoPriorityChanged struct {
+	Priority string `json:"priority"`
+}
+
+type todoTagsChanged struct {
+	Tags []string `json:"tags"`
+}
+
+// todoStatusChanged completes the todo when To is a terminal state, at
+// CompletedAt when it was completed before the event was logged.
+type todoStatusChanged struct {
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+type todoMovedToProject struct {
+	ProjectID *uint `json:"projectId"`
+}
+
+type todoRanked struct {
+	Rank string `json:"rank"`
+}
+
+// eventSourcedTodoStorage turns every mutation into events appended to the todo
+// event log. The events are projected onto the same tables the CRUD storage
+// writes, within the same transaction, so reads are shared with it.
+//
+// Only changes made through this storage are part of the log, so it expects to
+// start from an empty database. Other storages changing todos, such as the
+// project storage when a project is deleted, go through it.
+type eventSourcedTodoStorage struct {
+	*todoStorage
+}
+
+func NewEventSourcedTodoStorage(lc fx.Lifecycle, db *gorm.DB, wf *Workflow, blobs BlobStore) TodoStorage {
+	s := &eventSourcedTodoStorage{
+		todoStorage: &todoStorage{db: db, workflow: wf, blobs: blobs},
+	}
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			if err := db.AutoMigrate(&Todo{}, &TodoTransition{}, &TodoEvent{}, &TodoLogEntry{}, &OutboxMessage{}); err != nil {
+				return err
+			}
+			return s.backfill()
+		},
+	})
+	return s
+}
+
+// backfill completes the todos like the CRUD storage, logging the ranks it
+// spreads. Todos completed by events logged before completion times were are
+// taken to be completed at their last completion, as ReplayTodoLog does.
+func (s *eventSourcedTodoStorage) backfill() error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		completed := tx.Model(&TodoLogEntry{}).Select("todo_id").Where("type = ?", EventTodoCompleted)
+		lastCompleted := tx.Model(&TodoLogEntry{}).Select("MAX(created_at)").Where("todo_id = todos.id AND type = ?", EventTodoCompleted)
+		if err := tx.Unscoped().Model(&Todo{}).
+			Where("completed = ? AND completed_at IS NULL AND id IN (?)", true, completed).
+			UpdateColumn("completed_at", lastCompleted).Error; err != nil {
+			return err
+		}
+		return backfillTodos(tx, s.workflow, s.spreadRanks)
+	})
+}
+
+func (s *eventSourcedTodoStorage) Create(ctx context.Context, todo *Todo) error {
+	if err := ValidateRRule(todo.RRule, todo.DueAt); err != nil {
+		return err
+	}
+	if todo.DueAt != nil {
+		todo.DueAt = ptr(todo.DueAt.UTC())
+	}
+	todo.Status = s.workflow.Initial
+	todo.Completed = ptr(s.workflow.IsTerminal(todo.Status))
+
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		if todo.ProjectID != nil {
+			if err := tx.First(&Project{}, *todo.ProjectID).Error; err != nil {
+				return err
+			}
+		}
+		rank, err := s.appendRank(tx)
+		if err != nil {
+			return err
+		}
+		todo.Rank = rank
+
+		return s.create(tx, todo)
+	})
+}
+
+func (s *eventSourcedTodoStorage) Update(ctx context.Context, id int, todo Todo) error {
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+
+		dueAt := current.DueAt
+		if todo.DueAt != nil {
+			dueAt = ptr(todo.DueAt.UTC())
+		}
+		rrule := current.RRule
+		if todo.RRule != "" {
+			rrule = todo.RRule
+		}
+		if err := ValidateRRule(rrule, dueAt); err != nil {
+			return err
+		}
+
+		if todo.DueAt != nil && (current.DueAt == nil || !current.DueAt.Equal(*dueAt)) {
+			if err := s.emit(tx, current.ID, EventTodoRescheduled, todoRescheduled{DueAt: dueAt}); err != nil {
+				return err
+			}
+			current.DueAt = dueAt
+		}
+		if err := s.emitFieldChanges(tx, &current, todo); err != nil {
+			return err
+		}
+
+		if todo.Completed != nil && *todo.Completed != s.workflow.IsTerminal(current.Status) {
+			to := s.workflow.Initial
+			if *todo.Completed {
+				to = s.workflow.Completed
+			}
+			if _, err := s.transition(tx, current, to, todo.CompletedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *eventSourcedTodoStorage) Replace(ctx context.Context, id int, todo Todo) error {
+	if todo.DueAt != nil {
+		todo.DueAt = ptr(todo.DueAt.UTC())
+	}
+	if err := ValidateRRule(todo.RRule, todo.DueAt); err != nil {
+		return err
+	}
+
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+
+		if todo.Title != current.Title {
+			if err := s.emit(tx, current.ID, EventTodoRenamed, todoRenamed{Title: todo.Title}); err != nil {
+				return err
+			}
+		}
+		if todo.Description != current.Description {
+			if err := s.emit(tx, current.ID, EventTodoDescriptionChanged, todoDescriptionChanged{Description: todo.Description}); err != nil {
+				return err
+			}
+		}
+		if (todo.DueAt == nil) != (current.DueAt == nil) || todo.DueAt != nil && !todo.DueAt.Equal(*current.DueAt) {
+			if err := s.emit(tx, current.ID, EventTodoRescheduled, todoRescheduled{DueAt: todo.DueAt}); err != nil {
+				return err
+			}
+			current.DueAt = todo.DueAt
+		}
+		if todo.RRule != current.RRule || todo.RRule != "" && current.SeriesID == nil {
+			seriesID := current.SeriesID
+			if todo.RRule != "" && seriesID == nil {
+				seriesID = ptr(current.ID)
+			}
+			if err := s.emit(tx, current.ID, EventTodoRecurrenceChanged, todoRecurrenceChanged{RRule: todo.RRule, SeriesID: seriesID}); err != nil {
+				return err
+			}
+			current.RRule = todo.RRule
+			current.SeriesID = seriesID
+		}
+		if todo.Priority != current.Priority {
+			if err := s.emit(tx, current.ID, EventTodoPriorityChanged, todoPriorityChanged{Priority: todo.Priority}); err != nil {
+				return err
+			}
+		}
+		if !slices.Equal(todo.Tags, current.Tags) {
+			if err := s.emit(tx, current.ID, EventTodoTagsChanged, todoTagsChanged{Tags: todo.Tags}); err != nil {
+				return err
+			}
+		}
+
+		if todo.Completed != nil && *todo.Completed != s.workflow.IsTerminal(current.Status) {
+			to := s.workflow.Initial
+			if *todo.Completed {
+				to = s.workflow.Completed
+			}
+			if _, err := s.transition(tx, current, to, todo.CompletedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *eventSourcedTodoStorage) Upsert(ctx context.Context, todo *Todo) (bool, error) {
+	return upsertTodo(ctx, s.db, s, todo)
+}
+
+func (s *eventSourcedTodoStorage) UpdateSeries(ctx context.Context, id int, todo Todo) error {
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+		if current.SeriesID == nil {
+			return fmt.Errorf("%w: todo %d is not part of a series", ErrInvalidRRule, id)
+		}
+		if todo.RRule != "" {
+			if _, err := ParseRRule(todo.RRule, time.Time{}); err != nil {
+				return err
+			}
+		}
+
+		var occurrences []Todo
+		if err := tx.
+			Where("series_id = ?", *current.SeriesID).
+			Where("status NOT IN ?", s.workflow.Terminal).
+			Find(&occurrences).Error; err != nil {
+			return err
+		}
+
+		for _, occurrence := range occurrences {
+			if err := s.emitFieldChanges(tx, &occurrence, todo); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *eventSourcedTodoStorage) MoveToProject(ctx context.Context, id int, projectID *uint) error {
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+		if projectID != nil {
+			if err := tx.First(&Project{}, *projectID).Error; err != nil {
+				return err
+			}
+		}
+		if valueOf(current.ProjectID) == valueOf(projectID) {
+			return nil
+		}
+		return s.emit(tx, current.ID, EventTodoMovedToProject, todoMovedToProject{ProjectID: projectID})
+	})
+}
+
+func (s *eventSourcedTodoStorage) Move(ctx context.Context, id int, after, before *int) error {
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+
+		rank, err := s.moveRank(tx, id, after, before)
+		if err != nil {
+			return err
+		}
+		if err := s.emit(tx, current.ID, EventTodoRanked, todoRanked{Rank: rank}); err != nil {
+			return err
+		}
+		return s.rebalanceIfNeeded(tx, rank)
+	})
+}
+
+func (s *eventSourcedTodoStorage) Delete(ctx context.Context, id int) error {
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+		return s.emit(tx, current.ID, EventTodoDeleted, struct{}{})
+	})
+}
+
+// Purge removes the comments and attachments of a todo and drops it from the
+// projections. The events of the todo stay in the log, ending with TodoPurged.
+func (s *eventSourcedTodoStorage) Purge(ctx context.Context, id int) error {
+	var keys []string
+	err := dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.Unscoped().First(&current, id).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if keys, err = purgeAttachedData(tx, id); err != nil {
+			return err
+		}
+		return s.emit(tx, current.ID, EventTodoPurged, struct{}{})
+	})
+	if err != nil {
+		return err
+	}
+
+	afterCommit(ctx, func() { deleteBlobs(ctx, s.blobs, keys...) })
+	return nil
+}
+
+func (s *eventSourcedTodoStorage) Transition(ctx context.Context, id int, status string) (TodoTransition, error) {
+	var transition TodoTransition
+	err := dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+
+		var err error
+		transition, err = s.transition(tx, current, status, nil)
+		return err
+	})
+	return transition, err
+}
+
+// create appends the TodoCreated event of a new todo and reads the projected
+// todo back into todo.
+func (s *eventSourcedTodoStorage) create(tx *gorm.DB, todo *Todo) error {
+	var ids struct {
+		Logged    uint
+		Projected uint
+	}
+	if err := tx.Raw("SELECT (SELECT COALESCE(MAX(todo_id), 0) FROM todo_log_entries) AS logged, (SELECT COALESCE(MAX(id), 0) FROM todos) AS projected").
+		Scan(&ids).Error; err != nil {
+		return err
+	}
+	id := max(ids.Logged, ids.Projected) + 1
+
+	if todo.RRule != "" && todo.SeriesID == nil {
+		todo.SeriesID = ptr(id)
+	}
+	if err := s.emit(tx, id, EventTodoCreated, todoCreated{
+		Title:       todo.Title,
+		Description: todo.Description,
+		Status:      todo.Status,
+		Completed:   todo.Completed != nil && *todo.Completed,
+		DueAt:       todo.DueAt,
+		RRule:       todo.RRule,
+		SeriesID:    todo.SeriesID,
+		ProjectID:   todo.ProjectID,
+		Rank:        todo.Rank,
+		Priority:    todo.Priority,
+		Tags:        todo.Tags,
+
+		ExternalSource: todo.ExternalSource,
+		ExternalID:     todo.ExternalID,
+	}); err != nil {
+		return err
+	}
+	if err := s.rebalanceIfNeeded(tx, todo.Rank); err != nil {
+		return err
+	}
+	return tx.First(todo, id).Error
+}
+
+// emitFieldChanges appends an event for each of the title, description and
+// recurrence rule of todo that is set and differs from current, and applies
+// them to current.
+func (s *eventSourcedTodoStorage) emitFieldChanges(tx *gorm.DB, current *Todo, todo Todo) error {
+	if todo.Title != "" && todo.Title != current.Title {
+		if err := s.emit(tx, current.ID, EventTodoRenamed, todoRenamed{Title: todo.Title}); err != nil {
+			return err
+		}
+		current.Title = todo.Title
+	}
+	if todo.Description != "" && todo.Description != current.Description {
+		if err := s.emit(tx, current.ID, EventTodoDescriptionChanged, todoDescriptionChanged{Description: todo.Description}); err != nil {
+			return err
+		}
+		current.Description = todo.Description
+	}
+	if todo.RRule != "" && (todo.RRule != current.RRule || current.SeriesID == nil) {
+		seriesID := current.SeriesID
+		if seriesID == nil {
+			seriesID = ptr(current.ID)
+		}
+		if err := s.emit(tx, current.ID, EventTodoRecurrenceChanged, todoRecurrenceChanged{RRule: todo.RRule, SeriesID: seriesID}); err != nil {
+			return err
+		}
+		current.RRule = todo.RRule
+		current.SeriesID = seriesID
+	}
+	return nil
+}
+
+func (s *eventSourcedTodoStorage) transition(tx *gorm.DB, todo Todo, to string, completedAt *time.Time) (TodoTransition, error) {
+	if err := s.workflow.CheckTransition(todo.Status, to); err != nil {
+		return TodoTransition{}, err
+	}
+
+	eventType := EventTodoStatusChanged
+	if s.workflow.IsTerminal(to) {
+		eventType = EventTodoCompleted
+	} else {
+		completedAt = nil
+		if s.workflow.IsTerminal(todo.Status) {
+			eventType = EventTodoReopened
+		}
+	}
+	if err := s.emit(tx, todo.ID, eventType, todoStatusChanged{From: todo.Status, To: to, CompletedAt: completedAt}); err != nil {
+		return TodoTransition{}, err
+	}
+
+	var transition TodoTransition
+	if err := tx.Where("todo_id = ?", todo.ID).Last(&transition).Error; err != nil {
+		return TodoTransition{}, err
+	}
+
+	if !s.workflow.IsTerminal(todo.Status) && s.workflow.IsTerminal(to) {
+		if err := s.spawnNextOccurrence(tx, todo); err != nil {
+			return TodoTransition{}, err
+		}
+	}
+	return transition, nil
+}
+
+func (s *eventSourcedTodoStorage) spawnNextOccurrence(tx *gorm.DB, todo Todo) error {
+	dueAt, err := s.nextOccurrence(tx, todo)
+	if err != nil || dueAt == nil {
+		return err
+	}
+
+	rank, err := s.appendRank(tx)
+	if err != nil {
+		return err
+	}
+
+	return s.create(tx, &Todo{
+		Rank:        rank,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Status:      s.workflow.Initial,
+		Completed:   ptr(s.workflow.IsTerminal(s.workflow.Initial)),
+		DueAt:       dueAt,
+		RRule:       todo.RRule,
+		SeriesID:    todo.SeriesID,
+		ProjectID:   todo.ProjectID,
+		Priority:    todo.Priority,
+		Tags:        todo.Tags,
+	})
+}
+
+func (s *eventSourcedTodoStorage) rebalanceIfNeeded(tx *gorm.DB, rank string) error {
+	if len(rank) <= MaxRankLength {
+		return nil
+	}
+	return s.spreadRanks(tx)
+}
+
+func (s *eventSourcedTodoStorage) spreadRanks(tx *gorm.DB) error {
+	var ids []uint
+	if err := tx.Model(&Todo{}).Order("rank").Order("id").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for i, rank := range EvenRanks(len(ids)) {
+		if err := s.emit(tx, ids[i], EventTodoRanked, todoRanked{Rank: rank}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emit appends an event for the todo with the given ID to the log and applies
+// it to the projections.
+func (s *eventSourcedTodoStorage) emit(tx *gorm.DB, todoID uint, eventType string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	if err := tx.Model(&TodoLogEntry{}).Where("todo_id = ?", todoID).Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+		return err
+	}
+
+	entry := TodoLogEntry{
+		TodoID:  todoID,
+		Version: version + 1,
+		Type:    eventType,
+		Data:    string(b),
+	}
+	ctx := tx.Statement.Context
+	if actor, ok := ctx.Value(actorKey{}).(string); ok {
+		entry.Actor = actor
+	}
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		entry.RequestID = id
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return err
+	}
+
+	event, err := applyTodoLogEntry(tx, entry, true)
+	if err != nil || event == nil {
+		return err
+	}
+	return enqueueTodoChanged(tx, *event)
+}
+
+// applyTodoLogEntry projects entry onto the todos and their transitions and,
+// with record, onto their audit trail, returning the audit event it recorded,
+// if any. The projected rows take their timestamps from the entry so that
+// replaying the log reproduces them. Replaying neither records nor publishes
+// the events again.
+func applyTodoLogEntry(tx *gorm.DB, entry TodoLogEntry, record bool) (*TodoEvent, error) {
+	var before *Todo
+	if entry.Type != EventTodoCreated {
+		before = &Todo{}
+		if err := tx.Unscoped().First(before, entry.TodoID).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	update := func(columns map[string]any) error {
+		columns["updated_at"] = entry.CreatedAt
+		return tx.Model(&Todo{}).Unscoped().Where("id = ?", entry.TodoID).UpdateColumns(columns).Error
+	}
+
+	var err error
+	action := TodoEventUpdated
+	switch entry.Type {
+	case EventTodoCreated:
+		var data todoCreated
+		if err := json.Unmarshal([]byte(entry.Data), &data); err != nil {
+			return nil, err
+		}
+		action = TodoEventCreated
+		err = tx.Create(&Todo{
+			Model: gorm.Model{
+				ID:        entry.TodoID,
+				CreatedAt: entry.CreatedAt,
+				UpdatedAt: entry.CreatedAt,
+			},
+			Title:       data.Title,
+			Description: data.Description,
+			Status:      data.Status,
+			Completed:   &data.Completed,
+			DueAt:       data.DueAt,
+			RRule:       data.RRule,
+			SeriesID:    data.SeriesID,
+			ProjectID:   data.ProjectID,
+			Rank:        data.Rank,
+			Priority:    data.Priority,
+			Tags:        data.Tags,
+
+			ExternalSource: data.ExternalSource,
+			ExternalID:     data.ExternalID,
+		}).Error
+	case EventTodoRenamed:
+		var data todoRenamed
+		if err := json.Unmarshal([]byte(entry.Data), &data); err != nil {
+			return nil, err
+		}
+		err = update(map[string]any{"title": data.Title})
+	case EventTodoDescriptionChanged:
+		var data todoDescriptionChanged
+		if err := json.Unmarshal([]byte(entry.Data), &data); err != nil {
+			return nil, err
+		}
+		err = update(map[string]any{"description": data.Description})
+	case EventTodoRescheduled:
+		var data todoRescheduled
+		if err := json.Unmarshal([]byte(entry.Data), &data); err != nil {
+			return nil, err
+		}
+		err = update(map[string]any{"due_at": data.DueAt})
+	case EventTodoRecurrenceChanged:
+		var data todoRecurrenceChanged
+		if err := json.Unmarshal([]byte(entry.Data), &data); err != nil {
+			return nil, err
+		}
+		err = update(map[string]any{"r_rule": data.RRule, "series_id": data.SeriesID})
+	case EventTodoPriorityChanged:
+		var data todoPriorityChanged
+		if err := json.Unmarshal([]byte(entry.Data), &data); err != nil {
+			return nil, err
+		}
+		err = update(map[string]any{"priority": data.Priority})
+	case EventTodoTagsChanged:
+		var data todoTagsChanged
+		if err := json.Unmarshal([]byte(entry.Data), &data); err != nil {
+			return nil, err
+		}
+		// Maps skip the serializer of the column, so the tags are encoded the
+		// way it would.
+		var tags any
+		if data.Tags != nil {
+			b, err := json.Marshal(data.Tags)
+			if err != nil {
+				return nil, err
+			}
+			tags = string(b)
+		}
+		err = update(map[string]any{"tags": tags})
+	case EventTodoStatusChanged, EventTodoCompleted, EventTodoReopened:
+		var data todoStatusChanged
+		if err := json.Unmarshal([]byte(entry.Data), &data); err != nil {
+			return nil, err
+		}
+		var completedAt *time.Time
+		if entry.Type == EventTodoCompleted {
+			completedAt = &entry.CreatedAt
+			if data.CompletedAt != nil {
+				completedAt = data.CompletedAt
+			}
+		}
+		if err := update(map[string]any{"status": data.To, "completed": entry.Type == EventTodoCompleted, "completed_at": completedAt}); err != nil {
+			return nil, err
+		}
+		err = tx.Create(&TodoTransition{
+			CreatedAt: entry.CreatedAt,
+			TodoID:    entry.TodoID,
+			From:      data.From,
+			To:        data.To,
+		}).Error
+	case EventTodoMovedToProject:
+		var data todoMovedToProject
+		if err := json.Unmarshal([]byte(entry.Data), &data); err != nil {
+			return nil, err
+		}
+		err = update(map[string]any{"project_id": data.ProjectID})
+	case EventTodoRanked:
+		var data todoRanked
+		if err := json.Unmarshal([]byte(entry.Data), &data); err != nil {
+			return nil, err
+		}
+		err = update(map[string]any{"rank": data.Rank})
+	case EventTodoDeleted:
+		action = TodoEventDeleted
+		err = tx.Model(&Todo{}).Where("id = ?", entry.TodoID).UpdateColumn("deleted_at", entry.CreatedAt).Error
+	case EventTodoPurged:
+		action = TodoEventPurged
+		if err := tx.Where("todo_id = ?", entry.TodoID).Delete(&TodoTransition{}).Error; err != nil {
+			return nil, err
+		}
+		err = tx.Unscoped().Delete(&Todo{}, entry.TodoID).Error
+	default:
+		err = fmt.Errorf("unknown todo event type %q", entry.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !record {
+		return nil, nil
+	}
+
+	var after *Todo
+	if action != TodoEventDeleted && action != TodoEventPurged {
+		after = &Todo{}
+		if err := tx.First(after, entry.TodoID).Error; err != nil {
+			return nil, err
+		}
+	}
+	return appendTodoEvent(tx, TodoEvent{
+		CreatedAt: entry.CreatedAt,
+		Action:    action,
+		Actor:     entry.Actor,
+		RequestID: entry.RequestID,
+	}, before, after)
+}
+
+// ReplayTodoLog rebuilds the todos and their transitions from scratch by
+// applying the whole todo event log again. The audit trail is kept as it is,
+// since sync tokens point into it. It fails with ErrNotEventSourced, leaving
+// everything as is, when a todo was not created through the log, since it
+// would be lost.
+func ReplayTodoLog(ctx context.Context, db *gorm.DB) error {
+	if err := db.AutoMigrate(&Todo{}, &TodoTransition{}, &TodoEvent{}, &TodoLogEntry{}); err != nil {
+		return err
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var unlogged int64
+		if err := tx.Unscoped().Model(&Todo{}).
+			Where("id NOT IN (?)", tx.Model(&TodoLogEntry{}).Select("todo_id").Where("type = ?", EventTodoCreated)).
+			Count(&unlogged).Error; err != nil {
+			return err
+		}
+		if unlogged > 0 {
+			return fmt.Errorf("%w: %d todos have no %s event", ErrNotEventSourced, unlogged, EventTodoCreated)
+		}
+
+		for _, model := range []any{&TodoTransition{}, &Todo{}} {
+			if err := tx.Unscoped().Where("1 = 1").Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		var entries []TodoLogEntry
+		return tx.FindInBatches(&entries, 500, func(*gorm.DB, int) error {
+			for _, entry := range entries {
+				if _, err := applyTodoLogEntry(tx, entry, false); err != nil {
+					return fmt.Errorf("apply todo event %d: %w", entry.ID, err)
+				}
+			}
+			return nil
+		}).Error
+	})
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx/fxtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestEventSourcedTodoStorage_Replay(t *testing.T) {
+	Convey("Given an event sourced todo storage with some history", t, func() {
+		db, err := gorm.Open(sqlite.Open("file:eventsourced?mode=memory&cache=shared"), &gorm.Config{})
+		So(err, ShouldBeNil)
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+
+		wf := &Workflow{
+			Initial:   "todo",
+			Completed: "done",
+			States:    []string{"todo", "in_progress", "done"},
+			Terminal:  []string{"done"},
+			Transitions: map[string][]string{
+				"todo":        {"in_progress", "done"},
+				"in_progress": {"todo", "done"},
+				"done":        {"todo"},
+			},
+		}
+		lc := fxtest.NewLifecycle(t)
+		s := NewEventSourcedTodoStorage(lc, db, wf, nil)
+		projects := NewProjectStorage(lc, db, s)
+		lc.RequireStart()
+		defer lc.RequireStop()
+
+		ctx := WithActor(context.Background(), "alice")
+		dueAt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+		recurring := Todo{Title: "Water plants", DueAt: &dueAt, RRule: "FREQ=DAILY;COUNT=3", Priority: "B", Tags: []string{"+garden"}}
+		So(s.Create(ctx, &recurring), ShouldBeNil)
+		other := Todo{Title: "Write report"}
+		So(s.Create(ctx, &other), ShouldBeNil)
+		So(s.Update(ctx, int(other.ID), Todo{Title: "Write annual report", Description: "Q4"}), ShouldBeNil)
+		_, err = s.Transition(ctx, int(other.ID), "in_progress")
+		So(err, ShouldBeNil)
+		So(s.Replace(ctx, int(other.ID), Todo{Title: "Write annual report", Completed: ptr(false), Priority: "A", Tags: []string{"@work"}}), ShouldBeNil)
+		replaced, err := s.Get(ctx, int(other.ID))
+		So(err, ShouldBeNil)
+		So(replaced.Priority, ShouldEqual, "A")
+		So(replaced.Tags, ShouldResemble, []string{"@work"})
+		So(s.Replace(ctx, int(other.ID), Todo{Title: "Write annual report", Completed: ptr(false)}), ShouldBeNil)
+		So(s.Update(ctx, int(recurring.ID), Todo{Completed: ptr(true)}), ShouldBeNil)
+		So(s.Move(ctx, int(other.ID), nil, ptr(int(recurring.ID))), ShouldBeNil)
+		So(s.Delete(ctx, int(recurring.ID)), ShouldBeNil)
+		synced := Todo{Title: "Synced", ExternalSource: ptr("jira"), ExternalID: ptr("PROJ-1")}
+		_, err = s.Upsert(ctx, &synced)
+		So(err, ShouldBeNil)
+		home, work := Project{Name: "Home"}, Project{Name: "Work"}
+		So(projects.Create(ctx, &home), ShouldBeNil)
+		So(projects.Create(ctx, &work), ShouldBeNil)
+		So(s.Create(ctx, &Todo{Title: "Clean the house", ProjectID: &home.ID}), ShouldBeNil)
+		So(s.Create(ctx, &Todo{Title: "Plan the week", ProjectID: &work.ID}), ShouldBeNil)
+		So(projects.Delete(ctx, int(home.ID), true), ShouldBeNil)
+		So(projects.Delete(ctx, int(work.ID), false), ShouldBeNil)
+
+		snapshot := func() ([]Todo, []TodoTransition, []TodoEvent) {
+			var todos []Todo
+			var transitions []TodoTransition
+			var events []TodoEvent
+			So(db.Unscoped().Order("id").Find(&todos).Error, ShouldBeNil)
+			So(db.Order("id").Find(&transitions).Error, ShouldBeNil)
+			So(db.Order("id").Find(&events).Error, ShouldBeNil)
+			return todos, transitions, events
+		}
+		todos, transitions, events := snapshot()
+
+		Convey("Then completing the recurring todo should have created its next occurrence", func() {
+			So(todos, ShouldHaveLength, 6)
+			So(todos[2].SeriesID, ShouldResemble, recurring.SeriesID)
+			So(todos[2].DueAt.Equal(dueAt.AddDate(0, 0, 1)), ShouldBeTrue)
+			So(todos[2].Priority, ShouldEqual, "B")
+			So(todos[2].Tags, ShouldResemble, []string{"+garden"})
+		})
+
+		Convey("Then replacing a todo should have cleared the fields it left out", func() {
+			So(todos[1].Title, ShouldEqual, "Write annual report")
+			So(todos[1].Description, ShouldBeEmpty)
+			So(todos[1].Priority, ShouldBeEmpty)
+			So(todos[1].Tags, ShouldBeEmpty)
+			So(todos[1].Status, ShouldEqual, "in_progress")
+		})
+
+		Convey("Then deleting the projects should have deleted or moved their todos", func() {
+			So(todos[4].DeletedAt.Valid, ShouldBeTrue)
+			So(todos[5].DeletedAt.Valid, ShouldBeFalse)
+			So(todos[5].ProjectID, ShouldBeNil)
+		})
+
+		Convey("When a todo was written without the event log", func() {
+			So(db.Create(&Todo{Title: "Outside", Status: "todo"}).Error, ShouldBeNil)
+			err := ReplayTodoLog(context.Background(), db)
+
+			Convey("Then the replay should be refused and nothing dropped", func() {
+				So(err, ShouldWrap, ErrNotEventSourced)
+				replayedTodos, _, replayedEvents := snapshot()
+				So(replayedTodos, ShouldHaveLength, len(todos)+1)
+				So(replayedEvents, ShouldHaveLength, len(events))
+			})
+		})
+
+		Convey("When the storage starts again with todos without a rank or a completion time", func() {
+			So(db.Model(&Todo{}).Where("id = ?", other.ID).UpdateColumn("rank", "").Error, ShouldBeNil)
+			So(db.Unscoped().Model(&Todo{}).Where("id = ?", recurring.ID).
+				UpdateColumns(map[string]any{"completed_at": nil, "updated_at": time.Now().Add(time.Hour)}).Error, ShouldBeNil)
+			restarted := fxtest.NewLifecycle(t)
+			NewEventSourcedTodoStorage(restarted, db, wf, nil)
+			restarted.RequireStart()
+			restarted.RequireStop()
+			ranked, _, _ := snapshot()
+			So(ReplayTodoLog(context.Background(), db), ShouldBeNil)
+			replayedTodos, _, _ := snapshot()
+
+			Convey("Then the ranks spread again and the completion times should be replayed", func() {
+				So(ranked[1].Rank, ShouldNotBeEmpty)
+				So(ranked[0].CompletedAt, ShouldNotBeNil)
+				for i := range ranked {
+					So(replayedTodos[i].Rank, ShouldEqual, ranked[i].Rank)
+					So(replayedTodos[i].CompletedAt, ShouldResemble, ranked[i].CompletedAt)
+				}
+			})
+		})
+
+		Convey("When the projections are replayed from the event log", func() {
+			So(ReplayTodoLog(context.Background(), db), ShouldBeNil)
+			replayedTodos, replayedTransitions, replayedEvents := snapshot()
+
+			Convey("Then they should be rebuilt exactly", func() {
+				So(replayedTodos, ShouldHaveLength, len(todos))
+				for i := range todos {
+					So(replayedTodos[i].ID, ShouldEqual, todos[i].ID)
+					So(replayedTodos[i].Title, ShouldEqual, todos[i].Title)
+					So(replayedTodos[i].Description, ShouldEqual, todos[i].Description)
+					So(replayedTodos[i].Status, ShouldEqual, todos[i].Status)
+					So(replayedTodos[i].Completed, ShouldResemble, todos[i].Completed)
+					So(replayedTodos[i].Rank, ShouldEqual, todos[i].Rank)
+					So(replayedTodos[i].Priority, ShouldEqual, todos[i].Priority)
+					So(replayedTodos[i].Tags, ShouldResemble, todos[i].Tags)
+					So(replayedTodos[i].SeriesID, ShouldResemble, todos[i].SeriesID)
+					So(replayedTodos[i].ProjectID, ShouldResemble, todos[i].ProjectID)
+					So(replayedTodos[i].ExternalID, ShouldResemble, todos[i].ExternalID)
+					So(replayedTodos[i].DeletedAt.Valid, ShouldEqual, todos[i].DeletedAt.Valid)
+					So(replayedTodos[i].UpdatedAt.Equal(todos[i].UpdatedAt), ShouldBeTrue)
+				}
+
+				So(replayedTransitions, ShouldHaveLength, len(transitions))
+				for i := range transitions {
+					So(replayedTransitions[i].To, ShouldEqual, transitions[i].To)
+				}
+
+				So(replayedEvents, ShouldHaveLength, len(events))
+				for i := range events {
+					So(replayedEvents[i].ID, ShouldEqual, events[i].ID)
+					So(replayedEvents[i].Action, ShouldEqual, events[i].Action)
+					So(replayedEvents[i].Actor, ShouldEqual, "alice")
+					So(replayedEvents[i].Changes, ShouldResemble, events[i].Changes)
+				}
+			})
+		})
+	})
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	Convey("Given a local blob store", t, func() {
+		blobs, err := NewLocalBlobStore(t.TempDir())
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+
+		Convey("When putting a blob", func() {
+			So(blobs.Put(ctx, "todos/1/abc", strings.NewReader("hello, world")), ShouldBeNil)
+
+			Convey("Then it can be read back from any offset", func() {
+				r, err := blobs.Open(ctx, "todos/1/abc")
+				So(err, ShouldBeNil)
+				defer r.Close()
+
+				_, err = r.Seek(7, io.SeekStart)
+				So(err, ShouldBeNil)
+				b, err := io.ReadAll(r)
+				So(err, ShouldBeNil)
+				So(string(b), ShouldEqual, "world")
+			})
+
+			Convey("And it can be deleted twice", func() {
+				So(blobs.Delete(ctx, "todos/1/abc"), ShouldBeNil)
+				So(blobs.Delete(ctx, "todos/1/abc"), ShouldBeNil)
+				_, err := blobs.Open(ctx, "todos/1/abc")
+				So(err, ShouldWrap, fs.ErrNotExist)
+			})
+		})
+
+		Convey("When using a key escaping the directory", func() {
+			So(blobs.Put(ctx, "../secret", strings.NewReader("x")), ShouldNotBeNil)
+			So(blobs.Put(ctx, "/etc/passwd", strings.NewReader("x")), ShouldNotBeNil)
+		})
+	})
+}
@@ -12,6 +12,7 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	storage "github.com/wei840222/go-restful-sample/storage"
 	gomock "go.uber.org/mock/gomock"
@@ -69,6 +70,20 @@ func (mr *MockTodoStorageMockRecorder) Delete(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockTodoStorage)(nil).Delete), ctx, id)
 }
 
+// Each mocks base method.
+func (m *MockTodoStorage) Each(ctx context.Context, filter storage.TodoFilter, fn func(storage.Todo) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Each", ctx, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Each indicates an expected call of Each.
+func (mr *MockTodoStorageMockRecorder) Each(ctx, filter, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Each", reflect.TypeOf((*MockTodoStorage)(nil).Each), ctx, filter, fn)
+}
+
 // Get mocks base method.
 func (m *MockTodoStorage) Get(ctx context.Context, id int) (storage.Todo, error) {
 	m.ctrl.T.Helper()
@@ -99,6 +114,138 @@ func (mr *MockTodoStorageMockRecorder) List(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockTodoStorage)(nil).List), ctx)
 }
 
+// ListHistory mocks base method.
+func (m *MockTodoStorage) ListHistory(ctx context.Context, id int) ([]storage.TodoEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListHistory", ctx, id)
+	ret0, _ := ret[0].([]storage.TodoEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListHistory indicates an expected call of ListHistory.
+func (mr *MockTodoStorageMockRecorder) ListHistory(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListHistory", reflect.TypeOf((*MockTodoStorage)(nil).ListHistory), ctx, id)
+}
+
+// ListOccurrences mocks base method.
+func (m *MockTodoStorage) ListOccurrences(ctx context.Context, id, n int) ([]time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOccurrences", ctx, id, n)
+	ret0, _ := ret[0].([]time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOccurrences indicates an expected call of ListOccurrences.
+func (mr *MockTodoStorageMockRecorder) ListOccurrences(ctx, id, n any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOccurrences", reflect.TypeOf((*MockTodoStorage)(nil).ListOccurrences), ctx, id, n)
+}
+
+// ListTransitions mocks base method.
+func (m *MockTodoStorage) ListTransitions(ctx context.Context, id int) ([]storage.TodoTransition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransitions", ctx, id)
+	ret0, _ := ret[0].([]storage.TodoTransition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransitions indicates an expected call of ListTransitions.
+func (mr *MockTodoStorageMockRecorder) ListTransitions(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransitions", reflect.TypeOf((*MockTodoStorage)(nil).ListTransitions), ctx, id)
+}
+
+// Move mocks base method.
+func (m *MockTodoStorage) Move(ctx context.Context, id int, after, before *int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Move", ctx, id, after, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Move indicates an expected call of Move.
+func (mr *MockTodoStorageMockRecorder) Move(ctx, id, after, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Move", reflect.TypeOf((*MockTodoStorage)(nil).Move), ctx, id, after, before)
+}
+
+// MoveToProject mocks base method.
+func (m *MockTodoStorage) MoveToProject(ctx context.Context, id int, projectID *uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveToProject", ctx, id, projectID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MoveToProject indicates an expected call of MoveToProject.
+func (mr *MockTodoStorageMockRecorder) MoveToProject(ctx, id, projectID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveToProject", reflect.TypeOf((*MockTodoStorage)(nil).MoveToProject), ctx, id, projectID)
+}
+
+// Purge mocks base method.
+func (m *MockTodoStorage) Purge(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockTodoStorageMockRecorder) Purge(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockTodoStorage)(nil).Purge), ctx, id)
+}
+
+// Replace mocks base method.
+func (m *MockTodoStorage) Replace(ctx context.Context, id int, todo storage.Todo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", ctx, id, todo)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockTodoStorageMockRecorder) Replace(ctx, id, todo any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockTodoStorage)(nil).Replace), ctx, id, todo)
+}
+
+// Search mocks base method.
+func (m *MockTodoStorage) Search(ctx context.Context, filter storage.TodoFilter, offset, limit int) ([]storage.Todo, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, filter, offset, limit)
+	ret0, _ := ret[0].([]storage.Todo)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockTodoStorageMockRecorder) Search(ctx, filter, offset, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockTodoStorage)(nil).Search), ctx, filter, offset, limit)
+}
+
+// Transition mocks base method.
+func (m *MockTodoStorage) Transition(ctx context.Context, id int, status string) (storage.TodoTransition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transition", ctx, id, status)
+	ret0, _ := ret[0].(storage.TodoTransition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Transition indicates an expected call of Transition.
+func (mr *MockTodoStorageMockRecorder) Transition(ctx, id, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transition", reflect.TypeOf((*MockTodoStorage)(nil).Transition), ctx, id, status)
+}
+
 // Update mocks base method.
 func (m *MockTodoStorage) Update(ctx context.Context, id int, todo storage.Todo) error {
 	m.ctrl.T.Helper()
@@ -112,3 +259,32 @@ func (mr *MockTodoStorageMockRecorder) Update(ctx, id, todo any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockTodoStorage)(nil).Update), ctx, id, todo)
 }
+
+// UpdateSeries mocks base method.
+func (m *MockTodoStorage) UpdateSeries(ctx context.Context, id int, todo storage.Todo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSeries", ctx, id, todo)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSeries indicates an expected call of UpdateSeries.
+func (mr *MockTodoStorageMockRecorder) UpdateSeries(ctx, id, todo any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSeries", reflect.TypeOf((*MockTodoStorage)(nil).UpdateSeries), ctx, id, todo)
+}
+
+// Upsert mocks base method.
+func (m *MockTodoStorage) Upsert(ctx context.Context, todo *storage.Todo) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upsert", ctx, todo)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Upsert indicates an expected call of Upsert.
+func (mr *MockTodoStorageMockRecorder) Upsert(ctx, todo any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockTodoStorage)(nil).Upsert), ctx, todo)
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxMessage is a domain event written in the same transaction as the change
+// it describes and delivered afterwards by the outbox relay. Messages sharing a
+// Key are delivered in order.
+type OutboxMessage struct {
+	ID             uint `gorm:"primarykey"`
+	CreatedAt      time.Time
+	Topic          string
+	Key            string `gorm:"index"`
+	Payload        string
+	Attempts       int
+	NextAttemptAt  time.Time `gorm:"index"`
+	LastError      string
+	DeliveredAt    *time.Time `gorm:"index"`
+	DeadLetteredAt *time.Time `gorm:"index"`
+}
+
+// TodoChanged is the payload of the domain events published when a todo is
+// created, updated, deleted or purged. Todo holds the current state of the todo
+// and is omitted once it is gone.
+type TodoChanged struct {
+	Type       string         `json:"type"`
+	EventID    uint           `json:"eventId"`
+	TodoID     uint           `json:"todoId"`
+	Actor      string         `json:"actor"`
+	RequestID  string         `json:"requestId"`
+	OccurredAt time.Time      `json:"occurredAt"`
+	Changes    []FieldChange  `json:"changes"`
+	Todo       map[string]any `json:"todo,omitempty"`
+}
+
+// TodoTopic returns the topic of the domain events for the given audit action,
+// such as "todo.updated".
+func TodoTopic(action string) string {
+	return "todo." + action
+}
+
+// enqueueTodoChanged queues the domain event for an audit event inside tx.
+func enqueueTodoChanged(tx *gorm.DB, event TodoEvent) error {
+	payload := TodoChanged{
+		Type:       TodoTopic(event.Action),
+		EventID:    event.ID,
+		TodoID:     event.TodoID,
+		Actor:      event.Actor,
+		RequestID:  event.RequestID,
+		OccurredAt: event.CreatedAt,
+		Changes:    event.Changes,
+	}
+	if event.Action != TodoEventDeleted && event.Action != TodoEventPurged {
+		var todo Todo
+		if err := tx.First(&todo, event.TodoID).Error; err != nil {
+			return err
+		}
+		payload.Todo = map[string]any{"id": todo.ID}
+		for _, field := range auditedFields(&todo) {
+			payload.Todo[field.name] = field.value
+		}
+	}
+	return enqueueOutbox(tx, payload.Type, "todo:"+strconv.FormatUint(uint64(event.TodoID), 10), payload)
+}
+
+func enqueueOutbox(tx *gorm.DB, topic, key string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&OutboxMessage{
+		Topic:         topic,
+		Key:           key,
+		Payload:       string(b),
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+//go:generate mockgen -destination=mock/outbox.go -package=mock . OutboxStorage
+type OutboxStorage interface {
+	ListDue(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error)
+	MarkDelivered(ctx context.Context, id uint) error
+	MarkFailed(ctx context.Context, id uint, cause error, retryAt *time.Time) error
+}
+
+type outboxStorage struct {
+	db *gorm.DB
+}
+
+func NewOutboxStorage(lc fx.Lifecycle, db *gorm.DB) OutboxStorage {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return db.AutoMigrate(&OutboxMessage{})
+		},
+	})
+	return &outboxStorage{db: db}
+}
+
+// ListDue returns the messages due for delivery at now, oldest first. Only the
+// oldest pending message of each key is considered, so a message waiting for a
+// retry holds back the later messages of its key.
+func (s *outboxStorage) ListDue(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error) {
+	heads := s.db.Model(&OutboxMessage{}).
+		Select("MIN(id)").
+		Where("delivered_at IS NULL AND dead_lettered_at IS NULL").
+		// Key is a reserved word on some databases, so unlike Group the
+		// clause quotes it.
+		Clauses(clause.GroupBy{Columns: []clause.Column{{Name: "key"}}})
+
+	var messages []OutboxMessage
+	if err := s.db.WithContext(ctx).
+		Where("id IN (?)", heads).
+		Where("next_attempt_at <= ?", now).
+		Order("id").
+		Limit(limit).
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *outboxStorage) MarkDelivered(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Model(&OutboxMessage{}).Where("id = ?", id).Updates(map[string]any{
+		"attempts":     gorm.Expr("attempts + 1"),
+		"delivered_at": time.Now(),
+		"last_error":   "",
+	}).Error
+}
+
+// MarkFailed records a failed delivery attempt. The message is retried at
+// retryAt, or dead-lettered when retryAt is nil.
+func (s *outboxStorage) MarkFailed(ctx context.Context, id uint, cause error, retryAt *time.Time) error {
+	updates := map[string]any{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": cause.Error(),
+	}
+	if retryAt != nil {
+		updates["next_attempt_at"] = *retryAt
+	} else {
+		updates["dead_lettered_at"] = time.Now()
+	}
+	return s.db.WithContext(ctx).Model(&OutboxMessage{}).Where("id = ?", id).Updates(updates).Error
+}
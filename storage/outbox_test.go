@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx/fxtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestOutboxStorage_ListDue(t *testing.T) {
+	Convey("Given an outbox with messages for two keys", t, func() {
+		db, err := gorm.Open(sqlite.Open("file:outbox?mode=memory&cache=shared"), &gorm.Config{})
+		So(err, ShouldBeNil)
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+
+		lc := fxtest.NewLifecycle(t)
+		s := NewOutboxStorage(lc, db)
+		lc.RequireStart()
+		defer lc.RequireStop()
+
+		for _, key := range []string{"todo:1", "todo:2", "todo:1"} {
+			So(enqueueOutbox(db, "todo.updated", key, map[string]string{"key": key}), ShouldBeNil)
+		}
+		ctx := context.Background()
+		now := time.Now().Add(time.Second)
+
+		Convey("When listing the due messages", func() {
+			messages, err := s.ListDue(ctx, now, 10)
+
+			Convey("Then only the oldest message of each key should be due", func() {
+				So(err, ShouldBeNil)
+				So(messages, ShouldHaveLength, 2)
+				So(messages[0].ID, ShouldEqual, 1)
+				So(messages[1].ID, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When the oldest message of a key is delivered", func() {
+			So(s.MarkDelivered(ctx, 1), ShouldBeNil)
+			messages, err := s.ListDue(ctx, now, 10)
+
+			Convey("Then the next message of the key should be due", func() {
+				So(err, ShouldBeNil)
+				So(messages, ShouldHaveLength, 2)
+				So(messages[1].ID, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When the oldest message of a key waits for a retry", func() {
+			So(s.MarkFailed(ctx, 1, errors.New("unavailable"), ptr(now.Add(time.Minute))), ShouldBeNil)
+			messages, err := s.ListDue(ctx, now, 10)
+
+			Convey("Then the key should be held back", func() {
+				So(err, ShouldBeNil)
+				So(messages, ShouldHaveLength, 1)
+				So(messages[0].ID, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When the oldest message of a key is dead-lettered", func() {
+			So(s.MarkFailed(ctx, 1, errors.New("unavailable"), nil), ShouldBeNil)
+			messages, err := s.ListDue(ctx, now, 10)
+
+			Convey("Then the next message of the key should be due", func() {
+				So(err, ShouldBeNil)
+				So(messages, ShouldHaveLength, 2)
+				So(messages[1].ID, ShouldEqual, 3)
+
+				var dead OutboxMessage
+				So(db.First(&dead, 1).Error, ShouldBeNil)
+				So(dead.DeadLetteredAt, ShouldNotBeNil)
+				So(dead.LastError, ShouldEqual, "unavailable")
+				So(dead.Attempts, ShouldEqual, 1)
+			})
+		})
+	})
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+type Project struct {
+	gorm.Model
+	Name     string
+	Color    string
+	Archived *bool `gorm:"default:false"`
+	Position int
+}
+
+//go:generate mockgen -destination=mock/project.go -package=mock . ProjectStorage
+type ProjectStorage interface {
+	Get(ctx context.Context, id int) (Project, error)
+	List(ctx context.Context) ([]Project, error)
+	ListByIDs(ctx context.Context, ids []uint) ([]Project, error)
+	ListTodos(ctx context.Context, id int) ([]Todo, error)
+	Create(ctx context.Context, project *Project) error
+	Update(ctx context.Context, id int, project Project) error
+	Delete(ctx context.Context, id int, cascade bool) error
+}
+
+type projectStorage struct {
+	db    *gorm.DB
+	todos TodoStorage
+}
+
+// NewProjectStorage changes the todos of deleted projects through todos, so
+// that the event sourced storage logs them.
+func NewProjectStorage(lc fx.Lifecycle, db *gorm.DB, todos TodoStorage) ProjectStorage {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return db.AutoMigrate(&Project{})
+		},
+	})
+	return &projectStorage{db: db, todos: todos}
+}
+
+func (s *projectStorage) Create(ctx context.Context, project *Project) error {
+	return s.db.WithContext(ctx).Create(project).Error
+}
+
+func (s *projectStorage) List(ctx context.Context) ([]Project, error) {
+	var projects []Project
+	if err := s.db.WithContext(ctx).Order("position").Order("id").Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// ListByIDs returns the projects with the given IDs, skipping unknown ones.
+func (s *projectStorage) ListByIDs(ctx context.Context, ids []uint) ([]Project, error) {
+	var projects []Project
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (s *projectStorage) Get(ctx context.Context, id int) (Project, error) {
+	var project Project
+	if err := s.db.WithContext(ctx).First(&project, id).Error; err != nil {
+		return project, err
+	}
+	return project, nil
+}
+
+// ListTodos returns the todos of a project, including those of an archived
+// project which are hidden from the default todo listing.
+func (s *projectStorage) ListTodos(ctx context.Context, id int) ([]Todo, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	var todos []Todo
+	if err := s.db.WithContext(ctx).Where("project_id = ?", id).Order("rank").Order("id").Find(&todos).Error; err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (s *projectStorage) Update(ctx context.Context, id int, project Project) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Model(&Project{}).Where("id = ?", id).Updates(project).Error
+}
+
+// Delete removes a project. Its todos are deleted along with it when cascade is
+// set, otherwise they are moved back to the inbox.
+func (s *projectStorage) Delete(ctx context.Context, id int, cascade bool) error {
+	return NewTxManager(s.db).WithinTx(ctx, func(ctx context.Context) error {
+		tx := dbFrom(ctx, s.db)
+		if err := tx.First(&Project{}, id).Error; err != nil {
+			return err
+		}
+
+		var todoIDs []int
+		if err := tx.Model(&Todo{}).Where("project_id = ?", id).Order("id").Pluck("id", &todoIDs).Error; err != nil {
+			return err
+		}
+
+		for _, todoID := range todoIDs {
+			var err error
+			if cascade {
+				err = s.todos.Delete(ctx, todoID)
+			} else {
+				err = s.todos.MoveToProject(ctx, todoID, nil)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&Project{}, id).Error
+	})
+}
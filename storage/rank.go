@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Ranks are base-36 fractional keys compared lexicographically. A key never
+// ends with the smallest digit, so there is always room for another key
+// before it and moving a todo only has to rewrite that single row.
+const (
+	rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+	rankBase   = len(rankDigits)
+
+	// MaxRankLength is the length above which ranks are rebalanced.
+	MaxRankLength = 16
+)
+
+// RankBetween returns a rank strictly between a and b. An empty a means the
+// start of the list and an empty b means the end of the list.
+func RankBetween(a, b string) (string, error) {
+	if err := validateRank(a); err != nil {
+		return "", err
+	}
+	if err := validateRank(b); err != nil {
+		return "", err
+	}
+	if a != "" && b != "" && a >= b {
+		return "", fmt.Errorf("%w: %q is not before %q", ErrInvalidMove, a, b)
+	}
+	if a != "" && b == "" {
+		return rankAfter(a), nil
+	}
+	return rankMidpoint(a, b), nil
+}
+
+// rankAfter returns the shortest rank greater than a by incrementing its first
+// digit that can still be incremented.
+func rankAfter(a string) string {
+	for i := 0; i < len(a); i++ {
+		if d := strings.IndexByte(rankDigits, a[i]); d < rankBase-1 {
+			return a[:i] + string(rankDigits[d+1])
+		}
+	}
+	return a + string(rankDigits[rankBase/2])
+}
+
+// rankMidpoint returns a rank between a and b, where b may be empty to mean
+// the end of the list.
+func rankMidpoint(a, b string) string {
+	n := 0
+	for n < len(b) && rankDigit(a, n) == b[n] {
+		n++
+	}
+	if n > 0 {
+		rest := ""
+		if n < len(a) {
+			rest = a[n:]
+		}
+		return b[:n] + rankMidpoint(rest, b[n:])
+	}
+
+	da := 0
+	if a != "" {
+		da = strings.IndexByte(rankDigits, a[0])
+	}
+	db := rankBase
+	if b != "" {
+		db = strings.IndexByte(rankDigits, b[0])
+	}
+	if db-da > 1 {
+		return string(rankDigits[(da+db)/2])
+	}
+	if len(b) > 1 {
+		return b[:1]
+	}
+
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(rankDigits[da]) + rankMidpoint(rest, "")
+}
+
+func rankDigit(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return rankDigits[0]
+}
+
+func validateRank(rank string) error {
+	for i := 0; i < len(rank); i++ {
+		if strings.IndexByte(rankDigits, rank[i]) < 0 {
+			return fmt.Errorf("%w: invalid rank %q", ErrInvalidMove, rank)
+		}
+	}
+	if strings.HasSuffix(rank, rankDigits[:1]) {
+		return fmt.Errorf("%w: invalid rank %q", ErrInvalidMove, rank)
+	}
+	return nil
+}
+
+// EvenRanks returns n increasing ranks spread evenly over the key space using
+// the shortest width that fits them.
+func EvenRanks(n int) []string {
+	width := 1
+	space := big.NewInt(int64(rankBase))
+	count := big.NewInt(int64(n + 1))
+	for space.Cmp(count) <= 0 {
+		width++
+		space.Mul(space, big.NewInt(int64(rankBase)))
+	}
+
+	ranks := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		v := new(big.Int).Mul(space, big.NewInt(int64(i)))
+		v.Div(v, count)
+
+		digits := []byte(v.Text(rankBase))
+		rank := strings.Repeat(rankDigits[:1], width-len(digits)) + string(digits)
+		ranks = append(ranks, strings.TrimRight(rank, rankDigits[:1]))
+	}
+	return ranks
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRankBetween(t *testing.T) {
+	Convey("Given an empty list", t, func() {
+		rank, err := RankBetween("", "")
+
+		Convey("Then the first rank should be in the middle of the key space", func() {
+			So(err, ShouldBeNil)
+			So(rank, ShouldEqual, "i")
+		})
+	})
+
+	Convey("Given two adjacent ranks", t, func() {
+		rank, err := RankBetween("i", "j")
+
+		Convey("Then it should return a longer rank between them", func() {
+			So(err, ShouldBeNil)
+			So(rank, ShouldBeGreaterThan, "i")
+			So(rank, ShouldBeLessThan, "j")
+		})
+	})
+
+	Convey("Given a rank and the start of the list", t, func() {
+		rank, err := RankBetween("", "1")
+
+		Convey("Then it should return a rank before it", func() {
+			So(err, ShouldBeNil)
+			So(rank, ShouldBeLessThan, "1")
+			So(rank, ShouldNotEndWith, "0")
+		})
+	})
+
+	Convey("Given the last rank of the list", t, func() {
+		Convey("Then appending should increment the first digit that can grow", func() {
+			So(rankAfter("iab"), ShouldEqual, "j")
+			So(rankAfter("z"), ShouldEqual, "zi")
+		})
+	})
+
+	Convey("Given ranks in the wrong order", t, func() {
+		_, err := RankBetween("j", "i")
+
+		Convey("Then it should return ErrInvalidMove", func() {
+			So(IsInvalidMove(err), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given repeated inserts at the same position", t, func() {
+		a, b := "i", "j"
+		var err error
+		for i := 0; i < 100; i++ {
+			b, err = RankBetween(a, b)
+			So(err, ShouldBeNil)
+			So(b, ShouldBeGreaterThan, a)
+		}
+
+		Convey("Then ranks should grow long enough to need rebalancing", func() {
+			So(len(b), ShouldBeGreaterThan, MaxRankLength)
+		})
+	})
+}
+
+func TestEvenRanks(t *testing.T) {
+	Convey("Given a list to rebalance", t, func() {
+		ranks := EvenRanks(1000)
+
+		Convey("Then ranks should be short, increasing and valid", func() {
+			So(len(ranks), ShouldEqual, 1000)
+			So(sort.StringsAreSorted(ranks), ShouldBeTrue)
+			for i, rank := range ranks {
+				So(len(rank), ShouldBeLessThanOrEqualTo, 3)
+				So(validateRank(rank), ShouldBeNil)
+				if i > 0 {
+					So(rank, ShouldNotEqual, ranks[i-1])
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// maxRRuleIterations bounds the occurrences NextOccurrences goes through,
+// from the start of the series, before giving up on a rule.
+const maxRRuleIterations = 100000
+
+// ParseRRule parses an iCalendar RRULE value such as "FREQ=WEEKLY;BYDAY=MO",
+// with or without the "RRULE:" prefix. The rule is anchored at dtstart, which
+// for todos is the due date of the first occurrence of the series. Rules
+// recurring more often than daily are rejected, since every occurrence since
+// dtstart has to be gone through to find the next ones.
+func ParseRRule(rule string, dtstart time.Time) (*rrule.RRule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if strings.Contains(rule, "DTSTART") || strings.Contains(rule, "\n") {
+		return nil, fmt.Errorf("%w: DTSTART is taken from the due date", ErrInvalidRRule)
+	}
+
+	opt, err := rrule.StrToROption(rule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRRule, err)
+	}
+	if opt.Freq > rrule.DAILY {
+		return nil, fmt.Errorf("%w: FREQ=%s is more frequent than daily", ErrInvalidRRule, opt.Freq)
+	}
+	opt.Dtstart = dtstart
+
+	r, err := rrule.NewRRule(*opt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRRule, err)
+	}
+	return r, nil
+}
+
+// ValidateRRule checks that a recurring todo has a parsable rule and a due
+// date to anchor it.
+func ValidateRRule(rule string, dueAt *time.Time) error {
+	if rule == "" {
+		return nil
+	}
+	if dueAt == nil {
+		return fmt.Errorf("%w: a due date is required", ErrInvalidRRule)
+	}
+	_, err := ParseRRule(rule, *dueAt)
+	return err
+}
+
+// NextOccurrences returns at most n occurrences of rule strictly after the
+// given time. It fails with ErrInvalidRRule for a rule with more than
+// maxRRuleIterations occurrences to go through.
+func NextOccurrences(rule string, dtstart, after time.Time, n int) ([]time.Time, error) {
+	r, err := ParseRRule(rule, dtstart)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := make([]time.Time, 0, n)
+	next := r.Iterator()
+	for i := 0; len(occurrences) < n; i++ {
+		if i == maxRRuleIterations {
+			return nil, fmt.Errorf("%w: more than %d occurrences to go through", ErrInvalidRRule, maxRRuleIterations)
+		}
+		t, ok := next()
+		if !ok {
+			break
+		}
+		if t.After(after) {
+			occurrences = append(occurrences, t)
+		}
+	}
+	return occurrences, nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNextOccurrences(t *testing.T) {
+	Convey("Given a weekly rule starting on a Monday", t, func() {
+		dtstart := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+		rule := "FREQ=WEEKLY;BYDAY=MO"
+
+		Convey("When listing the next occurrences after the first one", func() {
+			occurrences, err := NextOccurrences(rule, dtstart, dtstart, 3)
+
+			Convey("Then it should return the following Mondays", func() {
+				So(err, ShouldBeNil)
+				So(len(occurrences), ShouldEqual, 3)
+				So(occurrences[0].Equal(dtstart.AddDate(0, 0, 7)), ShouldBeTrue)
+				So(occurrences[2].Equal(dtstart.AddDate(0, 0, 21)), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the rule is limited by COUNT", func() {
+			occurrences, err := NextOccurrences("RRULE:FREQ=WEEKLY;COUNT=2", dtstart, dtstart, 5)
+
+			Convey("Then it should stop at the end of the series", func() {
+				So(err, ShouldBeNil)
+				So(len(occurrences), ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given an invalid rule", t, func() {
+		_, err := NextOccurrences("FREQ=SOMETIMES", time.Now(), time.Now(), 1)
+
+		Convey("Then it should return ErrInvalidRRule", func() {
+			So(IsInvalidRRule(err), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a rule recurring more often than daily", t, func() {
+		dueAt := time.Now()
+		err := ValidateRRule("FREQ=SECONDLY", &dueAt)
+
+		Convey("Then it should return ErrInvalidRRule", func() {
+			So(IsInvalidRRule(err), ShouldBeTrue)
+			So(IsInvalidRRule(ValidateRRule("FREQ=MINUTELY;INTERVAL=30", &dueAt)), ShouldBeTrue)
+			So(ValidateRRule("FREQ=DAILY", &dueAt), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a daily rule expanded to every second of the day", t, func() {
+		dtstart := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		rule := "FREQ=DAILY;BYHOUR=0,1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20,21,22,23;BYMINUTE=0,15,30,45;BYSECOND=0,30"
+
+		Convey("When the next occurrence is far from the start of the series", func() {
+			_, err := NextOccurrences(rule, dtstart, dtstart.AddDate(2, 0, 0), 1)
+
+			Convey("Then it should give up with ErrInvalidRRule", func() {
+				So(IsInvalidRRule(err), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given a recurring todo without a due date", t, func() {
+		err := ValidateRRule("FREQ=DAILY", nil)
+
+		Convey("Then it should return ErrInvalidRRule", func() {
+			So(IsInvalidRRule(err), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config configures a BlobStore backed by an S3 compatible object storage
+// such as AWS S3 or MinIO. Objects are addressed path-style. Without an access
+// key, credentials are looked up in the environment, the shared credentials
+// file and the instance metadata, and refreshed as they expire. Without a
+// region, the region of the bucket is looked up.
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+}
+
+type s3BlobStore struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3BlobStore returns a BlobStore talking to an S3 compatible API.
+func NewS3BlobStore(config S3Config) (BlobStore, error) {
+	return newS3BlobStore(config, nil)
+}
+
+// newS3BlobStore returns a BlobStore sending its requests through transport,
+// or the default transport when nil.
+func newS3BlobStore(config S3Config, transport http.RoundTripper) (BlobStore, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", config.Endpoint)
+	}
+	if endpoint.Path != "" && endpoint.Path != "/" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q: it cannot have a path", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	})
+	if config.AccessKey != "" {
+		creds = credentials.NewStaticV4(config.AccessKey, config.SecretKey, "")
+	}
+
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        creds,
+		Secure:       endpoint.Scheme == "https",
+		Region:       config.Region,
+		BucketLookup: minio.BucketLookupPath,
+		Transport:    transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3BlobStore{client: client, bucket: config.Bucket}, nil
+}
+
+// Put spools the blob to a temporary file so that its size is known before
+// the upload starts: small blobs are uploaded at once and larger ones in
+// parts read concurrently from the file.
+func (s *s3BlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	if err := validateBlobKey(key); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "s3-upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err := s.client.PutObject(ctx, s.bucket, key, f, size, minio.PutObjectOptions{}); err != nil {
+		return fmt.Errorf("s3 put %q: %w", key, err)
+	}
+	return nil
+}
+
+// Open checks that the object exists and returns it. It is read lazily with
+// ranged requests starting at the current offset.
+func (s *s3BlobStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if err := validateBlobKey(key); err != nil {
+		return nil, err
+	}
+
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("s3 get %q: %w", key, err)
+	}
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("s3 object %q: %w", key, fs.ErrNotExist)
+		}
+		return nil, fmt.Errorf("s3 get %q: %w", key, err)
+	}
+	return obj, nil
+}
+
+func (s *s3BlobStore) Delete(ctx context.Context, key string) error {
+	if err := validateBlobKey(key); err != nil {
+		return err
+	}
+
+	err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+	if err != nil && minio.ToErrorResponse(err).StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3 delete %q: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3 compatible server.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		b, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = b
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(b)))
+	case http.MethodGet, http.MethodHead:
+		b, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(b)))
+		http.ServeContent(w, r, "", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), bytes.NewReader(b))
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3BlobStore(t *testing.T) {
+	Convey("Given an S3 blob store backed by a local stand-in", t, func() {
+		fake := &fakeS3{objects: make(map[string][]byte)}
+		srv := httptest.NewTLSServer(fake)
+		defer srv.Close()
+
+		blobs, err := newS3BlobStore(S3Config{
+			Endpoint:  srv.URL,
+			Region:    "us-east-1",
+			Bucket:    "attachments",
+			AccessKey: "access",
+			SecretKey: "secret",
+		}, srv.Client().Transport)
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+
+		Convey("When putting a blob", func() {
+			So(blobs.Put(ctx, "todos/1/abc", strings.NewReader("hello, world")), ShouldBeNil)
+
+			Convey("Then it should be stored path-style in the bucket", func() {
+				So(string(fake.objects["/attachments/todos/1/abc"]), ShouldEqual, "hello, world")
+			})
+
+			Convey("And it can be read back from any offset", func() {
+				r, err := blobs.Open(ctx, "todos/1/abc")
+				So(err, ShouldBeNil)
+				defer r.Close()
+
+				_, err = r.Seek(7, io.SeekStart)
+				So(err, ShouldBeNil)
+				b, err := io.ReadAll(r)
+				So(err, ShouldBeNil)
+				So(string(b), ShouldEqual, "world")
+
+				size, err := r.Seek(0, io.SeekEnd)
+				So(err, ShouldBeNil)
+				So(size, ShouldEqual, 12)
+			})
+
+			Convey("And it can be deleted", func() {
+				So(blobs.Delete(ctx, "todos/1/abc"), ShouldBeNil)
+				_, err := blobs.Open(ctx, "todos/1/abc")
+				So(err, ShouldWrap, fs.ErrNotExist)
+			})
+		})
+
+		Convey("When using a key escaping the bucket", func() {
+			So(blobs.Put(ctx, "../secret", strings.NewReader("x")), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestNewS3BlobStore(t *testing.T) {
+	Convey("Given an endpoint with a path", t, func() {
+		_, err := NewS3BlobStore(S3Config{Endpoint: "https://s3.example.com/prefix", Bucket: "attachments"})
+
+		Convey("Then it should be rejected since objects are addressed from the root", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given no bucket", t, func() {
+		_, err := NewS3BlobStore(S3Config{Endpoint: "https://s3.example.com"})
+
+		Convey("Then it should be rejected", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TodoVersion identifies the state of a todo by the last event of its audit
+// trail. Version is 0 for todos without any recorded event.
+type TodoVersion struct {
+	TodoID     uint
+	Version    uint
+	ModifiedAt time.Time
+}
+
+// TodoChange is the net change of a todo since a point of the audit trail. Todo
+// is loaded including soft deleted todos and is empty for purged ones.
+type TodoChange struct {
+	TodoVersion
+	Todo    Todo
+	Created bool
+	Deleted bool
+}
+
+//go:generate mockgen -destination=mock/sync.go -package=mock . SyncStorage
+type SyncStorage interface {
+	ListChanges(ctx context.Context, since, after uint, limit int) ([]TodoChange, error)
+	GetVersion(ctx context.Context, id int) (TodoVersion, error)
+	CurrentVersion(ctx context.Context) (uint, error)
+}
+
+type syncStorage struct {
+	db *gorm.DB
+}
+
+// NewSyncStorage reads the todos and the audit trail migrated by the todo
+// storage.
+func NewSyncStorage(db *gorm.DB) SyncStorage {
+	return &syncStorage{db: db}
+}
+
+// ListChanges returns the todos changed by the events after after, ordered by
+// their version so that the version of the last change can resume the listing.
+// A todo is created when its first event is after since, the version the
+// listing started from, so that it does not depend on the page it comes in.
+func (s *syncStorage) ListChanges(ctx context.Context, since, after uint, limit int) ([]TodoChange, error) {
+	var rows []struct {
+		TodoID  uint
+		Version uint
+	}
+	if err := s.db.WithContext(ctx).Model(&TodoEvent{}).
+		Select("todo_id, MAX(id) AS version").
+		Where("id > ?", after).
+		Group("todo_id").
+		Order("version").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, 0, len(rows))
+	versions := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.TodoID)
+		versions = append(versions, row.Version)
+	}
+
+	var todos []Todo
+	if err := s.db.WithContext(ctx).Unscoped().Where("id IN ?", ids).Find(&todos).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]Todo, len(todos))
+	for _, todo := range todos {
+		byID[todo.ID] = todo
+	}
+
+	var firsts []struct {
+		TodoID uint
+		First  uint
+	}
+	if err := s.db.WithContext(ctx).Model(&TodoEvent{}).
+		Select("todo_id, MIN(id) AS first").
+		Where("todo_id IN ?", ids).
+		Group("todo_id").
+		Scan(&firsts).Error; err != nil {
+		return nil, err
+	}
+	created := make(map[uint]bool, len(firsts))
+	for _, first := range firsts {
+		created[first.TodoID] = first.First > since
+	}
+
+	var events []TodoEvent
+	if err := s.db.WithContext(ctx).Select("id, created_at").Where("id IN ?", versions).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	modifiedAt := make(map[uint]time.Time, len(events))
+	for _, event := range events {
+		modifiedAt[event.ID] = event.CreatedAt
+	}
+
+	changes := make([]TodoChange, 0, len(rows))
+	for _, row := range rows {
+		todo, ok := byID[row.TodoID]
+		changes = append(changes, TodoChange{
+			TodoVersion: TodoVersion{
+				TodoID:     row.TodoID,
+				Version:    row.Version,
+				ModifiedAt: modifiedAt[row.Version],
+			},
+			Todo:    todo,
+			Created: created[row.TodoID],
+			Deleted: !ok || todo.DeletedAt.Valid,
+		})
+	}
+	return changes, nil
+}
+
+// GetVersion returns the current version of a todo that is not deleted.
+func (s *syncStorage) GetVersion(ctx context.Context, id int) (TodoVersion, error) {
+	var todo Todo
+	if err := s.db.WithContext(ctx).First(&todo, id).Error; err != nil {
+		return TodoVersion{}, err
+	}
+
+	version := TodoVersion{TodoID: todo.ID, ModifiedAt: todo.UpdatedAt}
+	var event TodoEvent
+	err := s.db.WithContext(ctx).Where("todo_id = ?", id).Order("id DESC").Take(&event).Error
+	if err == nil {
+		version.Version = event.ID
+		version.ModifiedAt = event.CreatedAt
+	} else if !IsNotFound(err) {
+		return TodoVersion{}, err
+	}
+	return version, nil
+}
+
+// CurrentVersion returns the version of the last event of the audit trail, or
+// 0 before any.
+func (s *syncStorage) CurrentVersion(ctx context.Context) (uint, error) {
+	var version uint
+	if err := s.db.WithContext(ctx).Model(&TodoEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&version).Error; err != nil {
+		return 0, err
+	}
+	return version, nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx/fxtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSyncStorage_ListChanges(t *testing.T) {
+	Convey("Given todos changed after a sync", t, func() {
+		db, err := gorm.Open(sqlite.Open("file:sync?mode=memory&cache=shared"), &gorm.Config{})
+		So(err, ShouldBeNil)
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+		So(db.AutoMigrate(&Comment{}, &CommentRevision{}, &CommentMention{}, &Attachment{}), ShouldBeNil)
+
+		wf := &Workflow{
+			Initial:     "todo",
+			Completed:   "done",
+			States:      []string{"todo", "done"},
+			Terminal:    []string{"done"},
+			Transitions: map[string][]string{"todo": {"done"}, "done": {"todo"}},
+		}
+		lc := fxtest.NewLifecycle(t)
+		todos := NewTodoStorage(lc, db, wf, nil)
+		s := NewSyncStorage(db)
+		lc.RequireStart()
+		defer lc.RequireStop()
+
+		ctx := context.Background()
+		kept, deleted := Todo{Title: "kept"}, Todo{Title: "deleted"}
+		So(todos.Create(ctx, &kept), ShouldBeNil)
+		So(todos.Create(ctx, &deleted), ShouldBeNil)
+		synced, err := s.GetVersion(ctx, int(deleted.ID))
+		So(err, ShouldBeNil)
+
+		So(todos.Update(ctx, int(kept.ID), Todo{Title: "renamed"}), ShouldBeNil)
+		So(todos.Delete(ctx, int(deleted.ID)), ShouldBeNil)
+		added := Todo{Title: "added"}
+		So(todos.Create(ctx, &added), ShouldBeNil)
+
+		Convey("When listing the changes since the sync", func() {
+			changes, err := s.ListChanges(ctx, synced.Version, synced.Version, 10)
+
+			Convey("Then every changed todo should be listed once in version order", func() {
+				So(err, ShouldBeNil)
+				So(changes, ShouldHaveLength, 3)
+
+				So(changes[0].TodoID, ShouldEqual, kept.ID)
+				So(changes[0].Todo.Title, ShouldEqual, "renamed")
+				So(changes[0].Created, ShouldBeFalse)
+				So(changes[0].Deleted, ShouldBeFalse)
+
+				So(changes[1].TodoID, ShouldEqual, deleted.ID)
+				So(changes[1].Deleted, ShouldBeTrue)
+				So(changes[1].Todo.DeletedAt.Valid, ShouldBeTrue)
+
+				So(changes[2].TodoID, ShouldEqual, added.ID)
+				So(changes[2].Created, ShouldBeTrue)
+				So(changes[2].Version, ShouldBeGreaterThan, changes[1].Version)
+				So(changes[2].ModifiedAt, ShouldNotBeZeroValue)
+			})
+		})
+
+		Convey("When a todo is purged", func() {
+			So(todos.Purge(ctx, int(deleted.ID)), ShouldBeNil)
+			changes, err := s.ListChanges(ctx, synced.Version, synced.Version, 10)
+
+			Convey("Then it should be listed as deleted without its row", func() {
+				So(err, ShouldBeNil)
+				So(changes[2].TodoID, ShouldEqual, deleted.ID)
+				So(changes[2].Deleted, ShouldBeTrue)
+				So(changes[2].Todo.ID, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When getting the current version", func() {
+			version, err := s.CurrentVersion(ctx)
+			changes, _ := s.ListChanges(ctx, synced.Version, synced.Version, 10)
+
+			Convey("Then it should be the version of the last change", func() {
+				So(err, ShouldBeNil)
+				So(version, ShouldEqual, changes[len(changes)-1].Version)
+			})
+		})
+
+		Convey("When getting the version of a deleted todo", func() {
+			_, err := s.GetVersion(ctx, int(deleted.ID))
+
+			Convey("Then it should not be found", func() {
+				So(IsNotFound(err), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given a todo created and updated around the change of another one", t, func() {
+		db, err := gorm.Open(sqlite.Open("file:sync_pages?mode=memory&cache=shared"), &gorm.Config{})
+		So(err, ShouldBeNil)
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+
+		wf := &Workflow{
+			Initial:     "todo",
+			Completed:   "done",
+			States:      []string{"todo", "done"},
+			Terminal:    []string{"done"},
+			Transitions: map[string][]string{"todo": {"done"}, "done": {"todo"}},
+		}
+		lc := fxtest.NewLifecycle(t)
+		todos := NewTodoStorage(lc, db, wf, nil)
+		s := NewSyncStorage(db)
+		lc.RequireStart()
+		defer lc.RequireStop()
+
+		ctx := context.Background()
+		a, b := Todo{Title: "a"}, Todo{Title: "b"}
+		So(todos.Create(ctx, &a), ShouldBeNil)
+		So(todos.Create(ctx, &b), ShouldBeNil)
+		So(todos.Update(ctx, int(a.ID), Todo{Title: "renamed"}), ShouldBeNil)
+
+		Convey("When listing the changes since the start one at a time", func() {
+			first, err := s.ListChanges(ctx, 0, 0, 1)
+			So(err, ShouldBeNil)
+			So(first, ShouldHaveLength, 1)
+			second, err := s.ListChanges(ctx, 0, first[0].Version, 1)
+			So(err, ShouldBeNil)
+
+			Convey("Then the todo listed on the second page should still be created", func() {
+				So(first[0].TodoID, ShouldEqual, b.ID)
+				So(first[0].Created, ShouldBeTrue)
+				So(second, ShouldHaveLength, 1)
+				So(second[0].TodoID, ShouldEqual, a.ID)
+				So(second[0].Created, ShouldBeTrue)
+			})
+		})
+
+		Convey("When listing the changes since its creation", func() {
+			first, err := s.ListChanges(ctx, 0, 0, 1)
+			So(err, ShouldBeNil)
+			changes, err := s.ListChanges(ctx, first[0].Version, first[0].Version, 10)
+
+			Convey("Then it should be updated", func() {
+				So(err, ShouldBeNil)
+				So(changes, ShouldHaveLength, 1)
+				So(changes[0].TodoID, ShouldEqual, a.ID)
+				So(changes[0].Created, ShouldBeFalse)
+			})
+		})
+	})
+}
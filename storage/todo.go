@@ -2,6 +2,9 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"go.uber.org/fx"
 	"gorm.io/gorm"
@@ -11,61 +14,808 @@ type Todo struct {
 	gorm.Model
 	Title       string
 	Description string
+	Status      string
 	Completed   *bool `gorm:"default:false"`
+	// CompletedAt is when the todo last reached a terminal state, or nil
+	// while it is open.
+	CompletedAt *time.Time
+	DueAt       *time.Time
+	RRule       string
+	SeriesID    *uint  `gorm:"index"`
+	ProjectID   *uint  `gorm:"index"`
+	Rank        string `gorm:"index"`
+	// Priority is a letter from A, the highest, to Z, or empty for none.
+	Priority string
+	Tags     []string `gorm:"serializer:json"`
+	// ExternalSource and ExternalID identify a todo synced from another
+	// system. They are either both set or both nil.
+	ExternalSource *string `gorm:"uniqueIndex:idx_todo_external"`
+	ExternalID     *string `gorm:"uniqueIndex:idx_todo_external"`
+}
+
+// TodoFilter narrows down todos for Search. Zero fields do not filter.
+type TodoFilter struct {
+	Status    string
+	Completed *bool
+	ProjectID *uint
+	Query     string
+	DueAfter  *time.Time
+	DueBefore *time.Time
+	// Tag selects the todos with the tag, such as @phone or +garden.
+	Tag string
+	// ExternalSource and ExternalID select the todo created from a record of
+	// another system.
+	ExternalSource string
+	ExternalID     string
+}
+
+type TodoTransition struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	TodoID    uint `gorm:"index"`
+	From      string
+	To        string
 }
 
 //go:generate mockgen -destination=mock/todo.go -package=mock . TodoStorage
 type TodoStorage interface {
 	Get(ctx context.Context, id int) (Todo, error)
 	List(ctx context.Context) ([]Todo, error)
+	Search(ctx context.Context, filter TodoFilter, offset, limit int) ([]Todo, int64, error)
+	Each(ctx context.Context, filter TodoFilter, fn func(Todo) error) error
 	Create(ctx context.Context, todo *Todo) error
 	Update(ctx context.Context, id int, todo Todo) error
+	Replace(ctx context.Context, id int, todo Todo) error
+	Upsert(ctx context.Context, todo *Todo) (bool, error)
 	Delete(ctx context.Context, id int) error
+	Transition(ctx context.Context, id int, status string) (TodoTransition, error)
+	ListTransitions(ctx context.Context, id int) ([]TodoTransition, error)
+	ListHistory(ctx context.Context, id int) ([]TodoEvent, error)
+	UpdateSeries(ctx context.Context, id int, todo Todo) error
+	ListOccurrences(ctx context.Context, id, n int) ([]time.Time, error)
+	MoveToProject(ctx context.Context, id int, projectID *uint) error
+	Move(ctx context.Context, id int, after, before *int) error
+	Purge(ctx context.Context, id int) error
 }
 
 type todoStorage struct {
-	db *gorm.DB
+	db       *gorm.DB
+	workflow *Workflow
+	blobs    BlobStore
 }
 
-func NewTodoStorage(lc fx.Lifecycle, db *gorm.DB) TodoStorage {
+func NewTodoStorage(lc fx.Lifecycle, db *gorm.DB, wf *Workflow, blobs BlobStore) TodoStorage {
+	s := &todoStorage{db: db, workflow: wf, blobs: blobs}
 	lc.Append(fx.Hook{
 		OnStart: func(context.Context) error {
-			return db.AutoMigrate(&Todo{})
+			if err := db.AutoMigrate(&Todo{}, &TodoTransition{}, &TodoEvent{}, &OutboxMessage{}); err != nil {
+				return err
+			}
+			return backfillTodos(db, wf, s.spreadRanks)
 		},
 	})
-	return &todoStorage{db: db}
+	return s
+}
+
+// backfillTodos completes the todos created before the columns they were
+// migrated with. Todos without a status are given the completed state of the
+// workflow when they are completed and its initial state otherwise. Completed
+// todos without a completion time are taken to be completed when last
+// updated. Todos without a rank, which sort first, have the ranks of all todos
+// spread evenly again in their current order by spreadRanks.
+func backfillTodos(db *gorm.DB, wf *Workflow, spreadRanks func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&Todo{}).
+			Where("(status = '' OR status IS NULL) AND completed = ?", true).
+			UpdateColumn("status", wf.Completed).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&Todo{}).
+			Where("status = '' OR status IS NULL").
+			UpdateColumn("status", wf.Initial).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&Todo{}).
+			Where("completed = ? AND completed_at IS NULL", true).
+			UpdateColumn("completed_at", gorm.Expr("updated_at")).Error; err != nil {
+			return err
+		}
+
+		var unranked int64
+		if err := tx.Model(&Todo{}).Where("rank = '' OR rank IS NULL").Count(&unranked).Error; err != nil {
+			return err
+		}
+		if unranked == 0 {
+			return nil
+		}
+		return spreadRanks(tx)
+	})
 }
 
+// Create inserts todo in the initial workflow state. A recurring todo becomes
+// the first occurrence of a new series.
 func (s *todoStorage) Create(ctx context.Context, todo *Todo) error {
-	return s.db.WithContext(ctx).Create(todo).Error
+	if err := ValidateRRule(todo.RRule, todo.DueAt); err != nil {
+		return err
+	}
+	if todo.DueAt != nil {
+		todo.DueAt = ptr(todo.DueAt.UTC())
+	}
+	todo.Status = s.workflow.Initial
+	todo.Completed = ptr(s.workflow.IsTerminal(todo.Status))
+	todo.CompletedAt = nil
+
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		if todo.ProjectID != nil {
+			if err := tx.First(&Project{}, *todo.ProjectID).Error; err != nil {
+				return err
+			}
+		}
+		rank, err := s.appendRank(tx)
+		if err != nil {
+			return err
+		}
+		todo.Rank = rank
+
+		if err := tx.Create(todo).Error; err != nil {
+			return err
+		}
+		if err := s.rebalanceIfNeeded(tx, todo.Rank); err != nil {
+			return err
+		}
+		if todo.RRule != "" {
+			todo.SeriesID = ptr(todo.ID)
+			if err := tx.Model(todo).Update("series_id", todo.SeriesID).Error; err != nil {
+				return err
+			}
+		}
+		return s.record(tx, TodoEventCreated, nil, todo.ID)
+	})
 }
 
+// List returns all todos in rank order except those belonging to an archived
+// project.
 func (s *todoStorage) List(ctx context.Context) ([]Todo, error) {
+	archived := dbFrom(ctx, s.db).Model(&Project{}).Select("id").Where("archived = ?", true)
+
 	var todos []Todo
-	if err := s.db.WithContext(ctx).Find(&todos).Error; err != nil {
+	if err := dbFrom(ctx, s.db).
+		Where("project_id IS NULL OR project_id NOT IN (?)", archived).
+		Order("rank").Order("id").
+		Find(&todos).Error; err != nil {
 		return nil, err
 	}
 	return todos, nil
 }
 
+// Search returns a page of the todos matching filter in rank order along with
+// their total count. Like List, it hides the todos of archived projects unless
+// filtering on the project.
+func (s *todoStorage) Search(ctx context.Context, filter TodoFilter, offset, limit int) ([]Todo, int64, error) {
+	query := s.filter(ctx, filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var todos []Todo
+	if err := query.Order("rank").Order("id").Offset(offset).Limit(limit).Find(&todos).Error; err != nil {
+		return nil, 0, err
+	}
+	return todos, total, nil
+}
+
+// Each calls fn with every todo matching filter as Search does, but in ID
+// order and loading them in batches so that they never all sit in memory. It
+// stops at the first error fn returns.
+func (s *todoStorage) Each(ctx context.Context, filter TodoFilter, fn func(Todo) error) error {
+	var todos []Todo
+	return s.filter(ctx, filter).FindInBatches(&todos, 500, func(*gorm.DB, int) error {
+		for _, todo := range todos {
+			if err := fn(todo); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// filter returns the query of the todos matching filter.
+func (s *todoStorage) filter(ctx context.Context, filter TodoFilter) *gorm.DB {
+	query := dbFrom(ctx, s.db).Model(&Todo{})
+	if filter.ProjectID != nil {
+		query = query.Where("project_id = ?", *filter.ProjectID)
+	} else {
+		archived := dbFrom(ctx, s.db).Model(&Project{}).Select("id").Where("archived = ?", true)
+		query = query.Where("project_id IS NULL OR project_id NOT IN (?)", archived)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Completed != nil {
+		query = query.Where("completed = ?", *filter.Completed)
+	}
+	if filter.Query != "" {
+		pattern := "%" + filter.Query + "%"
+		query = query.Where("title LIKE ? OR description LIKE ?", pattern, pattern)
+	}
+	if filter.DueAfter != nil {
+		query = query.Where("due_at >= ?", filter.DueAfter.UTC())
+	}
+	if filter.DueBefore != nil {
+		query = query.Where("due_at < ?", filter.DueBefore.UTC())
+	}
+	if filter.Tag != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM json_each(todos.tags) WHERE value = ?)", filter.Tag)
+	}
+	if filter.ExternalSource != "" {
+		query = query.Where("external_source = ? AND external_id = ?", filter.ExternalSource, filter.ExternalID)
+	}
+	return query
+}
+
 func (s *todoStorage) Get(ctx context.Context, id int) (Todo, error) {
 	var todo Todo
-	if err := s.db.WithContext(ctx).First(&todo, id).Error; err != nil {
+	if err := dbFrom(ctx, s.db).First(&todo, id).Error; err != nil {
 		return todo, err
 	}
 	return todo, nil
 }
 
+// Update applies the non-zero fields of todo. The legacy Completed flag is
+// mapped onto the workflow: true moves the todo to the configured completed
+// state, completed at CompletedAt when it is set, and false reopens it to the
+// initial state.
 func (s *todoStorage) Update(ctx context.Context, id int, todo Todo) error {
-	if _, err := s.Get(ctx, id); err != nil {
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+		before := current
+
+		if todo.DueAt != nil {
+			todo.DueAt = ptr(todo.DueAt.UTC())
+			current.DueAt = todo.DueAt
+		}
+		if todo.RRule != "" {
+			current.RRule = todo.RRule
+			if current.SeriesID == nil {
+				todo.SeriesID = ptr(current.ID)
+				current.SeriesID = todo.SeriesID
+			}
+		}
+		if err := ValidateRRule(current.RRule, current.DueAt); err != nil {
+			return err
+		}
+
+		todo.Status = ""
+		if todo.Completed != nil && *todo.Completed != s.workflow.IsTerminal(current.Status) {
+			to := s.workflow.Initial
+			if *todo.Completed {
+				to = s.workflow.Completed
+			}
+			if _, err := s.transition(tx, current, to, todo.CompletedAt); err != nil {
+				return err
+			}
+		}
+		todo.Completed = nil
+		todo.CompletedAt = nil
+
+		if err := tx.Model(&Todo{}).Where("id = ?", id).Updates(todo).Error; err != nil {
+			return err
+		}
+		return s.record(tx, TodoEventUpdated, &before, current.ID)
+	})
+}
+
+// Replace sets the title, description, due date, recurrence rule, priority and
+// tags of a todo to those of todo, zero values included, and maps Completed onto the workflow
+// like Update when it is set. Clearing the recurrence rule ends the series
+// with this occurrence.
+func (s *todoStorage) Replace(ctx context.Context, id int, todo Todo) error {
+	if todo.DueAt != nil {
+		todo.DueAt = ptr(todo.DueAt.UTC())
+	}
+	if err := ValidateRRule(todo.RRule, todo.DueAt); err != nil {
 		return err
 	}
-	return s.db.WithContext(ctx).Model(&Todo{}).Where("id = ?", id).Updates(todo).Error
+
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+		before := current
+
+		current.Title = todo.Title
+		current.Description = todo.Description
+		current.DueAt = todo.DueAt
+		current.RRule = todo.RRule
+		current.Priority = todo.Priority
+		current.Tags = todo.Tags
+		if current.RRule != "" && current.SeriesID == nil {
+			current.SeriesID = ptr(current.ID)
+		}
+		if err := tx.Model(&current).Select("Title", "Description", "DueAt", "RRule", "SeriesID", "Priority", "Tags").Updates(current).Error; err != nil {
+			return err
+		}
+
+		if todo.Completed != nil && *todo.Completed != s.workflow.IsTerminal(current.Status) {
+			to := s.workflow.Initial
+			if *todo.Completed {
+				to = s.workflow.Completed
+			}
+			if _, err := s.transition(tx, current, to, todo.CompletedAt); err != nil {
+				return err
+			}
+		}
+		return s.record(tx, TodoEventUpdated, &before, current.ID)
+	})
+}
+
+func (s *todoStorage) Upsert(ctx context.Context, todo *Todo) (bool, error) {
+	return upsertTodo(ctx, s.db, s, todo)
+}
+
+// upsertTodo replaces the todo with the external source and ID of todo through
+// s, or creates it when there is none, and reads the result back into todo. It
+// reports whether the todo was created. The ID of a deleted todo cannot be
+// reused until it is purged.
+func upsertTodo(ctx context.Context, db *gorm.DB, s TodoStorage, todo *Todo) (bool, error) {
+	if todo.ExternalSource == nil || todo.ExternalID == nil {
+		return false, errors.New("external source and id are required")
+	}
+
+	created := false
+	err := NewTxManager(db).WithinTx(ctx, func(ctx context.Context) error {
+		var current Todo
+		err := dbFrom(ctx, db).Unscoped().
+			Where("external_source = ? AND external_id = ?", *todo.ExternalSource, *todo.ExternalID).
+			First(&current).Error
+		switch {
+		case IsNotFound(err):
+			// Todos are created open, so completing one takes a replacement.
+			completed, completedAt := todo.Completed, todo.CompletedAt
+			if err := s.Create(ctx, todo); err != nil {
+				return err
+			}
+			created = true
+			if completed != nil && *completed {
+				todo.Completed, todo.CompletedAt = completed, completedAt
+				if err := s.Replace(ctx, int(todo.ID), *todo); err != nil {
+					return err
+				}
+			}
+		case err != nil:
+			return err
+		case current.DeletedAt.Valid:
+			return fmt.Errorf("%w: todo %d", ErrTodoDeleted, current.ID)
+		default:
+			if err := s.Replace(ctx, int(current.ID), *todo); err != nil {
+				return err
+			}
+			todo.ID = current.ID
+		}
+
+		*todo, err = s.Get(ctx, int(todo.ID))
+		return err
+	})
+	return created, err
+}
+
+// UpdateSeries applies the title, description and recurrence rule of todo to
+// every open occurrence of the series the given todo belongs to. Completed
+// occurrences are history and are left untouched.
+func (s *todoStorage) UpdateSeries(ctx context.Context, id int, todo Todo) error {
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+		if current.SeriesID == nil {
+			return fmt.Errorf("%w: todo %d is not part of a series", ErrInvalidRRule, id)
+		}
+		if todo.RRule != "" {
+			if _, err := ParseRRule(todo.RRule, time.Time{}); err != nil {
+				return err
+			}
+		}
+
+		var occurrences []Todo
+		if err := tx.
+			Where("series_id = ?", *current.SeriesID).
+			Where("status NOT IN ?", s.workflow.Terminal).
+			Find(&occurrences).Error; err != nil {
+			return err
+		}
+
+		for _, occurrence := range occurrences {
+			if err := tx.Model(&Todo{}).Where("id = ?", occurrence.ID).Updates(Todo{
+				Title:       todo.Title,
+				Description: todo.Description,
+				RRule:       todo.RRule,
+			}).Error; err != nil {
+				return err
+			}
+			if err := s.record(tx, TodoEventUpdated, &occurrence, occurrence.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListOccurrences previews the next n due dates of a recurring todo after its
+// own due date.
+func (s *todoStorage) ListOccurrences(ctx context.Context, id, n int) ([]time.Time, error) {
+	todo, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if todo.RRule == "" || todo.DueAt == nil {
+		return nil, fmt.Errorf("%w: todo %d is not recurring", ErrInvalidRRule, id)
+	}
+
+	dtstart, err := s.seriesStart(dbFrom(ctx, s.db), todo)
+	if err != nil {
+		return nil, err
+	}
+	return NextOccurrences(todo.RRule, dtstart, *todo.DueAt, n)
+}
+
+// MoveToProject moves a todo into another project, or back to the inbox when
+// projectID is nil.
+func (s *todoStorage) MoveToProject(ctx context.Context, id int, projectID *uint) error {
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+		if projectID != nil {
+			if err := tx.First(&Project{}, *projectID).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&Todo{}).Where("id = ?", id).Update("project_id", projectID).Error; err != nil {
+			return err
+		}
+		return s.record(tx, TodoEventUpdated, &current, current.ID)
+	})
+}
+
+// Move places a todo right after the todo with ID after and right before the
+// todo with ID before. Either neighbour may be omitted to place the todo
+// relative to only one of them; omitting both moves it to the end of the list.
+func (s *todoStorage) Move(ctx context.Context, id int, after, before *int) error {
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+
+		rank, err := s.moveRank(tx, id, after, before)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&Todo{}).Where("id = ?", id).Update("rank", rank).Error; err != nil {
+			return err
+		}
+		if err := s.rebalanceIfNeeded(tx, rank); err != nil {
+			return err
+		}
+		return s.record(tx, TodoEventUpdated, &current, current.ID)
+	})
 }
 
 func (s *todoStorage) Delete(ctx context.Context, id int) error {
+	return dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&current).Error; err != nil {
+			return err
+		}
+		return recordTodoEvent(tx, TodoEventDeleted, &current, nil)
+	})
+}
+
+// Purge permanently removes a todo, including a soft deleted one, together with
+// everything attached to it. Attachment blobs are deleted once the deletion
+// commits. The audit trail of the todo is kept.
+func (s *todoStorage) Purge(ctx context.Context, id int) error {
+	var keys []string
+	err := dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.Unscoped().First(&current, id).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if keys, err = purgeAttachedData(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Where("todo_id = ?", id).Delete(&TodoTransition{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&Todo{}, id).Error; err != nil {
+			return err
+		}
+		return recordTodoEvent(tx, TodoEventPurged, &current, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	afterCommit(ctx, func() { deleteBlobs(ctx, s.blobs, keys...) })
+	return nil
+}
+
+// purgeAttachedData permanently removes the comments and attachments of a todo
+// and returns the keys of the attachment blobs to delete once tx commits.
+func purgeAttachedData(tx *gorm.DB, id int) ([]string, error) {
+	var keys []string
+	if err := tx.Model(&Attachment{}).Unscoped().Where("todo_id = ?", id).Pluck("key", &keys).Error; err != nil {
+		return nil, err
+	}
+
+	comments := tx.Model(&Comment{}).Unscoped().Select("id").Where("todo_id = ?", id)
+	if err := tx.Unscoped().Where("todo_id = ?", id).Delete(&Attachment{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("comment_id IN (?)", comments).Delete(&CommentRevision{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("comment_id IN (?)", comments).Delete(&CommentMention{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("todo_id = ?", id).Delete(&Comment{}).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *todoStorage) Transition(ctx context.Context, id int, status string) (TodoTransition, error) {
+	var transition TodoTransition
+	err := dbFrom(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		var current Todo
+		if err := tx.First(&current, id).Error; err != nil {
+			return err
+		}
+
+		var err error
+		transition, err = s.transition(tx, current, status, nil)
+		if err != nil {
+			return err
+		}
+		return s.record(tx, TodoEventUpdated, &current, current.ID)
+	})
+	return transition, err
+}
+
+func (s *todoStorage) ListTransitions(ctx context.Context, id int) ([]TodoTransition, error) {
 	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	var transitions []TodoTransition
+	if err := dbFrom(ctx, s.db).Where("todo_id = ?", id).Order("id").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+// ListHistory returns the audit trail of a todo, oldest first. The history of a
+// deleted todo remains available.
+func (s *todoStorage) ListHistory(ctx context.Context, id int) ([]TodoEvent, error) {
+	if err := dbFrom(ctx, s.db).Unscoped().First(&Todo{}, id).Error; err != nil {
+		return nil, err
+	}
+
+	var events []TodoEvent
+	if err := dbFrom(ctx, s.db).Where("todo_id = ?", id).Order("id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// transition moves todo to the given status inside tx and records the change
+// in the transition history. A todo reaching a terminal state is completed at
+// completedAt, or now when it is nil.
+func (s *todoStorage) transition(tx *gorm.DB, todo Todo, to string, completedAt *time.Time) (TodoTransition, error) {
+	if err := s.workflow.CheckTransition(todo.Status, to); err != nil {
+		return TodoTransition{}, err
+	}
+
+	if !s.workflow.IsTerminal(to) {
+		completedAt = nil
+	} else if completedAt == nil {
+		completedAt = ptr(tx.NowFunc())
+	}
+	if err := tx.Model(&Todo{}).Where("id = ?", todo.ID).Updates(map[string]any{
+		"status":       to,
+		"completed":    s.workflow.IsTerminal(to),
+		"completed_at": completedAt,
+	}).Error; err != nil {
+		return TodoTransition{}, err
+	}
+
+	transition := TodoTransition{
+		TodoID: todo.ID,
+		From:   todo.Status,
+		To:     to,
+	}
+	if err := tx.Create(&transition).Error; err != nil {
+		return TodoTransition{}, err
+	}
+
+	if !s.workflow.IsTerminal(todo.Status) && s.workflow.IsTerminal(to) {
+		if err := s.spawnNextOccurrence(tx, todo); err != nil {
+			return TodoTransition{}, err
+		}
+	}
+	return transition, nil
+}
+
+// spawnNextOccurrence creates the occurrence following todo in its series, if
+// the rule has one and it has not been created already.
+func (s *todoStorage) spawnNextOccurrence(tx *gorm.DB, todo Todo) error {
+	dueAt, err := s.nextOccurrence(tx, todo)
+	if err != nil || dueAt == nil {
+		return err
+	}
+
+	rank, err := s.appendRank(tx)
+	if err != nil {
+		return err
+	}
+
+	occurrence := Todo{
+		Rank:        rank,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Status:      s.workflow.Initial,
+		Completed:   ptr(s.workflow.IsTerminal(s.workflow.Initial)),
+		DueAt:       dueAt,
+		RRule:       todo.RRule,
+		SeriesID:    todo.SeriesID,
+		ProjectID:   todo.ProjectID,
+		Priority:    todo.Priority,
+		Tags:        todo.Tags,
+	}
+	if err := tx.Create(&occurrence).Error; err != nil {
+		return err
+	}
+	return s.record(tx, TodoEventCreated, nil, occurrence.ID)
+}
+
+// nextOccurrence returns the due date of the occurrence following todo in its
+// series, or nil if the rule has none or it has been created already.
+func (s *todoStorage) nextOccurrence(tx *gorm.DB, todo Todo) (*time.Time, error) {
+	if todo.RRule == "" || todo.DueAt == nil || todo.SeriesID == nil {
+		return nil, nil
+	}
+
+	dtstart, err := s.seriesStart(tx, todo)
+	if err != nil {
+		return nil, err
+	}
+	next, err := NextOccurrences(todo.RRule, dtstart, *todo.DueAt, 1)
+	if err != nil || len(next) == 0 {
+		return nil, err
+	}
+	dueAt := next[0].UTC()
+
+	var count int64
+	if err := tx.Model(&Todo{}).Where("series_id = ? AND due_at = ?", *todo.SeriesID, dueAt).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, nil
+	}
+	return &dueAt, nil
+}
+
+// seriesStart returns the due date of the first occurrence of the series, which
+// anchors COUNT and INTERVAL of the recurrence rule.
+func (s *todoStorage) seriesStart(tx *gorm.DB, todo Todo) (time.Time, error) {
+	if todo.SeriesID == nil || *todo.SeriesID == todo.ID {
+		return *todo.DueAt, nil
+	}
+
+	var first Todo
+	if err := tx.Unscoped().First(&first, *todo.SeriesID).Error; err != nil {
+		return time.Time{}, err
+	}
+	if first.DueAt == nil {
+		return *todo.DueAt, nil
+	}
+	return *first.DueAt, nil
+}
+
+// moveRank returns the rank placing the todo with the given ID between the
+// todos with IDs after and before, as described by Move.
+func (s *todoStorage) moveRank(tx *gorm.DB, id int, after, before *int) (string, error) {
+	var prev, next Todo
+	if after != nil {
+		if *after == id {
+			return "", fmt.Errorf("%w: a todo cannot be moved after itself", ErrInvalidMove)
+		}
+		if err := tx.First(&prev, *after).Error; err != nil {
+			return "", err
+		}
+	}
+	if before != nil {
+		if *before == id {
+			return "", fmt.Errorf("%w: a todo cannot be moved before itself", ErrInvalidMove)
+		}
+		if err := tx.First(&next, *before).Error; err != nil {
+			return "", err
+		}
+	}
+
+	switch {
+	case after != nil && before == nil:
+		if err := tx.Where("rank > ? AND id <> ?", prev.Rank, id).Order("rank").Limit(1).Find(&next).Error; err != nil {
+			return "", err
+		}
+	case after == nil && before != nil:
+		if err := tx.Where("rank < ? AND id <> ?", next.Rank, id).Order("rank DESC").Limit(1).Find(&prev).Error; err != nil {
+			return "", err
+		}
+	case after == nil && before == nil:
+		if err := tx.Where("id <> ?", id).Order("rank DESC").Limit(1).Find(&prev).Error; err != nil {
+			return "", err
+		}
+	}
+
+	return RankBetween(prev.Rank, next.Rank)
+}
+
+// appendRank returns a rank that places a new todo at the end of the list.
+func (s *todoStorage) appendRank(tx *gorm.DB) (string, error) {
+	var last Todo
+	if err := tx.Order("rank DESC").Limit(1).Find(&last).Error; err != nil {
+		return "", err
+	}
+	return RankBetween(last.Rank, "")
+}
+
+// rebalanceIfNeeded spreads the ranks of all todos evenly again once a newly
+// assigned rank has grown longer than MaxRankLength.
+func (s *todoStorage) rebalanceIfNeeded(tx *gorm.DB, rank string) error {
+	if len(rank) <= MaxRankLength {
+		return nil
+	}
+	return s.spreadRanks(tx)
+}
+
+// spreadRanks gives all todos evenly spread ranks, keeping their order.
+func (s *todoStorage) spreadRanks(tx *gorm.DB) error {
+	var ids []uint
+	if err := tx.Model(&Todo{}).Order("rank").Order("id").Pluck("id", &ids).Error; err != nil {
 		return err
 	}
-	return s.db.WithContext(ctx).Delete(&Todo{}, id).Error
+	for i, rank := range EvenRanks(len(ids)) {
+		if err := tx.Model(&Todo{}).Where("id = ?", ids[i]).Update("rank", rank).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record appends the difference between before and the current state of the
+// todo with the given ID to the audit trail.
+func (s *todoStorage) record(tx *gorm.DB, action string, before *Todo, id uint) error {
+	var after Todo
+	if err := tx.First(&after, id).Error; err != nil {
+		return err
+	}
+	return recordTodoEvent(tx, action, before, &after)
+}
+
+func ptr[T any](v T) *T {
+	return &v
 }
@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx/fxtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTodoStorage_Upsert(t *testing.T) {
+	Convey("Given a todo storage", t, func() {
+		db, err := gorm.Open(sqlite.Open("file:upsert?mode=memory&cache=shared"), &gorm.Config{})
+		So(err, ShouldBeNil)
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+
+		wf := &Workflow{
+			Initial:     "todo",
+			Completed:   "done",
+			States:      []string{"todo", "done"},
+			Terminal:    []string{"done"},
+			Transitions: map[string][]string{"todo": {"done"}, "done": {"todo"}},
+		}
+		lc := fxtest.NewLifecycle(t)
+		s := NewTodoStorage(lc, db, wf, nil)
+		lc.RequireStart()
+		defer lc.RequireStop()
+
+		ctx := context.Background()
+		synced := func(source, id string, todo Todo) *Todo {
+			todo.ExternalSource = &source
+			todo.ExternalID = &id
+			return &todo
+		}
+
+		Convey("When the external ID is not known yet", func() {
+			completedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+			todo := synced("jira", "PROJ-1", Todo{Title: "Imported", Description: "From Jira", Completed: ptr(true), CompletedAt: &completedAt, Priority: "A", Tags: []string{"@work"}})
+			created, err := s.Upsert(ctx, todo)
+
+			Convey("Then it should create the todo as given", func() {
+				So(err, ShouldBeNil)
+				So(created, ShouldBeTrue)
+				So(todo.ID, ShouldNotBeZeroValue)
+				So(todo.Title, ShouldEqual, "Imported")
+				So(todo.Status, ShouldEqual, "done")
+				So(todo.CompletedAt.Equal(completedAt), ShouldBeTrue)
+				So(todo.Priority, ShouldEqual, "A")
+				So(*todo.ExternalID, ShouldEqual, "PROJ-1")
+			})
+
+			Convey("When it is upserted again", func() {
+				again := synced("jira", "PROJ-1", Todo{Title: "Renamed"})
+				created, err := s.Upsert(ctx, again)
+
+				Convey("Then it should replace the same todo, resetting the omitted fields", func() {
+					So(err, ShouldBeNil)
+					So(created, ShouldBeFalse)
+					So(again.ID, ShouldEqual, todo.ID)
+					So(again.Title, ShouldEqual, "Renamed")
+					So(again.Description, ShouldBeEmpty)
+					So(again.Status, ShouldEqual, "done")
+
+					stored, err := s.Get(ctx, int(todo.ID))
+					So(err, ShouldBeNil)
+					So(stored.Priority, ShouldBeEmpty)
+					So(stored.Tags, ShouldBeEmpty)
+				})
+			})
+
+			Convey("When the same ID is upserted from another source", func() {
+				other := synced("github", "PROJ-1", Todo{Title: "Other"})
+				created, err := s.Upsert(ctx, other)
+
+				Convey("Then it should create another todo", func() {
+					So(err, ShouldBeNil)
+					So(created, ShouldBeTrue)
+					So(other.ID, ShouldNotEqual, todo.ID)
+				})
+			})
+
+			Convey("When the todo has been deleted", func() {
+				So(s.Delete(ctx, int(todo.ID)), ShouldBeNil)
+				_, err := s.Upsert(ctx, synced("jira", "PROJ-1", Todo{Title: "Back"}))
+
+				Convey("Then it should not be recreated", func() {
+					So(IsTodoDeleted(err), ShouldBeTrue)
+				})
+			})
+		})
+
+		Convey("When no external ID is given", func() {
+			_, err := s.Upsert(ctx, &Todo{Title: "Local"})
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestTodoStorage_Each(t *testing.T) {
+	Convey("Given a todo storage with some todos", t, func() {
+		db, err := gorm.Open(sqlite.Open("file:each?mode=memory&cache=shared"), &gorm.Config{})
+		So(err, ShouldBeNil)
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+
+		wf := &Workflow{
+			Initial:     "todo",
+			Completed:   "done",
+			States:      []string{"todo", "done"},
+			Terminal:    []string{"done"},
+			Transitions: map[string][]string{"todo": {"done"}, "done": {"todo"}},
+		}
+		lc := fxtest.NewLifecycle(t)
+		s := NewTodoStorage(lc, db, wf, nil)
+		NewProjectStorage(lc, db, s)
+		lc.RequireStart()
+		defer lc.RequireStop()
+
+		ctx := context.Background()
+		for _, title := range []string{"Buy milk", "Walk the dog", "Buy bread"} {
+			So(s.Create(ctx, &Todo{Title: title}), ShouldBeNil)
+		}
+
+		Convey("When iterating over the todos matching a filter", func() {
+			var titles []string
+			err := s.Each(ctx, TodoFilter{Query: "Buy"}, func(todo Todo) error {
+				titles = append(titles, todo.Title)
+				return nil
+			})
+
+			Convey("Then it should call back with each of them in ID order", func() {
+				So(err, ShouldBeNil)
+				So(titles, ShouldResemble, []string{"Buy milk", "Buy bread"})
+			})
+		})
+
+		Convey("When filtering on an external ID", func() {
+			source, id := "ical", "abc"
+			So(s.Create(ctx, &Todo{Title: "Imported", ExternalSource: &source, ExternalID: &id}), ShouldBeNil)
+			var titles []string
+			err := s.Each(ctx, TodoFilter{ExternalSource: source, ExternalID: id}, func(todo Todo) error {
+				titles = append(titles, todo.Title)
+				return nil
+			})
+
+			Convey("Then it should only call back with the todo of that ID", func() {
+				So(err, ShouldBeNil)
+				So(titles, ShouldResemble, []string{"Imported"})
+			})
+		})
+
+		Convey("When filtering on a tag", func() {
+			So(s.Create(ctx, &Todo{Title: "Call mom", Tags: []string{"@phone", "+family"}}), ShouldBeNil)
+			So(s.Create(ctx, &Todo{Title: "Buy a phone", Tags: []string{"@phones"}}), ShouldBeNil)
+			var titles []string
+			err := s.Each(ctx, TodoFilter{Tag: "@phone"}, func(todo Todo) error {
+				titles = append(titles, todo.Title)
+				return nil
+			})
+
+			Convey("Then it should only call back with the todos having exactly that tag", func() {
+				So(err, ShouldBeNil)
+				So(titles, ShouldResemble, []string{"Call mom"})
+			})
+		})
+
+		Convey("When the callback fails", func() {
+			errStop := errors.New("stop")
+			calls := 0
+			err := s.Each(ctx, TodoFilter{}, func(Todo) error {
+				calls++
+				return errStop
+			})
+
+			Convey("Then it should stop and return the error", func() {
+				So(err, ShouldEqual, errStop)
+				So(calls, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+// legacyTodo is a todo as stored before the workflow and ranks were added.
+type legacyTodo struct {
+	gorm.Model
+	Title       string
+	Description string
+	Completed   *bool `gorm:"default:false"`
+}
+
+func (legacyTodo) TableName() string {
+	return "todos"
+}
+
+func TestTodoStorage_Backfill(t *testing.T) {
+	Convey("Given todos stored before the workflow existed", t, func() {
+		db, err := gorm.Open(sqlite.Open("file:backfill?mode=memory&cache=shared"), &gorm.Config{})
+		So(err, ShouldBeNil)
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+
+		So(db.AutoMigrate(&legacyTodo{}), ShouldBeNil)
+		So(db.Create(&[]legacyTodo{
+			{Title: "Open", Completed: ptr(false)},
+			{Title: "Done", Completed: ptr(true)},
+		}).Error, ShouldBeNil)
+
+		wf := &Workflow{
+			Initial:     "todo",
+			Completed:   "done",
+			States:      []string{"todo", "done"},
+			Terminal:    []string{"done"},
+			Transitions: map[string][]string{"todo": {"done"}, "done": {"todo"}},
+		}
+
+		So(db.Create(&legacyTodo{Title: "Later"}).Error, ShouldBeNil)
+
+		Convey("When the todo storage starts", func() {
+			lc := fxtest.NewLifecycle(t)
+			s := NewTodoStorage(lc, db, wf, nil)
+			NewProjectStorage(lc, db, s)
+			lc.RequireStart()
+			defer lc.RequireStop()
+			ctx := context.Background()
+
+			Convey("Then their status should follow their completion", func() {
+				open, err := s.Get(ctx, 1)
+				So(err, ShouldBeNil)
+				So(open.Status, ShouldEqual, "todo")
+				So(open.CompletedAt, ShouldBeNil)
+				done, err := s.Get(ctx, 2)
+				So(err, ShouldBeNil)
+				So(done.Status, ShouldEqual, "done")
+				So(done.CompletedAt, ShouldNotBeNil)
+				So(*done.CompletedAt, ShouldHappenOnOrBefore, done.UpdatedAt)
+			})
+
+			Convey("Then they should still be completed and reopened", func() {
+				So(s.Update(ctx, 1, Todo{Completed: ptr(true)}), ShouldBeNil)
+				So(s.Update(ctx, 2, Todo{Completed: ptr(false)}), ShouldBeNil)
+				done, err := s.Get(ctx, 1)
+				So(err, ShouldBeNil)
+				So(done.CompletedAt, ShouldNotBeNil)
+				open, err := s.Get(ctx, 2)
+				So(err, ShouldBeNil)
+				So(open.Status, ShouldEqual, "todo")
+				So(open.CompletedAt, ShouldBeNil)
+			})
+
+			Convey("Then they should be ranked in ID order", func() {
+				todos, err := s.List(ctx)
+				So(err, ShouldBeNil)
+				So(todos, ShouldHaveLength, 3)
+				for i, todo := range todos {
+					So(todo.ID, ShouldEqual, i+1)
+					So(todo.Rank, ShouldNotBeEmpty)
+				}
+			})
+
+			Convey("Then a todo moved before one of them should sort before it", func() {
+				So(s.Move(ctx, 3, nil, ptr(2)), ShouldBeNil)
+				So(s.Create(ctx, &Todo{Title: "New"}), ShouldBeNil)
+				todos, err := s.List(ctx)
+				So(err, ShouldBeNil)
+				var titles []string
+				for _, todo := range todos {
+					titles = append(titles, todo.Title)
+				}
+				So(titles, ShouldResemble, []string{"Open", "Later", "Done", "New"})
+			})
+		})
+	})
+}
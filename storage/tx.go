@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// unitOfWork is the transaction carried by a context, with the functions to
+// run once it commits.
+type unitOfWork struct {
+	tx          *gorm.DB
+	afterCommit []func()
+}
+
+//go:generate mockgen -destination=mock/tx.go -package=mock . TxManager
+type TxManager interface {
+	// WithinTx runs fn in a transaction committed when fn returns nil and
+	// rolled back otherwise. Storage calls made with the context given to fn
+	// join the transaction. Nested calls run in a savepoint.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txManager struct {
+	db *gorm.DB
+}
+
+func NewTxManager(db *gorm.DB) TxManager {
+	return &txManager{db: db}
+}
+
+func (m *txManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	parent, _ := ctx.Value(txKey{}).(*unitOfWork)
+	db := m.db
+	if parent != nil {
+		db = parent.tx
+	}
+
+	uow := &unitOfWork{}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		uow.tx = tx
+		return fn(context.WithValue(ctx, txKey{}, uow))
+	})
+	if err != nil {
+		return err
+	}
+
+	// A savepoint is only final once the outermost transaction commits.
+	if parent != nil {
+		parent.afterCommit = append(parent.afterCommit, uow.afterCommit...)
+		return nil
+	}
+	for _, f := range uow.afterCommit {
+		f()
+	}
+	return nil
+}
+
+// dbFrom returns the transaction of ctx if any, or db.
+func dbFrom(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if uow, ok := ctx.Value(txKey{}).(*unitOfWork); ok {
+		db = uow.tx
+	}
+	return db.WithContext(ctx)
+}
+
+// afterCommit runs f once the transaction of ctx commits, or right away when
+// ctx has none. It is meant for side effects that cannot be rolled back.
+func afterCommit(ctx context.Context, f func()) {
+	if uow, ok := ctx.Value(txKey{}).(*unitOfWork); ok {
+		uow.afterCommit = append(uow.afterCommit, f)
+		return
+	}
+	f()
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTxManager_WithinTx(t *testing.T) {
+	constructors := map[string]func(fx.Lifecycle, *gorm.DB, *Workflow, BlobStore) TodoStorage{
+		"state":         NewTodoStorage,
+		"event sourced": NewEventSourcedTodoStorage,
+	}
+	for name, newStorage := range constructors {
+		Convey("Given a "+name+" todo storage and a transaction manager", t, func() {
+			db, err := gorm.Open(sqlite.Open("file:tx?mode=memory&cache=shared"), &gorm.Config{})
+			So(err, ShouldBeNil)
+			sqlDB, _ := db.DB()
+			defer sqlDB.Close()
+
+			wf := &Workflow{
+				Initial:     "todo",
+				Completed:   "done",
+				States:      []string{"todo", "done"},
+				Terminal:    []string{"done"},
+				Transitions: map[string][]string{"todo": {"done"}, "done": {"todo"}},
+			}
+			lc := fxtest.NewLifecycle(t)
+			s := newStorage(lc, db, wf, nil)
+			lc.RequireStart()
+			defer lc.RequireStop()
+
+			m := NewTxManager(db)
+			ctx := context.Background()
+			existing := Todo{Title: "existing"}
+			So(s.Create(ctx, &existing), ShouldBeNil)
+
+			count := func() int64 {
+				var n int64
+				So(db.Model(&Todo{}).Count(&n).Error, ShouldBeNil)
+				return n
+			}
+			errFailed := errors.New("failed")
+
+			Convey("When the function fails after several storage calls", func() {
+				committed := false
+				err := m.WithinTx(ctx, func(ctx context.Context) error {
+					So(s.Create(ctx, &Todo{Title: "new"}), ShouldBeNil)
+					So(s.Update(ctx, int(existing.ID), Todo{Title: "renamed"}), ShouldBeNil)
+					afterCommit(ctx, func() { committed = true })
+					return errFailed
+				})
+
+				Convey("Then every call should be rolled back", func() {
+					So(err, ShouldEqual, errFailed)
+					So(count(), ShouldEqual, 1)
+					So(committed, ShouldBeFalse)
+
+					todo, err := s.Get(ctx, int(existing.ID))
+					So(err, ShouldBeNil)
+					So(todo.Title, ShouldEqual, "existing")
+
+					var events int64
+					So(db.Model(&TodoEvent{}).Count(&events).Error, ShouldBeNil)
+					So(events, ShouldEqual, 1)
+				})
+			})
+
+			Convey("When a storage call fails after others succeeded", func() {
+				err := m.WithinTx(ctx, func(ctx context.Context) error {
+					So(s.Create(ctx, &Todo{Title: "new"}), ShouldBeNil)
+					So(s.Update(ctx, int(existing.ID), Todo{Title: "renamed"}), ShouldBeNil)
+					return s.Delete(ctx, 999)
+				})
+
+				Convey("Then the calls that succeeded should be rolled back too", func() {
+					So(IsNotFound(err), ShouldBeTrue)
+					So(count(), ShouldEqual, 1)
+
+					todo, err := s.Get(ctx, int(existing.ID))
+					So(err, ShouldBeNil)
+					So(todo.Title, ShouldEqual, "existing")
+				})
+			})
+
+			Convey("When the function succeeds", func() {
+				committed := false
+				err := m.WithinTx(ctx, func(ctx context.Context) error {
+					So(s.Create(ctx, &Todo{Title: "new"}), ShouldBeNil)
+					afterCommit(ctx, func() { committed = true })
+					return s.Delete(ctx, int(existing.ID))
+				})
+
+				Convey("Then every call should be committed", func() {
+					So(err, ShouldBeNil)
+					So(count(), ShouldEqual, 1)
+					So(committed, ShouldBeTrue)
+				})
+			})
+
+			Convey("When a nested call fails", func() {
+				committed := false
+				err := m.WithinTx(ctx, func(ctx context.Context) error {
+					So(s.Create(ctx, &Todo{Title: "kept"}), ShouldBeNil)
+					err := m.WithinTx(ctx, func(ctx context.Context) error {
+						So(s.Create(ctx, &Todo{Title: "dropped"}), ShouldBeNil)
+						afterCommit(ctx, func() { committed = true })
+						return errFailed
+					})
+					So(err, ShouldEqual, errFailed)
+					return nil
+				})
+
+				Convey("Then only the nested calls should be rolled back", func() {
+					So(err, ShouldBeNil)
+					So(count(), ShouldEqual, 2)
+					So(committed, ShouldBeFalse)
+				})
+			})
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Events a webhook can subscribe to. WebhookEventAll matches every event and
+// WebhookEventTest is only sent on request.
+const (
+	WebhookEventAll           = "*"
+	WebhookEventTodoCreated   = "todo.created"
+	WebhookEventTodoUpdated   = "todo.updated"
+	WebhookEventTodoCompleted = "todo.completed"
+	WebhookEventTodoDeleted   = "todo.deleted"
+	WebhookEventTodoPurged    = "todo.purged"
+	WebhookEventTest          = "webhook.test"
+)
+
+// Statuses of a webhook delivery.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook is a subscription of an external URL to todo events. FailureCount
+// counts the consecutive failed delivery attempts.
+type Webhook struct {
+	gorm.Model
+	URL          string
+	Events       []string `gorm:"serializer:json"`
+	Secret       string
+	Active       *bool `gorm:"default:true"`
+	FailureCount int
+	DisabledAt   *time.Time
+}
+
+// Subscribes reports whether the webhook subscribes to event.
+func (w Webhook) Subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == WebhookEventAll || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is an event to deliver to a webhook. MessageID refers to the
+// outbox message the event originates from and is nil for test events.
+type WebhookDelivery struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	WebhookID     uint  `gorm:"uniqueIndex:idx_webhook_delivery_message"`
+	MessageID     *uint `gorm:"uniqueIndex:idx_webhook_delivery_message"`
+	Event         string
+	Payload       string
+	Status        string `gorm:"index"`
+	Attempts      int
+	NextAttemptAt time.Time `gorm:"index"`
+	ResponseCode  int
+	DeliveredAt   *time.Time
+	Webhook       Webhook                  `gorm:"foreignKey:WebhookID"`
+	AttemptLog    []WebhookDeliveryAttempt `gorm:"foreignKey:DeliveryID"`
+}
+
+// WebhookDeliveryAttempt logs a single attempt of a delivery. ResponseCode is 0
+// when no response was received.
+type WebhookDeliveryAttempt struct {
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	DeliveryID   uint `gorm:"index"`
+	ResponseCode int
+	ResponseBody string
+	Error        string
+	Duration     time.Duration
+}
+
+// WebhookPayload is the body posted to webhooks.
+type WebhookPayload struct {
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Data       any       `json:"data"`
+}
+
+//go:generate mockgen -destination=mock/webhook.go -package=mock . WebhookStorage
+type WebhookStorage interface {
+	Get(ctx context.Context, id int) (Webhook, error)
+	List(ctx context.Context) ([]Webhook, error)
+	Create(ctx context.Context, webhook *Webhook) error
+	Update(ctx context.Context, id int, webhook Webhook) error
+	Delete(ctx context.Context, id int) error
+	ListDeliveries(ctx context.Context, id, offset, limit int) ([]WebhookDelivery, int64, error)
+	CreateTestDelivery(ctx context.Context, id int) (WebhookDelivery, error)
+	ListActive(ctx context.Context) ([]Webhook, error)
+	CreateDeliveries(ctx context.Context, deliveries []WebhookDelivery) error
+	ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, id uint, attempt WebhookDeliveryAttempt, retryAt *time.Time) (int, error)
+	Disable(ctx context.Context, id uint) error
+}
+
+type webhookStorage struct {
+	db *gorm.DB
+}
+
+func NewWebhookStorage(lc fx.Lifecycle, db *gorm.DB) WebhookStorage {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return db.AutoMigrate(&Webhook{}, &WebhookDelivery{}, &WebhookDeliveryAttempt{})
+		},
+	})
+	return &webhookStorage{db: db}
+}
+
+// Create saves a new webhook, generating a secret if none is given.
+func (s *webhookStorage) Create(ctx context.Context, webhook *Webhook) error {
+	if webhook.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return err
+		}
+		webhook.Secret = secret
+	}
+	return s.db.WithContext(ctx).Create(webhook).Error
+}
+
+func (s *webhookStorage) List(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := s.db.WithContext(ctx).Order("id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (s *webhookStorage) Get(ctx context.Context, id int) (Webhook, error) {
+	var webhook Webhook
+	if err := s.db.WithContext(ctx).First(&webhook, id).Error; err != nil {
+		return webhook, err
+	}
+	return webhook, nil
+}
+
+// Update applies the non-zero fields of webhook. Reactivating a webhook resets
+// its failure count.
+func (s *webhookStorage) Update(ctx context.Context, id int, webhook Webhook) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&Webhook{}, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Webhook{}).Where("id = ?", id).Updates(webhook).Error; err != nil {
+			return err
+		}
+		if webhook.Active != nil && *webhook.Active {
+			return tx.Model(&Webhook{}).Where("id = ?", id).Updates(map[string]any{
+				"failure_count": 0,
+				"disabled_at":   nil,
+			}).Error
+		}
+		return nil
+	})
+}
+
+func (s *webhookStorage) Delete(ctx context.Context, id int) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Delete(&Webhook{}, id).Error
+}
+
+// ListDeliveries returns the deliveries of a webhook with their attempts, newest
+// first.
+func (s *webhookStorage) ListDeliveries(ctx context.Context, id, offset, limit int) ([]WebhookDelivery, int64, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := s.db.WithContext(ctx).Model(&WebhookDelivery{}).Where("webhook_id = ?", id).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var deliveries []WebhookDelivery
+	if err := s.db.WithContext(ctx).
+		Preload("AttemptLog", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("webhook_id = ?", id).
+		Order("id DESC").
+		Offset(offset).Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// CreateTestDelivery queues a test event for a webhook, even a disabled one.
+func (s *webhookStorage) CreateTestDelivery(ctx context.Context, id int) (WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var webhook Webhook
+		if err := tx.First(&webhook, id).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		payload, err := json.Marshal(WebhookPayload{
+			Event:      WebhookEventTest,
+			OccurredAt: now,
+			Data:       map[string]any{"webhookId": webhook.ID},
+		})
+		if err != nil {
+			return err
+		}
+
+		delivery = WebhookDelivery{
+			WebhookID:     webhook.ID,
+			Event:         WebhookEventTest,
+			Payload:       string(payload),
+			Status:        WebhookDeliveryPending,
+			NextAttemptAt: now,
+		}
+		return tx.Create(&delivery).Error
+	})
+	return delivery, err
+}
+
+func (s *webhookStorage) ListActive(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := s.db.WithContext(ctx).Where("active = ?", true).Order("id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// CreateDeliveries queues deliveries, skipping those already queued for the
+// same webhook and outbox message.
+func (s *webhookStorage) CreateDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+// ListDueDeliveries returns the pending deliveries due at now together with
+// their webhook. Test deliveries are sent to disabled webhooks too.
+func (s *webhookStorage) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	active := s.db.Model(&Webhook{}).Select("id").Where("active = ?", true)
+	existing := s.db.Model(&Webhook{}).Select("id")
+
+	var deliveries []WebhookDelivery
+	if err := s.db.WithContext(ctx).
+		Preload("Webhook").
+		Where("status = ? AND next_attempt_at <= ?", WebhookDeliveryPending, now).
+		Where("webhook_id IN (?) OR (event = ? AND webhook_id IN (?))", active, WebhookEventTest, existing).
+		Order("id").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt logs an attempt of a delivery. A failed attempt is retried at
+// retryAt, or fails the delivery for good when retryAt is nil. It returns the
+// number of consecutive failed attempts of the webhook.
+func (s *webhookStorage) RecordAttempt(ctx context.Context, id uint, attempt WebhookDeliveryAttempt, retryAt *time.Time) (int, error) {
+	var failures int
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var delivery WebhookDelivery
+		if err := tx.First(&delivery, id).Error; err != nil {
+			return err
+		}
+
+		attempt.DeliveryID = id
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+
+		succeeded := attempt.Error == ""
+		updates := map[string]any{
+			"attempts":      gorm.Expr("attempts + 1"),
+			"response_code": attempt.ResponseCode,
+		}
+		switch {
+		case succeeded:
+			updates["status"] = WebhookDeliverySucceeded
+			updates["delivered_at"] = attempt.CreatedAt
+		case retryAt != nil:
+			updates["next_attempt_at"] = *retryAt
+		default:
+			updates["status"] = WebhookDeliveryFailed
+		}
+		if err := tx.Model(&delivery).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		failureCount := gorm.Expr("failure_count + 1")
+		if succeeded {
+			failureCount = gorm.Expr("0")
+		}
+		if err := tx.Model(&Webhook{}).Where("id = ?", delivery.WebhookID).Update("failure_count", failureCount).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Webhook{}).Where("id = ?", delivery.WebhookID).Pluck("failure_count", &failures).Error
+	})
+	return failures, err
+}
+
+// Disable deactivates a webhook. Its pending deliveries are kept and resumed
+// once it is reactivated.
+func (s *webhookStorage) Disable(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Model(&Webhook{}).Where("id = ?", id).Updates(map[string]any{
+		"active":      false,
+		"disabled_at": time.Now(),
+	}).Error
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx/fxtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestWebhookStorage_Deliveries(t *testing.T) {
+	Convey("Given a webhook with a queued delivery", t, func() {
+		db, err := gorm.Open(sqlite.Open("file:webhook?mode=memory&cache=shared"), &gorm.Config{})
+		So(err, ShouldBeNil)
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+
+		lc := fxtest.NewLifecycle(t)
+		s := NewWebhookStorage(lc, db)
+		lc.RequireStart()
+		defer lc.RequireStop()
+
+		ctx := context.Background()
+		webhook := Webhook{URL: "http://example.com/hook", Events: []string{WebhookEventAll}}
+		So(s.Create(ctx, &webhook), ShouldBeNil)
+		So(webhook.Secret, ShouldStartWith, "whsec_")
+
+		now := time.Now()
+		messageID := uint(7)
+		delivery := WebhookDelivery{
+			WebhookID:     webhook.ID,
+			MessageID:     &messageID,
+			Event:         WebhookEventTodoCreated,
+			Status:        WebhookDeliveryPending,
+			NextAttemptAt: now,
+		}
+		So(s.CreateDeliveries(ctx, []WebhookDelivery{delivery}), ShouldBeNil)
+
+		Convey("When the same message is dispatched again", func() {
+			So(s.CreateDeliveries(ctx, []WebhookDelivery{delivery}), ShouldBeNil)
+			deliveries, err := s.ListDueDeliveries(ctx, now, 10)
+
+			Convey("Then it should be queued once", func() {
+				So(err, ShouldBeNil)
+				So(deliveries, ShouldHaveLength, 1)
+				So(deliveries[0].Webhook.URL, ShouldEqual, webhook.URL)
+			})
+		})
+
+		Convey("When an attempt fails and is retried later", func() {
+			failures, err := s.RecordAttempt(ctx, 1, WebhookDeliveryAttempt{ResponseCode: 500, Error: "500"}, ptr(now.Add(time.Minute)))
+			So(err, ShouldBeNil)
+			deliveries, err := s.ListDueDeliveries(ctx, now, 10)
+
+			Convey("Then the failure should be counted and the delivery no longer due", func() {
+				So(err, ShouldBeNil)
+				So(failures, ShouldEqual, 1)
+				So(deliveries, ShouldBeEmpty)
+			})
+
+			Convey("And a later success should reset the failure count", func() {
+				failures, err := s.RecordAttempt(ctx, 1, WebhookDeliveryAttempt{ResponseCode: 204}, nil)
+				So(err, ShouldBeNil)
+				So(failures, ShouldEqual, 0)
+
+				deliveries, total, err := s.ListDeliveries(ctx, int(webhook.ID), 0, 10)
+				So(err, ShouldBeNil)
+				So(total, ShouldEqual, 1)
+				So(deliveries[0].Status, ShouldEqual, WebhookDeliverySucceeded)
+				So(deliveries[0].Attempts, ShouldEqual, 2)
+				So(deliveries[0].AttemptLog, ShouldHaveLength, 2)
+				So(deliveries[0].AttemptLog[0].ResponseCode, ShouldEqual, 500)
+			})
+		})
+
+		Convey("When the webhook is disabled", func() {
+			So(s.Disable(ctx, webhook.ID), ShouldBeNil)
+			_, err := s.CreateTestDelivery(ctx, int(webhook.ID))
+			So(err, ShouldBeNil)
+			deliveries, err := s.ListDueDeliveries(ctx, time.Now(), 10)
+
+			Convey("Then only test deliveries should be due", func() {
+				So(err, ShouldBeNil)
+				So(deliveries, ShouldHaveLength, 1)
+				So(deliveries[0].Event, ShouldEqual, WebhookEventTest)
+			})
+
+			Convey("And reactivating it should resume its deliveries", func() {
+				So(s.Update(ctx, int(webhook.ID), Webhook{Active: ptr(true)}), ShouldBeNil)
+				deliveries, err := s.ListDueDeliveries(ctx, time.Now(), 10)
+				So(err, ShouldBeNil)
+				So(deliveries, ShouldHaveLength, 2)
+
+				reactivated, err := s.Get(ctx, int(webhook.ID))
+				So(err, ShouldBeNil)
+				So(reactivated.DisabledAt, ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Workflow is the todo status state machine loaded from config.
+type Workflow struct {
+	Initial     string              `mapstructure:"initial"`
+	Completed   string              `mapstructure:"completed"`
+	States      []string            `mapstructure:"states"`
+	Terminal    []string            `mapstructure:"terminal"`
+	Transitions map[string][]string `mapstructure:"transitions"`
+}
+
+func (w *Workflow) Validate() error {
+	if len(w.States) == 0 {
+		return fmt.Errorf("workflow: no states defined")
+	}
+	if !w.HasState(w.Initial) {
+		return fmt.Errorf("workflow: initial state %q is not defined", w.Initial)
+	}
+	if !w.IsTerminal(w.Completed) {
+		return fmt.Errorf("workflow: completed state %q is not a terminal state", w.Completed)
+	}
+	for _, s := range w.Terminal {
+		if !w.HasState(s) {
+			return fmt.Errorf("workflow: terminal state %q is not defined", s)
+		}
+	}
+	for from, tos := range w.Transitions {
+		if !w.HasState(from) {
+			return fmt.Errorf("workflow: transition from undefined state %q", from)
+		}
+		for _, to := range tos {
+			if !w.HasState(to) {
+				return fmt.Errorf("workflow: transition from %q to undefined state %q", from, to)
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Workflow) HasState(state string) bool {
+	return slices.Contains(w.States, state)
+}
+
+func (w *Workflow) IsTerminal(state string) bool {
+	return slices.Contains(w.Terminal, state)
+}
+
+func (w *Workflow) CanTransition(from, to string) bool {
+	return slices.Contains(w.Transitions[from], to)
+}
+
+// CheckTransition returns ErrUnknownStatus or ErrIllegalTransition when the
+// todo is not allowed to move from one state to the other.
+func (w *Workflow) CheckTransition(from, to string) error {
+	if !w.HasState(to) {
+		return fmt.Errorf("%w: %q", ErrUnknownStatus, to)
+	}
+	if !w.CanTransition(from, to) {
+		return fmt.Errorf("%w: %q -> %q", ErrIllegalTransition, from, to)
+	}
+	return nil
+}
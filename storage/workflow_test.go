@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWorkflow(t *testing.T) {
+	Convey("Given a workflow", t, func() {
+		wf := &Workflow{
+			Initial:   "todo",
+			Completed: "done",
+			States:    []string{"todo", "in_progress", "done", "wont_do"},
+			Terminal:  []string{"done", "wont_do"},
+			Transitions: map[string][]string{
+				"todo":        {"in_progress", "wont_do"},
+				"in_progress": {"done"},
+				"done":        {"todo"},
+			},
+		}
+
+		Convey("Then it should be valid", func() {
+			So(wf.Validate(), ShouldBeNil)
+		})
+
+		Convey("When the completed state is not terminal", func() {
+			wf.Completed = "in_progress"
+
+			Convey("Then it should be invalid", func() {
+				So(wf.Validate(), ShouldNotBeNil)
+			})
+		})
+
+		Convey("When a transition targets an undefined state", func() {
+			wf.Transitions["done"] = []string{"archived"}
+
+			Convey("Then it should be invalid", func() {
+				So(wf.Validate(), ShouldNotBeNil)
+			})
+		})
+
+		Convey("When checking an allowed transition", func() {
+			So(wf.CheckTransition("todo", "in_progress"), ShouldBeNil)
+		})
+
+		Convey("When checking a transition that is not allowed", func() {
+			So(IsIllegalTransition(wf.CheckTransition("todo", "done")), ShouldBeTrue)
+		})
+
+		Convey("When checking a transition to an unknown state", func() {
+			So(IsUnknownStatus(wf.CheckTransition("todo", "archived")), ShouldBeTrue)
+		})
+
+		Convey("When checking terminal states", func() {
+			So(wf.IsTerminal("done"), ShouldBeTrue)
+			So(wf.IsTerminal("wont_do"), ShouldBeTrue)
+			So(wf.IsTerminal("in_progress"), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,261 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/fx"
+
+	"github.com/wei840222/go-restful-sample/outbox"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Config controls the event buffer and the subscriber streams.
+type Config struct {
+	// BufferSize is the number of recent events kept for subscribers resuming
+	// with a Last-Event-ID.
+	BufferSize int `mapstructure:"buffer_size"`
+	// SubscriberBuffer is the number of events queued for a subscriber before it
+	// is considered too slow and disconnected.
+	SubscriberBuffer int           `mapstructure:"subscriber_buffer"`
+	Heartbeat        time.Duration `mapstructure:"heartbeat"`
+	// Retry is the reconnection delay suggested to clients.
+	Retry time.Duration `mapstructure:"retry"`
+}
+
+// Event is a todo change as streamed to subscribers. Its ID is the ID of the
+// outbox message it was published from.
+type Event struct {
+	ID      string
+	Type    string
+	Data    json.RawMessage
+	changed storage.TodoChanged
+}
+
+// Filter selects the events of a subscription. Zero fields match everything.
+type Filter struct {
+	ProjectID uint
+	Tag       string
+}
+
+// Match reports whether the event concerns a todo matching f, either before or
+// after the change, so that subscribers also learn about todos leaving their
+// selection.
+func (f Filter) Match(e Event) bool {
+	if f.ProjectID != 0 && !e.has("projectId", f.ProjectID) {
+		return false
+	}
+	if f.Tag != "" && !e.has("tags", f.Tag) {
+		return false
+	}
+	return true
+}
+
+func (e Event) has(field string, want any) bool {
+	values := []any{e.changed.Todo[field]}
+	for _, change := range e.changed.Changes {
+		if change.Field == field {
+			values = append(values, change.Before, change.After)
+		}
+	}
+	for _, value := range values {
+		if contains(value, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains compares a JSON decoded value, or any of its elements if it is an
+// array, with want.
+func contains(value, want any) bool {
+	switch v := value.(type) {
+	case []any:
+		for _, elem := range v {
+			if contains(elem, want) {
+				return true
+			}
+		}
+		return false
+	case float64:
+		if id, ok := want.(uint); ok {
+			return v == float64(id)
+		}
+		return false
+	default:
+		return value == want
+	}
+}
+
+// Subscription is a stream of events for one subscriber. Events is closed when
+// the subscriber falls behind or the broker closes.
+type Subscription struct {
+	// Replay holds the buffered events following the Last-Event-ID.
+	Replay []Event
+	// Reset is set when the Last-Event-ID is no longer buffered, so the
+	// subscriber has missed events and must refetch its state.
+	Reset  bool
+	Events <-chan Event
+
+	events chan Event
+	filter Filter
+}
+
+// Broker fans the todo changes relayed from the outbox out to the subscribers
+// of the event stream. It is an outbox.Sink and keeps the most recent events in
+// a ring buffer for resuming subscribers.
+type Broker struct {
+	config Config
+
+	mu          sync.Mutex
+	buffer      []Event
+	next        int
+	seen        map[string]bool
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewBroker(lc fx.Lifecycle, cfg Config) *Broker {
+	b := &Broker{
+		config:      cfg,
+		buffer:      make([]Event, 0, cfg.BufferSize),
+		seen:        make(map[string]bool, cfg.BufferSize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+	lc.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			b.Close()
+			return nil
+		},
+	})
+	return b
+}
+
+func (b *Broker) Config() Config {
+	return b.config
+}
+
+// Publish buffers the event of msg and hands it to the matching subscribers.
+// Messages relayed again after a failure of another sink are dropped. A
+// subscriber whose queue is full is disconnected rather than slowing down the
+// relay; it resumes from the buffer when it reconnects.
+func (b *Broker) Publish(_ context.Context, msg outbox.Message) error {
+	var changed storage.TodoChanged
+	if err := json.Unmarshal(msg.Payload, &changed); err != nil {
+		return err
+	}
+	event := Event{
+		ID:      strconv.FormatUint(uint64(msg.ID), 10),
+		Type:    msg.Topic,
+		Data:    json.RawMessage(msg.Payload),
+		changed: changed,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed || b.seen[event.ID] {
+		return nil
+	}
+	b.append(event)
+
+	for sub := range b.subscribers {
+		if !sub.filter.Match(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			b.unsubscribe(sub)
+		}
+	}
+	return nil
+}
+
+func (b *Broker) append(event Event) {
+	if b.config.BufferSize == 0 {
+		return
+	}
+	if len(b.buffer) < b.config.BufferSize {
+		b.buffer = append(b.buffer, event)
+	} else {
+		delete(b.seen, b.buffer[b.next].ID)
+		b.buffer[b.next] = event
+		b.next = (b.next + 1) % b.config.BufferSize
+	}
+	b.seen[event.ID] = true
+}
+
+// buffered returns the buffered events, oldest first.
+func (b *Broker) buffered() []Event {
+	events := make([]Event, 0, len(b.buffer))
+	events = append(events, b.buffer[b.next:]...)
+	return append(events, b.buffer[:b.next]...)
+}
+
+// Subscribe starts a subscription to the events matching filter. With a
+// lastEventID, the buffered events published after it are replayed first.
+func (b *Broker) Subscribe(lastEventID string, filter Filter) *Subscription {
+	events := make(chan Event, b.config.SubscriberBuffer)
+	sub := &Subscription{Events: events, events: events, filter: filter}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(events)
+		return sub
+	}
+
+	if lastEventID != "" {
+		if b.seen[lastEventID] {
+			buffered := b.buffered()
+			for i, event := range buffered {
+				if event.ID != lastEventID {
+					continue
+				}
+				for _, event := range buffered[i+1:] {
+					if filter.Match(event) {
+						sub.Replay = append(sub.Replay, event)
+					}
+				}
+				break
+			}
+		} else {
+			sub.Reset = true
+		}
+	}
+
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe ends a subscription and closes its events.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unsubscribe(sub)
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
+
+// Close ends all subscriptions, letting their streams finish so that the HTTP
+// server can shut down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.unsubscribe(sub)
+	}
+}
@@ -0,0 +1,162 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx/fxtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/outbox"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+func message(id uint, changed storage.TodoChanged) outbox.Message {
+	payload, _ := json.Marshal(changed)
+	return outbox.Message{ID: id, Topic: changed.Type, Payload: payload}
+}
+
+func ids(events []Event) []string {
+	var ids []string
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestBroker(t *testing.T) {
+	Convey("Given a broker buffering three events", t, func() {
+		lc := fxtest.NewLifecycle(t)
+		b := NewBroker(lc, Config{BufferSize: 3, SubscriberBuffer: 1})
+		lc.RequireStart()
+		defer lc.RequireStop()
+
+		ctx := context.Background()
+		for id := uint(1); id <= 4; id++ {
+			So(b.Publish(ctx, message(id, storage.TodoChanged{Type: "todo.created", TodoID: id})), ShouldBeNil)
+		}
+
+		Convey("When resuming from a buffered event", func() {
+			sub := b.Subscribe("2", Filter{})
+
+			Convey("Then the later events should be replayed", func() {
+				So(sub.Reset, ShouldBeFalse)
+				So(ids(sub.Replay), ShouldResemble, []string{"3", "4"})
+			})
+		})
+
+		Convey("When resuming from an event no longer buffered", func() {
+			sub := b.Subscribe("1", Filter{})
+
+			Convey("Then the subscriber should be told to reset", func() {
+				So(sub.Reset, ShouldBeTrue)
+				So(sub.Replay, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a message is relayed again", func() {
+			sub := b.Subscribe("", Filter{})
+			So(b.Publish(ctx, message(4, storage.TodoChanged{Type: "todo.created", TodoID: 4})), ShouldBeNil)
+
+			Convey("Then it should not be streamed twice", func() {
+				So(sub.Events, ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When a subscriber falls behind", func() {
+			sub := b.Subscribe("", Filter{})
+			So(b.Publish(ctx, message(5, storage.TodoChanged{Type: "todo.created", TodoID: 5})), ShouldBeNil)
+			So(b.Publish(ctx, message(6, storage.TodoChanged{Type: "todo.created", TodoID: 6})), ShouldBeNil)
+
+			Convey("Then it should be disconnected after its queued events", func() {
+				event, ok := <-sub.Events
+				So(ok, ShouldBeTrue)
+				So(event.ID, ShouldEqual, "5")
+				_, ok = <-sub.Events
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the broker closes", func() {
+			sub := b.Subscribe("", Filter{})
+			b.Close()
+
+			Convey("Then the subscriptions should end", func() {
+				_, ok := <-sub.Events
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestFilter_Match(t *testing.T) {
+	Convey("Given a filter on a project", t, func() {
+		filter := Filter{ProjectID: 1}
+		event := func(changed storage.TodoChanged) Event {
+			var e Event
+			b, _ := json.Marshal(changed)
+			json.Unmarshal(b, &e.changed)
+			return e
+		}
+
+		Convey("Then it should match todos in the project", func() {
+			So(filter.Match(event(storage.TodoChanged{Todo: map[string]any{"projectId": 1}})), ShouldBeTrue)
+			So(filter.Match(event(storage.TodoChanged{Todo: map[string]any{"projectId": 2}})), ShouldBeFalse)
+		})
+
+		Convey("Then it should match todos moved out of or deleted from the project", func() {
+			So(filter.Match(event(storage.TodoChanged{
+				Todo:    map[string]any{"projectId": 2},
+				Changes: []storage.FieldChange{{Field: "projectId", Before: 1, After: 2}},
+			})), ShouldBeTrue)
+			So(filter.Match(event(storage.TodoChanged{
+				Changes: []storage.FieldChange{{Field: "projectId", Before: 1}},
+			})), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a filter on a tag", t, func() {
+		filter := Filter{Tag: "home"}
+
+		Convey("Then it should match todos carrying the tag", func() {
+			var e Event
+			json.Unmarshal([]byte(`{"todo":{"tags":["work","home"]}}`), &e.changed)
+			So(filter.Match(e), ShouldBeTrue)
+			So(filter.Match(Event{}), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given the event of a tagged todo created through the storage", t, func() {
+		db, err := gorm.Open(sqlite.Open("file:stream?mode=memory&cache=shared"), &gorm.Config{})
+		So(err, ShouldBeNil)
+		sqlDB, _ := db.DB()
+		defer sqlDB.Close()
+
+		wf := &storage.Workflow{
+			Initial:     "todo",
+			Completed:   "done",
+			States:      []string{"todo", "done"},
+			Terminal:    []string{"done"},
+			Transitions: map[string][]string{"todo": {"done"}, "done": {"todo"}},
+		}
+		lc := fxtest.NewLifecycle(t)
+		todos := storage.NewTodoStorage(lc, db, wf, nil)
+		lc.RequireStart()
+		defer lc.RequireStop()
+
+		So(todos.Create(context.Background(), &storage.Todo{Title: "Call mom", Tags: []string{"@phone", "+family"}}), ShouldBeNil)
+		var msg storage.OutboxMessage
+		So(db.Last(&msg).Error, ShouldBeNil)
+		var e Event
+		So(json.Unmarshal([]byte(msg.Payload), &e.changed), ShouldBeNil)
+
+		Convey("Then a filter on one of its tags should match it", func() {
+			So(Filter{Tag: "@phone"}.Match(e), ShouldBeTrue)
+			So(Filter{Tag: "+family"}.Match(e), ShouldBeTrue)
+			So(Filter{Tag: "@work"}.Match(e), ShouldBeFalse)
+		})
+	})
+}
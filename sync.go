@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/handler"
+)
+
+func NewSyncConfig() (handler.SyncConfig, error) {
+	cfg := handler.SyncConfig{
+		ConflictPolicy: viper.GetString(config.ConfigKeySyncConflictPolicy),
+		MaxBatchSize:   viper.GetInt(config.ConfigKeySyncMaxBatchSize),
+	}
+	switch cfg.ConflictPolicy {
+	case handler.SyncConflictLastWriteWins, handler.SyncConflictReject:
+	default:
+		return cfg, fmt.Errorf("unknown sync conflict policy %q", cfg.ConflictPolicy)
+	}
+	if cfg.MaxBatchSize <= 0 {
+		return cfg, fmt.Errorf("invalid sync max batch size %d", cfg.MaxBatchSize)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfans/fxlogger"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+func NewTodoStorage(lc fx.Lifecycle, db *gorm.DB, wf *storage.Workflow, blobs storage.BlobStore) (storage.TodoStorage, error) {
+	switch backend := viper.GetString(config.ConfigKeyTodoStorage); backend {
+	case "crud":
+		return storage.NewTodoStorage(lc, db, wf, blobs), nil
+	case "eventsourced":
+		return storage.NewEventSourcedTodoStorage(lc, db, wf, blobs), nil
+	default:
+		return nil, fmt.Errorf("unknown todo storage %q", backend)
+	}
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Rebuild the todo projections from the event log",
+	Long:  `Replay drops the todos and their transitions and rebuilds them from the todo event log of the event sourced storage, in the configured database. The audit trail is kept, so sync tokens stay valid`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if backend := viper.GetString(config.ConfigKeyTodoStorage); backend != "eventsourced" {
+			return fmt.Errorf("replay needs the eventsourced todo storage, not %q", backend)
+		}
+
+		app := fx.New(
+			fx.Provide(NewGorm),
+			fx.Invoke(func(db *gorm.DB) error {
+				return storage.ReplayTodoLog(cmd.Context(), db)
+			}),
+			fx.WithLogger(fxlogger.WithZerolog(log.Logger)),
+		)
+		if err := app.Err(); err != nil {
+			return err
+		}
+
+		log.Info().Msg("todo projections rebuilt")
+		return app.Stop(context.Background())
+	},
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/ipfans/fxlogger"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/transfer"
+)
+
+// runWithTodoStorage starts the todo storage against the database, without
+// the servers, and runs fn with it.
+func runWithTodoStorage(ctx context.Context, fn func(storage.TodoStorage, storage.TxManager) error) error {
+	var (
+		s  storage.TodoStorage
+		tx storage.TxManager
+	)
+	app := fx.New(
+		fx.Provide(
+			NewGorm,
+			NewWorkflow,
+			NewBlobStore,
+			NewTodoStorage,
+			storage.NewTxManager,
+			storage.NewProjectStorage,
+		),
+		// Todos are looked up along with their projects, whose table only
+		// exists once the project storage has started.
+		fx.Invoke(func(storage.ProjectStorage) {}),
+		fx.Populate(&s, &tx),
+		fx.WithLogger(fxlogger.WithZerolog(log.Logger)),
+	)
+	if err := app.Start(ctx); err != nil {
+		return err
+	}
+	defer app.Stop(context.Background())
+
+	return fn(s, tx)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export todos",
+	Long:  `Export writes every todo of the configured database to a CSV, JSON, NDJSON, todo.txt or Markdown document, on the standard output unless an output file is given`,
+	Args:  cobra.NoArgs,
+	Annotations: map[string]string{
+		annotationStdout: "true",
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		var w io.Writer = cmd.OutOrStdout()
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		enc, err := transfer.NewEncoder(w, format)
+		if err != nil {
+			return err
+		}
+
+		return runWithTodoStorage(cmd.Context(), func(s storage.TodoStorage, _ storage.TxManager) error {
+			if err := s.Each(cmd.Context(), storage.TodoFilter{}, func(todo storage.Todo) error {
+				return enc.Encode(transfer.NewRecord(todo))
+			}); err != nil {
+				return err
+			}
+			return enc.Close()
+		})
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import todos",
+	Long:  `Import creates todos in the configured database from a CSV, JSON, NDJSON, todo.txt or Markdown document read from the given file or the standard input, reporting the rows it skipped`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		var r io.Reader = cmd.InOrStdin()
+		if len(args) > 0 {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		dec, err := transfer.NewDecoder(r, format)
+		if err != nil {
+			return err
+		}
+
+		return runWithTodoStorage(cmd.Context(), func(s storage.TodoStorage, tx storage.TxManager) error {
+			res, err := transfer.NewImporter(s, tx).Import(cmd.Context(), dec, dryRun)
+			if err != nil {
+				return err
+			}
+
+			for _, row := range res.Rows {
+				log.Warn().Int("row", row.Row).Str("status", row.Status).Err(row.Err).Msg("row skipped")
+			}
+			log.Info().
+				Bool("dryRun", res.DryRun).
+				Int("total", res.Total).
+				Int("created", res.Created).
+				Int("duplicates", res.Duplicates).
+				Int("failed", res.Failed).
+				Msg("todos imported")
+			return nil
+		})
+	},
+}
@@ -0,0 +1,189 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxNDJSONLine bounds the length of a line of an NDJSON document.
+const maxNDJSONLine = 1 << 20
+
+// Decoder reads records one at a time. Decode returns io.EOF after the last
+// record, an error wrapping ErrInvalidRow for a row that is skipped, and an
+// error wrapping ErrMalformed when the document cannot be read any further.
+type Decoder interface {
+	Decode() (Record, error)
+}
+
+func NewDecoder(r io.Reader, format string) (Decoder, error) {
+	switch format {
+	case FormatCSV:
+		return &csvDecoder{r: csv.NewReader(r)}, nil
+	case FormatJSON:
+		return &jsonDecoder{dec: json.NewDecoder(r)}, nil
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxNDJSONLine)
+		return &ndjsonDecoder{scanner: scanner}, nil
+	case FormatTodoTxt:
+		return &todoTxtDecoder{scanner: bufio.NewScanner(r)}, nil
+	case FormatMarkdown:
+		return &markdownDecoder{scanner: bufio.NewScanner(r)}, nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+}
+
+// csvDecoder reads the columns named by the header row. Unknown columns are
+// ignored.
+type csvDecoder struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func (d *csvDecoder) Decode() (Record, error) {
+	if d.columns == nil {
+		header, err := d.r.Read()
+		if err == io.EOF {
+			return Record{}, err
+		} else if err != nil {
+			return Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		d.columns = make(map[string]int, len(header))
+		for i, name := range header {
+			d.columns[strings.TrimSpace(strings.ToLower(name))] = i
+		}
+		if _, ok := d.columns["title"]; !ok {
+			return Record{}, fmt.Errorf("%w: no title column", ErrMalformed)
+		}
+	}
+
+	row, err := d.r.Read()
+	if errors.Is(err, csv.ErrFieldCount) {
+		return Record{}, fmt.Errorf("%w: %v", ErrInvalidRow, err)
+	} else if err == io.EOF {
+		return Record{}, err
+	} else if err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	field := func(name string) string {
+		if i, ok := d.columns[name]; ok {
+			return row[i]
+		}
+		return ""
+	}
+	rec := Record{
+		Title:          field("title"),
+		Description:    field("description"),
+		Status:         field("status"),
+		RRule:          field("rrule"),
+		Priority:       field("priority"),
+		ExternalSource: field("external_source"),
+		ExternalID:     field("external_id"),
+	}
+	if v := field("completed"); v != "" {
+		if rec.Completed, err = strconv.ParseBool(v); err != nil {
+			return Record{}, fmt.Errorf("%w: completed: %v", ErrInvalidRow, err)
+		}
+	}
+	if v := field("tags"); v != "" {
+		rec.Tags = strings.Fields(v)
+	}
+	if rec.CompletedAt, err = parseTime(field("completed_at")); err != nil {
+		return Record{}, fmt.Errorf("%w: completed_at: %v", ErrInvalidRow, err)
+	}
+	if rec.DueAt, err = parseTime(field("due_at")); err != nil {
+		return Record{}, fmt.Errorf("%w: due_at: %v", ErrInvalidRow, err)
+	}
+	if v := field("project_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return Record{}, fmt.Errorf("%w: project_id: %v", ErrInvalidRow, err)
+		}
+		rec.ProjectID = ptr(uint(id))
+	}
+	return rec, nil
+}
+
+// jsonDecoder reads the elements of an array one at a time.
+type jsonDecoder struct {
+	dec     *json.Decoder
+	started bool
+}
+
+func (d *jsonDecoder) Decode() (Record, error) {
+	if !d.started {
+		d.started = true
+		if tok, err := d.dec.Token(); err != nil {
+			return Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+		} else if tok != json.Delim('[') {
+			return Record{}, fmt.Errorf("%w: expected an array", ErrMalformed)
+		}
+	}
+
+	if !d.dec.More() {
+		if _, err := d.dec.Token(); err != nil {
+			return Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		return Record{}, io.EOF
+	}
+
+	// Elements are read raw first so that one of the wrong shape does not stop
+	// the following ones from being read.
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return unmarshalRecord(raw)
+}
+
+// ndjsonDecoder reads a record per line, skipping blank lines.
+type ndjsonDecoder struct {
+	scanner *bufio.Scanner
+}
+
+func (d *ndjsonDecoder) Decode() (Record, error) {
+	for d.scanner.Scan() {
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		return unmarshalRecord(line)
+	}
+	if err := d.scanner.Err(); err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return Record{}, io.EOF
+}
+
+func unmarshalRecord(data []byte) (Record, error) {
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrInvalidRow, err)
+	}
+	return rec, nil
+}
+
+func parseTime(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,147 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvHeader lists the columns of a CSV document, in the order they are
+// exported. Imported documents can order them freely and omit all but title.
+var csvHeader = []string{
+	"id", "title", "description", "status", "completed", "completed_at", "due_at", "rrule",
+	"project_id", "priority", "tags", "external_source", "external_id",
+	"created_at", "updated_at",
+}
+
+// Encoder writes records one at a time so that a document never has to be held
+// in memory. Nothing is written before the first record or Close, which ends
+// the document.
+type Encoder interface {
+	Encode(rec Record) error
+	Close() error
+}
+
+func NewEncoder(w io.Writer, format string) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	case FormatJSON:
+		return &jsonEncoder{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}, nil
+	case FormatTodoTxt:
+		return &todoTxtEncoder{w: w}, nil
+	case FormatMarkdown:
+		return &markdownEncoder{w: w}, nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (e *csvEncoder) Encode(rec Record) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.w.Write([]string{
+		formatUint(&rec.ID),
+		rec.Title,
+		rec.Description,
+		rec.Status,
+		strconv.FormatBool(rec.Completed),
+		formatTime(rec.CompletedAt),
+		formatTime(rec.DueAt),
+		rec.RRule,
+		formatUint(rec.ProjectID),
+		rec.Priority,
+		strings.Join(rec.Tags, " "),
+		rec.ExternalSource,
+		rec.ExternalID,
+		formatTime(rec.CreatedAt),
+		formatTime(rec.UpdatedAt),
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.w.Write(csvHeader)
+}
+
+// jsonEncoder writes an array of records.
+type jsonEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonEncoder) Encode(rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	sep := ","
+	if e.count == 0 {
+		sep = "["
+	}
+	e.count++
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonEncoder) Close() error {
+	end := "]\n"
+	if e.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// ndjsonEncoder writes a record per line.
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(rec Record) error {
+	return e.enc.Encode(rec)
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+func formatUint(v *uint) string {
+	if v == nil || *v == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*v), 10)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
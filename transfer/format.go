@@ -0,0 +1,163 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"time"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Formats todos can be exported to and imported from.
+const (
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatNDJSON   = "ndjson"
+	FormatTodoTxt  = "todotxt"
+	FormatMarkdown = "markdown"
+)
+
+var contentTypes = map[string]string{
+	FormatCSV:      "text/csv",
+	FormatJSON:     "application/json",
+	FormatNDJSON:   "application/x-ndjson",
+	FormatTodoTxt:  "text/x-todo-txt",
+	FormatMarkdown: "text/markdown",
+}
+
+var fileNames = map[string]string{
+	FormatTodoTxt:  "todo.txt",
+	FormatMarkdown: "todos.md",
+}
+
+var (
+	// ErrUnknownFormat is returned for a format that is not one of the
+	// Format constants.
+	ErrUnknownFormat = errors.New("unknown format")
+	// ErrMalformed is returned when a document cannot be read any further.
+	ErrMalformed = errors.New("malformed document")
+	// ErrInvalidRow is returned for a row that cannot be read as a todo while
+	// the following ones still can.
+	ErrInvalidRow = errors.New("invalid row")
+)
+
+// ContentType returns the media type of format.
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// FileName returns the name of a document of format.
+func FileName(format string) string {
+	if name, ok := fileNames[format]; ok {
+		return name
+	}
+	return "todos." + format
+}
+
+// FormatOf returns the format of a media type, or an empty string when it is
+// not one of the supported formats.
+func FormatOf(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for format, t := range contentTypes {
+		if t == mediaType {
+			return format
+		}
+	}
+	return ""
+}
+
+// Record is a todo as exported and imported. ID, Status and the creation and
+// update times are exported for reference but ignored on import, where new
+// todos are created. Completed todos keep their completion time.
+type Record struct {
+	ID             uint       `json:"id,omitempty"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	Completed      bool       `json:"completed"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+	DueAt          *time.Time `json:"dueAt,omitempty"`
+	RRule          string     `json:"rrule,omitempty"`
+	ProjectID      *uint      `json:"projectId,omitempty"`
+	Priority       string     `json:"priority,omitempty"`
+	Tags           []string   `json:"tags,omitempty"`
+	ExternalSource string     `json:"externalSource,omitempty"`
+	ExternalID     string     `json:"externalId,omitempty"`
+	CreatedAt      *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt      *time.Time `json:"updatedAt,omitempty"`
+}
+
+func NewRecord(todo storage.Todo) Record {
+	rec := Record{
+		ID:          todo.ID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Status:      todo.Status,
+		Completed:   todo.Completed != nil && *todo.Completed,
+		CompletedAt: todo.CompletedAt,
+		DueAt:       todo.DueAt,
+		RRule:       todo.RRule,
+		ProjectID:   todo.ProjectID,
+		Priority:    todo.Priority,
+		Tags:        todo.Tags,
+		CreatedAt:   &todo.CreatedAt,
+		UpdatedAt:   &todo.UpdatedAt,
+	}
+	if todo.ExternalSource != nil && todo.ExternalID != nil {
+		rec.ExternalSource = *todo.ExternalSource
+		rec.ExternalID = *todo.ExternalID
+	}
+	return rec
+}
+
+// Validate checks the fields needed to create the todo of r.
+func (r Record) Validate() error {
+	if r.Title == "" {
+		return errors.New("title is required")
+	}
+	if r.Priority != "" && !isPriority(r.Priority) {
+		return fmt.Errorf("priority %q is not a letter from A to Z", r.Priority)
+	}
+	if (r.ExternalSource == "") != (r.ExternalID == "") {
+		return errors.New("externalSource and externalId must be set together")
+	}
+	return nil
+}
+
+// Todo returns the todo to create for r.
+func (r Record) Todo() storage.Todo {
+	todo := storage.Todo{
+		Title:       r.Title,
+		Description: r.Description,
+		Completed:   &r.Completed,
+		CompletedAt: r.CompletedAt,
+		DueAt:       r.DueAt,
+		RRule:       r.RRule,
+		ProjectID:   r.ProjectID,
+		Priority:    r.Priority,
+		Tags:        r.Tags,
+	}
+	if r.ExternalSource != "" {
+		todo.ExternalSource = &r.ExternalSource
+		todo.ExternalID = &r.ExternalID
+	}
+	return todo
+}
+
+// key identifies the todo of r when looking for duplicates: its external
+// source and ID when it has some, or else its title and due date.
+func (r Record) key() string {
+	if r.ExternalSource != "" {
+		return fmt.Sprintf("external\x00%s\x00%s", r.ExternalSource, r.ExternalID)
+	}
+	due := ""
+	if r.DueAt != nil {
+		due = r.DueAt.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("title\x00%s\x00%s", r.Title, due)
+}
+
+func isPriority(s string) bool {
+	return len(s) == 1 && s[0] >= 'A' && s[0] <= 'Z'
+}
@@ -0,0 +1,136 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	Convey("Given some records", t, func() {
+		dueAt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+		projectID := uint(2)
+		records := []Record{
+			{ID: 1, Title: "Buy milk, eggs", Description: "two \"large\" ones", Status: "todo", DueAt: &dueAt, ProjectID: &projectID, Priority: "B", Tags: []string{"@store", "groceries"}},
+			{ID: 2, Title: "Synced", Status: "done", Completed: true, RRule: "FREQ=DAILY", ExternalSource: "jira", ExternalID: "PROJ-1"},
+		}
+
+		for _, format := range []string{FormatCSV, FormatJSON, FormatNDJSON} {
+			Convey("When they are encoded and decoded again as "+format, func() {
+				var buf bytes.Buffer
+				enc, err := NewEncoder(&buf, format)
+				So(err, ShouldBeNil)
+				for _, rec := range records {
+					So(enc.Encode(rec), ShouldBeNil)
+				}
+				So(enc.Close(), ShouldBeNil)
+
+				dec, err := NewDecoder(&buf, format)
+				So(err, ShouldBeNil)
+				var decoded []Record
+				for {
+					rec, err := dec.Decode()
+					if err == io.EOF {
+						break
+					}
+					So(err, ShouldBeNil)
+					decoded = append(decoded, rec)
+				}
+
+				Convey("Then the imported fields should be kept", func() {
+					So(decoded, ShouldHaveLength, 2)
+					for i := range records {
+						So(decoded[i].Todo(), ShouldResemble, records[i].Todo())
+					}
+				})
+			})
+
+			Convey("When no record is encoded as "+format, func() {
+				var buf bytes.Buffer
+				enc, err := NewEncoder(&buf, format)
+				So(err, ShouldBeNil)
+				So(enc.Close(), ShouldBeNil)
+
+				Convey("Then the document should still be decoded", func() {
+					dec, err := NewDecoder(&buf, format)
+					So(err, ShouldBeNil)
+					_, err = dec.Decode()
+					So(err, ShouldEqual, io.EOF)
+				})
+			})
+		}
+	})
+
+	Convey("Given a CSV document with an invalid row", t, func() {
+		dec, err := NewDecoder(strings.NewReader("title,due_at\nfirst,\nsecond,tomorrow\nthird,2024-01-01T09:00:00Z\n"), FormatCSV)
+		So(err, ShouldBeNil)
+
+		Convey("Then it should be skipped and the following rows still read", func() {
+			rec, err := dec.Decode()
+			So(err, ShouldBeNil)
+			So(rec.Title, ShouldEqual, "first")
+			_, err = dec.Decode()
+			So(err, ShouldWrap, ErrInvalidRow)
+			rec, err = dec.Decode()
+			So(err, ShouldBeNil)
+			So(rec.Title, ShouldEqual, "third")
+			So(rec.DueAt, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a CSV document without a title column", t, func() {
+		dec, err := NewDecoder(strings.NewReader("name\nfirst\n"), FormatCSV)
+		So(err, ShouldBeNil)
+
+		Convey("Then it should be malformed", func() {
+			_, err := dec.Decode()
+			So(err, ShouldWrap, ErrMalformed)
+		})
+	})
+
+	Convey("Given a JSON document with an element of the wrong shape", t, func() {
+		dec, err := NewDecoder(strings.NewReader(`[{"title": "first"}, {"title": 5}, {"title": "third"}]`), FormatJSON)
+		So(err, ShouldBeNil)
+
+		Convey("Then it should be skipped and the following elements still read", func() {
+			_, err := dec.Decode()
+			So(err, ShouldBeNil)
+			_, err = dec.Decode()
+			So(err, ShouldWrap, ErrInvalidRow)
+			rec, err := dec.Decode()
+			So(err, ShouldBeNil)
+			So(rec.Title, ShouldEqual, "third")
+			_, err = dec.Decode()
+			So(err, ShouldEqual, io.EOF)
+		})
+	})
+
+	Convey("Given a JSON document that is not an array", t, func() {
+		dec, err := NewDecoder(strings.NewReader(`{"title": "first"}`), FormatJSON)
+		So(err, ShouldBeNil)
+
+		Convey("Then it should be malformed", func() {
+			_, err := dec.Decode()
+			So(err, ShouldWrap, ErrMalformed)
+		})
+	})
+
+	Convey("Given an unknown format", t, func() {
+		_, err := NewDecoder(strings.NewReader(""), "xml")
+
+		Convey("Then it should be rejected", func() {
+			So(err, ShouldWrap, ErrUnknownFormat)
+		})
+	})
+
+	Convey("Given media types", t, func() {
+		So(FormatOf("text/csv; charset=utf-8"), ShouldEqual, FormatCSV)
+		So(FormatOf("application/x-ndjson"), ShouldEqual, FormatNDJSON)
+		So(FormatOf("text/markdown; charset=utf-8"), ShouldEqual, FormatMarkdown)
+		So(FormatOf("text/plain"), ShouldBeEmpty)
+	})
+}
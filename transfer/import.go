@@ -0,0 +1,130 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Statuses of the rows of an import that were not created.
+const (
+	RowInvalid   = "invalid"
+	RowDuplicate = "duplicate"
+	RowFailed    = "failed"
+)
+
+// RowResult reports a row that was not created. Rows are numbered from 1,
+// not counting the header of a CSV document.
+type RowResult struct {
+	Row    int
+	Status string
+	Err    error
+}
+
+type ImportResult struct {
+	DryRun     bool
+	Total      int
+	Created    int
+	Duplicates int
+	Failed     int
+	Rows       []RowResult
+}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+type Importer struct {
+	storage storage.TodoStorage
+	tx      storage.TxManager
+}
+
+func NewImporter(s storage.TodoStorage, tx storage.TxManager) *Importer {
+	return &Importer{storage: s, tx: tx}
+}
+
+// Import creates a todo for every record of dec and reports the rows it
+// skipped. Invalid rows and rows failing to be created are skipped, and so are
+// duplicates: rows with the same external source and ID as an existing todo
+// or an earlier row, or with the same title and due date when they have none.
+//
+// The todos are created in a single transaction, each row in a savepoint of
+// its own, and a dry run rolls it back once every row has been tried. An error
+// is only returned when the whole import failed, such as for a malformed
+// document, in which case nothing is created.
+func (i *Importer) Import(ctx context.Context, dec Decoder, dryRun bool) (ImportResult, error) {
+	res := ImportResult{DryRun: dryRun}
+
+	seen := make(map[string]bool)
+	if err := i.storage.Each(ctx, storage.TodoFilter{}, func(todo storage.Todo) error {
+		seen[NewRecord(todo).key()] = true
+		return nil
+	}); err != nil {
+		return res, err
+	}
+
+	err := i.tx.WithinTx(ctx, func(ctx context.Context) error {
+		for row := 1; ; row++ {
+			rec, err := dec.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err == nil {
+				err = rec.Validate()
+			} else if !errors.Is(err, ErrInvalidRow) {
+				return err
+			}
+			res.Total++
+
+			if err != nil {
+				res.Failed++
+				res.Rows = append(res.Rows, RowResult{Row: row, Status: RowInvalid, Err: err})
+				continue
+			}
+			key := rec.key()
+			if seen[key] {
+				res.Duplicates++
+				res.Rows = append(res.Rows, RowResult{Row: row, Status: RowDuplicate})
+				continue
+			}
+			seen[key] = true
+
+			if err := i.tx.WithinTx(ctx, func(ctx context.Context) error {
+				return i.create(ctx, rec)
+			}); err != nil {
+				res.Failed++
+				res.Rows = append(res.Rows, RowResult{Row: row, Status: RowFailed, Err: err})
+				continue
+			}
+			res.Created++
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	if err != nil {
+		return ImportResult{DryRun: dryRun}, err
+	}
+	return res, nil
+}
+
+// create creates the todo of rec. Todos are created open, so completing one
+// takes a replacement, which keeps its completion time.
+func (i *Importer) create(ctx context.Context, rec Record) error {
+	todo := rec.Todo()
+	if err := i.storage.Create(ctx, &todo); err != nil {
+		return err
+	}
+	if !rec.Completed {
+		return nil
+	}
+	todo.Completed = &rec.Completed
+	todo.CompletedAt = rec.CompletedAt
+	return i.storage.Replace(ctx, int(todo.ID), todo)
+}
@@ -0,0 +1,117 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestImporter_Import(t *testing.T) {
+	Convey("Given an importer with mock storage holding a todo", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockTodoStorage(ctrl)
+		mockTx := mock.NewMockTxManager(ctrl)
+		i := NewImporter(mockStorage, mockTx)
+
+		mockStorage.EXPECT().
+			Each(gomock.Any(), gomock.Eq(storage.TodoFilter{}), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ storage.TodoFilter, fn func(storage.Todo) error) error {
+				return fn(storage.Todo{Model: gorm.Model{ID: 1}, Title: "existing"})
+			}).
+			Times(1)
+		// The outermost transaction is the last to end.
+		var txErr error
+		mockTx.EXPECT().
+			WithinTx(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error {
+				txErr = fn(ctx)
+				return txErr
+			}).
+			AnyTimes()
+
+		doc := `[
+			{"title": "new"},
+			{"title": "existing"},
+			{"title": "done", "completed": true},
+			{"title": "new"},
+			{"description": "no title"},
+			{"title": 5},
+			{"title": "in a missing project", "projectId": 9}
+		]`
+
+		Convey("When importing a document", func() {
+			gomock.InOrder(
+				mockStorage.EXPECT().
+					Create(gomock.Any(), gomock.Cond(func(todo *storage.Todo) bool { return todo.Title == "new" })).
+					Return(nil),
+				mockStorage.EXPECT().
+					Create(gomock.Any(), gomock.Cond(func(todo *storage.Todo) bool { return todo.Title == "done" })).
+					DoAndReturn(func(_ context.Context, todo *storage.Todo) error {
+						todo.ID = 3
+						return nil
+					}),
+				mockStorage.EXPECT().
+					Replace(gomock.Any(), gomock.Eq(3), gomock.Cond(func(todo storage.Todo) bool { return *todo.Completed })).
+					Return(nil),
+				mockStorage.EXPECT().
+					Create(gomock.Any(), gomock.Cond(func(todo *storage.Todo) bool { return *todo.ProjectID == 9 })).
+					Return(gorm.ErrRecordNotFound),
+			)
+
+			dec, _ := NewDecoder(strings.NewReader(doc), FormatJSON)
+			res, err := i.Import(context.Background(), dec, false)
+
+			Convey("Then it should create the valid rows and report the others", func() {
+				So(err, ShouldBeNil)
+				So(res.Total, ShouldEqual, 7)
+				So(res.Created, ShouldEqual, 2)
+				So(res.Duplicates, ShouldEqual, 2)
+				So(res.Failed, ShouldEqual, 3)
+				So(res.Rows, ShouldHaveLength, 5)
+				So(res.Rows[0].Row, ShouldEqual, 2)
+				So(res.Rows[0].Status, ShouldEqual, RowDuplicate)
+				So(res.Rows[1].Row, ShouldEqual, 4)
+				So(res.Rows[1].Status, ShouldEqual, RowDuplicate)
+				So(res.Rows[2].Status, ShouldEqual, RowInvalid)
+				So(res.Rows[3].Status, ShouldEqual, RowInvalid)
+				So(res.Rows[4].Row, ShouldEqual, 7)
+				So(res.Rows[4].Status, ShouldEqual, RowFailed)
+			})
+		})
+
+		Convey("When running a dry run", func() {
+			mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+			dec, _ := NewDecoder(strings.NewReader(`[{"title": "new"}]`), FormatJSON)
+			res, err := i.Import(context.Background(), dec, true)
+
+			Convey("Then it should report the result but roll it back", func() {
+				So(err, ShouldBeNil)
+				So(res.DryRun, ShouldBeTrue)
+				So(res.Created, ShouldEqual, 1)
+				So(txErr, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When the document is malformed", func() {
+			mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+			dec, _ := NewDecoder(strings.NewReader(`[{"title": "new"}, {"title"`), FormatJSON)
+			_, err := i.Import(context.Background(), dec, false)
+
+			Convey("Then the whole import should fail", func() {
+				So(errors.Is(err, ErrMalformed), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -0,0 +1,136 @@
+package transfer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// taskListItem matches the items of a GitHub-flavoured Markdown task list,
+// capturing their indentation, their check mark and their text.
+var taskListItem = regexp.MustCompile(`^(\s*)(?:[-*+]|\d{1,9}[.)])\s+\[([ xX])\](?:\s+(.*))?$`)
+
+// markdownEncoder writes a task list item per record, checked for completed
+// todos, such as "- [ ] (A) Title @context +project due:2024-01-05". The text
+// of the items follows the conventions of todo.txt for the priority, tags, due
+// date and recurrence rule, and the description is indented below them.
+type markdownEncoder struct {
+	w io.Writer
+}
+
+func (e *markdownEncoder) Encode(rec Record) error {
+	var b strings.Builder
+	if rec.Completed {
+		b.WriteString("- [x] ")
+	} else {
+		b.WriteString("- [ ] ")
+	}
+	if rec.Priority != "" {
+		b.WriteString("(" + rec.Priority + ") ")
+	}
+	b.WriteString(formatTaskText(rec, false))
+	b.WriteString("\n")
+	if rec.Description != "" {
+		for _, line := range strings.Split(rec.Description, "\n") {
+			if line = strings.TrimRight(line, " \t\r"); line != "" {
+				b.WriteString("  " + line)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+func (e *markdownEncoder) Close() error {
+	return nil
+}
+
+// markdownDecoder reads the task list items of a Markdown document, nested
+// ones included, ignoring everything else. The lines indented below an item
+// make its description, up to the next item or unindented line.
+type markdownDecoder struct {
+	scanner *bufio.Scanner
+	// next is the item line read ahead while looking for the end of the
+	// description of the previous one.
+	next string
+}
+
+func (d *markdownDecoder) Decode() (Record, error) {
+	var (
+		item        []string
+		indent      string
+		description []string
+		blanks      int
+		ended       bool
+	)
+	for {
+		line, ok := d.line()
+		if !ok {
+			break
+		}
+		if m := taskListItem.FindStringSubmatch(line); m != nil {
+			if item != nil {
+				d.next = line
+				break
+			}
+			item = m
+			// Descriptions are indented like the text of the item.
+			indent = strings.Repeat(" ", strings.Index(line, "["))
+			continue
+		}
+		if item == nil || ended {
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			if len(description) > 0 {
+				blanks++
+			}
+		case line[0] == ' ' || line[0] == '\t':
+			for ; blanks > 0; blanks-- {
+				description = append(description, "")
+			}
+			if rest, ok := strings.CutPrefix(line, indent); ok {
+				line = rest
+			} else {
+				line = strings.TrimLeft(line, " \t")
+			}
+			description = append(description, strings.TrimRight(line, " \t\r"))
+		default:
+			ended = true
+		}
+	}
+	if err := d.scanner.Err(); err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if item == nil {
+		return Record{}, io.EOF
+	}
+
+	rec := Record{
+		Completed:   item[2] != " ",
+		Description: strings.Join(description, "\n"),
+	}
+	text := strings.TrimSpace(item[3])
+	if priority, rest, ok := cutPriority(text); ok {
+		rec.Priority, text = priority, rest
+	}
+	return parseTaskText(rec, text)
+}
+
+func (d *markdownDecoder) line() (string, bool) {
+	if d.next != "" {
+		line := d.next
+		d.next = ""
+		return line, true
+	}
+	if !d.scanner.Scan() {
+		return "", false
+	}
+	return d.scanner.Text(), true
+}
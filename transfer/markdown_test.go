@@ -0,0 +1,84 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMarkdown(t *testing.T) {
+	Convey("Given some records", t, func() {
+		dueAt := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+		records := []Record{
+			{Title: "Write the report", Description: "Sections:\n\n    1. Intro", Priority: "B", Tags: []string{"@work"}, DueAt: &dueAt},
+			{Title: "Book flights", Completed: true},
+		}
+
+		Convey("When they are encoded as Markdown", func() {
+			var buf bytes.Buffer
+			enc, err := NewEncoder(&buf, FormatMarkdown)
+			So(err, ShouldBeNil)
+			for _, rec := range records {
+				So(enc.Encode(rec), ShouldBeNil)
+			}
+			So(enc.Close(), ShouldBeNil)
+
+			Convey("Then there should be a task list item per record", func() {
+				So(buf.String(), ShouldEqual,
+					"- [ ] (B) Write the report @work due:2024-01-05\n"+
+						"  Sections:\n"+
+						"\n"+
+						"      1. Intro\n"+
+						"- [x] Book flights\n")
+			})
+
+			Convey("Then they should be decoded again", func() {
+				dec, err := NewDecoder(&buf, FormatMarkdown)
+				So(err, ShouldBeNil)
+				var decoded []Record
+				for {
+					rec, err := dec.Decode()
+					if err == io.EOF {
+						break
+					}
+					So(err, ShouldBeNil)
+					decoded = append(decoded, rec)
+				}
+				So(decoded, ShouldHaveLength, 2)
+				for i := range records {
+					So(decoded[i].Todo(), ShouldResemble, records[i].Todo())
+				}
+			})
+		})
+	})
+
+	Convey("Given a Markdown document with other content", t, func() {
+		doc := "# Trip\n\nSome notes.\n\n* [X] Pack\n  - [ ] Socks +clothes\n1. [ ] Leave\n   by noon\n\nAfterwards.\n    Not a description\n- Plain item\n"
+		dec, err := NewDecoder(strings.NewReader(doc), FormatMarkdown)
+		So(err, ShouldBeNil)
+
+		Convey("Then only the task list items should be read, nested ones included", func() {
+			rec, err := dec.Decode()
+			So(err, ShouldBeNil)
+			So(rec.Title, ShouldEqual, "Pack")
+			So(rec.Completed, ShouldBeTrue)
+
+			rec, err = dec.Decode()
+			So(err, ShouldBeNil)
+			So(rec.Title, ShouldEqual, "Socks")
+			So(rec.Tags, ShouldResemble, []string{"+clothes"})
+
+			rec, err = dec.Decode()
+			So(err, ShouldBeNil)
+			So(rec.Title, ShouldEqual, "Leave")
+			So(rec.Description, ShouldEqual, "by noon")
+
+			_, err = dec.Decode()
+			So(err, ShouldEqual, io.EOF)
+		})
+	})
+}
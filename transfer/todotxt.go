@@ -0,0 +1,207 @@
+package transfer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// dateLayout is the layout of the dates of todo.txt, which have no time.
+const dateLayout = "2006-01-02"
+
+// Keys of the key:value pairs of todo.txt that fill the fields of a record.
+// Other pairs are left in the title.
+const (
+	keyDue      = "due"
+	keyRRule    = "rrule"
+	keyPriority = "pri"
+)
+
+// todoTxtEncoder writes a record per line in the todo.txt format:
+//
+//	x 2024-01-02 2024-01-01 Title @context +project due:2024-01-05 pri:A
+//	(A) 2024-01-01 Title @context +project due:2024-01-05 rrule:FREQ=WEEKLY
+//
+// Completed todos start with an x and their completion date, and keep their
+// priority as a pri key since a priority cannot follow the x. The creation
+// date comes next. Tags missing from the title are appended as projects
+// unless they are contexts already. Due dates are written as dates, and the
+// description is left out.
+type todoTxtEncoder struct {
+	w io.Writer
+}
+
+func (e *todoTxtEncoder) Encode(rec Record) error {
+	var parts []string
+	if rec.Completed {
+		parts = append(parts, "x")
+		if rec.CompletedAt != nil && !rec.CompletedAt.IsZero() {
+			parts = append(parts, formatDate(*rec.CompletedAt))
+			// A creation date is only told apart from a completion date
+			// when both are given.
+			if rec.CreatedAt != nil && !rec.CreatedAt.IsZero() {
+				parts = append(parts, formatDate(*rec.CreatedAt))
+			}
+		}
+	} else {
+		if rec.Priority != "" {
+			parts = append(parts, "("+rec.Priority+")")
+		}
+		if rec.CreatedAt != nil && !rec.CreatedAt.IsZero() {
+			parts = append(parts, formatDate(*rec.CreatedAt))
+		}
+	}
+	parts = append(parts, formatTaskText(rec, rec.Completed))
+
+	_, err := io.WriteString(e.w, strings.Join(parts, " ")+"\n")
+	return err
+}
+
+func (e *todoTxtEncoder) Close() error {
+	return nil
+}
+
+// todoTxtDecoder reads a record per line of a todo.txt document, skipping
+// blank lines. Contexts and projects become tags, and those within the text
+// also stay in the title.
+type todoTxtDecoder struct {
+	scanner *bufio.Scanner
+}
+
+func (d *todoTxtDecoder) Decode() (Record, error) {
+	for d.scanner.Scan() {
+		line := strings.TrimSpace(d.scanner.Text())
+		if line == "" {
+			continue
+		}
+		return parseTodoTxt(line)
+	}
+	if err := d.scanner.Err(); err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return Record{}, io.EOF
+}
+
+func parseTodoTxt(line string) (Record, error) {
+	var rec Record
+	if rest, ok := strings.CutPrefix(line, "x "); ok {
+		rec.Completed = true
+		line = rest
+		if completed, rest, ok := cutDate(line); ok {
+			rec.CompletedAt, line = &completed, rest
+			if created, rest, ok := cutDate(line); ok {
+				rec.CreatedAt, line = &created, rest
+			}
+		}
+	} else {
+		if priority, rest, ok := cutPriority(line); ok {
+			rec.Priority, line = priority, rest
+		}
+		if created, rest, ok := cutDate(line); ok {
+			rec.CreatedAt, line = &created, rest
+		}
+	}
+	return parseTaskText(rec, line)
+}
+
+// formatTaskText returns the title of rec followed by the tags it is missing
+// and the key:value pairs of its due date and recurrence rule, and of its
+// priority when withPriority is set.
+func formatTaskText(rec Record, withPriority bool) string {
+	words := strings.Fields(rec.Title)
+	text := strings.Join(words, " ")
+	for _, tag := range rec.Tags {
+		if tag = tagWord(tag); tag != "" && !slices.Contains(words, tag) {
+			text += " " + tag
+		}
+	}
+	if rec.DueAt != nil {
+		text += " " + keyDue + ":" + formatDate(*rec.DueAt)
+	}
+	if rec.RRule != "" {
+		text += " " + keyRRule + ":" + strings.Join(strings.Fields(rec.RRule), "")
+	}
+	if withPriority && rec.Priority != "" {
+		text += " " + keyPriority + ":" + rec.Priority
+	}
+	return text
+}
+
+// parseTaskText completes rec with the title, tags and key:value pairs of the
+// text of a task. The tags ending the text are left out of the title, where
+// formatTaskText puts them back.
+func parseTaskText(rec Record, text string) (Record, error) {
+	var title []string
+	for _, word := range strings.Fields(text) {
+		key, value, _ := strings.Cut(word, ":")
+		switch {
+		case key == keyDue && value != "":
+			dueAt, err := parseDate(value)
+			if err != nil {
+				return Record{}, fmt.Errorf("%w: %s: %v", ErrInvalidRow, keyDue, err)
+			}
+			rec.DueAt = &dueAt
+		case key == keyRRule && value != "":
+			rec.RRule = value
+		case key == keyPriority && value != "":
+			rec.Priority = value
+		default:
+			if isTag(word) && !slices.Contains(rec.Tags, word) {
+				rec.Tags = append(rec.Tags, word)
+			}
+			title = append(title, word)
+		}
+	}
+	for len(title) > 0 && isTag(title[len(title)-1]) {
+		title = title[:len(title)-1]
+	}
+	rec.Title = strings.Join(title, " ")
+	return rec, nil
+}
+
+// isTag reports whether word is a todo.txt context or project.
+func isTag(word string) bool {
+	return len(word) > 1 && (word[0] == '@' || word[0] == '+')
+}
+
+// tagWord returns tag as a single word starting like a todo.txt context or
+// project, making a project of the tags that are neither.
+func tagWord(tag string) string {
+	tag = strings.Join(strings.Fields(tag), "_")
+	if tag == "" || isTag(tag) {
+		return tag
+	}
+	return "+" + tag
+}
+
+func cutPriority(s string) (string, string, bool) {
+	if len(s) < 4 || s[0] != '(' || s[2] != ')' || s[3] != ' ' || !isPriority(s[1:2]) {
+		return "", s, false
+	}
+	return s[1:2], s[4:], true
+}
+
+func cutDate(s string) (time.Time, string, bool) {
+	word, rest, _ := strings.Cut(s, " ")
+	t, err := time.Parse(dateLayout, word)
+	if err != nil {
+		return time.Time{}, s, false
+	}
+	return t, rest, true
+}
+
+func formatDate(t time.Time) string {
+	return t.UTC().Format(dateLayout)
+}
+
+// parseDate parses a date of todo.txt, also accepting the RFC 3339 timestamps
+// of the other formats.
+func parseDate(v string) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
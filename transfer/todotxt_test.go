@@ -0,0 +1,89 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTodoTxt(t *testing.T) {
+	Convey("Given some records", t, func() {
+		createdAt := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+		updatedAt := time.Date(2024, 1, 3, 17, 0, 0, 0, time.UTC)
+		completedAt := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+		dueAt := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+		records := []Record{
+			{Title: "Call @mom about the trip", Description: "Not exported", Priority: "A", Tags: []string{"@mom", "+family", "long weekend"}, DueAt: &dueAt, RRule: "FREQ=WEEKLY", CreatedAt: &createdAt, UpdatedAt: &updatedAt},
+			{Title: "Pay rent", Completed: true, CompletedAt: &completedAt, Priority: "C", CreatedAt: &createdAt, UpdatedAt: &updatedAt},
+		}
+
+		Convey("When they are encoded as todo.txt", func() {
+			var buf bytes.Buffer
+			enc, err := NewEncoder(&buf, FormatTodoTxt)
+			So(err, ShouldBeNil)
+			for _, rec := range records {
+				So(enc.Encode(rec), ShouldBeNil)
+			}
+			So(enc.Close(), ShouldBeNil)
+
+			Convey("Then there should be a line per record", func() {
+				So(buf.String(), ShouldEqual,
+					"(A) 2024-01-01 Call @mom about the trip +family +long_weekend due:2024-01-05 rrule:FREQ=WEEKLY\n"+
+						"x 2024-01-02 2024-01-01 Pay rent pri:C\n")
+			})
+
+			Convey("Then they should be decoded again", func() {
+				dec, err := NewDecoder(&buf, FormatTodoTxt)
+				So(err, ShouldBeNil)
+
+				rec, err := dec.Decode()
+				So(err, ShouldBeNil)
+				So(rec.Title, ShouldEqual, "Call @mom about the trip")
+				So(rec.Priority, ShouldEqual, "A")
+				So(rec.Tags, ShouldResemble, []string{"@mom", "+family", "+long_weekend"})
+				So(*rec.DueAt, ShouldEqual, dueAt)
+				So(rec.RRule, ShouldEqual, "FREQ=WEEKLY")
+				So(rec.Completed, ShouldBeFalse)
+
+				rec, err = dec.Decode()
+				So(err, ShouldBeNil)
+				So(rec.Title, ShouldEqual, "Pay rent")
+				So(rec.Priority, ShouldEqual, "C")
+				So(rec.Completed, ShouldBeTrue)
+				So(rec.CompletedAt.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)), ShouldBeTrue)
+				So(rec.CreatedAt.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)), ShouldBeTrue)
+
+				_, err = dec.Decode()
+				So(err, ShouldEqual, io.EOF)
+			})
+		})
+	})
+
+	Convey("Given a todo.txt document", t, func() {
+		dec, err := NewDecoder(strings.NewReader("\nx Done without dates\n(B) Call http://example.com due:someday\n2024-02-01 Plain task key:value\n"), FormatTodoTxt)
+		So(err, ShouldBeNil)
+
+		Convey("Then blank lines should be skipped and invalid rows reported", func() {
+			rec, err := dec.Decode()
+			So(err, ShouldBeNil)
+			So(rec.Title, ShouldEqual, "Done without dates")
+			So(rec.Completed, ShouldBeTrue)
+
+			_, err = dec.Decode()
+			So(err, ShouldWrap, ErrInvalidRow)
+
+			rec, err = dec.Decode()
+			So(err, ShouldBeNil)
+			So(rec.Title, ShouldEqual, "Plain task key:value")
+			So(rec.CreatedAt, ShouldNotBeNil)
+			So(rec.Priority, ShouldBeEmpty)
+
+			_, err = dec.Decode()
+			So(err, ShouldEqual, io.EOF)
+		})
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/transfer"
+)
+
+func TestTransferCmd(t *testing.T) {
+	Convey("Given a file database", t, func() {
+		dir := t.TempDir()
+		dsn := "file:" + filepath.Join(dir, "todo.db") + "?_busy_timeout=5000"
+		t.Setenv("DATABASE_DSN", dsn)
+		t.Setenv("BLOB_LOCAL_DIR", filepath.Join(dir, "blobs"))
+
+		input := filepath.Join(dir, "todo.txt")
+		So(os.WriteFile(input, []byte("(A) Call mom @phone\nWater the plants +garden due:2024-01-05\n"), 0o600), ShouldBeNil)
+
+		Convey("When importing a document and exporting it in separate runs", func() {
+			rootCmd.SetArgs([]string{"import", "--format", "todotxt", input})
+			So(rootCmd.Execute(), ShouldBeNil)
+
+			db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+			So(err, ShouldBeNil)
+			var count int64
+			So(db.Model(&storage.Todo{}).Count(&count).Error, ShouldBeNil)
+			So(count, ShouldEqual, 2)
+			sqlDB, _ := db.DB()
+			sqlDB.Close()
+
+			output := filepath.Join(dir, "export.md")
+			rootCmd.SetArgs([]string{"export", "--format", "markdown", "--output", output})
+			So(rootCmd.Execute(), ShouldBeNil)
+
+			Convey("Then the export should have the imported todos", func() {
+				b, err := os.ReadFile(output)
+				So(err, ShouldBeNil)
+				So(string(b), ShouldEqual, "- [ ] (A) Call mom @phone\n- [ ] Water the plants +garden due:2024-01-05\n")
+			})
+		})
+	})
+}
+
+func TestTransferCmd_CompletionDate(t *testing.T) {
+	for _, backend := range []string{"crud", "eventsourced"} {
+		Convey("Given a file database with the "+backend+" todo storage", t, func() {
+			dir := t.TempDir()
+			t.Setenv("DATABASE_DSN", "file:"+filepath.Join(dir, "todo.db")+"?_busy_timeout=5000")
+			t.Setenv("BLOB_LOCAL_DIR", filepath.Join(dir, "blobs"))
+			t.Setenv("TODO_STORAGE", backend)
+
+			input := filepath.Join(dir, "todo.txt")
+			So(os.WriteFile(input, []byte("x 2024-01-02 2024-01-01 Pay rent\n"), 0o600), ShouldBeNil)
+
+			Convey("When importing a completed todo and exporting it", func() {
+				rootCmd.SetArgs([]string{"import", "--format", "todotxt", input})
+				So(rootCmd.Execute(), ShouldBeNil)
+
+				output := filepath.Join(dir, "export.json")
+				rootCmd.SetArgs([]string{"export", "--format", "json", "--output", output})
+				So(rootCmd.Execute(), ShouldBeNil)
+
+				Convey("Then it should keep its completion date", func() {
+					b, err := os.ReadFile(output)
+					So(err, ShouldBeNil)
+					var records []transfer.Record
+					So(json.Unmarshal(b, &records), ShouldBeNil)
+					So(records, ShouldHaveLength, 1)
+					So(records[0].Completed, ShouldBeTrue)
+					So(records[0].CompletedAt.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)), ShouldBeTrue)
+				})
+			})
+		})
+	}
+}
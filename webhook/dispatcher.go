@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/wei840222/go-restful-sample/outbox"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// Dispatcher is an outbox.Sink queueing a delivery of every todo event for each
+// active webhook subscribed to it. Deliveries are keyed by the outbox message,
+// so a message relayed twice is only queued once.
+type Dispatcher struct {
+	storage storage.WebhookStorage
+	now     func() time.Time
+}
+
+func NewDispatcher(s storage.WebhookStorage) *Dispatcher {
+	return &Dispatcher{
+		storage: s,
+		now:     time.Now,
+	}
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, msg outbox.Message) error {
+	var changed storage.TodoChanged
+	if err := json.Unmarshal(msg.Payload, &changed); err != nil {
+		return err
+	}
+	events := Events(changed)
+	if len(events) == 0 {
+		return nil
+	}
+
+	webhooks, err := d.storage.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	var deliveries []storage.WebhookDelivery
+	for _, webhook := range webhooks {
+		event, ok := match(webhook, events)
+		if !ok {
+			continue
+		}
+		payload, err := json.Marshal(storage.WebhookPayload{
+			Event:      event,
+			OccurredAt: changed.OccurredAt,
+			Data:       json.RawMessage(msg.Payload),
+		})
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, storage.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			MessageID:     &msg.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        storage.WebhookDeliveryPending,
+			NextAttemptAt: d.now(),
+		})
+	}
+	return d.storage.CreateDeliveries(ctx, deliveries)
+}
+
+// Events returns the webhook events a todo change stands for, most specific
+// first. Completing a todo is both a todo.completed and a todo.updated event.
+func Events(changed storage.TodoChanged) []string {
+	switch changed.Type {
+	case storage.WebhookEventTodoCreated, storage.WebhookEventTodoDeleted, storage.WebhookEventTodoPurged:
+		return []string{changed.Type}
+	case storage.WebhookEventTodoUpdated:
+		for _, change := range changed.Changes {
+			if change.Field == "completed" && change.After == true {
+				return []string{storage.WebhookEventTodoCompleted, changed.Type}
+			}
+		}
+		return []string{changed.Type}
+	default:
+		return nil
+	}
+}
+
+// match returns the most specific of events the webhook subscribes to, so that
+// every change is delivered at most once per webhook.
+func match(webhook storage.Webhook, events []string) (string, bool) {
+	for _, event := range events {
+		if webhook.Subscribes(event) {
+			return event, true
+		}
+	}
+	return "", false
+}
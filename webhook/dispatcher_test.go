@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"github.com/wei840222/go-restful-sample/outbox"
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestEvents(t *testing.T) {
+	Convey("Given todo changes", t, func() {
+		Convey("Then completing a todo should be a completed and an updated event", func() {
+			events := Events(storage.TodoChanged{
+				Type:    storage.TodoTopic(storage.TodoEventUpdated),
+				Changes: []storage.FieldChange{{Field: "completed", Before: false, After: true}},
+			})
+			So(events, ShouldResemble, []string{storage.WebhookEventTodoCompleted, storage.WebhookEventTodoUpdated})
+		})
+
+		Convey("Then reopening a todo should only be an updated event", func() {
+			events := Events(storage.TodoChanged{
+				Type:    storage.TodoTopic(storage.TodoEventUpdated),
+				Changes: []storage.FieldChange{{Field: "completed", Before: true, After: false}},
+			})
+			So(events, ShouldResemble, []string{storage.WebhookEventTodoUpdated})
+		})
+
+		Convey("Then unknown changes should not be events", func() {
+			So(Events(storage.TodoChanged{Type: "project.created"}), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestDispatcher_Publish(t *testing.T) {
+	Convey("Given a dispatcher with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mock.NewMockWebhookStorage(ctrl)
+		d := NewDispatcher(mockStorage)
+		now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+		d.now = func() time.Time { return now }
+
+		mockStorage.EXPECT().ListActive(gomock.Any()).Return([]storage.Webhook{
+			{Model: gorm.Model{ID: 1}, Events: []string{storage.WebhookEventTodoUpdated, storage.WebhookEventTodoCompleted}},
+			{Model: gorm.Model{ID: 2}, Events: []string{storage.WebhookEventAll}},
+			{Model: gorm.Model{ID: 3}, Events: []string{storage.WebhookEventTodoCreated}},
+		}, nil)
+
+		Convey("When a todo is completed", func() {
+			payload, _ := json.Marshal(storage.TodoChanged{
+				Type:    storage.TodoTopic(storage.TodoEventUpdated),
+				TodoID:  4,
+				Changes: []storage.FieldChange{{Field: "completed", Before: false, After: true}},
+			})
+
+			var deliveries []storage.WebhookDelivery
+			mockStorage.EXPECT().
+				CreateDeliveries(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, d []storage.WebhookDelivery) error {
+					deliveries = d
+					return nil
+				})
+
+			err := d.Publish(context.Background(), outbox.Message{ID: 9, Topic: "todo.updated", Payload: payload})
+
+			Convey("Then it should queue one delivery per subscribed webhook", func() {
+				So(err, ShouldBeNil)
+				So(deliveries, ShouldHaveLength, 2)
+				So(deliveries[0].WebhookID, ShouldEqual, 1)
+				So(deliveries[0].Event, ShouldEqual, storage.WebhookEventTodoCompleted)
+				So(*deliveries[0].MessageID, ShouldEqual, 9)
+				So(deliveries[1].WebhookID, ShouldEqual, 2)
+				So(deliveries[1].NextAttemptAt, ShouldEqual, now)
+
+				var body storage.WebhookPayload
+				So(json.Unmarshal([]byte(deliveries[0].Payload), &body), ShouldBeNil)
+				So(body.Event, ShouldEqual, storage.WebhookEventTodoCompleted)
+			})
+		})
+	})
+}
@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.uber.org/fx"
+
+	"github.com/wei840222/go-restful-sample/outbox"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+// maxResponseBody is how much of a response body is kept in the delivery log.
+const maxResponseBody = 1024
+
+// Config controls how the worker polls for deliveries and retries them. Polling
+// and backoff work as for the outbox relay.
+type Config struct {
+	outbox.Config `mapstructure:",squash"`
+	// DisableAfter is the number of consecutive failed attempts after which a
+	// webhook is disabled.
+	DisableAfter int           `mapstructure:"disable_after"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+}
+
+// Worker sends the queued deliveries to their webhooks.
+type Worker struct {
+	storage storage.WebhookStorage
+	client  *http.Client
+	config  Config
+	now     func() time.Time
+}
+
+func NewWorker(s storage.WebhookStorage, cfg Config) *Worker {
+	return &Worker{
+		storage: s,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		config:  cfg,
+		now:     time.Now,
+	}
+}
+
+// RunWorker runs the worker in the background for the lifetime of the app. On
+// stop it finishes the delivery in progress and returns.
+func RunWorker(lc fx.Lifecycle, w *Worker) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				w.Run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+}
+
+// Run sends deliveries until ctx is cancelled, polling again right away after a
+// full batch.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := w.SendOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("failed to send webhook deliveries")
+		}
+		if n == w.config.BatchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendOnce sends the deliveries currently due and returns how many it
+// attempted.
+func (w *Worker) SendOnce(ctx context.Context) (int, error) {
+	deliveries, err := w.storage.ListDueDeliveries(ctx, w.now(), w.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	disabled := make(map[uint]bool)
+	for i, delivery := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		// Deliveries of a webhook disabled during this batch wait for it to be
+		// reactivated, except for test events.
+		if disabled[delivery.WebhookID] && delivery.Event != storage.WebhookEventTest {
+			continue
+		}
+		failures, err := w.send(ctx, delivery)
+		if err != nil {
+			return i + 1, err
+		}
+		if w.config.DisableAfter > 0 && failures >= w.config.DisableAfter && !disabled[delivery.WebhookID] {
+			log.Warn().Uint("webhook", delivery.WebhookID).Int("failures", failures).Msg("webhook disabled after repeated failures")
+			if err := w.storage.Disable(ctx, delivery.WebhookID); err != nil {
+				return i + 1, err
+			}
+			disabled[delivery.WebhookID] = true
+		}
+	}
+	return len(deliveries), nil
+}
+
+// send attempts a delivery and records the outcome, returning the number of
+// consecutive failures of the webhook.
+func (w *Worker) send(ctx context.Context, delivery storage.WebhookDelivery) (int, error) {
+	start := w.now()
+	code, body, err := w.post(ctx, delivery, start)
+	if err != nil && ctx.Err() != nil {
+		// Interrupted by shutdown rather than failed: try again on next start
+		// without counting the attempt.
+		return 0, ctx.Err()
+	}
+
+	attempt := storage.WebhookDeliveryAttempt{
+		CreatedAt:    start,
+		ResponseCode: code,
+		ResponseBody: body,
+		Duration:     w.now().Sub(start),
+	}
+	var retryAt *time.Time
+	if err != nil {
+		attempt.Error = err.Error()
+		attempts := delivery.Attempts + 1
+		if attempts < w.config.MaxAttempts {
+			t := w.now().Add(w.config.Backoff(attempts))
+			retryAt = &t
+		}
+		log.Warn().Err(err).Uint("delivery", delivery.ID).Uint("webhook", delivery.WebhookID).
+			Int("attempts", attempts).Interface("retryAt", retryAt).Msg("failed to send webhook delivery")
+	}
+	return w.storage.RecordAttempt(ctx, delivery.ID, attempt, retryAt)
+}
+
+func (w *Worker) post(ctx context.Context, delivery storage.WebhookDelivery, now time.Time) (int, string, error) {
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Webhook.Secret, now, payload))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+
+	b, _ := io.ReadAll(io.LimitReader(res.Body, maxResponseBody))
+	body := strings.TrimSpace(string(b))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, body, fmt.Errorf("webhook responded %s", res.Status)
+	}
+	return res.StatusCode, body, nil
+}
@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/mock/gomock"
+
+	"github.com/wei840222/go-restful-sample/outbox"
+	"github.com/wei840222/go-restful-sample/storage"
+	"github.com/wei840222/go-restful-sample/storage/mock"
+)
+
+func TestSign(t *testing.T) {
+	Convey("Given a signed body", t, func() {
+		now := time.Unix(1700000000, 0)
+		body := []byte(`{"event":"todo.created"}`)
+		signature := Sign("secret", now, body)
+
+		Convey("Then it should verify with the same secret and timestamp", func() {
+			So(signature, ShouldStartWith, "sha256=")
+			So(Verify("secret", signature, "1700000000", body, now, time.Minute), ShouldBeNil)
+		})
+
+		Convey("Then it should not verify with another secret or body", func() {
+			So(Verify("other", signature, "1700000000", body, now, time.Minute), ShouldEqual, ErrInvalidSignature)
+			So(Verify("secret", signature, "1700000000", []byte("{}"), now, time.Minute), ShouldEqual, ErrInvalidSignature)
+		})
+
+		Convey("Then it should not verify once the timestamp is too old", func() {
+			So(Verify("secret", signature, "1700000000", body, now.Add(time.Hour), time.Minute), ShouldEqual, ErrExpiredTimestamp)
+		})
+	})
+}
+
+func TestWorker_SendOnce(t *testing.T) {
+	Convey("Given a worker with mock storage", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		var received *http.Request
+		var receivedBody []byte
+		status := http.StatusNoContent
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			receivedBody, _ = io.ReadAll(r.Body)
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		mockStorage := mock.NewMockWebhookStorage(ctrl)
+		w := NewWorker(mockStorage, Config{
+			Config: outbox.Config{
+				BatchSize:      10,
+				MaxAttempts:    3,
+				InitialBackoff: time.Second,
+				MaxBackoff:     time.Minute,
+			},
+			DisableAfter: 2,
+			Timeout:      time.Second,
+		})
+		now := time.Unix(1700000000, 0)
+		w.now = func() time.Time { return now }
+
+		delivery := storage.WebhookDelivery{
+			ID:        5,
+			WebhookID: 1,
+			Event:     storage.WebhookEventTodoCreated,
+			Payload:   `{"event":"todo.created"}`,
+			Webhook:   storage.Webhook{URL: server.URL, Secret: "secret"},
+		}
+		mockStorage.EXPECT().
+			ListDueDeliveries(gomock.Any(), now, 10).
+			DoAndReturn(func(context.Context, time.Time, int) ([]storage.WebhookDelivery, error) {
+				return []storage.WebhookDelivery{delivery}, nil
+			})
+
+		Convey("When the webhook accepts the delivery", func() {
+			mockStorage.EXPECT().
+				RecordAttempt(gomock.Any(), uint(5), gomock.Any(), gomock.Nil()).
+				DoAndReturn(func(_ context.Context, _ uint, attempt storage.WebhookDeliveryAttempt, _ *time.Time) (int, error) {
+					So(attempt.ResponseCode, ShouldEqual, http.StatusNoContent)
+					So(attempt.Error, ShouldBeEmpty)
+					return 0, nil
+				})
+
+			n, err := w.SendOnce(context.Background())
+
+			Convey("Then it should post a signed delivery", func() {
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 1)
+				So(received.Header.Get(HeaderDelivery), ShouldEqual, "5")
+				So(received.Header.Get(HeaderEvent), ShouldEqual, storage.WebhookEventTodoCreated)
+				So(Verify("secret", received.Header.Get(HeaderSignature), received.Header.Get(HeaderTimestamp), receivedBody, now, time.Minute), ShouldBeNil)
+			})
+		})
+
+		Convey("When the webhook fails", func() {
+			status = http.StatusServiceUnavailable
+			retryAt := now.Add(time.Second)
+			mockStorage.EXPECT().
+				RecordAttempt(gomock.Any(), uint(5), gomock.Any(), gomock.Eq(&retryAt)).
+				DoAndReturn(func(_ context.Context, _ uint, attempt storage.WebhookDeliveryAttempt, _ *time.Time) (int, error) {
+					So(attempt.ResponseCode, ShouldEqual, http.StatusServiceUnavailable)
+					So(attempt.Error, ShouldNotBeEmpty)
+					return 1, nil
+				})
+
+			_, err := w.SendOnce(context.Background())
+
+			Convey("Then it should schedule a retry with backoff", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When the webhook fails too many times in a row", func() {
+			status = http.StatusInternalServerError
+			mockStorage.EXPECT().RecordAttempt(gomock.Any(), uint(5), gomock.Any(), gomock.Any()).Return(2, nil)
+			mockStorage.EXPECT().Disable(gomock.Any(), uint(1)).Return(nil).Times(1)
+
+			_, err := w.SendOnce(context.Background())
+
+			Convey("Then it should disable the webhook", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When the last attempt fails", func() {
+			status = http.StatusInternalServerError
+			delivery.Attempts = 2
+			mockStorage.EXPECT().RecordAttempt(gomock.Any(), uint(5), gomock.Any(), gomock.Nil()).Return(1, nil)
+
+			_, err := w.SendOnce(context.Background())
+
+			Convey("Then the delivery should fail for good", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/wei840222/go-restful-sample/config"
+	"github.com/wei840222/go-restful-sample/storage"
+)
+
+func NewWorkflow() (*storage.Workflow, error) {
+	var wf storage.Workflow
+	if err := viper.UnmarshalKey(config.ConfigKeyWorkflow, &wf); err != nil {
+		return nil, err
+	}
+	if err := wf.Validate(); err != nil {
+		return nil, err
+	}
+	return &wf, nil
+}